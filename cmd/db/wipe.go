@@ -92,11 +92,21 @@ func main() {
 			label:             "DeviceGroup",
 			interfaceInstance: &models.DeviceGroup{}})
 
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "MaintenanceWindow",
+			interfaceInstance: &models.MaintenanceWindow{}})
+
 	modelsInterfaces = append(modelsInterfaces,
 		ModelInterface{
 			label:             "DispatchRecord",
 			interfaceInstance: &models.DispatchRecord{}})
 
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "DispatchRecordAttempt",
+			interfaceInstance: &models.DispatchRecordAttempt{}})
+
 	modelsInterfaces = append(modelsInterfaces,
 		ModelInterface{
 			label:             "FDODevice",
@@ -152,6 +162,66 @@ func main() {
 			label:             "UpdateTransaction",
 			interfaceInstance: &models.UpdateTransaction{}})
 
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "UpdateWave",
+			interfaceInstance: &models.UpdateWave{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "UpdateRepoCache",
+			interfaceInstance: &models.UpdateRepoCache{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "DeviceGroupUpdate",
+			interfaceInstance: &models.DeviceGroupUpdate{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "DeviceGroupUpdateRejectedDevice",
+			interfaceInstance: &models.DeviceGroupUpdateRejectedDevice{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "UpdateHook",
+			interfaceInstance: &models.UpdateHook{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "Event",
+			interfaceInstance: &models.Event{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "UpdateBundle",
+			interfaceInstance: &models.UpdateBundle{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "Job",
+			interfaceInstance: &models.Job{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "ConcurrencySlot",
+			interfaceInstance: &models.ConcurrencySlot{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "RetentionPolicy",
+			interfaceInstance: &models.RetentionPolicy{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "RetentionReport",
+			interfaceInstance: &models.RetentionReport{}})
+
+	modelsInterfaces = append(modelsInterfaces,
+		ModelInterface{
+			label:             "RetentionReportImage",
+			interfaceInstance: &models.RetentionReportImage{}})
+
 	for modelsIndex, modelsInterface := range modelsInterfaces {
 		log.Debugf("Removing Model %d: %s", modelsIndex, modelsInterface.label)
 
@@ -1,21 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/redhatinsights/edge-api/config"
 	l "github.com/redhatinsights/edge-api/logger"
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
 	log "github.com/sirupsen/logrus"
-
-	clowder "github.com/redhatinsights/app-common-go/pkg/api/v1"
 )
 
 // NOTE: this is currently designed for a single ibvents replica
+// the image and update builds themselves run on the job workers of the edge-api replicas
 
 // get images with a build of x status and older than y hours
 func getStaleBuilds(status string, age int) []models.Image {
@@ -23,7 +22,10 @@ func getStaleBuilds(status string, age int) []models.Image {
 
 	// looks like we ran into a known pgx issue when using ? for parameters in certain prepared SQL statements
 	// 		using Sprintf to predefine the query and pass to Where
-	query := fmt.Sprintf("status = '%s' AND updated_at < NOW() - INTERVAL '%d hours'", status, age)
+	// the images whose build job is still pending or running are left to the job workers
+	query := fmt.Sprintf("status = '%s' AND updated_at < NOW() - INTERVAL '%d hours' AND id NOT IN "+
+		"(SELECT resource_id FROM jobs WHERE type = '%s' AND status IN ('%s', '%s'))",
+		status, age, models.JobTypeImageBuild, models.JobStatusPending, models.JobStatusRunning)
 	qresult := db.DB.Debug().Where(query).Find(&images)
 	if qresult.Error != nil {
 		log.WithField("error", qresult.Error.Error()).Error("Stale builds query failed")
@@ -56,13 +58,6 @@ func main() {
 	// set things up
 	log.Info("Starting up...")
 
-	var images []models.Image
-	// IBevent represents the struct of the value in a Kafka message
-	// TODO: add the original requestid
-	type IBevent struct {
-		ImageID uint `json:"image_id"`
-	}
-
 	config.Init()
 	l.InitLogger()
 	cfg := config.Get()
@@ -89,11 +84,33 @@ func main() {
 	}).Info("Configuration Values:")
 	db.InitDB()
 
+	updateService := services.NewUpdateService(context.Background(), log.WithField("service", "ibvents"))
+	imageSetService := services.NewImageSetsService(context.Background(), log.WithField("service", "ibvents"))
+
 	log.Info("Entering the infinite loop...")
 	for {
 		log.Debug("Sleeping...")
 		time.Sleep(5 * time.Minute)
-		// TODO: work out programatic method to avoid resuming a build until app is up or on way up
+
+		// dispatch the devices held back by the update schedules whose maintenance window is now open,
+		// the maintenance windows last at least models.MinMaintenanceWindowDuration to be seen open by the loop
+		if err := updateService.DispatchScheduledUpdates(); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to dispatch scheduled updates")
+		}
+		// fail the devices that didn't boot their update commit before the reboot deadline
+		if err := updateService.ExpireRebootingDispatchRecords(); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to expire rebooting dispatch records")
+		}
+		// check the playbook runs of the devices playbook dispatcher didn't report about
+		if err := updateService.ReconcileDispatchRecords(); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to reconcile dispatch records")
+		}
+		// enqueue the retention policies due, the job workers of edge-api remove the old image versions they don't keep
+		if _, err := imageSetService.EnqueueDueRetentionPolicies(); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to enqueue retention policies")
+		}
+		// the interrupted image builds are resumed by the job workers of edge-api,
+		// the stale builds left are the ones without a job, from before the job workers
 
 		// handle stale interrupted builds not complete after x hours
 		// FIXME: change 48 hours to something closer to stale builds (6?)
@@ -125,100 +142,5 @@ func main() {
 				log.Error("Failed to update stale building image build status")
 			}
 		}
-
-		// handle image builds in INTERRUPTED status
-		//	this is meant to handle builds that are interrupted when they are interrupted
-		// 	the stale interrupted build routine should never actually find anything while this is running
-		qresult := db.DB.Debug().Where(&models.Image{Status: models.ImageStatusInterrupted}).Find(&images)
-		log.WithField("numImages", qresult.RowsAffected).Info("Found image(s) with interrupted status")
-
-		for _, image := range images {
-			log.WithField("imageID", image.ID).Info("Processing interrupted image")
-
-			/* we have a choice here...
-			1. Send an event and a consumer on Edge API calls the resume.
-			2. Send an API call to Edge API to call the resume.
-
-			Currently...
-			1. Testing a Kafka event.
-			2. Will implement a call to the API /retry
-			3. Will create an API endpoint specifically for resume()
-				so it can pick up where it left off
-			*/
-
-			/* temp disable until auth can be resolved
-			// send an API request
-			url := fmt.Sprintf("%s/api/edge/v1/images/%d/retry", cfg.EdgeAPIBaseURL, image.ID)
-			req, _ := http.NewRequest("POST", url, nil)
-			req.Header.Add("Content-Type", "application/json")
-
-			client := &http.Client{}
-			res, err := client.Do(req)
-			if err != nil {
-				var code int
-				if res != nil {
-					code = res.StatusCode
-				}
-				log.WithFields(log.Fields{
-					"statusCode": code,
-					"error":      err,
-				}).Error("Edge API retry request error")
-			}
-			respBody, err := ioutil.ReadAll(res.Body)
-			log.WithFields(log.Fields{
-				"statusCode":   res.StatusCode,
-				"responseBody": string(respBody),
-				"error":        err,
-			}).Debug("Edge API retry response")
-			if err != nil {
-				log.Error("Error reading body of uninterrupted build resume response")
-			}
-			res.Body.Close()
-			*/
-
-			// send an event on image-build topic
-			if clowder.IsClowderEnabled() {
-				// get the list of brokers from the config
-				brokers := make([]string, len(clowder.LoadedConfig.Kafka.Brokers))
-				for i, b := range clowder.LoadedConfig.Kafka.Brokers {
-					brokers[i] = fmt.Sprintf("%s:%d", b.Hostname, *b.Port)
-					fmt.Println(brokers[i])
-				}
-
-				topic := "platform.edge.fleetmgmt.image-build"
-
-				// Create Producer instance
-				// TODO: do this once before loop
-				p, err := kafka.NewProducer(&kafka.ConfigMap{
-					"bootstrap.servers": brokers[0]})
-				if err != nil {
-					log.WithField("error", err).Error("Failed to create producer")
-				}
-				// assemble the message to be sent
-				// TODO: formalize message formats
-				recordKey := "resume_image"
-				ibvent := IBevent{}
-				ibvent.ImageID = image.ID
-				ibventMessage, _ := json.Marshal(ibvent)
-				log.WithField("message", ibvent).Debug("Preparing record for producer")
-				// send the message
-				perr := p.Produce(&kafka.Message{
-					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-					Key:            []byte(recordKey),
-					Value:          ibventMessage,
-				}, nil)
-				if perr != nil {
-					log.Error("Error sending message")
-				}
-
-				// Wait for all messages to be delivered
-				p.Flush(15 * 1000)
-
-				// TODO: do this once at break from loop
-				p.Close()
-
-				log.WithField("topic", topic).Debug("IBvents interrupted build message was produced to topic")
-			}
-		}
 	}
 }
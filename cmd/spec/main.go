@@ -59,7 +59,21 @@ func main() {
 	gen.addSchema("v1.DeviceGroup", &models.DeviceGroup{})
 	gen.addSchema("v1.DeviceGroupListDetail", &models.DeviceGroupListDetail{})
 	gen.addSchema("v1.DeviceGroupDetails", &models.DeviceGroupDetails{})
+	gen.addSchema("v1.MaintenanceWindow", &models.MaintenanceWindow{})
+	gen.addSchema("v1.UpdateHooks", &models.UpdateHooks{})
+	gen.addSchema("v1.DeviceGroupUpdateRequest", &routes.DeviceGroupUpdateRequest{})
+	gen.addSchema("v1.DeviceGroupUpdate", &models.DeviceGroupUpdate{})
 	gen.addSchema("v1.ValidateUpdateResponse", &routes.ValidateUpdateResponse{})
+	gen.addSchema("v1.UpdatePreviewRequest", &routes.UpdatePreviewRequest{})
+	gen.addSchema("v1.UpdatePreview", &models.UpdatePreview{})
+	gen.addSchema("v1.Event", &models.Event{})
+	gen.addSchema("v1.ImageComparison", &models.ImageComparison{})
+	gen.addSchema("v1.UpdateBundleRequest", &routes.UpdateBundleRequest{})
+	gen.addSchema("v1.UpdateBundle", &models.UpdateBundle{})
+	gen.addSchema("v1.UpdateBundleDownload", &models.UpdateBundleDownload{})
+	gen.addSchema("v1.Job", &models.Job{})
+	gen.addSchema("v1.RetentionPolicy", &models.RetentionPolicy{})
+	gen.addSchema("v1.RetentionReport", &models.RetentionReport{})
 
 	type Swagger struct {
 		Components openapi3.Components `json:"components,omitempty" yaml:"components,omitempty"`
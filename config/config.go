@@ -29,6 +29,24 @@ type EdgeConfig struct {
 	TemplatesPath            string                    `json:"templates_path,omitempty"`
 	EdgeAPIBaseURL           string                    `json:"edge_api_base_url,omitempty"`
 	UploadWorkers            int                       `json:"upload_workers,omitempty"`
+	UpdateRebootTimeout      int                       `json:"update_reboot_timeout,omitempty"`
+	DispatchRecordTimeout    int                       `json:"dispatch_record_timeout,omitempty"`
+	GpgKeysPath              string                    `json:"gpg_keys_path,omitempty"`
+	PlaybookSigningKeyPath   string                    `json:"playbook_signing_key_path,omitempty"`
+	UpdateBundleURLTimeout   int                       `json:"update_bundle_url_timeout,omitempty"`
+	JobWorkers               int                       `json:"job_workers,omitempty"`
+	JobLeaseTimeout          int                       `json:"job_lease_timeout,omitempty"`
+	JobMaxAttempts           int                       `json:"job_max_attempts,omitempty"`
+	JobRetryBackoff          int                       `json:"job_retry_backoff,omitempty"`
+	ComposeCheckInterval     int                       `json:"compose_check_interval,omitempty"`
+	ComposeCheckMaxInterval  int                       `json:"compose_check_max_interval,omitempty"`
+	ComposeCheckBatchSize    int                       `json:"compose_check_batch_size,omitempty"`
+	UpdateRepoDeltaVersions  int                       `json:"update_repo_delta_versions,omitempty"`
+	UpdateBuildsPerAccount   int                       `json:"update_builds_per_account,omitempty"`
+	UpdateBuildsGlobal       int                       `json:"update_builds_global,omitempty"`
+	RolloutsPerAccount       int                       `json:"rollouts_per_account,omitempty"`
+	RolloutsGlobal           int                       `json:"rollouts_global,omitempty"`
+	RetentionPolicyInterval  int                       `json:"retention_policy_interval,omitempty"`
 	KafkaConfig              *clowder.KafkaConfig      `json:"kafka,omitempty"`
 	FDO                      *fdoConfig                `json:"fdo,omitempty"`
 	Local                    bool                      `json:"local,omitempty"`
@@ -50,7 +68,8 @@ type fdoConfig struct {
 }
 
 type imageBuilderConfig struct {
-	URL string `json:"url,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Backend string `json:"backend,omitempty"`
 }
 
 type inventoryConfig struct {
@@ -58,12 +77,12 @@ type inventoryConfig struct {
 }
 
 type playbookDispatcherConfig struct {
-	URL    string `json:"url,omitempty"`
-	PSK    string `json:"-"`
-	Status string `json:"status,omitempty"`
+	URL       string `json:"url,omitempty"`
+	PSK       string `json:"-"`
+	Status    string `json:"status,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
 }
 
-//
 type loggingConfig struct {
 	AccessKeyID     string `json:"-"`
 	SecretAccessKey string `json:"-"`
@@ -83,10 +102,12 @@ func Init() {
 	options.SetDefault("Debug", false)
 	options.SetDefault("EdgeTarballsBucket", "rh-edge-tarballs")
 	options.SetDefault("ImageBuilderUrl", "http://image-builder:8080")
+	options.SetDefault("ImageBuilderBackend", "hosted")
 	options.SetDefault("InventoryUrl", "http://host-inventory-service:8080/")
 	options.SetDefault("PlaybookDispatcherURL", "http://playbook-dispatcher:8080/")
 	options.SetDefault("PlaybookDispatcherStatusURL", "http://playbook-dispatcher:8080/")
 	options.SetDefault("PlaybookDispatcherPSK", "xxxxx")
+	options.SetDefault("PlaybookDispatcherBatchSize", 50)
 	options.SetDefault("RepoTempPath", "/tmp/repos/")
 	options.SetDefault("OpenAPIFilePath", "./cmd/spec/openapi.json")
 	options.SetDefault("Database", "sqlite")
@@ -95,6 +116,24 @@ func Init() {
 	options.SetDefault("TemplatesPath", "/usr/local/etc/")
 	options.SetDefault("EdgeAPIBaseURL", "http://localhost:3000")
 	options.SetDefault("UploadWorkers", 100)
+	options.SetDefault("UpdateRebootTimeout", 30)
+	options.SetDefault("DispatchRecordTimeout", 180)
+	options.SetDefault("GpgKeysPath", "")
+	options.SetDefault("PlaybookSigningKeyPath", "")
+	options.SetDefault("UpdateBundleURLTimeout", 60)
+	options.SetDefault("JobWorkers", 4)
+	options.SetDefault("JobLeaseTimeout", 120)
+	options.SetDefault("JobMaxAttempts", 3)
+	options.SetDefault("JobRetryBackoff", 60)
+	options.SetDefault("ComposeCheckInterval", 30)
+	options.SetDefault("ComposeCheckMaxInterval", 300)
+	options.SetDefault("ComposeCheckBatchSize", 50)
+	options.SetDefault("UpdateRepoDeltaVersions", 3)
+	options.SetDefault("UpdateBuildsPerAccount", 2)
+	options.SetDefault("UpdateBuildsGlobal", 10)
+	options.SetDefault("RolloutsPerAccount", 10)
+	options.SetDefault("RolloutsGlobal", 100)
+	options.SetDefault("RetentionPolicyInterval", 86400)
 	options.SetDefault("FDOHostURL", "https://fdo.redhat.com")
 	options.SetDefault("FDOApiVersion", "v1")
 	options.SetDefault("FDOAuthorizationBearer", "lorum-ipsum")
@@ -121,6 +160,8 @@ func Init() {
 		DefaultOSTreeRef: options.GetString("DefaultOSTreeRef"),
 		ImageBuilderConfig: &imageBuilderConfig{
 			URL: options.GetString("ImageBuilderUrl"),
+			// hosted builds the images with the Image Builder API, fake builds deterministic fake images locally
+			Backend: options.GetString("ImageBuilderBackend"),
 		},
 		InventoryConfig: &inventoryConfig{
 			URL: options.GetString("InventoryUrl"),
@@ -129,10 +170,47 @@ func Init() {
 			URL:    options.GetString("PlaybookDispatcherURL"),
 			PSK:    options.GetString("PlaybookDispatcherPSK"),
 			Status: options.GetString("PlaybookDispatcherStatusURL"),
+			// devices a dispatch request runs the playbook on, playbook dispatcher accepts up to 50
+			BatchSize: options.GetInt("PlaybookDispatcherBatchSize"),
 		},
 		TemplatesPath:  options.GetString("TemplatesPath"),
 		EdgeAPIBaseURL: options.GetString("EdgeAPIBaseURL"),
 		UploadWorkers:  options.GetInt("UploadWorkers"),
+		// minutes a device has to boot the update commit after the update playbook succeeded
+		UpdateRebootTimeout: options.GetInt("UpdateRebootTimeout"),
+		// minutes a dispatched device waits for a playbook dispatcher run event before its run is checked
+		DispatchRecordTimeout: options.GetInt("DispatchRecordTimeout"),
+		// directory of the per account GPG keys signing the ostree commits, commits are not signed when empty
+		GpgKeysPath: options.GetString("GpgKeysPath"),
+		// GPG home directory of the key signing the update playbooks, the playbooks are not signed when empty
+		PlaybookSigningKeyPath: options.GetString("PlaybookSigningKeyPath"),
+		// minutes the pre-signed download URLs of the offline update bundles are valid
+		UpdateBundleURLTimeout: options.GetInt("UpdateBundleURLTimeout"),
+		// number of jobs, like image builds, a replica runs at the same time
+		JobWorkers: options.GetInt("JobWorkers"),
+		// seconds a worker holds the lease of a job without a heartbeat before another worker can claim it
+		JobLeaseTimeout: options.GetInt("JobLeaseTimeout"),
+		// attempts of a job before it fails
+		JobMaxAttempts: options.GetInt("JobMaxAttempts"),
+		// seconds before the first retry of a failed job, doubled for every following retry
+		JobRetryBackoff: options.GetInt("JobRetryBackoff"),
+		// seconds before checking a compose on image builder again, doubled for every check that finds it
+		// in the same state up to the max interval
+		ComposeCheckInterval:    options.GetInt("ComposeCheckInterval"),
+		ComposeCheckMaxInterval: options.GetInt("ComposeCheckMaxInterval"),
+		// composes checked at once by the compose scheduler
+		ComposeCheckBatchSize: options.GetInt("ComposeCheckBatchSize"),
+		// previous versions of an image set the update repo prebuilt for a new version has static deltas from,
+		// the update repos are not prebuilt when 0
+		UpdateRepoDeltaVersions: options.GetInt("UpdateRepoDeltaVersions"),
+		// update repos an account, and all the accounts, build at the same time, the other updates are queued, 0 is no limit
+		UpdateBuildsPerAccount: options.GetInt("UpdateBuildsPerAccount"),
+		UpdateBuildsGlobal:     options.GetInt("UpdateBuildsGlobal"),
+		// updates an account, and all the accounts, roll out at the same time, the other updates are queued, 0 is no limit
+		RolloutsPerAccount: options.GetInt("RolloutsPerAccount"),
+		RolloutsGlobal:     options.GetInt("RolloutsGlobal"),
+		// seconds between two applications of a retention policy
+		RetentionPolicyInterval: options.GetInt("RetentionPolicyInterval"),
 		FDO: &fdoConfig{
 			URL:                 options.GetString("FDOHostURL"),
 			APIVersion:          options.GetString("FDOApiVersion"),
@@ -95,6 +95,7 @@ func webRoutes(cfg *config.EdgeConfig) *chi.Mux {
 		s.Route("/thirdpartyrepo", routes.MakeThirdPartyRepoRouter)
 		s.Route("/fdo", routes.MakeFDORouter)
 		s.Route("/device-groups", routes.MakeDeviceGroupsRouter)
+		s.Route("/jobs", routes.MakeJobsRouter)
 	})
 	return route
 }
@@ -134,7 +135,7 @@ func gracefulTermination(server *http.Server, serviceName string) {
 
 func main() {
 	// this only catches interrupts for main
-	// see images for image build interrupt
+	// the image and update builds left running are resumed by the job workers of the other replicas
 	interruptSignal := make(chan os.Signal, 1)
 	signal.Notify(interruptSignal, os.Interrupt, syscall.SIGTERM)
 
@@ -153,6 +154,12 @@ func main() {
 	webServer := serveWeb(cfg, consumers)
 	metricsServer := serveMetrics(cfg.MetricsPort)
 
+	log.Info("Starting job worker")
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go services.NewJobWorker(log.NewEntry(log.StandardLogger())).Start(jobsCtx)
+	// the composes of the image builds are tracked by a single scheduler, which enqueues the next step of a build once its compose is done
+	go services.NewComposeScheduler(log.NewEntry(log.StandardLogger())).Start(jobsCtx)
+
 	if cfg.KafkaConfig != nil {
 		log.Info("Starting Kafka Consumers")
 		for _, consumer := range consumers {
@@ -165,6 +172,8 @@ func main() {
 	// block here and shut things down on interrupt
 	<-interruptSignal
 	log.Info("Shutting down gracefully...")
+	// stop claiming jobs, the leases of the jobs still running expire if they don't end before the shutdown
+	stopJobs()
 	// temporarily adding a sleep to help troubleshoot interrupts
 	time.Sleep(20 * time.Second)
 	gracefulTermination(webServer, "web")
@@ -26,7 +26,23 @@ type ClientInterface interface {
 	GetMetadata(image *models.Image) (*models.Image, error)
 }
 
-// Client is the implementation of an ClientInterface
+const (
+	// BackendHosted builds the images with the hosted Image Builder API
+	BackendHosted = "hosted"
+	// BackendFake builds deterministic fake images locally, without Image Builder
+	BackendFake = "fake"
+)
+
+// NewClient returns the client of the image builder backend set in the configuration
+func NewClient(ctx context.Context, log *log.Entry) ClientInterface {
+	cfg := config.Get()
+	if cfg.ImageBuilderConfig.Backend == BackendFake {
+		return InitFakeClient(ctx, log)
+	}
+	return InitClient(ctx, log)
+}
+
+// Client is the implementation of an ClientInterface with the hosted Image Builder API
 type Client struct {
 	ctx context.Context
 	log *log.Entry
@@ -304,10 +320,7 @@ func (c *Client) getComposeStatus(jobID string) (*ComposeStatus, error) {
 	client := &http.Client{}
 	res, err := client.Do(req)
 	if err != nil {
-		c.log.WithFields(log.Fields{
-			"statusCode": res.StatusCode,
-			"error":      err,
-		}).Error("Image Builder ComposeStatus Request Error")
+		c.log.WithField("error", err).Error("Image Builder ComposeStatus Request Error")
 		return nil, err
 	}
 	body, err := ioutil.ReadAll(res.Body)
@@ -339,6 +352,7 @@ func (c *Client) GetCommitStatus(image *models.Image) (*models.Image, error) {
 		return nil, err
 	}
 	c.log.WithField("status", cs.ImageStatus.Status).Info("Got commit response status")
+	image.Commit.ComposeStatus = string(cs.ImageStatus.Status)
 	if cs.ImageStatus.Status == imageStatusSuccess {
 		c.log.Info("Set image commit status with success")
 		image.Commit.Status = models.ImageStatusSuccess
@@ -358,6 +372,7 @@ func (c *Client) GetInstallerStatus(image *models.Image) (*models.Image, error)
 		return nil, err
 	}
 	c.log.WithField("status", cs.ImageStatus.Status).Info("Got installer response status")
+	image.Installer.ComposeStatus = string(cs.ImageStatus.Status)
 	if cs.ImageStatus.Status == imageStatusSuccess {
 		c.log.Info("Set image installer status with success")
 		image.Installer.Status = models.ImageStatusSuccess
@@ -45,6 +45,7 @@ var _ = Describe("Image Builder Client Test", func() {
 			&models.Repo{},
 			&models.Device{},
 			&models.DispatchRecord{},
+			&models.Event{},
 		)
 		if err != nil {
 			panic(err)
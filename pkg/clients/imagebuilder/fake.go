@@ -0,0 +1,189 @@
+package imagebuilder
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cavaliercoder/grab"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+)
+
+// FakeClient is a local ClientInterface that builds the images without Image Builder
+// It composes deterministic artifacts, a commit tarball with an empty ostree repo and a tiny ISO, in the
+// artifacts path and gives their file:// URLs, which lets the image and update flows run locally and in CI
+type FakeClient struct {
+	ctx           context.Context
+	log           *log.Entry
+	artifactsPath string
+}
+
+var registerFileProtocolOnce sync.Once
+
+// registerFileProtocol lets the HTTP clients downloading the commit tarballs and the ISOs get the file:// URLs
+func registerFileProtocol() {
+	registerFileProtocolOnce.Do(func() {
+		fileTransport := http.NewFileTransport(http.Dir("/"))
+		if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+			transport.RegisterProtocol("file", fileTransport)
+		}
+		if transport, ok := grab.DefaultClient.HTTPClient.Transport.(*http.Transport); ok {
+			transport.RegisterProtocol("file", fileTransport)
+		}
+	})
+}
+
+// InitFakeClient initializes the fake client, its artifacts are kept in the repo temp path
+func InitFakeClient(ctx context.Context, log *log.Entry) *FakeClient {
+	registerFileProtocol()
+	cfg := config.Get()
+	return &FakeClient{
+		ctx:           ctx,
+		log:           log.WithField("imageBuilderBackend", BackendFake),
+		artifactsPath: filepath.Clean(filepath.Join(cfg.RepoTempPath, "image-builder")),
+	}
+}
+
+// fakeComposeJobID gives the same compose job id to the same artifact, commit or installer, of an image
+func fakeComposeJobID(artifact string, image *models.Image) string {
+	return fmt.Sprintf("fake-%s-%d", artifact, image.ID)
+}
+
+// ComposeCommit starts the fake compose of a commit, it is done at the first status check
+func (c *FakeClient) ComposeCommit(image *models.Image) (*models.Image, error) {
+	image.Commit.ComposeJobID = fakeComposeJobID("commit", image)
+	image.Commit.Status = models.ImageStatusBuilding
+	image.Status = models.ImageStatusBuilding
+	c.log.WithField("composeJobID", image.Commit.ComposeJobID).Info("Fake commit compose started")
+	return image, nil
+}
+
+// ComposeInstaller starts the fake compose of an installer, it is done at the first status check
+func (c *FakeClient) ComposeInstaller(image *models.Image) (*models.Image, error) {
+	image.Installer.ComposeJobID = fakeComposeJobID("installer", image)
+	image.Installer.Status = models.ImageStatusBuilding
+	image.Status = models.ImageStatusBuilding
+	if tx := db.DB.Save(&image); tx.Error != nil {
+		c.log.WithField("error", tx.Error.Error()).Error("Error saving image")
+		return nil, tx.Error
+	}
+	if tx := db.DB.Save(&image.Installer); tx.Error != nil {
+		c.log.WithField("error", tx.Error.Error()).Error("Error saving installer")
+		return nil, tx.Error
+	}
+	c.log.WithField("composeJobID", image.Installer.ComposeJobID).Info("Fake installer compose started")
+	return image, nil
+}
+
+// GetCommitStatus writes the commit tarball of the compose and sets the commit status with success
+func (c *FakeClient) GetCommitStatus(image *models.Image) (*models.Image, error) {
+	path, err := c.writeCommitTarball(image.Commit.ComposeJobID)
+	if err != nil {
+		c.log.WithField("error", err.Error()).Error("Error writing fake commit tarball")
+		image.Commit.ComposeStatus = models.ComposeStatusFailure
+		image.Commit.Status = models.ImageStatusError
+		image.Status = models.ImageStatusError
+		return image, nil
+	}
+	image.Commit.ComposeStatus = models.ComposeStatusSuccess
+	image.Commit.Status = models.ImageStatusSuccess
+	image.Commit.ImageBuildTarURL = "file://" + path
+	return image, nil
+}
+
+// GetInstallerStatus writes the ISO of the compose and sets the installer status with success
+func (c *FakeClient) GetInstallerStatus(image *models.Image) (*models.Image, error) {
+	path, err := c.writeArtifact(image.Installer.ComposeJobID, "installer.iso",
+		[]byte(fmt.Sprintf("fake installer of the commit %s\n", image.Commit.OSTreeCommit)))
+	if err != nil {
+		c.log.WithField("error", err.Error()).Error("Error writing fake ISO")
+		image.Installer.ComposeStatus = models.ComposeStatusFailure
+		image.Installer.Status = models.ImageStatusError
+		image.Status = models.ImageStatusError
+		return image, nil
+	}
+	image.Installer.ComposeStatus = models.ComposeStatusSuccess
+	image.Installer.Status = models.ImageStatusSuccess
+	image.Installer.ImageBuildISOURL = "file://" + path
+	return image, nil
+}
+
+// GetMetadata gives the image its packages, with a fake version, and an ostree commit hash of its compose
+func (c *FakeClient) GetMetadata(image *models.Image) (*models.Image, error) {
+	for _, name := range *image.GetALLPackagesList() {
+		sum := sha256.Sum256([]byte(name))
+		image.Commit.InstalledPackages = append(image.Commit.InstalledPackages, models.InstalledPackage{
+			Arch: image.Commit.Arch, Name: name, Version: "1.0", Release: "1.el8", Type: "rpm",
+			Sigmd5: fmt.Sprintf("%x", sum[:16]),
+		})
+	}
+	image.Commit.OSTreeCommit = fmt.Sprintf("%x", sha256.Sum256([]byte(image.Commit.ComposeJobID)))
+	return image, nil
+}
+
+// writeArtifact writes an artifact of a compose once and returns its path
+func (c *FakeClient) writeArtifact(composeJobID string, name string, content []byte) (string, error) {
+	dir := filepath.Join(c.artifactsPath, composeJobID)
+	if err := os.MkdirAll(dir, os.FileMode(int(0755))); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := ioutil.WriteFile(path, content, os.FileMode(int(0644))); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeCommitTarball writes the commit tarball of a compose: an empty ostree repo in archive mode
+func (c *FakeClient) writeCommitTarball(composeJobID string) (string, error) {
+	dir := filepath.Join(c.artifactsPath, composeJobID)
+	if err := os.MkdirAll(dir, os.FileMode(int(0755))); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "commit.tar")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	file, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	tw := tar.NewWriter(file)
+	for _, name := range []string{"repo/", "repo/extensions/", "repo/objects/", "repo/refs/", "repo/refs/heads/",
+		"repo/refs/mirrors/", "repo/refs/remotes/", "repo/state/", "repo/tmp/"} {
+		if err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}); err != nil {
+			break
+		}
+	}
+	repoConfig := []byte("[core]\nrepo_version=1\nmode=archive-z2\n")
+	if err == nil {
+		err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "repo/config", Mode: 0644, Size: int64(len(repoConfig))})
+	}
+	if err == nil {
+		_, err = tw.Write(repoConfig)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
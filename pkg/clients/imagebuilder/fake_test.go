@@ -0,0 +1,114 @@
+package imagebuilder
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+)
+
+var _ = Describe("Image Builder Fake Client Test", func() {
+	var client *FakeClient
+	var dbName, tempPath string
+	var image *models.Image
+	BeforeEach(func() {
+		config.Init()
+		dbName = fmt.Sprintf("%d-fake.db", time.Now().UnixNano())
+		config.Get().Database.Name = dbName
+		db.InitDB()
+		Expect(db.DB.AutoMigrate(&models.ImageSet{}, &models.Commit{}, &models.Installer{}, &models.Package{}, &models.Image{}, &models.Repo{},
+			&models.Event{})).To(Succeed())
+		var err error
+		tempPath, err = ioutil.TempDir("", "fake-image-builder")
+		Expect(err).ToNot(HaveOccurred())
+		config.Get().RepoTempPath = tempPath
+		client = InitFakeClient(context.Background(), log.NewEntry(log.StandardLogger()))
+		image = &models.Image{
+			Name:         "image",
+			Distribution: "rhel-85",
+			Commit:       &models.Commit{Arch: "x86_64"},
+			Packages:     []models.Package{{Name: "vim"}},
+			Installer:    &models.Installer{},
+			OutputTypes:  []string{models.ImageTypeCommit, models.ImageTypeInstaller},
+		}
+		Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.Remove(dbName)
+		os.RemoveAll(tempPath)
+	})
+	It("should be the client of the fake backend", func() {
+		config.Get().ImageBuilderConfig.Backend = BackendFake
+		Expect(NewClient(context.Background(), log.NewEntry(log.StandardLogger()))).To(BeAssignableToTypeOf(&FakeClient{}))
+		config.Get().ImageBuilderConfig.Backend = BackendHosted
+		Expect(NewClient(context.Background(), log.NewEntry(log.StandardLogger()))).To(BeAssignableToTypeOf(&Client{}))
+	})
+	It("should compose a commit with a tarball of an ostree repo", func() {
+		image, err := client.ComposeCommit(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Commit.Status).To(Equal(models.ImageStatusBuilding))
+		Expect(image.Commit.ComposeJobID).To(Equal(fmt.Sprintf("fake-commit-%d", image.ID)))
+
+		image, err = client.GetCommitStatus(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Commit.Status).To(Equal(models.ImageStatusSuccess))
+		Expect(image.Commit.ImageBuildTarURL).To(HavePrefix("file://" + tempPath))
+
+		// the tarball is downloaded like the ones of Image Builder
+		res, err := http.Get(image.Commit.ImageBuildTarURL)
+		Expect(err).ToNot(HaveOccurred())
+		defer res.Body.Close()
+		var names []string
+		tr := tar.NewReader(res.Body)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			names = append(names, header.Name)
+		}
+		Expect(names).To(ContainElements("repo/", "repo/objects/", "repo/config"))
+	})
+	It("should give the same metadata to the same image", func() {
+		image, err := client.ComposeCommit(image)
+		Expect(err).ToNot(HaveOccurred())
+		image, err = client.GetMetadata(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Commit.OSTreeCommit).To(HaveLen(64))
+		Expect(image.Commit.InstalledPackages).ToNot(BeEmpty())
+		commit := image.Commit.OSTreeCommit
+
+		image.Commit.InstalledPackages = nil
+		image, err = client.GetMetadata(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Commit.OSTreeCommit).To(Equal(commit))
+	})
+	It("should compose an installer with an ISO", func() {
+		image, err := client.ComposeInstaller(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Installer.Status).To(Equal(models.ImageStatusBuilding))
+		var saved models.Installer
+		Expect(db.DB.First(&saved, image.Installer.ID).Error).ToNot(HaveOccurred())
+		Expect(saved.ComposeJobID).To(Equal(image.Installer.ComposeJobID))
+
+		image, err = client.GetInstallerStatus(image)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(image.Installer.Status).To(Equal(models.ImageStatusSuccess))
+		Expect(strings.TrimPrefix(image.Installer.ImageBuildISOURL, "file://")).To(BeAnExistingFile())
+	})
+})
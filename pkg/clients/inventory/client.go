@@ -19,7 +19,7 @@ import (
 type ClientInterface interface {
 	ReturnDevices(parameters *Params) (Response, error)
 	ReturnDevicesByID(deviceID string) (Response, error)
-	ReturnDevicesByTag(tag string) (Response, error)
+	ReturnDevicesByTag(tag string, parameters *Params) (Response, error)
 	BuildURL(parameters *Params) string
 }
 
@@ -53,8 +53,10 @@ type Device struct {
 
 // SystemProfile represents the struct of a SystemProfile on Inventory API
 type SystemProfile struct {
-	RHCClientID          string   `json:"rhc_client_id"`
-	RpmOstreeDeployments []OSTree `json:"rpm_ostree_deployments"`
+	RHCClientID               string   `json:"rhc_client_id"`
+	RpmOstreeDeployments      []OSTree `json:"rpm_ostree_deployments"`
+	GreenbootStatus           string   `json:"greenboot_status"`
+	GreenbootFallbackDetected bool     `json:"greenboot_fallback_detected"`
 }
 
 // OSTree represents the struct of a SystemProfile on Inventory API
@@ -204,10 +206,17 @@ func (c *Client) ReturnDevicesByID(deviceID string) (Response, error) {
 
 }
 
-// ReturnDevicesByTag will return the list of devices by tag
-func (c *Client) ReturnDevicesByTag(tag string) (Response, error) {
-	tagsParam := "?tags=" + tag
-	url := fmt.Sprintf("%s/%s%s%s", config.Get().InventoryConfig.URL, inventoryAPI, FilterParams, tagsParam)
+// ReturnDevicesByTag will return the list of devices by tag, the tag is in the namespace/key=value format
+func (c *Client) ReturnDevicesByTag(tag string, parameters *Params) (Response, error) {
+	params := url.Values{}
+	params.Add("tags", tag)
+	if parameters != nil && parameters.PerPage != "" {
+		params.Add("per_page", parameters.PerPage)
+	}
+	if parameters != nil && parameters.Page != "" {
+		params.Add("page", parameters.Page)
+	}
+	url := fmt.Sprintf("%s/%s%s&%s", config.Get().InventoryConfig.URL, inventoryAPI, FilterParams, params.Encode())
 	c.log.WithFields(log.Fields{
 		"url": url,
 	}).Info("Inventory ReturnDevicesByTag Request Started")
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"github.com/redhatinsights/edge-api/config"
 	"github.com/redhatinsights/edge-api/pkg/clients"
@@ -15,7 +16,8 @@ import (
 
 // ClientInterface is an Interface to make requests to PlaybookDispatcher
 type ClientInterface interface {
-	ExecuteDispatcher(payload DispatcherPayload) ([]Response, error)
+	ExecuteDispatcher(payloads []DispatcherPayload) ([]Response, error)
+	GetRun(account string, id string) (*Run, error)
 }
 
 // Client is the implementation of an ClientInterface
@@ -39,16 +41,23 @@ type DispatcherPayload struct {
 	Account     string `json:"account"`
 }
 
-// Response represents the response retrieved by playbook dispatcher
+// Run represents a playbook run of playbook dispatcher
+type Run struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Response represents the response retrieved by playbook dispatcher for a DispatcherPayload
 type Response struct {
 	StatusCode           int    `json:"code"`
 	PlaybookDispatcherID string `json:"id"`
 }
 
-// ExecuteDispatcher executes a DispatcherPayload, sending it to playbook dispatcher
-func (c *Client) ExecuteDispatcher(payload DispatcherPayload) ([]Response, error) {
+// ExecuteDispatcher executes a batch of DispatcherPayload, sending them to playbook dispatcher in a single request
+// The multi-status response has a Response for every payload, in the order of the payloads
+func (c *Client) ExecuteDispatcher(payloads []DispatcherPayload) ([]Response, error) {
 	payloadBuf := new(bytes.Buffer)
-	if err := json.NewEncoder(payloadBuf).Encode([1]DispatcherPayload{payload}); err != nil {
+	if err := json.NewEncoder(payloadBuf).Encode(payloads); err != nil {
 		return nil, err
 	}
 	url := c.url + "/internal/dispatch"
@@ -67,12 +76,10 @@ func (c *Client) ExecuteDispatcher(payload DispatcherPayload) ([]Response, error
 	client := &http.Client{}
 	res, err := client.Do(req)
 	if err != nil {
-		c.log.WithFields(log.Fields{
-			"statusCode": res.StatusCode,
-			"error":      err,
-		}).Error("PlaybookDispatcher ExecuteDispatcher Request Error")
+		c.log.WithField("error", err.Error()).Error("PlaybookDispatcher ExecuteDispatcher Request Error")
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	c.log.WithFields(log.Fields{
@@ -83,7 +90,6 @@ func (c *Client) ExecuteDispatcher(payload DispatcherPayload) ([]Response, error
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 	if res.StatusCode != http.StatusMultiStatus {
 		return nil, fmt.Errorf("error calling playbook dispatcher, got status code %d and body %s", res.StatusCode, body)
 	}
@@ -93,5 +99,56 @@ func (c *Client) ExecuteDispatcher(payload DispatcherPayload) ([]Response, error
 		c.log.Error("Error while trying to unmarshal ", &playbookResponse)
 		return nil, err
 	}
+	if len(playbookResponse) != len(payloads) {
+		return nil, fmt.Errorf("error calling playbook dispatcher, got %d responses for %d payloads", len(playbookResponse), len(payloads))
+	}
 	return playbookResponse, nil
 }
+
+// GetRun gets a playbook run of an account from the internal runs API of playbook dispatcher, it returns nil when the run is not found
+// The internal API is authenticated with the pre-shared key of edge-api, like the dispatch requests, as the runs
+// are checked by background jobs without the identity of a user
+func (c *Client) GetRun(account string, id string) (*Run, error) {
+	runsURL := fmt.Sprintf("%s/internal/runs?filter[id]=%s&filter[account]=%s&fields[data]=id,status",
+		c.url, url.QueryEscape(id), url.QueryEscape(account))
+	c.log.WithFields(log.Fields{"url": runsURL, "account": account}).Debug("PlaybookDispatcher GetRun Request Started")
+	req, err := http.NewRequest("GET", runsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	headers := clients.GetOutgoingHeaders(c.ctx)
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("PSK %s", c.psk))
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		c.log.WithField("error", err.Error()).Error("PlaybookDispatcher GetRun Request Error")
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error calling playbook dispatcher runs, got status code %d and body %s", res.StatusCode, body)
+	}
+
+	var runs struct {
+		Data []Run `json:"data"`
+	}
+	if err := json.Unmarshal(body, &runs); err != nil {
+		c.log.WithField("error", err.Error()).Error("Error while trying to unmarshal playbook dispatcher runs")
+		return nil, err
+	}
+	for _, run := range runs.Data {
+		if run.ID == id {
+			run := run // this will prevent implicit memory aliasing in the loop
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
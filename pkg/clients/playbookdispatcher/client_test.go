@@ -0,0 +1,93 @@
+package playbookdispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/redhatinsights/edge-api/config"
+)
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Playbook Dispatcher Client Suite")
+}
+
+var _ = Describe("Playbook Dispatcher Client Test", func() {
+	var client *Client
+	var server *httptest.Server
+	var handler http.HandlerFunc
+	BeforeEach(func() {
+		config.Init()
+		// a local stand-in of playbook dispatcher
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r)
+		}))
+		config.Get().PlaybookDispatcherConfig.URL = server.URL
+		config.Get().PlaybookDispatcherConfig.PSK = "psk"
+		client = InitClient(context.Background(), log.NewEntry(log.StandardLogger()))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+	Describe("dispatch", func() {
+		It("should send the payloads in a single request", func() {
+			var received []DispatcherPayload
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/internal/dispatch"))
+				Expect(json.NewDecoder(r.Body).Decode(&received)).To(Succeed())
+				w.WriteHeader(http.StatusMultiStatus)
+				fmt.Fprint(w, `[{"code": 201, "id": "run-1"}, {"code": 404}]`)
+			}
+			responses, err := client.ExecuteDispatcher([]DispatcherPayload{{Recipient: "device-1"}, {Recipient: "device-2"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(received).To(HaveLen(2))
+			Expect(responses).To(HaveLen(2))
+			Expect(responses[0].PlaybookDispatcherID).To(Equal("run-1"))
+			Expect(responses[1].StatusCode).To(Equal(http.StatusNotFound))
+		})
+		It("should fail when a payload has no response", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusMultiStatus)
+				fmt.Fprint(w, `[{"code": 201, "id": "run-1"}]`)
+			}
+			_, err := client.ExecuteDispatcher([]DispatcherPayload{{Recipient: "device-1"}, {Recipient: "device-2"}})
+			Expect(err).To(HaveOccurred())
+		})
+		It("should fail when playbook dispatcher is unreachable", func() {
+			server.Close()
+			_, err := client.ExecuteDispatcher([]DispatcherPayload{{Recipient: "device-1"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("get run", func() {
+		It("should return the run with its status", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/internal/runs"))
+				Expect(r.URL.Query().Get("filter[id]")).To(Equal("run-1"))
+				Expect(r.URL.Query().Get("filter[account]")).To(Equal("0000000"))
+				Expect(r.Header.Get("Authorization")).To(Equal("PSK psk"))
+				fmt.Fprint(w, `{"data": [{"id": "run-1", "status": "timeout"}]}`)
+			}
+			run, err := client.GetRun("0000000", "run-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(run).ToNot(BeNil())
+			Expect(run.Status).To(Equal("timeout"))
+		})
+		It("should return nil when the run is not found", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"data": []}`)
+			}
+			run, err := client.GetRun("0000000", "run-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(run).To(BeNil())
+		})
+	})
+})
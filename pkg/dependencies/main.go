@@ -23,6 +23,7 @@ type EdgeAPIServices struct {
 	ThirdPartyRepoService   services.ThirdPartyRepoServiceInterface
 	OwnershipVoucherService services.OwnershipVoucherServiceInterface
 	DeviceGroupsService     services.DeviceGroupsServiceInterface
+	JobService              services.JobServiceInterface
 	Log                     *log.Entry
 }
 
@@ -44,6 +45,7 @@ func Init(ctx context.Context) *EdgeAPIServices {
 		DeviceService:           services.NewDeviceService(ctx, log),
 		OwnershipVoucherService: services.NewOwnershipVoucherService(ctx, log),
 		DeviceGroupsService:     services.NewDeviceGroupsService(ctx, log),
+		JobService:              services.NewJobService(ctx, log),
 		Log:                     log,
 	}
 }
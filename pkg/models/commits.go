@@ -1,5 +1,7 @@
 package models
 
+import "gorm.io/gorm"
+
 const (
 	// RepoStatusBuilding is for when a image is on a error state
 	RepoStatusBuilding = "BUILDING"
@@ -9,6 +11,31 @@ const (
 	RepoStatusSuccess = "SUCCESS"
 )
 
+const (
+	// ComposeStatusPending is for when a compose waits to be built by image builder
+	ComposeStatusPending = "pending"
+	// ComposeStatusBuilding is for when image builder builds a compose
+	ComposeStatusBuilding = "building"
+	// ComposeStatusUploading is for when image builder uploads the artifact of a compose
+	ComposeStatusUploading = "uploading"
+	// ComposeStatusRegistering is for when image builder registers the artifact of a compose
+	ComposeStatusRegistering = "registering"
+	// ComposeStatusSuccess is for when the artifact of a compose is available
+	ComposeStatusSuccess = "success"
+	// ComposeStatusFailure is for when a compose failed
+	ComposeStatusFailure = "failure"
+)
+
+// ComposeProgress is the progress of the compose of a commit or an installer on image builder
+// It is stored so the compose is tracked again after a restart, ComposeStatus is the last state reported by
+// image builder while the commit or the installer is BUILDING and ComposeChecks the number of checks that found it
+type ComposeProgress struct {
+	ComposeStatus      string      `json:"ComposeStatus,omitempty"`
+	ComposeCheckedAt   EdgeAPITime `json:"ComposeCheckedAt,omitempty"`
+	ComposeNextCheckAt EdgeAPITime `json:"-" gorm:"index"`
+	ComposeChecks      int         `json:"-"`
+}
+
 // Commit represents an OSTree commit from image builder
 type Commit struct {
 	Model
@@ -17,6 +44,7 @@ type Commit struct {
 	ImageBuildHash       string             `json:"ImageBuildHash"`
 	ImageBuildParentHash string             `json:"ImageBuildParentHash"`
 	ImageBuildTarURL     string             `json:"ImageBuildTarURL"`
+	TarballSize          int64              `json:"TarballSize"` // size in bytes of the commit repo tarball
 	OSTreeCommit         string             `json:"OSTreeCommit"`
 	OSTreeParentCommit   string             `json:"OSTreeParentCommit"`
 	OSTreeRef            string             `json:"OSTreeRef"`
@@ -29,6 +57,19 @@ type Commit struct {
 	Status               string             `json:"Status"`
 	RepoID               *uint              `json:"RepoID"`
 	Repo                 *Repo              `json:"Repo"`
+	ComposeProgress
+
+	previousStatus string // status stored before the save, used to record its transitions
+}
+
+// BeforeSave is called before saving a commit, loads its stored status to record its transitions
+func (c *Commit) BeforeSave(tx *gorm.DB) error {
+	return loadPreviousStatus(tx, &Commit{}, c.ID, &c.previousStatus)
+}
+
+// AfterSave is called after saving a commit, records its status transition as an event
+func (c *Commit) AfterSave(tx *gorm.DB) error {
+	return recordStatusEvent(tx, EventResourceCommit, c.ID, c.Account, &c.previousStatus, c.Status)
 }
 
 // Repo is the delivery mechanism of a Commit over HTTP
@@ -0,0 +1,20 @@
+package models
+
+// ConcurrencySlot is a slot of a concurrency limit, the running update builds of an account for instance,
+// taken by a holder, like a job or an update transaction
+// Scope and Slot are unique so that a slot is taken by a single holder across all the replicas,
+// a scope with a limit of N has the slots 0 to N-1, the slots are deleted permanently when released
+type ConcurrencySlot struct {
+	Model
+	Scope      string `json:"Scope" gorm:"uniqueIndex:idx_concurrency_slots_slot"`
+	Slot       int    `json:"Slot" gorm:"uniqueIndex:idx_concurrency_slots_slot"`
+	HolderType string `json:"HolderType" gorm:"index:idx_concurrency_slots_holder"`
+	HolderID   uint   `json:"HolderID" gorm:"index:idx_concurrency_slots_holder"`
+}
+
+const (
+	// ConcurrencySlotHolderJob is the holder type of the slots taken by a running job
+	ConcurrencySlotHolderJob = "Job"
+	// ConcurrencySlotHolderUpdateTransaction is the holder type of the slots taken by an update transaction until its rollout is over
+	ConcurrencySlotHolderUpdateTransaction = "UpdateTransaction"
+)
@@ -10,12 +10,14 @@ import (
 // DeviceGroup is a record of Edge Devices Groups
 // Account is the account associated with the device group
 // Type is the device group type and must be "static" or "dynamic"
+// MaintenanceWindows are the windows of time during which the devices of the group can be updated
 type DeviceGroup struct {
 	Model
-	Account string   `json:"Account" gorm:"index;<-:create"`
-	Name    string   `json:"Name"`
-	Type    string   `json:"Type" gorm:"default:static;<-:create"`
-	Devices []Device `json:"Devices" gorm:"many2many:device_groups_devices;"`
+	Account            string              `json:"Account" gorm:"index;<-:create"`
+	Name               string              `json:"Name"`
+	Type               string              `json:"Type" gorm:"default:static;<-:create"`
+	Devices            []Device            `json:"Devices" gorm:"many2many:device_groups_devices;"`
+	MaintenanceWindows []MaintenanceWindow `json:"MaintenanceWindows"`
 }
 
 //DeviceGroupListDetail is a record of Edge Devices Groups with images and status information
@@ -80,7 +82,13 @@ func (group *DeviceGroup) ValidateRequest() error {
 	return nil
 }
 
-// BeforeDelete is called before deleting a device group, delete the device group devices first
+// BeforeDelete is called before deleting a device group, delete the device group devices, maintenance windows and update hooks first
 func (group *DeviceGroup) BeforeDelete(tx *gorm.DB) error {
-	return tx.Model(group).Association("Devices").Delete(&group.Devices)
+	if err := tx.Model(group).Association("Devices").Delete(&group.Devices); err != nil {
+		return err
+	}
+	if err := tx.Where("device_group_id = ?", group.ID).Delete(&MaintenanceWindow{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("device_group_id = ?", group.ID).Delete(&UpdateHook{}).Error
 }
@@ -0,0 +1,116 @@
+package models
+
+// DeviceGroupUpdate is the rollout of an update to all the devices of a DeviceGroup
+// The devices are split into an UpdateTransaction per image set they run,
+// the devices that can't be updated are reported in RejectedDevices.
+// CommitID is the commit requested for the update, when 0 each image set is
+// updated to its latest successful image.
+// Kind is ROLLBACK when the devices are rolled back to their previous image instead.
+type DeviceGroupUpdate struct {
+	Model
+	Account            string                            `json:"Account" gorm:"index"`
+	DeviceGroupID      uint                              `json:"DeviceGroupID" gorm:"index"`
+	CommitID           uint                              `json:"CommitID"`
+	Kind               string                            `json:"Kind"`
+	UpdateTransactions []UpdateTransaction               `json:"UpdateTransactions"`
+	RejectedDevices    []DeviceGroupUpdateRejectedDevice `json:"RejectedDevices"`
+	Progress           *DeviceGroupUpdateProgress        `json:"Progress" gorm:"-"`
+}
+
+// DeviceGroupUpdateRejectedDevice is a device of a DeviceGroupUpdate that can't be updated
+type DeviceGroupUpdateRejectedDevice struct {
+	Model
+	DeviceGroupUpdateID uint   `json:"DeviceGroupUpdateID" gorm:"index"`
+	DeviceID            uint   `json:"DeviceID"`
+	DeviceUUID          string `json:"DeviceUUID"`
+	Reason              string `json:"Reason"`
+}
+
+// DeviceGroupUpdateProgress is the aggregated progress of the update transactions of a DeviceGroupUpdate
+// Percentage is the percentage of the devices of the update transactions that have finished
+type DeviceGroupUpdateProgress struct {
+	Status          string `json:"Status"`
+	DevicesCount    int    `json:"DevicesCount"`
+	PendingCount    int    `json:"PendingCount"`
+	InProgressCount int    `json:"InProgressCount"`
+	SuccessCount    int    `json:"SuccessCount"`
+	FailureCount    int    `json:"FailureCount"`
+	CancelledCount  int    `json:"CancelledCount"`
+	RolledBackCount int    `json:"RolledBackCount"`
+	RejectedCount   int    `json:"RejectedCount"`
+	Percentage      int    `json:"Percentage"`
+}
+
+const (
+	// DeviceGroupUpdateRejectedImageUndefined is the reason a device without image is rejected
+	DeviceGroupUpdateRejectedImageUndefined = "device image is undefined"
+	// DeviceGroupUpdateRejectedImageSetUndefined is the reason a device running an image without image set is rejected
+	DeviceGroupUpdateRejectedImageSetUndefined = "device image has no image set"
+	// DeviceGroupUpdateRejectedOtherImageSet is the reason a device running an image of another image set than the requested commit is rejected
+	DeviceGroupUpdateRejectedOtherImageSet = "device image set is not the image set of the requested commit"
+	// DeviceGroupUpdateRejectedNoUpdate is the reason a device without image update is rejected
+	DeviceGroupUpdateRejectedNoUpdate = "device has no image update"
+	// DeviceGroupUpdateRejectedUpToDate is the reason a device already running the requested commit is rejected
+	DeviceGroupUpdateRejectedUpToDate = "device is already up to date"
+	// DeviceGroupUpdateRejectedNoRollback is the reason a device without a previous successful image is rejected from a rollback
+	DeviceGroupUpdateRejectedNoRollback = "device has no previous image to roll back to"
+)
+
+// ComputeProgress aggregates the progress of the update transactions, which need their devices and dispatch records loaded
+func (u *DeviceGroupUpdate) ComputeProgress() *DeviceGroupUpdateProgress {
+	progress := &DeviceGroupUpdateProgress{RejectedCount: len(u.RejectedDevices)}
+	buildingCount, pausedCount, errorCount, cancelledCount, rolledBackCount := 0, 0, 0, 0, 0
+	for _, update := range u.UpdateTransactions {
+		switch update.Status {
+		case UpdateStatusCreated, UpdateStatusQueued, UpdateStatusBuilding:
+			buildingCount++
+		case UpdateStatusPaused:
+			pausedCount++
+		case UpdateStatusError:
+			errorCount++
+		case UpdateStatusCancelled:
+			cancelledCount++
+		case UpdateStatusRolledBack:
+			rolledBackCount++
+		}
+		progress.DevicesCount += len(update.Devices)
+		dispatchedCount := 0
+		for _, dispatchRecord := range update.DispatchRecords {
+			switch dispatchRecord.Status {
+			case DispatchRecordStatusCreated, DispatchRecordStatusRunning, DispatchRecordStatusRebooting:
+				progress.InProgressCount++
+			case DispatchRecordStatusComplete:
+				progress.SuccessCount++
+			case DispatchRecordStatusError:
+				progress.FailureCount++
+			case DispatchRecordStatusCancelled:
+				progress.CancelledCount++
+			case DispatchRecordStatusRolledBack:
+				progress.RolledBackCount++
+			default:
+				continue
+			}
+			dispatchedCount++
+		}
+		progress.PendingCount += len(update.Devices) - dispatchedCount
+	}
+	if progress.DevicesCount > 0 {
+		progress.Percentage = (progress.SuccessCount + progress.FailureCount + progress.RolledBackCount) * 100 / progress.DevicesCount
+	}
+	switch {
+	case len(u.UpdateTransactions) == 0 || errorCount > 0 && buildingCount == 0 && pausedCount == 0:
+		progress.Status = UpdateStatusError
+	case buildingCount > 0:
+		progress.Status = UpdateStatusBuilding
+	case pausedCount > 0:
+		progress.Status = UpdateStatusPaused
+	case cancelledCount == len(u.UpdateTransactions):
+		progress.Status = UpdateStatusCancelled
+	case rolledBackCount > 0:
+		progress.Status = UpdateStatusRolledBack
+	default:
+		progress.Status = UpdateStatusSuccess
+	}
+	u.Progress = progress
+	return progress
+}
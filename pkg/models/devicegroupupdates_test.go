@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestDeviceGroupUpdateComputeProgress(t *testing.T) {
+	groupUpdate := &DeviceGroupUpdate{
+		UpdateTransactions: []UpdateTransaction{
+			{
+				Status:  UpdateStatusBuilding,
+				Devices: []Device{{UUID: "1"}, {UUID: "2"}, {UUID: "3"}},
+				DispatchRecords: []DispatchRecord{
+					{Status: DispatchRecordStatusComplete},
+					{Status: DispatchRecordStatusRunning},
+					{Status: DispatchRecordStatusPending},
+				},
+			},
+			{
+				Status:          UpdateStatusError,
+				Devices:         []Device{{UUID: "4"}},
+				DispatchRecords: []DispatchRecord{{Status: DispatchRecordStatusError}},
+			},
+		},
+		RejectedDevices: []DeviceGroupUpdateRejectedDevice{{DeviceUUID: "5", Reason: DeviceGroupUpdateRejectedUpToDate}},
+	}
+
+	progress := groupUpdate.ComputeProgress()
+	expected := DeviceGroupUpdateProgress{
+		Status:          UpdateStatusBuilding,
+		DevicesCount:    4,
+		PendingCount:    1,
+		InProgressCount: 1,
+		SuccessCount:    1,
+		FailureCount:    1,
+		RejectedCount:   1,
+		Percentage:      50,
+	}
+	if *progress != expected {
+		t.Errorf("expected progress %+v but got %+v", expected, *progress)
+	}
+	if groupUpdate.Progress != progress {
+		t.Errorf("expected progress to be set on the device group update")
+	}
+
+	groupUpdate.UpdateTransactions[0].Status = UpdateStatusSuccess
+	if progress := groupUpdate.ComputeProgress(); progress.Status != UpdateStatusError {
+		t.Errorf("expected status %q but got %q", UpdateStatusError, progress.Status)
+	}
+
+	groupUpdate.UpdateTransactions[1] = UpdateTransaction{
+		Status:          UpdateStatusRolledBack,
+		Devices:         []Device{{UUID: "4"}},
+		DispatchRecords: []DispatchRecord{{Status: DispatchRecordStatusRolledBack}},
+	}
+	progress = groupUpdate.ComputeProgress()
+	if progress.Status != UpdateStatusRolledBack || progress.RolledBackCount != 1 || progress.FailureCount != 0 {
+		t.Errorf("expected the rolled back device to be counted, got %+v", *progress)
+	}
+}
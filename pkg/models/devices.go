@@ -47,6 +47,9 @@ type DeviceView struct {
 	Status          string              `json:"Status"`
 	ImageSetID      uint                `json:"ImageSetID"`
 	DeviceGroups    []DeviceDeviceGroup `json:"DeviceGroups"`
+	// GreenbootStatus and GreenbootFallbackDetected are the greenboot health of the last boot of the device
+	GreenbootStatus           string `json:"GreenbootStatus"`
+	GreenbootFallbackDetected bool   `json:"GreenbootFallbackDetected"`
 }
 
 // DeviceDeviceGroup is a struct of device group name and id needed for DeviceView
@@ -62,6 +65,11 @@ const (
 	DeviceViewStatusUpdating = "UPDATING"
 	// DeviceViewStatusUpdateAvail is for when a update available for a device
 	DeviceViewStatusUpdateAvail = "UPDATE AVAILABLE"
+
+	// DeviceGreenbootStatusGreen is for when the greenboot health checks of the last boot passed
+	DeviceGreenbootStatusGreen = "green"
+	// DeviceGreenbootStatusRed is for when the greenboot health checks of the last boot failed
+	DeviceGreenbootStatusRed = "red"
 )
 
 // Device is a record of Edge Devices referenced by their UUID as per the
@@ -85,4 +93,8 @@ type Device struct {
 	UpdateAvailable   bool                 `json:"UpdateAvailable"`
 	DevicesGroups     []DeviceGroup        `faker:"-" gorm:"many2many:device_groups_devices;" json:"DevicesGroups"`
 	UpdateTransaction *[]UpdateTransaction `faker:"-" gorm:"many2many:updatetransaction_devices;" json:"UpdateTransaction"`
+	// GreenbootStatus is the greenboot health check status of the last boot, green or red,
+	// GreenbootFallbackDetected is set when greenboot rolled the device back to the previous deployment
+	GreenbootStatus           string `json:"GreenbootStatus,omitempty"`
+	GreenbootFallbackDetected bool   `json:"GreenbootFallbackDetected"`
 }
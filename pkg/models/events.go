@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	"gorm.io/gorm"
+)
+
+// Event is a status transition of an update transaction, dispatch record, image, commit or installer
+// Actor is the user of the request that changed the status, or the internal component that changed it
+// OldStatus is empty when the resource was created, Message is the error that caused the transition if any
+type Event struct {
+	Model
+	Account      string `json:"Account" gorm:"index"`
+	ResourceType string `json:"ResourceType" gorm:"index:idx_events_resource"`
+	ResourceID   uint   `json:"ResourceID" gorm:"index:idx_events_resource"`
+	Actor        string `json:"Actor"`
+	OldStatus    string `json:"OldStatus"`
+	NewStatus    string `json:"NewStatus"`
+	Message      string `json:"Message,omitempty"`
+}
+
+const (
+	// EventResourceUpdateTransaction is the resource type of the update transaction events
+	EventResourceUpdateTransaction = "UpdateTransaction"
+	// EventResourceDispatchRecord is the resource type of the dispatch record events
+	EventResourceDispatchRecord = "DispatchRecord"
+	// EventResourceImage is the resource type of the image events
+	EventResourceImage = "Image"
+	// EventResourceCommit is the resource type of the commit events
+	EventResourceCommit = "Commit"
+	// EventResourceInstaller is the resource type of the installer events
+	EventResourceInstaller = "Installer"
+
+	// EventActorEdgeAPI is the actor of the transitions made by edge management itself
+	EventActorEdgeAPI = "edge-api"
+	// EventActorPlaybookDispatcher is the actor of the transitions reported by the playbook dispatcher
+	EventActorPlaybookDispatcher = "playbook-dispatcher"
+	// EventActorImageBuilder is the actor of the transitions reported by image builder
+	EventActorImageBuilder = "image-builder"
+	// EventActorInventory is the actor of the transitions reported by the devices through inventory
+	EventActorInventory = "inventory"
+)
+
+type eventContextKey int
+
+const (
+	eventActorKey eventContextKey = iota
+	eventMessageKey
+)
+
+// ContextWithEventActor returns a context recording the status transitions saved with it as made by the actor
+func ContextWithEventActor(ctx context.Context, actor string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, eventActorKey, actor)
+}
+
+// ContextWithEventMessage returns a context recording the message with the status transitions saved with it
+func ContextWithEventMessage(ctx context.Context, message string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, eventMessageKey, message)
+}
+
+// EventContext returns a context carrying the event actor and message of ctx, without its deadline and
+// cancellation as the saves of the builds and updates outlive the request that started them
+func EventContext(ctx context.Context) context.Context {
+	eventCtx := ContextWithEventActor(context.Background(), eventActor(ctx))
+	if message := eventMessage(ctx); message != "" {
+		eventCtx = ContextWithEventMessage(eventCtx, message)
+	}
+	return eventCtx
+}
+
+// eventActor returns the actor of the transitions saved with the context, the actor set on the context,
+// else the user or account of the request identity, else edge management
+func eventActor(ctx context.Context) string {
+	if ctx == nil {
+		return EventActorEdgeAPI
+	}
+	if actor, ok := ctx.Value(eventActorKey).(string); ok && actor != "" {
+		return actor
+	}
+	if ident, ok := ctx.Value(identity.Key).(identity.XRHID); ok {
+		if ident.Identity.User.Username != "" {
+			return ident.Identity.User.Username
+		}
+		if ident.Identity.AccountNumber != "" {
+			return "account " + ident.Identity.AccountNumber
+		}
+	}
+	return EventActorEdgeAPI
+}
+
+// eventMessage returns the message of the transitions saved with the context
+func eventMessage(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	message, _ := ctx.Value(eventMessageKey).(string)
+	return message
+}
+
+// loadPreviousStatus reads the stored status of a resource before it is saved
+func loadPreviousStatus(tx *gorm.DB, model interface{}, id uint, previousStatus *string) error {
+	*previousStatus = ""
+	if id == 0 {
+		return nil
+	}
+	var statuses []string
+	result := tx.Session(&gorm.Session{NewDB: true}).Model(model).Where("id = ?", id).Pluck("status", &statuses)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(statuses) > 0 {
+		*previousStatus = statuses[0]
+	}
+	return nil
+}
+
+// recordStatusEvent records the transition of a saved resource when its status changed
+func recordStatusEvent(tx *gorm.DB, resourceType string, id uint, account string, previousStatus *string, status string) error {
+	if id == 0 || status == *previousStatus {
+		return nil
+	}
+	event := Event{
+		Account:      account,
+		ResourceType: resourceType,
+		ResourceID:   id,
+		Actor:        eventActor(tx.Statement.Context),
+		OldStatus:    *previousStatus,
+		NewStatus:    status,
+		Message:      eventMessage(tx.Statement.Context),
+	}
+	if err := tx.Session(&gorm.Session{NewDB: true}).Create(&event).Error; err != nil {
+		return err
+	}
+	*previousStatus = status
+	return nil
+}
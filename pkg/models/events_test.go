@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+)
+
+func TestStatusTransitionsAreRecorded(t *testing.T) {
+	account := "events-account"
+	ctx := ContextWithEventActor(context.Background(), EventActorImageBuilder)
+	image := Image{Name: "events-image", Account: account, Status: ImageStatusCreated}
+	if err := db.DB.WithContext(ctx).Create(&image).Error; err != nil {
+		t.Fatalf("failed to create image: %s", err.Error())
+	}
+
+	var savedImage Image
+	if err := db.DB.First(&savedImage, image.ID).Error; err != nil {
+		t.Fatalf("failed to get image: %s", err.Error())
+	}
+	savedImage.Status = ImageStatusBuilding
+	if err := db.DB.WithContext(ctx).Save(&savedImage).Error; err != nil {
+		t.Fatalf("failed to save image: %s", err.Error())
+	}
+	// saving without changing the status records nothing
+	savedImage.Description = "no transition"
+	if err := db.DB.Save(&savedImage).Error; err != nil {
+		t.Fatalf("failed to save image: %s", err.Error())
+	}
+	errorCtx := ContextWithEventMessage(context.Background(), "compose failed")
+	if err := db.DB.WithContext(errorCtx).Model(&Image{Model: Model{ID: image.ID}}).Update("status", ImageStatusError).Error; err != nil {
+		t.Fatalf("failed to update image status: %s", err.Error())
+	}
+
+	var events []Event
+	if err := db.DB.Where("resource_type = ? AND resource_id = ?", EventResourceImage, image.ID).Order("id").Find(&events).Error; err != nil {
+		t.Fatalf("failed to get events: %s", err.Error())
+	}
+	expected := []Event{
+		{Account: account, Actor: EventActorImageBuilder, OldStatus: "", NewStatus: ImageStatusCreated},
+		{Account: account, Actor: EventActorImageBuilder, OldStatus: ImageStatusCreated, NewStatus: ImageStatusBuilding},
+		{Actor: EventActorEdgeAPI, OldStatus: ImageStatusBuilding, NewStatus: ImageStatusError, Message: "compose failed"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events but got %d", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.Account != expected[i].Account || event.Actor != expected[i].Actor || event.OldStatus != expected[i].OldStatus ||
+			event.NewStatus != expected[i].NewStatus || event.Message != expected[i].Message {
+			t.Errorf("expected event %+v but got %+v", expected[i], event)
+		}
+	}
+}
+
+func TestEventContext(t *testing.T) {
+	ident := identity.XRHID{Identity: identity.Identity{AccountNumber: "0000000", User: identity.User{Username: "jdoe"}}}
+	requestCtx, cancel := context.WithCancel(context.WithValue(context.Background(), identity.Key, ident))
+	cancel()
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		actor   string
+		message string
+	}{
+		{name: "internal", ctx: context.Background(), actor: EventActorEdgeAPI},
+		{name: "component", ctx: ContextWithEventActor(context.Background(), EventActorPlaybookDispatcher), actor: EventActorPlaybookDispatcher},
+		{name: "user", ctx: requestCtx, actor: "jdoe"},
+		{
+			name:  "account",
+			ctx:   context.WithValue(context.Background(), identity.Key, identity.XRHID{Identity: identity.Identity{AccountNumber: "0000000"}}),
+			actor: "account 0000000",
+		},
+		{name: "message", ctx: ContextWithEventMessage(requestCtx, "failed"), actor: "jdoe", message: "failed"},
+	}
+	for _, c := range cases {
+		eventCtx := EventContext(c.ctx)
+		if eventCtx.Err() != nil {
+			t.Errorf("%s: expected the event context not to be cancelled", c.name)
+		}
+		if actor := eventActor(eventCtx); actor != c.actor {
+			t.Errorf("%s: expected actor %q but got %q", c.name, c.actor, actor)
+		}
+		if message := eventMessage(eventCtx); message != c.message {
+			t.Errorf("%s: expected message %q but got %q", c.name, c.message, message)
+		}
+	}
+}
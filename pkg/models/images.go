@@ -6,6 +6,7 @@ import (
 
 	"github.com/lib/pq"
 	"github.com/redhatinsights/edge-api/pkg/db"
+	"gorm.io/gorm"
 )
 
 // ImageSet represents a collection of images
@@ -36,6 +37,18 @@ type Image struct {
 	Packages               []Package        `json:"Packages,omitempty" gorm:"many2many:images_packages;"`
 	ThirdPartyRepositories []ThirdPartyRepo `json:"ThirdPartyRepositories,omitempty" gorm:"many2many:images_repos;"`
 	CustomPackages         []Package        `json:"CustomPackages,omitempty" gorm:"many2many:images_custom_packages"`
+
+	previousStatus string // status stored before the save, used to record its transitions
+}
+
+// BeforeSave is called before saving an image, loads its stored status to record its transitions
+func (i *Image) BeforeSave(tx *gorm.DB) error {
+	return loadPreviousStatus(tx, &Image{}, i.ID, &i.previousStatus)
+}
+
+// AfterSave is called after saving an image, records its status transition as an event
+func (i *Image) AfterSave(tx *gorm.DB) error {
+	return recordStatusEvent(tx, EventResourceImage, i.ID, i.Account, &i.previousStatus, i.Status)
 }
 
 // ImageUpdateAvailable contains image and differences between current and available commits
@@ -51,6 +64,49 @@ type PackageDiff struct {
 	Upgraded []InstalledPackage `json:"Upgraded"`
 }
 
+// ImageComparison is the difference from an image to another image of the account, of any image set,
+// the items added and the new values are the ones of the other image
+type ImageComparison struct {
+	ImageID                uint                  `json:"ImageID"`
+	OtherImageID           uint                  `json:"OtherImageID"`
+	InstalledPackages      InstalledPackagesDiff `json:"InstalledPackages"`
+	Packages               NamesDiff             `json:"Packages"`
+	CustomPackages         NamesDiff             `json:"CustomPackages"`
+	ThirdPartyRepositories ThirdPartyReposDiff   `json:"ThirdPartyRepositories"`
+	OutputTypes            NamesDiff             `json:"OutputTypes"`
+	Distribution           *ValueChange          `json:"Distribution,omitempty"`
+	Arch                   *ValueChange          `json:"Arch,omitempty"`
+	InstallerUsername      *ValueChange          `json:"InstallerUsername,omitempty"`
+	InstallerSSHKey        *ValueChange          `json:"InstallerSshKey,omitempty"`
+}
+
+// InstalledPackagesDiff is the difference between the packages installed by two commits,
+// Upgraded and Downgraded are the packages of the other commit installed with a newer or an older version
+type InstalledPackagesDiff struct {
+	Added      []InstalledPackage `json:"Added"`
+	Removed    []InstalledPackage `json:"Removed"`
+	Upgraded   []InstalledPackage `json:"Upgraded"`
+	Downgraded []InstalledPackage `json:"Downgraded"`
+}
+
+// NamesDiff is the difference between two lists of names
+type NamesDiff struct {
+	Added   []string `json:"Added"`
+	Removed []string `json:"Removed"`
+}
+
+// ThirdPartyReposDiff is the difference between the third party repositories of two images
+type ThirdPartyReposDiff struct {
+	Added   []ThirdPartyRepo `json:"Added"`
+	Removed []ThirdPartyRepo `json:"Removed"`
+}
+
+// ValueChange is a setting with a different value on the other image, Old is the value of the image
+type ValueChange struct {
+	Old string `json:"Old"`
+	New string `json:"New"`
+}
+
 // ImageInfo contains Image with updates available and rollback image
 type ImageInfo struct {
 	Image            Image                   `json:"Image"`
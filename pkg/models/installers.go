@@ -1,5 +1,7 @@
 package models
 
+import "gorm.io/gorm"
+
 // Installer defines the model for a ISO installer
 type Installer struct {
 	Model
@@ -10,4 +12,17 @@ type Installer struct {
 	Username         string `json:"Username"`
 	SSHKey           string `json:"SshKey"`
 	Checksum         string `json:"Checksum"`
+	ComposeProgress
+
+	previousStatus string // status stored before the save, used to record its transitions
+}
+
+// BeforeSave is called before saving an installer, loads its stored status to record its transitions
+func (i *Installer) BeforeSave(tx *gorm.DB) error {
+	return loadPreviousStatus(tx, &Installer{}, i.ID, &i.previousStatus)
+}
+
+// AfterSave is called after saving an installer, records its status transition as an event
+func (i *Installer) AfterSave(tx *gorm.DB) error {
+	return recordStatusEvent(tx, EventResourceInstaller, i.ID, i.Account, &i.previousStatus, i.Status)
 }
@@ -0,0 +1,53 @@
+package models
+
+// Job is a long-running unit of work, like an image build, run by the job workers of any replica
+// ResourceID is the id of the resource the job works on, the image of an image build for instance
+// A worker claims a job by taking its lease until LeaseExpiresAt and extends it with heartbeats,
+// the job is claimed again by another worker when its lease expires without being released,
+// a job failing is retried with a backoff, not before RunAt, until it used all its attempts
+type Job struct {
+	Model
+	Account        string      `json:"Account" gorm:"index"`
+	Type           string      `json:"Type" gorm:"index:idx_jobs_resource"`
+	ResourceID     uint        `json:"ResourceID" gorm:"index:idx_jobs_resource"`
+	Status         string      `json:"Status" gorm:"index"`
+	Attempts       int         `json:"Attempts"`
+	MaxAttempts    int         `json:"MaxAttempts"`
+	RunAt          EdgeAPITime `json:"RunAt"`
+	LeaseOwner     string      `json:"LeaseOwner,omitempty"`
+	LeaseExpiresAt EdgeAPITime `json:"LeaseExpiresAt,omitempty"`
+	HeartbeatAt    EdgeAPITime `json:"HeartbeatAt,omitempty"`
+	LastError      string      `json:"LastError,omitempty"`
+	// Identity is the x-rh-identity of the request that enqueued the job, used to call the other services
+	Identity string `json:"-"`
+}
+
+const (
+	// JobStatusPending is for when a job waits to be claimed by a worker
+	JobStatusPending = "PENDING"
+	// JobStatusRunning is for when a job is run by the worker holding its lease
+	JobStatusRunning = "RUNNING"
+	// JobStatusSuccess is for when a job is done
+	JobStatusSuccess = "SUCCESS"
+	// JobStatusFailed is for when a job failed all its attempts
+	JobStatusFailed = "FAILED"
+
+	// JobTypeImageBuild builds the commit and the installer of an image
+	JobTypeImageBuild = "image-build"
+	// JobTypeUpdateBuild builds the update repo of an update transaction and dispatches it to the devices
+	JobTypeUpdateBuild = "update-build"
+	// JobTypeUpdateBundleBuild builds an offline update bundle
+	JobTypeUpdateBundleBuild = "update-bundle-build"
+	// JobTypeUpdateRepoPrebuild builds the update repo of a new image version with the static deltas
+	// from the previous versions of its image set, before any update to it is created
+	JobTypeUpdateRepoPrebuild = "update-repo-prebuild"
+	// JobTypeImageArtifactsDelete deletes from the storage the tarball, the repo and the ISO of a deleted image
+	JobTypeImageArtifactsDelete = "image-artifacts-delete"
+	// JobTypeRetentionPolicyApply removes the old image versions of the image sets of a retention policy
+	JobTypeRetentionPolicyApply = "retention-policy-apply"
+)
+
+// IsDone tells if the job reached a final status
+func (j *Job) IsDone() bool {
+	return j.Status == JobStatusSuccess || j.Status == JobStatusFailed
+}
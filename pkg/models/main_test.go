@@ -40,6 +40,20 @@ func setUp() {
 		FDOUser{},
 		SSHKey{},
 		DeviceGroup{},
+		UpdateWave{},
+		MaintenanceWindow{},
+		DeviceGroupUpdate{},
+		DeviceGroupUpdateRejectedDevice{},
+		DispatchRecordAttempt{},
+		UpdateHook{},
+		Event{},
+		UpdateBundle{},
+		Job{},
+		UpdateRepoCache{},
+		ConcurrencySlot{},
+		RetentionPolicy{},
+		RetentionReport{},
+		RetentionReportImage{},
 	)
 	var testImage = Image{
 		Account:      "0000000",
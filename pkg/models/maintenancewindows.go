@@ -0,0 +1,172 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a recurring window of time during which the devices of a DeviceGroup can be updated
+// Schedule is a cron expression (minute hour day-of-month month day-of-week) of when the window opens
+// Timezone is the IANA timezone the schedule is evaluated in, UTC when empty
+// Duration is the number of minutes the window stays open, at least MinMaintenanceWindowDuration
+type MaintenanceWindow struct {
+	Model
+	Account       string `json:"Account" gorm:"index"`
+	DeviceGroupID uint   `json:"DeviceGroupID" gorm:"index"`
+	Schedule      string `json:"Schedule"`
+	Timezone      string `json:"Timezone"`
+	Duration      int    `json:"Duration"`
+}
+
+const (
+	// MaintenanceWindowScheduleInvalidMessage is the error message when the schedule is not a valid cron expression
+	MaintenanceWindowScheduleInvalidMessage = "schedule must be a cron expression with minute, hour, day of month, month and day of week fields"
+	// MaintenanceWindowTimezoneInvalidMessage is the error message when the timezone is unknown
+	MaintenanceWindowTimezoneInvalidMessage = "timezone must be a valid IANA timezone"
+	// MaintenanceWindowDurationInvalidMessage is the error message when the duration is out of range
+	MaintenanceWindowDurationInvalidMessage = "duration must be between 15 minutes and 7 days"
+
+	// MinMaintenanceWindowDuration is the shortest duration of a maintenance window, in minutes
+	// The held back devices are dispatched by a loop running every 5 minutes, a window has to stay open
+	// long enough for the loop to run at least once while it is open
+	MinMaintenanceWindowDuration = 15
+	// maxMaintenanceWindowDuration is the longest duration of a maintenance window, in minutes
+	maxMaintenanceWindowDuration = 7 * 24 * 60
+)
+
+// cronSchedule holds the values allowed by each field of a cron expression
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	anyDay      bool
+}
+
+// parseCronField parses a cron field made of comma separated values, ranges and steps
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:i]
+		}
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			}
+			if low < min || high > max || low > high {
+				return nil, fmt.Errorf("value %q out of range", part)
+			}
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// parseCronSchedule parses a cron expression with five fields
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, errors.New(MaintenanceWindowScheduleInvalidMessage)
+	}
+	var cron cronSchedule
+	var err error
+	if cron.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if cron.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if cron.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if cron.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if cron.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// both 0 and 7 are sunday
+	if cron.daysOfWeek[7] {
+		cron.daysOfWeek[0] = true
+	}
+	cron.anyDay = fields[2] == "*" || fields[4] == "*"
+	return &cron, nil
+}
+
+// matchesDay follows the cron convention, when both day fields are restricted either of them matches
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	if !c.months[int(t.Month())] {
+		return false
+	}
+	if c.anyDay {
+		return c.daysOfMonth[t.Day()] && c.daysOfWeek[int(t.Weekday())]
+	}
+	return c.daysOfMonth[t.Day()] || c.daysOfWeek[int(t.Weekday())]
+}
+
+func (c *cronSchedule) matches(t time.Time) bool {
+	return c.matchesDay(t) && c.hours[t.Hour()] && c.minutes[t.Minute()]
+}
+
+func (w *MaintenanceWindow) location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
+
+// ValidateRequest validates a MaintenanceWindow request
+func (w *MaintenanceWindow) ValidateRequest() error {
+	if _, err := parseCronSchedule(w.Schedule); err != nil {
+		return errors.New(MaintenanceWindowScheduleInvalidMessage)
+	}
+	if _, err := w.location(); err != nil {
+		return errors.New(MaintenanceWindowTimezoneInvalidMessage)
+	}
+	if w.Duration < MinMaintenanceWindowDuration || w.Duration > maxMaintenanceWindowDuration {
+		return errors.New(MaintenanceWindowDurationInvalidMessage)
+	}
+	return nil
+}
+
+// IsOpen returns whether the maintenance window is open at the given time
+func (w *MaintenanceWindow) IsOpen(t time.Time) (bool, error) {
+	cron, err := parseCronSchedule(w.Schedule)
+	if err != nil {
+		return false, err
+	}
+	location, err := w.location()
+	if err != nil {
+		return false, err
+	}
+	t = t.In(location).Truncate(time.Minute)
+	// look for a window opening that is still running
+	for i := 0; i < w.Duration; i++ {
+		if cron.matches(t.Add(-time.Duration(i) * time.Minute)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
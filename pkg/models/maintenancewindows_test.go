@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowValidateRequest(t *testing.T) {
+	testScenarios := []struct {
+		name     string
+		window   *MaintenanceWindow
+		expected error
+	}{
+		{name: "Empty schedule", window: &MaintenanceWindow{Duration: 60}, expected: errors.New(MaintenanceWindowScheduleInvalidMessage)},
+		{name: "Invalid schedule", window: &MaintenanceWindow{Schedule: "0 25 * * *", Duration: 60}, expected: errors.New(MaintenanceWindowScheduleInvalidMessage)},
+		{name: "Invalid timezone", window: &MaintenanceWindow{Schedule: "0 2 * * *", Timezone: "Mars/Olympus", Duration: 60}, expected: errors.New(MaintenanceWindowTimezoneInvalidMessage)},
+		{name: "Empty duration", window: &MaintenanceWindow{Schedule: "0 2 * * *"}, expected: errors.New(MaintenanceWindowDurationInvalidMessage)},
+		{name: "Duration shorter than the dispatch loop", window: &MaintenanceWindow{Schedule: "0 2 * * *", Duration: 5}, expected: errors.New(MaintenanceWindowDurationInvalidMessage)},
+		{name: "Valid maintenance window", window: &MaintenanceWindow{Schedule: "30 1-3 * * 1-5", Timezone: "America/New_York", Duration: 120}, expected: nil},
+	}
+
+	for _, testScenario := range testScenarios {
+		err := testScenario.window.ValidateRequest()
+		if err == nil && testScenario.expected != nil {
+			t.Errorf("Test %q was supposed to fail but passed successfully", testScenario.name)
+		}
+		if err != nil && testScenario.expected == nil {
+			t.Errorf("Test %q was supposed to pass but failed: %s", testScenario.name, err)
+		}
+		if err != nil && testScenario.expected != nil && err.Error() != testScenario.expected.Error() {
+			t.Errorf("Test %q: expected to fail on %q but got %q", testScenario.name, testScenario.expected, err)
+		}
+	}
+}
+
+func TestMaintenanceWindowIsOpen(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every weekday from 1am to 3am in New York
+	window := &MaintenanceWindow{Schedule: "0 1 * * 1-5", Timezone: "America/New_York", Duration: 120}
+
+	testScenarios := []struct {
+		name     string
+		time     time.Time
+		expected bool
+	}{
+		{name: "Window opening", time: time.Date(2022, 4, 5, 1, 0, 0, 0, newYork), expected: true},
+		{name: "Window running", time: time.Date(2022, 4, 5, 2, 59, 30, 0, newYork), expected: true},
+		{name: "Window closed", time: time.Date(2022, 4, 5, 3, 0, 0, 0, newYork), expected: false},
+		{name: "Window running in other timezone", time: time.Date(2022, 4, 5, 6, 30, 0, 0, time.UTC), expected: true},
+		{name: "Weekend", time: time.Date(2022, 4, 9, 1, 30, 0, 0, newYork), expected: false},
+	}
+
+	for _, testScenario := range testScenarios {
+		open, err := window.IsOpen(testScenario.time)
+		if err != nil {
+			t.Errorf("Test %q failed: %s", testScenario.name, err)
+		}
+		if open != testScenario.expected {
+			t.Errorf("Test %q: expected window open to be %t", testScenario.name, testScenario.expected)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "errors"
+
+// RetentionPolicy keeps the last KeepVersions successful versions of the image sets of an account, or of an image set
+// when ImageSetID is set, the policy of an image set replaces the one of its account
+// The older versions are removed with their artifacts, except the versions deployed on a device and their rollback targets
+// AppliedAt is when the policy was last applied, it is applied again once the retention policy interval elapsed
+type RetentionPolicy struct {
+	Model
+	Account      string      `json:"Account" gorm:"index"`
+	ImageSetID   *uint       `json:"ImageSetID,omitempty" gorm:"index"`
+	KeepVersions int         `json:"KeepVersions"`
+	AppliedAt    EdgeAPITime `json:"AppliedAt"`
+}
+
+// RetentionReport is the report of the versions of an image set removed by an application of a retention policy,
+// ProtectedVersions is the number of older versions kept as they are deployed, rollback targets or in an update in progress
+type RetentionReport struct {
+	Model
+	Account           string                 `json:"Account" gorm:"index"`
+	ImageSetID        uint                   `json:"ImageSetID" gorm:"index"`
+	RetentionPolicyID uint                   `json:"RetentionPolicyID"`
+	KeepVersions      int                    `json:"KeepVersions"`
+	ProtectedVersions int                    `json:"ProtectedVersions"`
+	RemovedImages     []RetentionReportImage `json:"RemovedImages"`
+}
+
+// RetentionReportImage is an image version removed by a retention policy
+type RetentionReportImage struct {
+	Model
+	RetentionReportID uint   `json:"RetentionReportID" gorm:"index"`
+	ImageID           uint   `json:"ImageID"`
+	Name              string `json:"Name"`
+	Version           int    `json:"Version"`
+	Status            string `json:"Status"`
+}
+
+const (
+	// RetentionPolicyKeepVersionsInvalidMessage is the error message when the number of versions to keep is invalid
+	RetentionPolicyKeepVersionsInvalidMessage = "the number of versions to keep must be between 1 and 1000"
+
+	// maxRetentionPolicyKeepVersions is the maximum number of versions a retention policy keeps
+	maxRetentionPolicyKeepVersions = 1000
+)
+
+// ValidateRequest validates a RetentionPolicy request
+func (p *RetentionPolicy) ValidateRequest() error {
+	if p.KeepVersions < 1 || p.KeepVersions > maxRetentionPolicyKeepVersions {
+		return errors.New(RetentionPolicyKeepVersionsInvalidMessage)
+	}
+	return nil
+}
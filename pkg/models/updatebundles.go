@@ -0,0 +1,47 @@
+package models
+
+// UpdateBundle is an offline update of the devices of the sites that can't reach the update repos
+// The bundle is a tarball of the ostree repo of the commit with the static deltas from the source commits,
+// a manifest, the checksums of its files and the script applying the update from local media
+// FilePath is the path of the tarball in the storage, Checksum its sha256 and Size its size in bytes
+type UpdateBundle struct {
+	Model
+	Account       string   `json:"Account" gorm:"index"`
+	CommitID      uint     `json:"CommitID"`
+	Commit        *Commit  `json:"Commit,omitempty"`
+	SourceCommits []Commit `json:"SourceCommits" gorm:"many2many:updatebundle_commits;"`
+	Status        string   `json:"Status"`
+	FilePath      string   `json:"-"`
+	Checksum      string   `json:"Checksum,omitempty"`
+	Size          int64    `json:"Size,omitempty"`
+}
+
+// UpdateBundleManifest is the manifest of an update bundle, Ref is the ostree ref of the commit
+// and SourceCommits the ostree commits the bundle has static deltas from
+type UpdateBundleManifest struct {
+	BundleID      uint     `json:"BundleID"`
+	Account       string   `json:"Account"`
+	Ref           string   `json:"Ref"`
+	Commit        string   `json:"Commit"`
+	SourceCommits []string `json:"SourceCommits"`
+	Signed        bool     `json:"Signed"`
+	CreatedAt     string   `json:"CreatedAt"`
+}
+
+// UpdateBundleDownload is the location an update bundle is downloaded from
+// URL is a pre-signed URL expiring at ExpiresAt, or the local path of the bundle without S3
+type UpdateBundleDownload struct {
+	URL       string      `json:"URL"`
+	ExpiresAt EdgeAPITime `json:"ExpiresAt,omitempty"`
+	Checksum  string      `json:"Checksum"`
+	Size      int64       `json:"Size"`
+}
+
+const (
+	// UpdateBundleStatusBuilding is for when an update bundle is being built
+	UpdateBundleStatusBuilding = "BUILDING"
+	// UpdateBundleStatusSuccess is for when an update bundle is ready to be downloaded
+	UpdateBundleStatusSuccess = "SUCCESS"
+	// UpdateBundleStatusError is for when an update bundle failed to be built
+	UpdateBundleStatusError = "ERROR"
+)
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// UpdateHook is a step of an image set or device group run on the devices around an update
+// The PRE_UPDATE hooks run before the update is applied, a failing one aborts the update of the device
+// The POST_UPDATE hooks run once the device booted the update and passed its health checks
+// Command is the shell command of the step, the hooks of a stage run in Position order
+type UpdateHook struct {
+	Model
+	Account       string `json:"Account" gorm:"index"`
+	ImageSetID    *uint  `json:"ImageSetID,omitempty" gorm:"index"`
+	DeviceGroupID *uint  `json:"DeviceGroupID,omitempty" gorm:"index"`
+	Stage         string `json:"Stage"`
+	Position      int    `json:"Position"`
+	Name          string `json:"Name"`
+	Command       string `json:"Command"`
+}
+
+// UpdateHooks are the hooks of an image set or device group by stage
+type UpdateHooks struct {
+	PreUpdate  []UpdateHook `json:"PreUpdate"`
+	PostUpdate []UpdateHook `json:"PostUpdate"`
+}
+
+const (
+	// UpdateHookStagePre is the stage of the hooks run before the update is applied
+	UpdateHookStagePre = "PRE_UPDATE"
+	// UpdateHookStagePost is the stage of the hooks run after the device booted the update
+	UpdateHookStagePost = "POST_UPDATE"
+
+	// UpdateHooksTooManyMessage is the error message when a stage has too many hooks
+	UpdateHooksTooManyMessage = "a stage can't have more than 20 hooks"
+	// UpdateHookNameInvalidMessage is the error message when the name of a hook is invalid
+	UpdateHookNameInvalidMessage = "hook name must be a single line of at most 100 characters"
+	// UpdateHookCommandInvalidMessage is the error message when the command of a hook is invalid
+	UpdateHookCommandInvalidMessage = "hook command can't be empty or longer than 4096 characters"
+
+	// maxUpdateHooks is the maximum number of hooks of a stage
+	maxUpdateHooks = 20
+	// maxUpdateHookNameLength is the maximum length of the name of a hook
+	maxUpdateHookNameLength = 100
+	// maxUpdateHookCommandLength is the maximum length of the command of a hook
+	maxUpdateHookCommandLength = 4096
+)
+
+// validateUpdateHooks validates the hooks of a stage
+func validateUpdateHooks(hooks []UpdateHook) error {
+	if len(hooks) > maxUpdateHooks {
+		return errors.New(UpdateHooksTooManyMessage)
+	}
+	for _, hook := range hooks {
+		if strings.TrimSpace(hook.Name) == "" || len(hook.Name) > maxUpdateHookNameLength || strings.ContainsAny(hook.Name, "\r\n") {
+			return errors.New(UpdateHookNameInvalidMessage)
+		}
+		if strings.TrimSpace(hook.Command) == "" || len(hook.Command) > maxUpdateHookCommandLength || strings.ContainsRune(hook.Command, 0) {
+			return errors.New(UpdateHookCommandInvalidMessage)
+		}
+	}
+	return nil
+}
+
+// ValidateRequest validates an UpdateHooks request
+func (h *UpdateHooks) ValidateRequest() error {
+	if err := validateUpdateHooks(h.PreUpdate); err != nil {
+		return err
+	}
+	return validateUpdateHooks(h.PostUpdate)
+}
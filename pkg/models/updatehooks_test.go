@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateHooksValidateRequest(t *testing.T) {
+	tooManyHooks := make([]UpdateHook, maxUpdateHooks+1)
+	for i := range tooManyHooks {
+		tooManyHooks[i] = UpdateHook{Name: "hook", Command: "true"}
+	}
+	testScenarios := []struct {
+		name     string
+		hooks    *UpdateHooks
+		expected error
+	}{
+		{name: "Empty hooks", hooks: &UpdateHooks{}, expected: nil},
+		{name: "Too many hooks", hooks: &UpdateHooks{PostUpdate: tooManyHooks}, expected: errors.New(UpdateHooksTooManyMessage)},
+		{name: "Empty name", hooks: &UpdateHooks{PreUpdate: []UpdateHook{{Name: " ", Command: "true"}}}, expected: errors.New(UpdateHookNameInvalidMessage)},
+		{name: "Multiline name", hooks: &UpdateHooks{PreUpdate: []UpdateHook{{Name: "stop\nbridge", Command: "true"}}}, expected: errors.New(UpdateHookNameInvalidMessage)},
+		{name: "Empty command", hooks: &UpdateHooks{PostUpdate: []UpdateHook{{Name: "smoke test"}}}, expected: errors.New(UpdateHookCommandInvalidMessage)},
+		{name: "Too long command", hooks: &UpdateHooks{PostUpdate: []UpdateHook{{Name: "smoke test", Command: strings.Repeat("a", maxUpdateHookCommandLength+1)}}}, expected: errors.New(UpdateHookCommandInvalidMessage)},
+		{name: "Valid hooks", hooks: &UpdateHooks{
+			PreUpdate:  []UpdateHook{{Name: "stop bridge", Command: "systemctl stop plc-bridge.service"}},
+			PostUpdate: []UpdateHook{{Name: "smoke test", Command: "curl -sf http://localhost:8080/health\nsystemctl start plc-bridge.service"}},
+		}, expected: nil},
+	}
+
+	for _, testScenario := range testScenarios {
+		err := testScenario.hooks.ValidateRequest()
+		if err == nil && testScenario.expected != nil {
+			t.Errorf("Test %q was supposed to fail but passed successfully", testScenario.name)
+		}
+		if err != nil && testScenario.expected == nil {
+			t.Errorf("Test %q was supposed to pass but failed: %s", testScenario.name, err)
+		}
+		if err != nil && testScenario.expected != nil && err.Error() != testScenario.expected.Error() {
+			t.Errorf("Test %q: expected to fail on %q but got %q", testScenario.name, testScenario.expected, err)
+		}
+	}
+}
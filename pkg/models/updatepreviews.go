@@ -0,0 +1,38 @@
+package models
+
+// UpdatePreview is the dry run of an update of a set of devices to a commit
+// Nothing is created, it tells which devices would be updated and what would be downloaded
+// EstimatedDownloadSize is the sum of the estimated download sizes of the updated devices, in bytes
+type UpdatePreview struct {
+	CommitID              uint                  `json:"CommitID"`
+	Devices               []UpdatePreviewDevice `json:"Devices"`
+	DevicesCount          int                   `json:"DevicesCount"`
+	UpdatedCount          int                   `json:"UpdatedCount"`
+	SkippedCount          int                   `json:"SkippedCount"`
+	EstimatedDownloadSize int64                 `json:"EstimatedDownloadSize"`
+}
+
+// UpdatePreviewDevice is what the update of a device would do
+// CurrentCommit is the booted ostree commit of the device, StaticDelta is set when a static delta
+// would be generated from it, that is when the booted commit is a known commit of the account
+// SkipReason tells why a skipped device would not be updated
+// EstimatedDownloadSize is the size of the commit tarball, reduced to the share of changed packages
+// when a static delta is generated, 0 when the size of the commit is unknown
+type UpdatePreviewDevice struct {
+	DeviceUUID            string       `json:"DeviceUUID"`
+	DeviceName            string       `json:"DeviceName"`
+	CurrentCommit         string       `json:"CurrentCommit"`
+	CurrentImageID        uint         `json:"CurrentImageID,omitempty"`
+	StaticDelta           bool         `json:"StaticDelta"`
+	PackageDiff           *PackageDiff `json:"PackageDiff,omitempty"`
+	Skipped               bool         `json:"Skipped"`
+	SkipReason            string       `json:"SkipReason,omitempty"`
+	EstimatedDownloadSize int64        `json:"EstimatedDownloadSize"`
+}
+
+const (
+	// UpdatePreviewSkippedNotFound is the reason a device unknown to edge management is skipped
+	UpdatePreviewSkippedNotFound = "device was not found"
+	// UpdatePreviewSkippedDisconnected is the reason a device that can't be reached by the playbook dispatcher is skipped
+	UpdatePreviewSkippedDisconnected = "device is disconnected"
+)
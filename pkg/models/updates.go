@@ -2,6 +2,9 @@ package models
 
 import (
 	"errors"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
 // UpdateTransaction represents the combination of an OSTree commit and a set of Inventory
@@ -24,6 +27,58 @@ type UpdateTransaction struct {
 	RepoID          uint             `json:"RepoID"`
 	Repo            *Repo            `json:"Repo"`
 	DispatchRecords []DispatchRecord `gorm:"many2many:updatetransaction_dispatchrecords;" json:"DispatchRecords"`
+	// SuccessThreshold is the percentage of devices of a wave that needs to be updated
+	// successfully before the next wave of a staged rollout is dispatched, 100 when not set
+	SuccessThreshold int `json:"SuccessThreshold"`
+	// FailureThreshold is the percentage of failed devices of a wave that pauses a staged rollout
+	// before the wave ends, 100 when not set
+	FailureThreshold int          `json:"FailureThreshold"`
+	Waves            []UpdateWave `json:"Waves"`
+	// NotBefore is the time before which no device is dispatched
+	NotBefore EdgeAPITime `json:"NotBefore"`
+	// SchedulePolicy defines when the devices are dispatched, NEXT_WINDOW holds every device
+	// until a maintenance window of one of its device groups is open
+	SchedulePolicy string `json:"SchedulePolicy"`
+	// DeviceGroupUpdateID is the DeviceGroupUpdate the update transaction is part of, if any
+	DeviceGroupUpdateID *uint `json:"DeviceGroupUpdateID,omitempty" gorm:"index"`
+	// Kind is UPDATE when the devices are updated to a newer commit, ROLLBACK when they are
+	// rolled back to the commit of the previous image of their image set
+	Kind string `json:"Kind"`
+	// HoldsRepoCache is set while the update counts in the ReferenceCount of the UpdateRepoCache of its repo
+	HoldsRepoCache bool `json:"-"`
+
+	previousStatus string // status stored before the save, used to record its transitions
+}
+
+// BeforeSave is called before saving an update transaction, loads its stored status to record its transitions
+func (u *UpdateTransaction) BeforeSave(tx *gorm.DB) error {
+	return loadPreviousStatus(tx, &UpdateTransaction{}, u.ID, &u.previousStatus)
+}
+
+// AfterSave is called after saving an update transaction, records its status transition as an event
+func (u *UpdateTransaction) AfterSave(tx *gorm.DB) error {
+	return recordStatusEvent(tx, EventResourceUpdateTransaction, u.ID, u.Account, &u.previousStatus, u.Status)
+}
+
+// UpdateWave represents a stage of a staged (canary) rollout of an UpdateTransaction
+// The devices of a wave are only dispatched after the previous wave
+// has reached the success threshold of the UpdateTransaction.
+type UpdateWave struct {
+	Model
+	UpdateTransactionID uint             `gorm:"index" json:"UpdateTransactionID"`
+	Position            int              `json:"Position"`
+	DevicesCount        int              `json:"DevicesCount"`
+	Status              string           `json:"Status"`
+	DispatchRecords     []DispatchRecord `json:"-"`
+}
+
+// UpdateRolloutPlan represents the staged rollout requested for an update
+// Waves holds the percentage of the devices of the update targeted by each wave,
+// the devices left out by the percentages are dispatched on a last wave.
+type UpdateRolloutPlan struct {
+	Waves            []int `json:"Waves"`
+	SuccessThreshold int   `json:"SuccessThreshold"`
+	FailureThreshold int   `json:"FailureThreshold"`
 }
 
 // DispatchRecord represents the combination of a Playbook Dispatcher (https://github.com/RedHatInsights/playbook-dispatcher),
@@ -36,20 +91,122 @@ type DispatchRecord struct {
 	Device               *Device `json:"Device"`
 	Status               string  `json:"Status"`
 	PlaybookDispatcherID string  `json:"PlaybookDispatcherID"`
+	UpdateWaveID         *uint   `json:"UpdateWaveID,omitempty"`
+	// Attempts is the number of times the playbook was dispatched to the device,
+	// the outcome of the previous attempts is kept in AttemptHistory
+	Attempts       int                     `json:"Attempts"`
+	AttemptHistory []DispatchRecordAttempt `json:"AttemptHistory,omitempty"`
+	// RebootDeadline is when a REBOOTING record fails if the device still hasn't booted the update commit
+	RebootDeadline EdgeAPITime `json:"RebootDeadline,omitempty"`
+	// DispatchedAt is when the playbook was last sent to the device, a greenboot fallback only rolls back
+	// the update when the device booted after it
+	DispatchedAt EdgeAPITime `json:"DispatchedAt,omitempty"`
+
+	previousStatus string // status stored before the save, used to record its transitions
+}
+
+// BeforeSave is called before saving a dispatch record, loads its stored status to record its transitions
+func (d *DispatchRecord) BeforeSave(tx *gorm.DB) error {
+	return loadPreviousStatus(tx, &DispatchRecord{}, d.ID, &d.previousStatus)
+}
+
+// AfterSave is called after saving a dispatch record, records its status transition as an event
+func (d *DispatchRecord) AfterSave(tx *gorm.DB) error {
+	return recordStatusEvent(tx, EventResourceDispatchRecord, d.ID, "", &d.previousStatus, d.Status)
+}
+
+// DispatchRecordAttempt is a previous attempt of dispatching the update playbook to the device of a DispatchRecord
+type DispatchRecordAttempt struct {
+	Model
+	DispatchRecordID     uint   `gorm:"index" json:"DispatchRecordID"`
+	Attempt              int    `json:"Attempt"`
+	Status               string `json:"Status"`
+	PlaybookDispatcherID string `json:"PlaybookDispatcherID"`
+}
+
+// UpdateRepoCache is an update repo built once for a commit of an account and shared by the update
+// transactions to this commit, it has the static deltas to the commit from all its FromCommits
+// ReferenceCount is the number of update transactions using the repo, it is removed with the artifacts
+// of the image of its commit once none does
+// Version is increased every time static deltas are added to the repo, a workspace of the repo is only
+// extended when it has the latest version
+// ExtendingOwner leases the repo until ExtendingUntil while it adds the missing static deltas of its update,
+// another update can extend the repo once the lease expired
+// SigningKeyID is the ID of the key the commit of the repo is signed with, empty when it is not signed, the repo
+// is only used by the updates of an account still signing with this key
+type UpdateRepoCache struct {
+	Model
+	Account        string      `json:"Account" gorm:"index:idx_update_repo_caches_commit"`
+	CommitID       uint        `json:"CommitID" gorm:"index:idx_update_repo_caches_commit"`
+	FromCommits    []Commit    `gorm:"many2many:updaterepocache_commits;" json:"FromCommits"`
+	RepoID         uint        `json:"RepoID"`
+	Repo           *Repo       `json:"Repo"`
+	ReferenceCount int         `json:"ReferenceCount"`
+	Version        int         `json:"Version"`
+	ExtendingOwner string      `json:"ExtendingOwner,omitempty"`
+	ExtendingUntil EdgeAPITime `json:"ExtendingUntil,omitempty"`
+	SigningKeyID   string      `json:"SigningKeyID,omitempty"`
 }
 
 const (
 	// DevicesCantBeEmptyMessage is the error message when the hosts are empty
 	DevicesCantBeEmptyMessage = "devices can not be empty"
+	// RolloutWavesCantBeEmptyMessage is the error message when a rollout plan has no waves
+	RolloutWavesCantBeEmptyMessage = "rollout waves can not be empty"
+	// RolloutWavePercentageMessage is the error message when a rollout wave percentage is out of range
+	RolloutWavePercentageMessage = "rollout waves must target between 1 and 100 percent of the devices"
+	// RolloutWavesTotalMessage is the error message when rollout waves target more than all the devices
+	RolloutWavesTotalMessage = "rollout waves can not target more than 100 percent of the devices"
+	// RolloutThresholdMessage is the error message when a rollout threshold is out of range
+	RolloutThresholdMessage = "rollout thresholds must be between 0 and 100"
+	// UpdateSchedulePolicyInvalidMessage is the error message when the schedule policy is unknown
+	UpdateSchedulePolicyInvalidMessage = "schedule policy must be \"IMMEDIATE\" or \"NEXT_WINDOW\""
+	// UpdateTagInvalidMessage is the error message when the tag is not in the namespace/key=value format
+	UpdateTagInvalidMessage = "tag must be in the namespace/key=value format"
 
 	// UpdateStatusCreated is for when a update is created
 	UpdateStatusCreated = "CREATED"
+	// UpdateStatusQueued is for when a update waits for its account to have less update builds or rollouts running
+	UpdateStatusQueued = "QUEUED"
 	// UpdateStatusBuilding is for when a update is building
 	UpdateStatusBuilding = "BUILDING"
 	// UpdateStatusError is for when a update is on a error state
 	UpdateStatusError = "ERROR"
 	// UpdateStatusSuccess is for when a update is available to the user
 	UpdateStatusSuccess = "SUCCESS"
+	// UpdateStatusPaused is for when a staged rollout was paused because a wave exceeded the failure threshold
+	UpdateStatusPaused = "PAUSED"
+	// UpdateStatusCancelled is for when a update was cancelled by the user
+	UpdateStatusCancelled = "CANCELLED"
+	// UpdateStatusRolledBack is for when greenboot rolled back a device of the update to its previous deployment
+	UpdateStatusRolledBack = "ROLLED_BACK"
+)
+
+const (
+	// UpdateKindUpdate is for when the devices are updated to a newer commit
+	UpdateKindUpdate = "UPDATE"
+	// UpdateKindRollback is for when the devices are rolled back to a previous commit
+	UpdateKindRollback = "ROLLBACK"
+)
+
+const (
+	// UpdateSchedulePolicyImmediate is for when the devices are dispatched as soon as the update is built
+	UpdateSchedulePolicyImmediate = "IMMEDIATE"
+	// UpdateSchedulePolicyNextWindow is for when the devices are dispatched on the next maintenance window of their groups
+	UpdateSchedulePolicyNextWindow = "NEXT_WINDOW"
+)
+
+const (
+	// UpdateWaveStatusPending is for when a wave is waiting for the previous wave to succeed
+	UpdateWaveStatusPending = "PENDING"
+	// UpdateWaveStatusRunning is for when the devices of a wave were dispatched
+	UpdateWaveStatusRunning = "RUNNING"
+	// UpdateWaveStatusSuccess is for when a wave has reached the success threshold
+	UpdateWaveStatusSuccess = "SUCCESS"
+	// UpdateWaveStatusFailed is for when a wave has exceeded the failure threshold or ended below the success threshold
+	UpdateWaveStatusFailed = "FAILED"
+	// UpdateWaveStatusResumed is for when the rollout was resumed after the wave failed
+	UpdateWaveStatusResumed = "RESUMED"
 )
 
 const (
@@ -62,10 +219,25 @@ const (
 	DispatchRecordStatusRunning = "RUNNING"
 	// DispatchRecordStatusError is for when a playbook dispatcher job is in a error state
 	DispatchRecordStatusError = "ERROR"
-	// DispatchRecordStatusComplete is for when a playbook dispatcher job is complete
+	// DispatchRecordStatusRebooting is for when a playbook dispatcher job is complete and the device is expected to boot the update commit
+	DispatchRecordStatusRebooting = "REBOOTING"
+	// DispatchRecordStatusComplete is for when the device booted the update commit
 	DispatchRecordStatusComplete = "COMPLETE"
+	// DispatchRecordStatusCancelled is for when the update was cancelled before the playbook dispatcher job was sent
+	DispatchRecordStatusCancelled = "CANCELLED"
+	// DispatchRecordStatusRolledBack is for when greenboot rolled the device back to its previous deployment after the update
+	DispatchRecordStatusRolledBack = "ROLLED_BACK"
 )
 
+// IsFinalDispatchRecordStatus returns whether a dispatch record with the given status is done with its device
+func IsFinalDispatchRecordStatus(status string) bool {
+	switch status {
+	case DispatchRecordStatusComplete, DispatchRecordStatusError, DispatchRecordStatusCancelled, DispatchRecordStatusRolledBack:
+		return true
+	}
+	return false
+}
+
 // ValidateRequest validates a Update Record Request
 func (ur *UpdateTransaction) ValidateRequest() error {
 	if ur.Devices == nil || len(ur.Devices) == 0 {
@@ -73,3 +245,64 @@ func (ur *UpdateTransaction) ValidateRequest() error {
 	}
 	return nil
 }
+
+// ValidateSchedulePolicy validates the schedule policy of an update
+func ValidateSchedulePolicy(policy string) error {
+	if policy != "" && policy != UpdateSchedulePolicyImmediate && policy != UpdateSchedulePolicyNextWindow {
+		return errors.New(UpdateSchedulePolicyInvalidMessage)
+	}
+	return nil
+}
+
+// ValidateUpdateTag validates the inventory tag of an update, the value of the tag is optional
+func ValidateUpdateTag(tag string) error {
+	namespaceAndKey := strings.SplitN(tag, "=", 2)[0]
+	parts := strings.SplitN(namespaceAndKey, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.New(UpdateTagInvalidMessage)
+	}
+	return nil
+}
+
+// ValidateRequest validates a Update Rollout Plan Request
+func (p *UpdateRolloutPlan) ValidateRequest() error {
+	if len(p.Waves) == 0 {
+		return errors.New(RolloutWavesCantBeEmptyMessage)
+	}
+	total := 0
+	for _, percentage := range p.Waves {
+		if percentage < 1 || percentage > 100 {
+			return errors.New(RolloutWavePercentageMessage)
+		}
+		total += percentage
+	}
+	if total > 100 {
+		return errors.New(RolloutWavesTotalMessage)
+	}
+	if p.SuccessThreshold < 0 || p.SuccessThreshold > 100 || p.FailureThreshold < 0 || p.FailureThreshold > 100 {
+		return errors.New(RolloutThresholdMessage)
+	}
+	return nil
+}
+
+// BuildWaves splits the given number of devices in the waves of the rollout plan
+// Every wave gets at least one device and empty waves are dropped.
+func (p *UpdateRolloutPlan) BuildWaves(devicesCount int) []UpdateWave {
+	waves := make([]UpdateWave, 0, len(p.Waves)+1)
+	remaining := devicesCount
+	for _, percentage := range p.Waves {
+		if remaining == 0 {
+			break
+		}
+		count := (devicesCount*percentage + 99) / 100
+		if count > remaining {
+			count = remaining
+		}
+		remaining -= count
+		waves = append(waves, UpdateWave{Position: len(waves) + 1, DevicesCount: count, Status: UpdateWaveStatusPending})
+	}
+	if remaining > 0 {
+		waves = append(waves, UpdateWave{Position: len(waves) + 1, DevicesCount: remaining, Status: UpdateWaveStatusPending})
+	}
+	return waves
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateRolloutPlanValidateRequest(t *testing.T) {
+	testScenarios := []struct {
+		name     string
+		plan     *UpdateRolloutPlan
+		expected error
+	}{
+		{name: "Empty waves", plan: &UpdateRolloutPlan{}, expected: errors.New(RolloutWavesCantBeEmptyMessage)},
+		{name: "Empty wave", plan: &UpdateRolloutPlan{Waves: []int{0, 100}}, expected: errors.New(RolloutWavePercentageMessage)},
+		{name: "Too many devices", plan: &UpdateRolloutPlan{Waves: []int{50, 60}}, expected: errors.New(RolloutWavesTotalMessage)},
+		{name: "Invalid success threshold", plan: &UpdateRolloutPlan{Waves: []int{10}, SuccessThreshold: 101}, expected: errors.New(RolloutThresholdMessage)},
+		{name: "Invalid failure threshold", plan: &UpdateRolloutPlan{Waves: []int{10}, FailureThreshold: -1}, expected: errors.New(RolloutThresholdMessage)},
+		{name: "Valid rollout plan", plan: &UpdateRolloutPlan{Waves: []int{10, 40}, SuccessThreshold: 90, FailureThreshold: 5}, expected: nil},
+	}
+
+	for _, testScenario := range testScenarios {
+		err := testScenario.plan.ValidateRequest()
+		if err == nil && testScenario.expected != nil {
+			t.Errorf("Test %q was supposed to fail but passed successfully", testScenario.name)
+		}
+		if err != nil && testScenario.expected == nil {
+			t.Errorf("Test %q was supposed to pass but failed: %s", testScenario.name, err)
+		}
+		if err != nil && testScenario.expected != nil && err.Error() != testScenario.expected.Error() {
+			t.Errorf("Test %q: expected to fail on %q but got %q", testScenario.name, testScenario.expected, err)
+		}
+	}
+}
+
+func TestUpdateRolloutPlanBuildWaves(t *testing.T) {
+	testScenarios := []struct {
+		name         string
+		plan         *UpdateRolloutPlan
+		devicesCount int
+		expected     []int
+	}{
+		{name: "Canary and remaining devices", plan: &UpdateRolloutPlan{Waves: []int{10}}, devicesCount: 20, expected: []int{2, 18}},
+		{name: "Rounds up the waves", plan: &UpdateRolloutPlan{Waves: []int{10, 50, 40}}, devicesCount: 5, expected: []int{1, 3, 1}},
+		{name: "Drops empty waves", plan: &UpdateRolloutPlan{Waves: []int{50, 50}}, devicesCount: 1, expected: []int{1}},
+	}
+
+	for _, testScenario := range testScenarios {
+		waves := testScenario.plan.BuildWaves(testScenario.devicesCount)
+		if len(waves) != len(testScenario.expected) {
+			t.Errorf("Test %q: expected %d waves but got %d", testScenario.name, len(testScenario.expected), len(waves))
+			continue
+		}
+		for i, wave := range waves {
+			if wave.Position != i+1 || wave.DevicesCount != testScenario.expected[i] || wave.Status != UpdateWaveStatusPending {
+				t.Errorf("Test %q: unexpected wave %d: %+v", testScenario.name, i, wave)
+			}
+		}
+	}
+}
+
+func TestValidateUpdateTag(t *testing.T) {
+	testScenarios := []struct {
+		name     string
+		tag      string
+		expected error
+	}{
+		{name: "Empty tag", tag: "", expected: errors.New(UpdateTagInvalidMessage)},
+		{name: "Missing namespace", tag: "location=boston", expected: errors.New(UpdateTagInvalidMessage)},
+		{name: "Missing key", tag: "insights-client/=boston", expected: errors.New(UpdateTagInvalidMessage)},
+		{name: "Tag without value", tag: "insights-client/edge", expected: nil},
+		{name: "Valid tag", tag: "insights-client/location=boston", expected: nil},
+	}
+
+	for _, testScenario := range testScenarios {
+		err := ValidateUpdateTag(testScenario.tag)
+		if err == nil && testScenario.expected != nil {
+			t.Errorf("Test %q was supposed to fail but passed successfully", testScenario.name)
+		}
+		if err != nil && testScenario.expected == nil {
+			t.Errorf("Test %q was supposed to pass but failed: %s", testScenario.name, err)
+		}
+	}
+}
@@ -22,7 +22,7 @@ func setUp() {
 	dbName = fmt.Sprintf("%d-routes-common.db", time)
 	config.Get().Database.Name = dbName
 	db.InitDB()
-	db.DB.AutoMigrate(&models.Image{})
+	db.DB.AutoMigrate(&models.Image{}, &models.Event{})
 	images := []models.Image{
 		{
 			Name:         "Motion Sensor 1",
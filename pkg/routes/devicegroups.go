@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -59,6 +60,13 @@ func MakeDeviceGroupsRouter(sub chi.Router) {
 			d.Use(DeviceGroupDeviceCtx)
 			d.Delete("/", DeleteDeviceGroupOneDevice)
 		})
+		r.Post("/maintenance-windows", AddDeviceGroupMaintenanceWindow)
+		r.Delete("/maintenance-windows/{WINDOW_ID}", DeleteDeviceGroupMaintenanceWindow)
+		r.Get("/update-hooks", GetDeviceGroupUpdateHooks)
+		r.Put("/update-hooks", SetDeviceGroupUpdateHooks)
+		r.Post("/updates", CreateDeviceGroupUpdate)
+		r.Get("/updates/{UPDATE_ID}", GetDeviceGroupUpdateByID)
+		r.Post("/rollback", CreateDeviceGroupRollback)
 	})
 }
 
@@ -570,6 +578,141 @@ func DeleteDeviceGroupOneDevice(w http.ResponseWriter, r *http.Request) {
 	respondWithJSONBody(w, ctxServices.Log, contextDeviceGroupDevice)
 }
 
+// AddDeviceGroupMaintenanceWindow adds a maintenance window to a device group
+func AddDeviceGroupMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	var maintenanceWindow models.MaintenanceWindow
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &maintenanceWindow); err != nil {
+		return
+	}
+	if err := maintenanceWindow.ValidateRequest(); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Info("Error validation request from maintenance window")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	windowAdded, err := ctxServices.DeviceGroupsService.AddDeviceGroupMaintenanceWindow(contextDeviceGroup.Account, contextDeviceGroup.ID, &maintenanceWindow)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when adding deviceGroup maintenance window")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined:
+			apiError = errors.NewBadRequest(err.Error())
+		case *services.DeviceGroupNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, windowAdded)
+}
+
+// DeleteDeviceGroupMaintenanceWindow deletes a maintenance window from a device group
+func DeleteDeviceGroupMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	windowID, err := strconv.ParseUint(chi.URLParam(r, "WINDOW_ID"), 10, 32)
+	if err != nil {
+		ctxServices.Log.Debug("maintenance window ID is not an integer")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	err = ctxServices.DeviceGroupsService.DeleteDeviceGroupMaintenanceWindow(contextDeviceGroup.Account, contextDeviceGroup.ID, uint(windowID))
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when removing deviceGroup maintenance window")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined:
+			apiError = errors.NewBadRequest(err.Error())
+		case *services.MaintenanceWindowNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, map[string]interface{}{"message": "Maintenance window deleted"})
+}
+
+// GetDeviceGroupUpdateHooks returns the update hooks of a device group
+func GetDeviceGroupUpdateHooks(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	hooks, err := ctxServices.DeviceGroupsService.GetDeviceGroupUpdateHooks(contextDeviceGroup.Account, contextDeviceGroup.ID)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when getting deviceGroup update hooks")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined:
+			apiError = errors.NewBadRequest(err.Error())
+		case *services.DeviceGroupNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, hooks)
+}
+
+// SetDeviceGroupUpdateHooks replaces the update hooks of a device group
+func SetDeviceGroupUpdateHooks(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	var hooks models.UpdateHooks
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &hooks); err != nil {
+		return
+	}
+	if err := hooks.ValidateRequest(); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Info("Error validation request from update hooks")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	hooksSet, err := ctxServices.DeviceGroupsService.SetDeviceGroupUpdateHooks(contextDeviceGroup.Account, contextDeviceGroup.ID, &hooks)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when setting deviceGroup update hooks")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined:
+			apiError = errors.NewBadRequest(err.Error())
+		case *services.DeviceGroupNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, hooksSet)
+}
+
 // CheckGroupName validates if a group name exists on an account
 func CheckGroupName(w http.ResponseWriter, r *http.Request) {
 	services := dependencies.ServicesFromContext(r.Context())
@@ -593,3 +736,127 @@ func CheckGroupName(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSONBody(w, services.Log, map[string]interface{}{"data": map[string]interface{}{"isValid": value}})
 }
+
+// DeviceGroupUpdateRequest is the update requested for all the devices of a device group
+// CommitID is optional, by default the devices are updated to the latest image of their image set
+type DeviceGroupUpdateRequest struct {
+	CommitID uint `json:"CommitID,omitempty"`
+}
+
+// CreateDeviceGroupUpdate updates all the devices of a device group
+func CreateDeviceGroupUpdate(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	// the request has no body when the devices are updated to the latest image of their image set
+	var updateRequest DeviceGroupUpdateRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil && err != io.EOF {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error parsing json from device group update request")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("invalid JSON request"))
+		return
+	}
+
+	groupUpdate, err := ctxServices.UpdateService.CreateDeviceGroupUpdate(contextDeviceGroup, updateRequest.CommitID)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when creating deviceGroup update")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined, *services.DeviceGroupDevicesNotFound:
+			apiError = errors.NewBadRequest(err.Error())
+		case *services.CommitNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	for i := range groupUpdate.UpdateTransactions {
+		update := &groupUpdate.UpdateTransactions[i]
+		if _, err := ctxServices.UpdateService.SendDeviceNotification(update); err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error to send notification")
+		}
+		ctxServices.Log.WithField("updateID", update.ID).Info("Starting asynchronous update process")
+		if _, err := ctxServices.JobService.Enqueue(models.JobTypeUpdateBuild, update.Account, update.ID); err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error enqueuing update build")
+			respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+			return
+		}
+	}
+	groupUpdate.ComputeProgress()
+
+	respondWithJSONBody(w, ctxServices.Log, groupUpdate)
+}
+
+// CreateDeviceGroupRollback rolls back the devices of a device group to the previous image of their image set
+// The rollback is tracked as a device group update that can be retrieved like the device group updates
+func CreateDeviceGroupRollback(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	groupRollback, err := ctxServices.UpdateService.CreateDeviceGroupRollback(contextDeviceGroup)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when creating deviceGroup rollback")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupAccountOrIDUndefined, *services.DeviceGroupDevicesNotFound:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	for i := range groupRollback.UpdateTransactions {
+		update := &groupRollback.UpdateTransactions[i]
+		ctxServices.Log.WithField("updateID", update.ID).Info("Starting asynchronous rollback process")
+		if _, err := ctxServices.JobService.Enqueue(models.JobTypeUpdateBuild, update.Account, update.ID); err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error enqueuing rollback build")
+			respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+			return
+		}
+	}
+	groupRollback.ComputeProgress()
+
+	respondWithJSONBody(w, ctxServices.Log, groupRollback)
+}
+
+// GetDeviceGroupUpdateByID returns a device group update with its aggregated progress
+func GetDeviceGroupUpdateByID(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	contextDeviceGroup := getContextDeviceGroup(w, r)
+	if contextDeviceGroup == nil {
+		return
+	}
+
+	updateID, err := strconv.ParseUint(chi.URLParam(r, "UPDATE_ID"), 10, 32)
+	if err != nil {
+		ctxServices.Log.Debug("device group update ID is not an integer")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	groupUpdate, err := ctxServices.UpdateService.GetDeviceGroupUpdateByID(contextDeviceGroup.Account, contextDeviceGroup.ID, uint(updateID))
+	if err != nil {
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceGroupUpdateNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, groupUpdate)
+}
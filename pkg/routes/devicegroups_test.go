@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 
 	"github.com/bxcodec/faker/v3"
+	"github.com/go-chi/chi"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/redhatinsights/edge-api/config"
@@ -555,4 +556,240 @@ var _ = Describe("DeviceGroup routes", func() {
 			})
 		})
 	})
+	Context("adding maintenance windows to DeviceGroup", func() {
+		deviceGroup := &models.DeviceGroup{
+			Name:    faker.Name(),
+			Type:    models.DeviceGroupTypeDefault,
+			Account: common.DefaultAccount,
+		}
+		When("all is valid", func() {
+			It("should add the maintenance window", func() {
+				window := models.MaintenanceWindow{Schedule: "0 2 * * 1-5", Timezone: "Europe/Paris", Duration: 120}
+				jsonWindowBytes, err := json.Marshal(window)
+				Expect(err).To(BeNil())
+				url := fmt.Sprintf("/%d/maintenance-windows", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonWindowBytes))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				mockDeviceGroupsService.EXPECT().AddDeviceGroupMaintenanceWindow(deviceGroup.Account, deviceGroup.ID, gomock.Any()).Return(&window, nil)
+				handler := http.HandlerFunc(AddDeviceGroupMaintenanceWindow)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		When("sending an invalid schedule", func() {
+			It("should return status code 400", func() {
+				window := models.MaintenanceWindow{Schedule: "every night", Duration: 120}
+				jsonWindowBytes, err := json.Marshal(window)
+				Expect(err).To(BeNil())
+				url := fmt.Sprintf("/%d/maintenance-windows", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonWindowBytes))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				handler := http.HandlerFunc(AddDeviceGroupMaintenanceWindow)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+	Context("setting DeviceGroup update hooks", func() {
+		deviceGroup := &models.DeviceGroup{
+			Name:    faker.Name(),
+			Type:    models.DeviceGroupTypeDefault,
+			Account: common.DefaultAccount,
+		}
+		When("all is valid", func() {
+			It("should set the update hooks", func() {
+				hooks := models.UpdateHooks{
+					PreUpdate:  []models.UpdateHook{{Name: "stop bridge", Command: "systemctl stop plc-bridge.service"}},
+					PostUpdate: []models.UpdateHook{{Name: "start bridge", Command: "systemctl start plc-bridge.service"}},
+				}
+				jsonHooksBytes, err := json.Marshal(hooks)
+				Expect(err).To(BeNil())
+				url := fmt.Sprintf("/%d/update-hooks", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonHooksBytes))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				mockDeviceGroupsService.EXPECT().SetDeviceGroupUpdateHooks(deviceGroup.Account, deviceGroup.ID, gomock.Any()).Return(&hooks, nil)
+				handler := http.HandlerFunc(SetDeviceGroupUpdateHooks)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		When("sending a hook without command", func() {
+			It("should return status code 400", func() {
+				hooks := models.UpdateHooks{PreUpdate: []models.UpdateHook{{Name: "stop bridge"}}}
+				jsonHooksBytes, err := json.Marshal(hooks)
+				Expect(err).To(BeNil())
+				url := fmt.Sprintf("/%d/update-hooks", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonHooksBytes))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				handler := http.HandlerFunc(SetDeviceGroupUpdateHooks)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+	Context("updating DeviceGroup devices", func() {
+		var mockUpdateService *mock_services.MockUpdateServiceInterface
+		var mockJobService *mock_services.MockJobServiceInterface
+		deviceGroup := &models.DeviceGroup{
+			Model:   models.Model{ID: 1},
+			Name:    faker.Name(),
+			Type:    models.DeviceGroupTypeDefault,
+			Account: common.DefaultAccount,
+		}
+		BeforeEach(func() {
+			mockUpdateService = mock_services.NewMockUpdateServiceInterface(ctrl)
+			mockJobService = mock_services.NewMockJobServiceInterface(ctrl)
+			edgeAPIServices.UpdateService = mockUpdateService
+			edgeAPIServices.JobService = mockJobService
+		})
+		When("all is valid", func() {
+			It("should create the device group update", func() {
+				url := fmt.Sprintf("/%d/updates", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer([]byte("{}")))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				groupUpdate := &models.DeviceGroupUpdate{
+					Account:            deviceGroup.Account,
+					DeviceGroupID:      deviceGroup.ID,
+					UpdateTransactions: []models.UpdateTransaction{{Model: models.Model{ID: 1}, Account: deviceGroup.Account, Status: models.UpdateStatusCreated}},
+				}
+				mockUpdateService.EXPECT().CreateDeviceGroupUpdate(deviceGroup, uint(0)).Return(groupUpdate, nil)
+				mockUpdateService.EXPECT().SendDeviceNotification(gomock.Any()).Return(services.ImageNotification{}, nil)
+				mockJobService.EXPECT().Enqueue(models.JobTypeUpdateBuild, deviceGroup.Account, uint(1)).Return(&models.Job{}, nil)
+				handler := http.HandlerFunc(CreateDeviceGroupUpdate)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response models.DeviceGroupUpdate
+				Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(BeNil())
+				Expect(response.Progress.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		When("the device group update is requested without body", func() {
+			It("should update the devices to the latest image of their image set", func() {
+				url := fmt.Sprintf("/%d/updates", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, http.NoBody)
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				groupUpdate := &models.DeviceGroupUpdate{
+					Account:            deviceGroup.Account,
+					DeviceGroupID:      deviceGroup.ID,
+					UpdateTransactions: []models.UpdateTransaction{{Model: models.Model{ID: 3}, Account: deviceGroup.Account, Status: models.UpdateStatusCreated}},
+				}
+				mockUpdateService.EXPECT().CreateDeviceGroupUpdate(deviceGroup, uint(0)).Return(groupUpdate, nil)
+				mockUpdateService.EXPECT().SendDeviceNotification(gomock.Any()).Return(services.ImageNotification{}, nil)
+				mockJobService.EXPECT().Enqueue(models.JobTypeUpdateBuild, deviceGroup.Account, uint(3)).Return(&models.Job{}, nil)
+				handler := http.HandlerFunc(CreateDeviceGroupUpdate)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		When("the device group has no devices", func() {
+			It("should return status code 400", func() {
+				url := fmt.Sprintf("/%d/updates", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer([]byte("{}")))
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				mockUpdateService.EXPECT().CreateDeviceGroupUpdate(deviceGroup, uint(0)).Return(nil, new(services.DeviceGroupDevicesNotFound))
+				handler := http.HandlerFunc(CreateDeviceGroupUpdate)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("the device group is rolled back", func() {
+			It("should create the device group rollback", func() {
+				url := fmt.Sprintf("/%d/rollback", deviceGroup.ID)
+				req, err := http.NewRequest(http.MethodPost, url, nil)
+				Expect(err).To(BeNil())
+
+				ctx := req.Context()
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				groupRollback := &models.DeviceGroupUpdate{
+					Account:            deviceGroup.Account,
+					DeviceGroupID:      deviceGroup.ID,
+					Kind:               models.UpdateKindRollback,
+					UpdateTransactions: []models.UpdateTransaction{{Model: models.Model{ID: 2}, Account: deviceGroup.Account, Kind: models.UpdateKindRollback, Status: models.UpdateStatusCreated}},
+				}
+				mockUpdateService.EXPECT().CreateDeviceGroupRollback(deviceGroup).Return(groupRollback, nil)
+				mockJobService.EXPECT().Enqueue(models.JobTypeUpdateBuild, deviceGroup.Account, uint(2)).Return(&models.Job{}, nil)
+				handler := http.HandlerFunc(CreateDeviceGroupRollback)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+
+				var response models.DeviceGroupUpdate
+				Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(BeNil())
+				Expect(response.Kind).To(Equal(models.UpdateKindRollback))
+				Expect(response.Progress.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		When("the device group update does not exist", func() {
+			It("should return status code 404", func() {
+				req, err := http.NewRequest(http.MethodGet, "/", nil)
+				Expect(err).To(BeNil())
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("UPDATE_ID", "99")
+
+				ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+				ctx = setContextDeviceGroup(ctx, deviceGroup)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				req = req.WithContext(ctx)
+				rr := httptest.NewRecorder()
+
+				mockUpdateService.EXPECT().GetDeviceGroupUpdateByID(deviceGroup.Account, deviceGroup.ID, uint(99)).Return(nil, new(services.DeviceGroupUpdateNotFound))
+				handler := http.HandlerFunc(GetDeviceGroupUpdateByID)
+				handler.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
 })
@@ -26,6 +26,7 @@ func MakeDevicesRouter(sub chi.Router) {
 		r.Get("/", GetDevice)
 		r.Get("/updates", GetUpdateAvailableForDevice)
 		r.Get("/image", GetDeviceImageInfo)
+		r.Post("/rollback", CreateDeviceRollback)
 	})
 }
 
@@ -129,6 +130,43 @@ func GetDeviceImageInfo(w http.ResponseWriter, r *http.Request) {
 	respondWithJSONBody(w, contextServices.Log, result)
 }
 
+// CreateDeviceRollback rolls back a device to the previous image of its image set
+func CreateDeviceRollback(w http.ResponseWriter, r *http.Request) {
+	contextServices := dependencies.ServicesFromContext(r.Context())
+	dc, ok := r.Context().Value(deviceContextKey).(DeviceContext)
+	if dc.DeviceUUID == "" || !ok {
+		return // Error set by DeviceCtx method
+	}
+	account, err := common.GetAccount(r)
+	if err != nil {
+		contextServices.Log.WithField("error", err).Debug("Account not found")
+		respondWithAPIError(w, contextServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+	update, err := contextServices.UpdateService.CreateDeviceRollback(account, dc.DeviceUUID)
+	if err != nil {
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.DeviceNotFoundError:
+			apiError = errors.NewNotFound("Could not find device")
+		case *services.DeviceHasImageUndefined, *services.ImageHasNoImageSet, *services.RollbackImageNotFound:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, contextServices.Log, apiError)
+		return
+	}
+	contextServices.Log.WithField("updateID", update.ID).Info("Starting asynchronous rollback process")
+	if _, err := contextServices.JobService.Enqueue(models.JobTypeUpdateBuild, update.Account, update.ID); err != nil {
+		contextServices.Log.WithField("error", err.Error()).Error("Error enqueuing rollback build")
+		respondWithAPIError(w, contextServices.Log, errors.NewInternalServerError())
+		return
+	}
+
+	respondWithJSONBody(w, contextServices.Log, update)
+}
+
 // GetDevice returns all available information that edge api has about a device
 // It returns the information stored on our database and the device ID on our side, if any.
 // Returns the information of a running image and previous image in case of a rollback.
@@ -23,6 +23,8 @@ import (
 var _ = Describe("Devices Router", func() {
 	var deviceUUID string
 	var mockDeviceService *mock_services.MockDeviceServiceInterface
+	var mockUpdateService *mock_services.MockUpdateServiceInterface
+	var mockJobService *mock_services.MockJobServiceInterface
 	var router chi.Router
 
 	BeforeEach(func() {
@@ -32,8 +34,12 @@ var _ = Describe("Devices Router", func() {
 		defer ctrl.Finish()
 
 		mockDeviceService = mock_services.NewMockDeviceServiceInterface(ctrl)
+		mockUpdateService = mock_services.NewMockUpdateServiceInterface(ctrl)
+		mockJobService = mock_services.NewMockJobServiceInterface(ctrl)
 		mockServices := &dependencies.EdgeAPIServices{
 			DeviceService: mockDeviceService,
+			UpdateService: mockUpdateService,
+			JobService:    mockJobService,
 			Log:           log.NewEntry(log.StandardLogger()),
 		}
 		router = chi.NewRouter()
@@ -132,6 +138,34 @@ var _ = Describe("Devices Router", func() {
 			})
 		})
 	})
+	Context("rollback device", func() {
+		var req *http.Request
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("POST", fmt.Sprintf("/devices/%s/rollback", deviceUUID), nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should start the rollback", func() {
+			update := &models.UpdateTransaction{Model: models.Model{ID: 1}, Kind: models.UpdateKindRollback, Status: models.UpdateStatusCreated}
+			mockUpdateService.EXPECT().CreateDeviceRollback(gomock.Any(), gomock.Eq(deviceUUID)).Return(update, nil)
+			mockJobService.EXPECT().Enqueue(models.JobTypeUpdateBuild, gomock.Any(), uint(1)).Return(&models.Job{}, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+		It("should fail when the device has no previous image", func() {
+			mockUpdateService.EXPECT().CreateDeviceRollback(gomock.Any(), gomock.Eq(deviceUUID)).Return(nil, new(services.RollbackImageNotFound))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should fail when device is not found", func() {
+			mockUpdateService.EXPECT().CreateDeviceRollback(gomock.Any(), gomock.Eq(deviceUUID)).Return(nil, new(services.DeviceNotFoundError))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
 	Context("get list of device", func() {
 
 		When("when device is not found", func() {
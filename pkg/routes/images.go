@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,8 +40,11 @@ func MakeImagesRouter(sub chi.Router) {
 	sub.Route("/{imageId}", func(r chi.Router) {
 		r.Use(ImageByIDCtx)
 		r.Get("/", GetImageByID)
+		r.Delete("/", DeleteImage)
 		r.Get("/details", GetImageDetailsByID)
 		r.Get("/status", GetImageStatusByID)
+		r.Get("/events", GetImageEvents)
+		r.Get("/compare/{otherImageId}", CompareImages)
 		r.Get("/repo", GetRepoForImage)
 		r.Get("/metadata", GetMetadataForImage)
 		r.Post("/installer", CreateInstallerForImage)
@@ -389,6 +393,68 @@ func GetImageStatusByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetImageEvents returns the timeline of the status transitions of an image build, of its commit and of its installer
+func GetImageEvents(w http.ResponseWriter, r *http.Request) {
+	if image := getImage(w, r); image != nil {
+		ctxServices := dependencies.ServicesFromContext(r.Context())
+		events, err := ctxServices.ImageService.GetImageEvents(image)
+		if err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error getting image events")
+			respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+			return
+		}
+		respondWithJSONBody(w, ctxServices.Log, events)
+	}
+}
+
+// DeleteImage deletes an image version with its commit, installer and repo, its artifacts are deleted from the storage
+func DeleteImage(w http.ResponseWriter, r *http.Request) {
+	if image := getImage(w, r); image != nil {
+		ctxServices := dependencies.ServicesFromContext(r.Context())
+		ctxLog := ctxServices.Log.WithField("imageID", image.ID)
+		ctxLog.Info("Deleting an image")
+		if err := ctxServices.ImageService.DeleteImage(image); err != nil {
+			ctxLog.WithField("error", err.Error()).Error("Error deleting image")
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.ImageInUse, *services.ImageHasUpdateInProgress, *services.ImageIsBuilding:
+				apiError = errors.NewBadRequest(err.Error())
+			default:
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxLog, apiError)
+			return
+		}
+		respondWithJSONBody(w, ctxLog, map[string]interface{}{"message": "Image deleted"})
+	}
+}
+
+// CompareImages returns the difference from an image to another image of the account, of any image set
+func CompareImages(w http.ResponseWriter, r *http.Request) {
+	if image := getImage(w, r); image != nil {
+		ctxServices := dependencies.ServicesFromContext(r.Context())
+		otherImageID, err := strconv.Atoi(chi.URLParam(r, "otherImageId"))
+		if err != nil || otherImageID < 1 {
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("other image id must be an integer"))
+			return
+		}
+		comparison, err := ctxServices.ImageService.CompareImages(image, uint(otherImageID))
+		if err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error comparing images")
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.ImageNotFoundError:
+				apiError = errors.NewNotFound(err.Error())
+			default:
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxServices.Log, apiError)
+			return
+		}
+		respondWithJSONBody(w, ctxServices.Log, comparison)
+	}
+}
+
 //ImageDetail return the structure to inform package info to images
 type ImageDetail struct {
 	Image              *models.Image `json:"image"`
@@ -461,7 +527,7 @@ func CreateInstallerForImage(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	image, _, err := services.ImageService.CreateInstallerForImage(image)
+	image, err := services.ImageService.CreateInstallerForImage(image)
 	if err != nil {
 		services.Log.WithField("error", err).Error("Failed to create installer")
 		err := errors.NewInternalServerError()
@@ -11,12 +11,14 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/redhatinsights/edge-api/config"
 	"github.com/redhatinsights/edge-api/pkg/dependencies"
 	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
 	"github.com/redhatinsights/edge-api/pkg/services/mock_services"
 )
 
@@ -234,6 +236,52 @@ func TestGetImageDetailsById(t *testing.T) {
 	}
 }
 
+func TestGetImageEvents(t *testing.T) {
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	ctrl := gomock.NewController(t)
+
+	defer ctrl.Finish()
+	events := []models.Event{
+		{ResourceType: models.EventResourceImage, ResourceID: testImage.ID, NewStatus: models.ImageStatusBuilding},
+		{ResourceType: models.EventResourceImage, ResourceID: testImage.ID, OldStatus: models.ImageStatusBuilding, NewStatus: models.ImageStatusError},
+	}
+	mockImageService := mock_services.NewMockImageServiceInterface(ctrl)
+	mockImageService.EXPECT().GetImageEvents(&testImage).Return(events, nil)
+
+	ctx := context.WithValue(req.Context(), imageKey, &testImage)
+
+	ctx = dependencies.ContextWithServices(ctx, &dependencies.EdgeAPIServices{
+		ImageService: mockImageService,
+		Log:          log.NewEntry(log.StandardLogger()),
+	})
+
+	handler := http.HandlerFunc(GetImageEvents)
+	handler.ServeHTTP(rr, req.WithContext(ctx))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+		return
+	}
+
+	var response []models.Event
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Errorf(err.Error())
+	}
+	if len(response) != len(events) {
+		t.Errorf("wrong events count: got %v want %v", len(response), len(events))
+		return
+	}
+	if response[1].NewStatus != models.ImageStatusError {
+		t.Errorf("wrong event status: got %v want %v", response[1].NewStatus, models.ImageStatusError)
+	}
+}
+
 func TestValidateGetAllSearchParams(t *testing.T) {
 	tt := []struct {
 		name          string
@@ -525,3 +573,98 @@ func TestGetImage(t *testing.T) {
 		t.Errorf("image should not be nil")
 	}
 }
+
+func TestDeleteImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "deleted", err: nil, expectedStatus: http.StatusOK},
+		{name: "in use", err: new(services.ImageInUse), expectedStatus: http.StatusBadRequest},
+		{name: "update in progress", err: new(services.ImageHasUpdateInProgress), expectedStatus: http.StatusBadRequest},
+		{name: "error", err: fmt.Errorf("database is down"), expectedStatus: http.StatusInternalServerError},
+	}
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("DELETE", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mockImageService := mock_services.NewMockImageServiceInterface(ctrl)
+		mockImageService.EXPECT().DeleteImage(&testImage).Return(testCase.err)
+
+		ctx := context.WithValue(req.Context(), imageKey, &testImage)
+		ctx = dependencies.ContextWithServices(ctx, &dependencies.EdgeAPIServices{
+			ImageService: mockImageService,
+			Log:          log.NewEntry(log.StandardLogger()),
+		})
+
+		handler := http.HandlerFunc(DeleteImage)
+		handler.ServeHTTP(rr, req.WithContext(ctx))
+
+		if status := rr.Code; status != testCase.expectedStatus {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", testCase.name, status, testCase.expectedStatus)
+		}
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		name           string
+		otherImageID   string
+		err            error
+		expectedStatus int
+	}{
+		{name: "compared", otherImageID: "2", expectedStatus: http.StatusOK},
+		{name: "not found", otherImageID: "2", err: new(services.ImageNotFoundError), expectedStatus: http.StatusNotFound},
+		{name: "invalid id", otherImageID: "other", expectedStatus: http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mockImageService := mock_services.NewMockImageServiceInterface(ctrl)
+		if testCase.expectedStatus != http.StatusBadRequest {
+			comparison := &models.ImageComparison{ImageID: testImage.ID, OtherImageID: 2}
+			if testCase.err != nil {
+				comparison = nil
+			}
+			mockImageService.EXPECT().CompareImages(&testImage, uint(2)).Return(comparison, testCase.err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("otherImageId", testCase.otherImageID)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, imageKey, &testImage)
+		ctx = dependencies.ContextWithServices(ctx, &dependencies.EdgeAPIServices{
+			ImageService: mockImageService,
+			Log:          log.NewEntry(log.StandardLogger()),
+		})
+
+		handler := http.HandlerFunc(CompareImages)
+		handler.ServeHTTP(rr, req.WithContext(ctx))
+
+		if status := rr.Code; status != testCase.expectedStatus {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", testCase.name, status, testCase.expectedStatus)
+			continue
+		}
+		if testCase.expectedStatus == http.StatusOK {
+			var response models.ImageComparison
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Errorf(err.Error())
+			}
+			if response.OtherImageID != 2 {
+				t.Errorf("wrong other image: got %v want %v", response.OtherImageID, 2)
+			}
+		}
+	}
+}
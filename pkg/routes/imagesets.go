@@ -14,6 +14,7 @@ import (
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/dependencies"
 	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/go-chi/chi"
@@ -31,9 +32,19 @@ var statusOption = []string{models.ImageStatusCreated, models.ImageStatusBuildin
 // MakeImageSetsRouter adds support for operations on image-sets
 func MakeImageSetsRouter(sub chi.Router) {
 	sub.With(validateFilterParams).With(common.Paginate).Get("/", ListAllImageSets)
+	sub.Get("/retention-policy", GetRetentionPolicy)
+	sub.Put("/retention-policy", SetRetentionPolicy)
+	sub.Delete("/retention-policy", DeleteRetentionPolicy)
+	sub.With(common.Paginate).Get("/retention-reports", GetRetentionReports)
 	sub.Route("/{imageSetID}", func(r chi.Router) {
 		r.Use(ImageSetCtx)
 		r.With(validateFilterParams).With(common.Paginate).Get("/", GetImageSetsByID)
+		r.Delete("/", DeleteImageSet)
+		r.Get("/update-hooks", GetImageSetUpdateHooks)
+		r.Put("/update-hooks", SetImageSetUpdateHooks)
+		r.Get("/retention-policy", GetRetentionPolicy)
+		r.Put("/retention-policy", SetRetentionPolicy)
+		r.Delete("/retention-policy", DeleteRetentionPolicy)
 	})
 }
 
@@ -378,3 +389,205 @@ func returnImageDetails(images []models.Image, s *dependencies.EdgeAPIServices)
 
 	return Imgs
 }
+
+// getContextImageSet returns the image set of the request context
+func getContextImageSet(w http.ResponseWriter, r *http.Request) *models.ImageSet {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	imageSet, ok := r.Context().Value(imageSetKey).(*models.ImageSet)
+	if !ok {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("Must pass image set id"))
+		return nil
+	}
+	return imageSet
+}
+
+// GetImageSetUpdateHooks returns the update hooks of an image set
+func GetImageSetUpdateHooks(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	imageSet := getContextImageSet(w, r)
+	if imageSet == nil {
+		return
+	}
+
+	hooks, err := ctxServices.ImageSetService.GetImageSetUpdateHooks(imageSet.Account, imageSet.ID)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when getting image set update hooks")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.ImageSetNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, hooks)
+}
+
+// SetImageSetUpdateHooks replaces the update hooks of an image set
+func SetImageSetUpdateHooks(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	imageSet := getContextImageSet(w, r)
+	if imageSet == nil {
+		return
+	}
+
+	var hooks models.UpdateHooks
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &hooks); err != nil {
+		return
+	}
+	if err := hooks.ValidateRequest(); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Info("Error validation request from update hooks")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	hooksSet, err := ctxServices.ImageSetService.SetImageSetUpdateHooks(imageSet.Account, imageSet.ID, &hooks)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when setting image set update hooks")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.ImageSetNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, hooksSet)
+}
+
+// DeleteImageSet deletes an image set with all its images, their artifacts are deleted from the storage
+func DeleteImageSet(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	imageSet := getContextImageSet(w, r)
+	if imageSet == nil {
+		return
+	}
+	ctxLog := ctxServices.Log.WithField("imageSetID", imageSet.ID)
+	ctxLog.Info("Deleting an image set")
+	if err := ctxServices.ImageSetService.DeleteImageSet(imageSet.Account, imageSet.ID); err != nil {
+		ctxLog.WithField("error", err.Error()).Error("Error deleting image set")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.ImageSetNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		case *services.ImageInUse, *services.ImageHasUpdateInProgress, *services.ImageIsBuilding:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxLog, apiError)
+		return
+	}
+	respondWithJSONBody(w, ctxLog, map[string]interface{}{"message": "Image set deleted"})
+}
+
+// getRetentionPolicyScope returns the account of the request and the image set of the retention policy requested,
+// nil for the retention policy of the account
+func getRetentionPolicyScope(w http.ResponseWriter, r *http.Request) (string, *uint, bool) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	if imageSet, ok := r.Context().Value(imageSetKey).(*models.ImageSet); ok {
+		return imageSet.Account, &imageSet.ID, true
+	}
+	account, err := common.GetAccount(r)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error retrieving account from the request")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return "", nil, false
+	}
+	return account, nil, true
+}
+
+// respondWithRetentionPolicyError responds with the API error of an error of the retention policies
+func respondWithRetentionPolicyError(w http.ResponseWriter, r *http.Request, err error) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	var apiError errors.APIError
+	switch err.(type) {
+	case *services.ImageSetNotFound, *services.RetentionPolicyNotFound:
+		apiError = errors.NewNotFound(err.Error())
+	default:
+		apiError = errors.NewInternalServerError()
+	}
+	respondWithAPIError(w, ctxServices.Log, apiError)
+}
+
+// GetRetentionPolicy returns the retention policy of the account, or of the image set
+func GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, imageSetID, ok := getRetentionPolicyScope(w, r)
+	if !ok {
+		return
+	}
+	policy, err := ctxServices.ImageSetService.GetRetentionPolicy(account, imageSetID)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when getting retention policy")
+		respondWithRetentionPolicyError(w, r, err)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, policy)
+}
+
+// SetRetentionPolicy sets the number of successful versions the retention policy of the account, or of the image set, keeps
+func SetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, imageSetID, ok := getRetentionPolicyScope(w, r)
+	if !ok {
+		return
+	}
+
+	var policy models.RetentionPolicy
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &policy); err != nil {
+		return
+	}
+	if err := policy.ValidateRequest(); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Info("Error validation request from retention policy")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	policySet, err := ctxServices.ImageSetService.SetRetentionPolicy(account, imageSetID, policy.KeepVersions)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when setting retention policy")
+		respondWithRetentionPolicyError(w, r, err)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, policySet)
+}
+
+// DeleteRetentionPolicy deletes the retention policy of the account, or of the image set
+func DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, imageSetID, ok := getRetentionPolicyScope(w, r)
+	if !ok {
+		return
+	}
+	if err := ctxServices.ImageSetService.DeleteRetentionPolicy(account, imageSetID); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error when deleting retention policy")
+		respondWithRetentionPolicyError(w, r, err)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, map[string]interface{}{"message": "Retention policy deleted"})
+}
+
+// GetRetentionReports returns the reports of the image versions removed by the retention policies of the account
+func GetRetentionReports(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, err := common.GetAccount(r)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error retrieving account from the request")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+	pagination := common.GetPagination(r)
+	reports, err := ctxServices.ImageSetService.GetRetentionReports(account, pagination.Limit, pagination.Offset)
+	if err != nil {
+		respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, reports)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/redhatinsights/edge-api/pkg/db"
@@ -16,6 +17,9 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	"github.com/redhatinsights/edge-api/pkg/services/mock_services"
+	log "github.com/sirupsen/logrus"
 )
 
 func TestListAllImageSets(t *testing.T) {
@@ -246,3 +250,94 @@ func TestImageSetDetailFiltersParams(t *testing.T) {
 
 	}
 }
+
+func TestDeleteImageSet(t *testing.T) {
+	imageSet := &models.ImageSet{Account: "0000000", Name: "image-set"}
+	imageSet.ID = 1
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "deleted", err: nil, expectedStatus: http.StatusOK},
+		{name: "not found", err: new(services.ImageSetNotFound), expectedStatus: http.StatusNotFound},
+		{name: "in use", err: new(services.ImageInUse), expectedStatus: http.StatusBadRequest},
+		{name: "building", err: new(services.ImageIsBuilding), expectedStatus: http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("DELETE", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mockImageSetService := mock_services.NewMockImageSetsServiceInterface(ctrl)
+		mockImageSetService.EXPECT().DeleteImageSet(imageSet.Account, imageSet.ID).Return(testCase.err)
+
+		ctx := context.WithValue(req.Context(), imageSetKey, imageSet)
+		ctx = dependencies.ContextWithServices(ctx, &dependencies.EdgeAPIServices{
+			ImageSetService: mockImageSetService,
+			Log:             log.NewEntry(log.StandardLogger()),
+		})
+
+		handler := http.HandlerFunc(DeleteImageSet)
+		handler.ServeHTTP(rr, req.WithContext(ctx))
+
+		if status := rr.Code; status != testCase.expectedStatus {
+			t.Errorf("%s: handler returned wrong status code: got %v, want %v", testCase.name, status, testCase.expectedStatus)
+		}
+	}
+}
+
+func TestSetRetentionPolicy(t *testing.T) {
+	imageSet := &models.ImageSet{Account: "0000000", Name: "image-set"}
+	imageSet.ID = 1
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCases := []struct {
+		name           string
+		imageSet       *models.ImageSet
+		body           string
+		expectedStatus int
+	}{
+		{name: "image set policy", imageSet: imageSet, body: `{"KeepVersions": 3}`, expectedStatus: http.StatusOK},
+		{name: "account policy", body: `{"KeepVersions": 3}`, expectedStatus: http.StatusOK},
+		{name: "no version kept", imageSet: imageSet, body: `{"KeepVersions": 0}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("PUT", "/", strings.NewReader(testCase.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mockImageSetService := mock_services.NewMockImageSetsServiceInterface(ctrl)
+		ctx := req.Context()
+		if testCase.imageSet != nil {
+			ctx = context.WithValue(ctx, imageSetKey, testCase.imageSet)
+		}
+		if testCase.expectedStatus == http.StatusOK {
+			var imageSetID *uint
+			account := common.DefaultAccount
+			if testCase.imageSet != nil {
+				imageSetID = &testCase.imageSet.ID
+				account = testCase.imageSet.Account
+			}
+			mockImageSetService.EXPECT().SetRetentionPolicy(account, imageSetID, 3).
+				Return(&models.RetentionPolicy{Account: account, ImageSetID: imageSetID, KeepVersions: 3}, nil)
+		}
+		ctx = dependencies.ContextWithServices(ctx, &dependencies.EdgeAPIServices{
+			ImageSetService: mockImageSetService,
+			Log:             log.NewEntry(log.StandardLogger()),
+		})
+
+		handler := http.HandlerFunc(SetRetentionPolicy)
+		handler.ServeHTTP(rr, req.WithContext(ctx))
+
+		if status := rr.Code; status != testCase.expectedStatus {
+			t.Errorf("%s: handler returned wrong status code: got %v, want %v", testCase.name, status, testCase.expectedStatus)
+		}
+	}
+}
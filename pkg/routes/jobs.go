@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/dependencies"
+	"github.com/redhatinsights/edge-api/pkg/errors"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	log "github.com/sirupsen/logrus"
+)
+
+type jobTypeKey string
+
+const jobKey = jobTypeKey("job_key")
+
+// MakeJobsRouter adds support for the operations on the jobs running the image and update builds
+func MakeJobsRouter(sub chi.Router) {
+	sub.With(validateGetAllJobsFilterParams).With(common.Paginate).Get("/", GetAllJobs)
+	sub.Route("/{ID}", func(r chi.Router) {
+		r.Use(JobCtx)
+		r.Get("/", GetJobByID)
+	})
+}
+
+var jobsFilters = common.ComposeFilters(
+	common.OneOfFilterHandler(&common.Filter{
+		QueryParam: "status",
+		DBField:    "jobs.status",
+	}),
+	common.OneOfFilterHandler(&common.Filter{
+		QueryParam: "type",
+		DBField:    "jobs.type",
+	}),
+	common.OneOfFilterHandler(&common.Filter{
+		QueryParam: "resource_id",
+		DBField:    "jobs.resource_id",
+	}),
+	common.SortFilterHandler("jobs", "created_at", "DESC"),
+)
+
+func validateGetAllJobsFilterParams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []validationError
+		for _, val := range r.URL.Query()["resource_id"] {
+			if _, err := strconv.Atoi(val); err != nil {
+				errs = append(errs, validationError{Key: "resource_id", Reason: fmt.Sprintf("%s is not a valid resource id", val)})
+			}
+		}
+		if val := r.URL.Query().Get("sort_by"); val != "" {
+			name := val
+			if string(val[0]) == "-" {
+				name = val[1:]
+			}
+			if name != "created_at" && name != "updated_at" && name != "run_at" {
+				errs = append(errs, validationError{Key: "sort_by", Reason: fmt.Sprintf("%s is not a valid sort_by. Sort-by must be created_at or updated_at or run_at", name)})
+			}
+		}
+
+		if len(errs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(&errs); err != nil {
+			ctxServices := dependencies.ServicesFromContext(r.Context())
+			ctxServices.Log.WithField("error", errs).Error("Error while trying to encode jobs filter validation errors")
+		}
+	})
+}
+
+// GetAllJobs returns the jobs of the account with their state
+func GetAllJobs(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	tx := jobsFilters(r, db.DB)
+
+	account, err := common.GetAccount(r)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error retrieving account from the request")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	pagination := common.GetPagination(r)
+
+	jobsCount, err := ctxServices.JobService.GetJobsCount(account, tx)
+	if err != nil {
+		respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+		return
+	}
+
+	jobs, err := ctxServices.JobService.GetJobs(account, pagination.Limit, pagination.Offset, tx)
+	if err != nil {
+		respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, map[string]interface{}{"data": jobs, "count": jobsCount})
+}
+
+// JobCtx is a handler for Job requests
+func JobCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxServices := dependencies.ServicesFromContext(r.Context())
+		account, err := common.GetAccount(r)
+		if err != nil {
+			ctxServices.Log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"account": account,
+			}).Error("Error retrieving account")
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+			return
+		}
+		jobID := chi.URLParam(r, "ID")
+		ctxServices.Log = ctxServices.Log.WithField("jobID", jobID)
+		id, err := strconv.Atoi(jobID)
+		if err != nil {
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+			return
+		}
+		job, err := ctxServices.JobService.GetJobByID(account, uint(id))
+		if err != nil {
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.JobNotFound:
+				apiError = errors.NewNotFound(err.Error())
+			default:
+				ctxServices.Log.WithField("error", err.Error()).Error("Error retrieving job")
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxServices.Log, apiError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), jobKey, job)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetJobByID returns a job with its state: status, attempts, lease and last error
+func GetJobByID(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	job, ok := r.Context().Value(jobKey).(*models.Job)
+	if !ok {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("Failed getting job from context"))
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, job)
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/dependencies"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Job routes", func() {
+	var router chi.Router
+	var job models.Job
+	BeforeEach(func() {
+		logger := log.NewEntry(log.StandardLogger())
+		edgeAPIServices := &dependencies.EdgeAPIServices{
+			JobService: services.NewJobService(context.Background(), logger),
+			Log:        logger,
+		}
+		router = chi.NewRouter()
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := dependencies.ContextWithServices(r.Context(), edgeAPIServices)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		router.Route("/jobs", MakeJobsRouter)
+
+		job = models.Job{Account: common.DefaultAccount, Type: models.JobTypeUpdateBuild, ResourceID: 1, Status: models.JobStatusFailed,
+			Attempts: 3, MaxAttempts: 3, LastError: "error building repo"}
+		Expect(db.DB.Create(&job).Error).ToNot(HaveOccurred())
+	})
+	Context("GET GetJobByID", func() {
+		It("should return the job with its state", func() {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/jobs/%d", job.ID), nil)
+			Expect(err).ToNot(HaveOccurred())
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var response models.Job
+			Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Status).To(Equal(models.JobStatusFailed))
+			Expect(response.Attempts).To(Equal(3))
+			Expect(response.LastError).To(Equal("error building repo"))
+		})
+		It("should return not found for the job of another account", func() {
+			other := models.Job{Account: "1111111", Type: models.JobTypeUpdateBuild, Status: models.JobStatusPending}
+			Expect(db.DB.Create(&other).Error).ToNot(HaveOccurred())
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/jobs/%d", other.ID), nil)
+			Expect(err).ToNot(HaveOccurred())
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+	Context("GET GetAllJobs", func() {
+		It("should filter the jobs by status and resource", func() {
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/jobs?status=%s&type=%s&resource_id=1", models.JobStatusFailed, models.JobTypeUpdateBuild), nil)
+			Expect(err).ToNot(HaveOccurred())
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var response struct {
+				Count int64        `json:"count"`
+				Data  []models.Job `json:"data"`
+			}
+			Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Count).To(BeNumerically(">=", 1))
+			for _, j := range response.Data {
+				Expect(j.Status).To(Equal(models.JobStatusFailed))
+				Expect(j.ResourceID).To(Equal(uint(1)))
+			}
+		})
+		It("should not sort by an unknown field", func() {
+			req, err := http.NewRequest(http.MethodGet, "/jobs?sort_by=-identity", nil)
+			Expect(err).ToNot(HaveOccurred())
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
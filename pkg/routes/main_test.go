@@ -62,6 +62,20 @@ func setUp() {
 		&models.DispatchRecord{},
 		&models.ThirdPartyRepo{},
 		&models.DeviceGroup{},
+		&models.UpdateWave{},
+		&models.MaintenanceWindow{},
+		&models.DeviceGroupUpdate{},
+		&models.DeviceGroupUpdateRejectedDevice{},
+		&models.DispatchRecordAttempt{},
+		&models.UpdateHook{},
+		&models.Event{},
+		&models.UpdateBundle{},
+		&models.Job{},
+		&models.UpdateRepoCache{},
+		&models.ConcurrencySlot{},
+		&models.RetentionPolicy{},
+		&models.RetentionReport{},
+		&models.RetentionReportImage{},
 	)
 	if err != nil {
 		panic(err)
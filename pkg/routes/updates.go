@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/redhatinsights/edge-api/pkg/clients/inventory"
@@ -15,6 +17,7 @@ import (
 	"github.com/redhatinsights/edge-api/pkg/errors"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -24,10 +27,23 @@ func MakeUpdatesRouter(sub chi.Router) {
 	sub.With(common.Paginate).Get("/", GetUpdates)
 	sub.Post("/", AddUpdate)
 	sub.Post("/validate", PostValidateUpdate)
+	sub.Post("/preview", PostPreviewUpdate)
+	sub.Route("/bundles", func(r chi.Router) {
+		r.Post("/", CreateUpdateBundle)
+		r.Route("/{bundleID}", func(r chi.Router) {
+			r.Use(UpdateBundleCtx)
+			r.Get("/", GetUpdateBundleByID)
+			r.Get("/download", GetUpdateBundleDownload)
+		})
+	})
 	sub.Route("/{updateID}", func(r chi.Router) {
 		r.Use(UpdateCtx)
 		r.Get("/", GetUpdateByID)
 		r.Get("/update-playbook.yml", GetUpdatePlaybook)
+		r.Post("/cancel", CancelUpdate)
+		r.Post("/retry", RetryUpdate)
+		r.Post("/resume", ResumeUpdate)
+		r.Get("/events", GetUpdateEvents)
 		r.Get("/notify", SendNotificationForDevice) //TMP ROUTE TO SEND THE NOTIFICATION
 	})
 	// TODO: This is for backwards compatibility with the previous route
@@ -37,8 +53,12 @@ func MakeUpdatesRouter(sub chi.Router) {
 
 type updateContextKey int
 
-// UpdateContextKey is the key to Update Context handler
-const UpdateContextKey updateContextKey = iota
+const (
+	// UpdateContextKey is the key to Update Context handler
+	UpdateContextKey updateContextKey = iota
+	// UpdateBundleContextKey is the key to Update Bundle Context handler
+	UpdateBundleContextKey
+)
 
 // UpdateCtx is a handler for Update requests
 func UpdateCtx(next http.Handler) http.Handler {
@@ -65,7 +85,7 @@ func UpdateCtx(next http.Handler) http.Handler {
 			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
 			return
 		}
-		result := db.DB.Preload("DispatchRecords").Preload("Devices").Where("update_transactions.account = ?", account).Joins("Commit").Joins("Repo").Find(&updates, id)
+		result := db.DB.Preload("DispatchRecords.AttemptHistory").Preload("Devices").Preload("Waves").Where("update_transactions.account = ?", account).Joins("Commit").Joins("Repo").Find(&updates, id)
 		if result.Error != nil {
 			ctxServices.Log.WithFields(log.Fields{
 				"error": result.Error.Error(),
@@ -131,7 +151,7 @@ func GetUpdates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// FIXME - need to sort out how to get this query to be against commit.account
-	result := db.DB.Preload("DispatchRecords").Preload("Devices").Where("update_transactions.account = ?", account).Joins("Commit").Joins("Repo").Find(&updates)
+	result := db.DB.Preload("DispatchRecords.AttemptHistory").Preload("Devices").Preload("Waves").Where("update_transactions.account = ?", account).Joins("Commit").Joins("Repo").Find(&updates)
 	if result.Error != nil {
 		services.Log.WithFields(log.Fields{
 			"error": result.Error.Error(),
@@ -153,8 +173,16 @@ func GetUpdates(w http.ResponseWriter, r *http.Request) {
 type DevicesUpdate struct {
 	CommitID    uint     `json:"CommitID,omitempty"`
 	DevicesUUID []string `json:"DevicesUUID"`
-	// TODO: Implement updates by tag
-	// Tag        string `json:"Tag"`
+	// Rollout dispatches the update to all the devices in staged waves
+	// instead of creating an update transaction per device
+	Rollout *models.UpdateRolloutPlan `json:"Rollout,omitempty"`
+	// NotBefore holds back the devices until the given time
+	NotBefore *time.Time `json:"NotBefore,omitempty"`
+	// SchedulePolicy is IMMEDIATE by default, NEXT_WINDOW holds back the devices
+	// until a maintenance window of their device groups opens
+	SchedulePolicy string `json:"SchedulePolicy,omitempty"`
+	// Tag updates all the edge devices with the given inventory tag, in the namespace/key=value format
+	Tag string `json:"Tag,omitempty"`
 }
 
 func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTransaction, error) {
@@ -184,9 +212,55 @@ func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTra
 	}
 	services.Log.WithField("updateJSON", devicesUpdate).Debug("Update JSON received")
 
-	// TODO: Implement update by tag - Add validation per tag
-	if devicesUpdate.DevicesUUID == nil {
-		err := errors.NewBadRequest("DeviceUUID required.")
+	if devicesUpdate.DevicesUUID == nil && devicesUpdate.Tag == "" {
+		err := errors.NewBadRequest("DeviceUUID or Tag required.")
+		w.WriteHeader(err.GetStatus())
+		return nil, err
+	}
+	if devicesUpdate.DevicesUUID != nil && devicesUpdate.Tag != "" {
+		err := errors.NewBadRequest("DeviceUUID and Tag can't be used together.")
+		w.WriteHeader(err.GetStatus())
+		return nil, err
+	}
+	var tagDevices inventory.Response
+	if devicesUpdate.Tag != "" {
+		if err := models.ValidateUpdateTag(devicesUpdate.Tag); err != nil {
+			err := errors.NewBadRequest(err.Error())
+			w.WriteHeader(err.GetStatus())
+			return nil, err
+		}
+		tagDevices, err = services.DeviceService.GetInventoryDevicesByTag(devicesUpdate.Tag)
+		if err != nil {
+			err := errors.NewInternalServerError()
+			w.WriteHeader(err.GetStatus())
+			return nil, err
+		}
+		if tagDevices.Count == 0 {
+			err := errors.NewNotFound(fmt.Sprintf("No devices found for Tag %s", devicesUpdate.Tag))
+			w.WriteHeader(err.GetStatus())
+			return nil, err
+		}
+		devicesUpdate.DevicesUUID = make([]string, 0, len(tagDevices.Result))
+		for _, device := range tagDevices.Result {
+			devicesUpdate.DevicesUUID = append(devicesUpdate.DevicesUUID, device.ID)
+		}
+		if err := validateDevicesImageSet(services, account, tagDevices.Result); err != nil {
+			w.WriteHeader(err.GetStatus())
+			if err := json.NewEncoder(w).Encode(&err); err != nil {
+				services.Log.WithField("error", err.Error()).Error("Error encoding error")
+			}
+			return nil, err
+		}
+	}
+	if devicesUpdate.Rollout != nil {
+		if err := devicesUpdate.Rollout.ValidateRequest(); err != nil {
+			err := errors.NewBadRequest(err.Error())
+			w.WriteHeader(err.GetStatus())
+			return nil, err
+		}
+	}
+	if err := models.ValidateSchedulePolicy(devicesUpdate.SchedulePolicy); err != nil {
+		err := errors.NewBadRequest(err.Error())
 		w.WriteHeader(err.GetStatus())
 		return nil, err
 	}
@@ -214,7 +288,11 @@ func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTra
 	client := inventory.InitClient(r.Context(), log.NewEntry(log.StandardLogger()))
 	var inv inventory.Response
 	var ii []inventory.Response
-	if len(devicesUpdate.DevicesUUID) > 0 {
+	if devicesUpdate.Tag != "" {
+		// All the devices with the tag are updated by a single update transaction
+		inv = tagDevices
+		ii = append(ii, inv)
+	} else if len(devicesUpdate.DevicesUUID) > 0 {
 		for _, UUID := range devicesUpdate.DevicesUUID {
 			inv, err = client.ReturnDevicesByID(UUID)
 			if inv.Count >= 0 {
@@ -228,17 +306,33 @@ func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTra
 		}
 	}
 
+	if devicesUpdate.Rollout != nil && len(ii) > 1 {
+		// A staged rollout is a single update transaction with waves of devices
+		rollout := inventory.Response{}
+		for _, inv := range ii {
+			rollout.Count += inv.Count
+			rollout.Total += inv.Total
+			rollout.Result = append(rollout.Result, inv.Result...)
+		}
+		ii = []inventory.Response{rollout}
+	}
+
 	services.Log.WithField("inventoryDevice", inv).Debug("Device retrieved from inventory")
 	var updates []models.UpdateTransaction
 	for _, inventory := range ii {
 
 		// Create the models.UpdateTransaction
 		update := models.UpdateTransaction{
-			Account:  account,
-			CommitID: devicesUpdate.CommitID,
-			Status:   models.UpdateStatusCreated,
-			// TODO: Implement update by tag
-			// Tag:      updateJSON.Tag,
+			Account:        account,
+			CommitID:       devicesUpdate.CommitID,
+			Status:         models.UpdateStatusCreated,
+			Kind:           models.UpdateKindUpdate,
+			SchedulePolicy: devicesUpdate.SchedulePolicy,
+			Tag:            devicesUpdate.Tag,
+		}
+
+		if devicesUpdate.NotBefore != nil {
+			update.NotBefore = models.EdgeAPITime{Time: *devicesUpdate.NotBefore, Valid: true}
 		}
 
 		// Get the models.Commit from the Commit ID passed in via JSON
@@ -362,6 +456,16 @@ func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTra
 				return nil, err
 			}
 		}
+		if devicesUpdate.Rollout != nil {
+			update.SuccessThreshold = devicesUpdate.Rollout.SuccessThreshold
+			update.FailureThreshold = devicesUpdate.Rollout.FailureThreshold
+			update.Waves = devicesUpdate.Rollout.BuildWaves(len(update.Devices))
+			if err := db.DB.Save(&update).Error; err != nil {
+				err := errors.NewBadRequest(err.Error())
+				w.WriteHeader(err.GetStatus())
+				return nil, err
+			}
+		}
 		updates = append(updates, update)
 		services.Log.WithField("updateID", update.ID).Info("Update has been created")
 
@@ -369,6 +473,67 @@ func updateFromHTTP(w http.ResponseWriter, r *http.Request) (*[]models.UpdateTra
 	return &updates, nil
 }
 
+// validateDevicesImageSet validates that the inventory devices run images of the same image set
+// The image of a device is the image it runs in the database, or the image of the commit it booted when it has none,
+// the devices whose image can't be resolved are reported
+func validateDevicesImageSet(services *dependencies.EdgeAPIServices, account string, devices []inventory.Device) errors.APIError {
+	devicesUUID := make([]string, 0, len(devices))
+	for _, device := range devices {
+		devicesUUID = append(devicesUUID, device.ID)
+	}
+	var knownDevices []models.Device
+	if result := db.DB.Select("uuid", "image_id").Where("account = ? AND uuid IN ? AND image_id > 0", account, devicesUUID).
+		Find(&knownDevices); result.Error != nil {
+		services.Log.WithField("error", result.Error.Error()).Error("Error retrieving devices images")
+		return errors.NewInternalServerError()
+	}
+	devicesImage := make(map[string]uint, len(knownDevices))
+	for _, device := range knownDevices {
+		devicesImage[device.UUID] = device.ImageID
+	}
+	imageIDs := make([]uint, 0, len(devices))
+	seenImages := make(map[uint]bool)
+	var unresolved []string
+	for _, device := range devices {
+		imageID, ok := devicesImage[device.ID]
+		if !ok {
+			for _, deployment := range device.Ostree.RpmOstreeDeployments {
+				if !deployment.Booted {
+					continue
+				}
+				var image models.Image
+				result := db.DB.Select("images.id").Joins("JOIN commits ON commits.id = images.commit_id").
+					Where("images.account = ? AND commits.os_tree_commit = ?", account, deployment.Checksum).Limit(1).Find(&image)
+				if result.Error != nil {
+					services.Log.WithField("error", result.Error.Error()).Error("Error retrieving device booted image")
+					return errors.NewInternalServerError()
+				}
+				imageID = image.ID
+			}
+		}
+		if imageID == 0 {
+			unresolved = append(unresolved, device.ID)
+			continue
+		}
+		if !seenImages[imageID] {
+			seenImages[imageID] = true
+			imageIDs = append(imageIDs, imageID)
+		}
+	}
+	if len(unresolved) > 0 {
+		return errors.NewBadRequest(fmt.Sprintf("The image of the devices %s can't be resolved", strings.Join(unresolved, ", ")))
+	}
+	valid, err := services.UpdateService.ValidateUpdateSelection(account, imageIDs)
+	if err != nil {
+		services.Log.WithField("error", err.Error()).Error("Error validating the devices images selection")
+		return errors.NewInternalServerError()
+	}
+	if !valid {
+		return errors.NewBadRequest("Devices must run images of the same image set")
+	}
+	return nil
+}
+
 // AddUpdate updates a device
 func AddUpdate(w http.ResponseWriter, r *http.Request) {
 	services := dependencies.ServicesFromContext(r.Context())
@@ -395,8 +560,6 @@ func AddUpdate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		upd = append(upd, update)
-		services.Log.WithField("updateID", update.ID).Info("Starting asynchronous update process")
-		go services.UpdateService.CreateUpdate(update.ID)
 	}
 	result := db.DB.Save(upd)
 	if result.Error != nil {
@@ -407,6 +570,15 @@ func AddUpdate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(err.GetStatus())
 		return
 	}
+	for _, update := range upd {
+		services.Log.WithField("updateID", update.ID).Info("Starting asynchronous update process")
+		if _, err := services.JobService.Enqueue(models.JobTypeUpdateBuild, update.Account, update.ID); err != nil {
+			services.Log.WithField("error", err.Error()).Error("Error enqueuing update build")
+			err := errors.NewInternalServerError()
+			w.WriteHeader(err.GetStatus())
+			return
+		}
+	}
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(updates); err != nil {
 		services.Log.WithField("error", updates).Error("Error while trying to encode")
@@ -437,6 +609,92 @@ func getUpdate(w http.ResponseWriter, r *http.Request) *models.UpdateTransaction
 	return update
 }
 
+// CancelUpdate cancels an update, the devices that were not dispatched yet won't be updated
+func CancelUpdate(w http.ResponseWriter, r *http.Request) {
+	update := getUpdate(w, r)
+	if update == nil {
+		// Error set by UpdateCtx already
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	if err := ctxServices.UpdateService.CancelUpdate(update); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error cancelling update")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.UpdateCannotBeCancelled:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, update)
+}
+
+// GetUpdateEvents returns the timeline of the status transitions of an update and of its dispatch records
+func GetUpdateEvents(w http.ResponseWriter, r *http.Request) {
+	update := getUpdate(w, r)
+	if update == nil {
+		// Error set by UpdateCtx already
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	events, err := ctxServices.UpdateService.GetUpdateTransactionEvents(update)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error getting update events")
+		respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, events)
+}
+
+// RetryUpdate dispatches again the update to the devices whose dispatch failed, without rebuilding the update repo
+func RetryUpdate(w http.ResponseWriter, r *http.Request) {
+	update := getUpdate(w, r)
+	if update == nil {
+		// Error set by UpdateCtx already
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	if err := ctxServices.UpdateService.RetryUpdate(update); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error retrying update")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.UpdateCannotBeRetried, *services.UpdateHasNoFailedDevices, *services.UpdateRolloutLimitReached:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, update)
+}
+
+// ResumeUpdate resumes a staged rollout paused by a failed wave
+func ResumeUpdate(w http.ResponseWriter, r *http.Request) {
+	update := getUpdate(w, r)
+	if update == nil {
+		// Error set by UpdateCtx already
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	if err := ctxServices.UpdateService.ResumeUpdate(update); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error resuming update")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.UpdateCannotBeResumed, *services.UpdateRolloutLimitReached:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, update)
+}
+
 //SendNotificationForDevice TMP route to validate
 func SendNotificationForDevice(w http.ResponseWriter, r *http.Request) {
 	if update := getUpdate(w, r); update != nil {
@@ -506,3 +764,234 @@ func PostValidateUpdate(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	respondWithJSONBody(w, services.Log, &ValidateUpdateResponse{UpdateValid: valid})
 }
+
+// UpdatePreviewRequest is the request of a dry run of an update
+type UpdatePreviewRequest struct {
+	// CommitID is the commit the devices would be updated to,
+	// the commit of the latest image of the image set of the devices when undefined
+	CommitID    uint     `json:"CommitID,omitempty"`
+	DevicesUUID []string `json:"DevicesUUID,omitempty"`
+	// DeviceGroupID previews the update of all the devices of the device group instead of DevicesUUID
+	DeviceGroupID uint `json:"DeviceGroupID,omitempty"`
+}
+
+// PostPreviewUpdate returns what an update of the devices would do without creating it
+func PostPreviewUpdate(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, err := common.GetAccount(r)
+	if err != nil {
+		ctxServices.Log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"account": account,
+		}).Error("Error retrieving account")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	var previewRequest UpdatePreviewRequest
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &previewRequest); err != nil {
+		return
+	}
+	if len(previewRequest.DevicesUUID) == 0 && previewRequest.DeviceGroupID == 0 {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("DevicesUUID or DeviceGroupID required."))
+		return
+	}
+	if len(previewRequest.DevicesUUID) > 0 && previewRequest.DeviceGroupID != 0 {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("DevicesUUID and DeviceGroupID can't be used together."))
+		return
+	}
+	devicesUUID := previewRequest.DevicesUUID
+	if previewRequest.DeviceGroupID != 0 {
+		deviceGroup, err := ctxServices.DeviceGroupsService.GetDeviceGroupByID(strconv.FormatUint(uint64(previewRequest.DeviceGroupID), 10))
+		if err != nil {
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.DeviceGroupNotFound:
+				apiError = errors.NewNotFound(err.Error())
+			default:
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxServices.Log, apiError)
+			return
+		}
+		if len(deviceGroup.Devices) == 0 {
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(new(services.DeviceGroupDevicesNotFound).Error()))
+			return
+		}
+		for _, device := range deviceGroup.Devices {
+			devicesUUID = append(devicesUUID, device.UUID)
+		}
+	}
+	if previewRequest.CommitID == 0 {
+		previewRequest.CommitID, err = ctxServices.DeviceService.GetLatestCommitFromDevices(account, devicesUUID)
+		if err != nil {
+			ctxServices.Log.WithField("error", err.Error()).Error("Error getting the latest commit of the devices")
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.DeviceHasImageUndefined, *services.ImageHasNoImageSet, *services.DeviceHasMoreThanOneImageSet, *services.DeviceHasNoImageUpdate:
+				apiError = errors.NewBadRequest(err.Error())
+			default:
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxServices.Log, apiError)
+			return
+		}
+	}
+
+	preview, err := ctxServices.UpdateService.PreviewUpdate(account, previewRequest.CommitID, devicesUUID)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error previewing update")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.CommitNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, preview)
+}
+
+// maxUpdateBundleSourceCommits is the maximum number of source commits of an update bundle
+const maxUpdateBundleSourceCommits = 10
+
+// UpdateBundleRequest is the request of an offline update bundle
+type UpdateBundleRequest struct {
+	// CommitID is the commit the devices are updated to
+	CommitID uint `json:"CommitID"`
+	// SourceCommitIDs are the commits the devices run, the bundle has static deltas from them
+	SourceCommitIDs []uint `json:"SourceCommitIDs,omitempty"`
+}
+
+// CreateUpdateBundle creates the offline update bundle of a commit, the bundle is built in the background
+func CreateUpdateBundle(w http.ResponseWriter, r *http.Request) {
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	account, err := common.GetAccount(r)
+	if err != nil {
+		ctxServices.Log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"account": account,
+		}).Error("Error retrieving account")
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+		return
+	}
+
+	var bundleRequest UpdateBundleRequest
+	if err := readRequestJSONBody(w, r, ctxServices.Log, &bundleRequest); err != nil {
+		return
+	}
+	if bundleRequest.CommitID == 0 {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest("CommitID required."))
+		return
+	}
+	if len(bundleRequest.SourceCommitIDs) > maxUpdateBundleSourceCommits {
+		respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(fmt.Sprintf("An update bundle can't have more than %d source commits.", maxUpdateBundleSourceCommits)))
+		return
+	}
+
+	bundle, err := ctxServices.UpdateService.CreateUpdateBundle(account, bundleRequest.CommitID, bundleRequest.SourceCommitIDs)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error creating update bundle")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.CommitNotFound:
+			apiError = errors.NewNotFound(err.Error())
+		case *services.UpdateBundleCommitNotBuilt:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+	if _, err := ctxServices.JobService.Enqueue(models.JobTypeUpdateBundleBuild, bundle.Account, bundle.ID); err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error enqueuing update bundle build")
+		respondWithAPIError(w, ctxServices.Log, errors.NewInternalServerError())
+		return
+	}
+
+	respondWithJSONBody(w, ctxServices.Log, bundle)
+}
+
+// UpdateBundleCtx is a handler for Update Bundle requests
+func UpdateBundleCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxServices := dependencies.ServicesFromContext(r.Context())
+		account, err := common.GetAccount(r)
+		if err != nil {
+			ctxServices.Log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"account": account,
+			}).Error("Error retrieving account")
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+			return
+		}
+		bundleID := chi.URLParam(r, "bundleID")
+		ctxServices.Log = ctxServices.Log.WithField("updateBundleID", bundleID)
+		id, err := strconv.Atoi(bundleID)
+		if err != nil {
+			respondWithAPIError(w, ctxServices.Log, errors.NewBadRequest(err.Error()))
+			return
+		}
+		bundle, err := ctxServices.UpdateService.GetUpdateBundleByID(account, uint(id))
+		if err != nil {
+			var apiError errors.APIError
+			switch err.(type) {
+			case *services.UpdateBundleNotFound:
+				apiError = errors.NewNotFound(err.Error())
+			default:
+				ctxServices.Log.WithField("error", err.Error()).Error("Error retrieving update bundle")
+				apiError = errors.NewInternalServerError()
+			}
+			respondWithAPIError(w, ctxServices.Log, apiError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), UpdateBundleContextKey, bundle)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getUpdateBundle(r *http.Request) *models.UpdateBundle {
+	bundle, ok := r.Context().Value(UpdateBundleContextKey).(*models.UpdateBundle)
+	if !ok {
+		// Error set by UpdateBundleCtx already
+		return nil
+	}
+	return bundle
+}
+
+// GetUpdateBundleByID returns an update bundle
+func GetUpdateBundleByID(w http.ResponseWriter, r *http.Request) {
+	bundle := getUpdateBundle(r)
+	if bundle == nil {
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	respondWithJSONBody(w, ctxServices.Log, bundle)
+}
+
+// GetUpdateBundleDownload returns where a built update bundle is downloaded from
+func GetUpdateBundleDownload(w http.ResponseWriter, r *http.Request) {
+	bundle := getUpdateBundle(r)
+	if bundle == nil {
+		return
+	}
+	ctxServices := dependencies.ServicesFromContext(r.Context())
+	download, err := ctxServices.UpdateService.GetUpdateBundleDownload(bundle)
+	if err != nil {
+		ctxServices.Log.WithField("error", err.Error()).Error("Error getting update bundle download")
+		var apiError errors.APIError
+		switch err.(type) {
+		case *services.UpdateBundleNotReady:
+			apiError = errors.NewBadRequest(err.Error())
+		default:
+			apiError = errors.NewInternalServerError()
+		}
+		respondWithAPIError(w, ctxServices.Log, apiError)
+		return
+	}
+	respondWithJSONBody(w, ctxServices.Log, download)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/clients/inventory"
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/services"
 	"github.com/redhatinsights/platform-go-middlewares/identity"
@@ -14,6 +15,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bxcodec/faker/v3"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -108,6 +110,417 @@ var _ = Describe("Update routes", func() {
 			Log:           logger,
 		}
 	})
+	Context("POST AddUpdate with a rollout plan", func() {
+		When("when the rollout waves target more than all the devices", func() {
+			It("should return bad request", func() {
+				jsonBytes, err := json.Marshal(DevicesUpdate{
+					DevicesUUID: []string{"1", "2"},
+					Rollout:     &models.UpdateRolloutPlan{Waves: []int{60, 60}},
+				})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				req = req.WithContext(ctx)
+				handler := http.HandlerFunc(AddUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+	Context("POST AddUpdate by tag", func() {
+		var ctrl *gomock.Controller
+		var mockDeviceService *mock_services.MockDeviceServiceInterface
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			mockDeviceService = mock_services.NewMockDeviceServiceInterface(ctrl)
+			edgeAPIServices.DeviceService = mockDeviceService
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		When("when the tag is not in the namespace/key=value format", func() {
+			It("should return bad request", func() {
+				jsonBytes, err := json.Marshal(DevicesUpdate{Tag: "location=boston"})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(AddUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when the tagged devices run images of different image sets", func() {
+			It("should return bad request", func() {
+				tag := "insights-client/location=boston"
+				var inventoryDevices inventory.Response
+				for _, name := range []string{"image-tag-1", "image-tag-2"} {
+					imageSet := models.ImageSet{Name: name, Account: "0000000"}
+					db.DB.Create(&imageSet)
+					image := models.Image{Name: name, Account: "0000000", ImageSetID: &imageSet.ID}
+					db.DB.Create(&image)
+					device := models.Device{UUID: faker.UUIDHyphenated(), Account: "0000000", ImageID: image.ID}
+					db.DB.Create(&device)
+					inventoryDevices.Result = append(inventoryDevices.Result, inventory.Device{ID: device.UUID})
+				}
+				inventoryDevices.Count = len(inventoryDevices.Result)
+				inventoryDevices.Total = len(inventoryDevices.Result)
+				mockDeviceService.EXPECT().GetInventoryDevicesByTag(tag).Return(inventoryDevices, nil)
+
+				jsonBytes, err := json.Marshal(DevicesUpdate{Tag: tag})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(AddUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when a tagged device is not known", func() {
+			It("should report the device whose image can't be resolved", func() {
+				tag := "insights-client/location=paris"
+				imageSet := models.ImageSet{Name: "image-tag-known", Account: "0000000"}
+				db.DB.Create(&imageSet)
+				image := models.Image{Name: "image-tag-known", Account: "0000000", ImageSetID: &imageSet.ID}
+				db.DB.Create(&image)
+				device := models.Device{UUID: faker.UUIDHyphenated(), Account: "0000000", ImageID: image.ID}
+				db.DB.Create(&device)
+				unknownUUID := faker.UUIDHyphenated()
+				mockDeviceService.EXPECT().GetInventoryDevicesByTag(tag).Return(inventory.Response{
+					Count:  2,
+					Total:  2,
+					Result: []inventory.Device{{ID: device.UUID}, {ID: unknownUUID}},
+				}, nil)
+
+				jsonBytes, err := json.Marshal(DevicesUpdate{Tag: tag})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(AddUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				Expect(rr.Body.String()).To(ContainSubstring(unknownUUID))
+			})
+		})
+		When("when a tagged device not known boots an image of another image set", func() {
+			It("should return bad request", func() {
+				tag := "insights-client/location=rome"
+				var inventoryDevices inventory.Response
+				for i, name := range []string{"image-tag-stored", "image-tag-booted"} {
+					imageSet := models.ImageSet{Name: name, Account: "0000000"}
+					db.DB.Create(&imageSet)
+					commit := models.Commit{Account: "0000000", OSTreeCommit: faker.UUIDHyphenated()}
+					db.DB.Create(&commit)
+					image := models.Image{Name: name, Account: "0000000", ImageSetID: &imageSet.ID, CommitID: commit.ID}
+					db.DB.Create(&image)
+					inventoryDevice := inventory.Device{ID: faker.UUIDHyphenated()}
+					if i == 0 {
+						db.DB.Create(&models.Device{UUID: inventoryDevice.ID, Account: "0000000", ImageID: image.ID})
+					} else {
+						inventoryDevice.Ostree.RpmOstreeDeployments = []inventory.OSTree{{Checksum: commit.OSTreeCommit, Booted: true}}
+					}
+					inventoryDevices.Result = append(inventoryDevices.Result, inventoryDevice)
+				}
+				inventoryDevices.Count = len(inventoryDevices.Result)
+				inventoryDevices.Total = len(inventoryDevices.Result)
+				mockDeviceService.EXPECT().GetInventoryDevicesByTag(tag).Return(inventoryDevices, nil)
+
+				jsonBytes, err := json.Marshal(DevicesUpdate{Tag: tag})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(AddUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				Expect(rr.Body.String()).To(ContainSubstring("same image set"))
+			})
+		})
+	})
+	Context("POST CancelUpdate", func() {
+		When("when the update is finished", func() {
+			It("should return bad request", func() {
+				update := models.UpdateTransaction{Account: "0000000", Status: models.UpdateStatusSuccess}
+				db.DB.Create(&update)
+
+				req, err := http.NewRequest(http.MethodPost, "/", nil)
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := context.WithValue(req.Context(), UpdateContextKey, &update)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				handler := http.HandlerFunc(CancelUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when the update is building", func() {
+			It("should cancel the update", func() {
+				update := models.UpdateTransaction{Account: "0000000", Status: models.UpdateStatusBuilding}
+				db.DB.Create(&update)
+
+				req, err := http.NewRequest(http.MethodPost, "/", nil)
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := context.WithValue(req.Context(), UpdateContextKey, &update)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				handler := http.HandlerFunc(CancelUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				var response models.UpdateTransaction
+				Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(BeNil())
+				Expect(response.Status).To(Equal(models.UpdateStatusCancelled))
+			})
+		})
+	})
+	Context("GET GetUpdateEvents", func() {
+		It("should return the timeline of the update", func() {
+			update := models.UpdateTransaction{Account: "0000000", Status: models.UpdateStatusBuilding}
+			db.DB.Create(&update)
+			update.Status = models.UpdateStatusError
+			db.DB.Save(&update)
+
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			Expect(err).To(BeNil())
+
+			rr := httptest.NewRecorder()
+			ctx := context.WithValue(req.Context(), UpdateContextKey, &update)
+			ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+			handler := http.HandlerFunc(GetUpdateEvents)
+
+			handler.ServeHTTP(rr, req.WithContext(ctx))
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var events []models.Event
+			Expect(json.Unmarshal(rr.Body.Bytes(), &events)).To(BeNil())
+			Expect(events).To(HaveLen(2))
+			Expect(events[0].NewStatus).To(Equal(models.UpdateStatusBuilding))
+			Expect(events[1].OldStatus).To(Equal(models.UpdateStatusBuilding))
+			Expect(events[1].NewStatus).To(Equal(models.UpdateStatusError))
+			Expect(events[1].Actor).To(Equal(models.EventActorEdgeAPI))
+		})
+	})
+	Context("POST RetryUpdate", func() {
+		When("when no device of the update failed", func() {
+			It("should return bad request", func() {
+				update := models.UpdateTransaction{
+					Account:         "0000000",
+					Status:          models.UpdateStatusSuccess,
+					Repo:            &models.Repo{Status: models.RepoStatusSuccess},
+					DispatchRecords: []models.DispatchRecord{{Status: models.DispatchRecordStatusComplete}},
+				}
+				db.DB.Create(&update)
+
+				req, err := http.NewRequest(http.MethodPost, "/", nil)
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := context.WithValue(req.Context(), UpdateContextKey, &update)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				handler := http.HandlerFunc(RetryUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+	Context("POST ResumeUpdate", func() {
+		When("when the update is not paused", func() {
+			It("should return bad request", func() {
+				update := models.UpdateTransaction{Account: "0000000", Status: models.UpdateStatusBuilding}
+				db.DB.Create(&update)
+
+				req, err := http.NewRequest(http.MethodPost, "/", nil)
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := context.WithValue(req.Context(), UpdateContextKey, &update)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				handler := http.HandlerFunc(ResumeUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+	Context("POST PostPreviewUpdate", func() {
+		When("when neither devices nor device group are given", func() {
+			It("should return bad request", func() {
+				jsonBytes, err := json.Marshal(UpdatePreviewRequest{CommitID: 1})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(PostPreviewUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when the devices and the commit are given", func() {
+			It("should return the update preview", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				defer ctrl.Finish()
+				devicesUUID := []string{faker.UUIDHyphenated()}
+				preview := &models.UpdatePreview{CommitID: 1, DevicesCount: 1, UpdatedCount: 1}
+				mockUpdateService := mock_services.NewMockUpdateServiceInterface(ctrl)
+				mockUpdateService.EXPECT().PreviewUpdate(gomock.Any(), uint(1), devicesUUID).Return(preview, nil)
+				edgeAPIServices.UpdateService = mockUpdateService
+
+				jsonBytes, err := json.Marshal(UpdatePreviewRequest{CommitID: 1, DevicesUUID: devicesUUID})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(PostPreviewUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				var response models.UpdatePreview
+				Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.UpdatedCount).To(Equal(1))
+			})
+		})
+		When("when the commit is not found", func() {
+			It("should return not found", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				defer ctrl.Finish()
+				mockUpdateService := mock_services.NewMockUpdateServiceInterface(ctrl)
+				mockUpdateService.EXPECT().PreviewUpdate(gomock.Any(), uint(99), gomock.Any()).Return(nil, new(services.CommitNotFound))
+				edgeAPIServices.UpdateService = mockUpdateService
+
+				jsonBytes, err := json.Marshal(UpdatePreviewRequest{CommitID: 99, DevicesUUID: []string{faker.UUIDHyphenated()}})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(PostPreviewUpdate)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+	Context("POST CreateUpdateBundle", func() {
+		When("when the commit is undefined", func() {
+			It("should return bad request", func() {
+				jsonBytes, err := json.Marshal(UpdateBundleRequest{SourceCommitIDs: []uint{1}})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(CreateUpdateBundle)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when there are too many source commits", func() {
+			It("should return bad request", func() {
+				jsonBytes, err := json.Marshal(UpdateBundleRequest{CommitID: 1, SourceCommitIDs: []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(CreateUpdateBundle)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+		When("when the commit doesn't exist", func() {
+			It("should return not found", func() {
+				jsonBytes, err := json.Marshal(UpdateBundleRequest{CommitID: 99999})
+				Expect(err).To(BeNil())
+
+				req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBytes))
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := dependencies.ContextWithServices(req.Context(), edgeAPIServices)
+				handler := http.HandlerFunc(CreateUpdateBundle)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+	Context("GET GetUpdateBundleDownload", func() {
+		When("when the update bundle is building", func() {
+			It("should return bad request", func() {
+				bundle := models.UpdateBundle{Account: "0000000", Status: models.UpdateBundleStatusBuilding}
+				db.DB.Create(&bundle)
+
+				req, err := http.NewRequest(http.MethodGet, "/", nil)
+				Expect(err).To(BeNil())
+
+				rr := httptest.NewRecorder()
+				ctx := context.WithValue(req.Context(), UpdateBundleContextKey, &bundle)
+				ctx = dependencies.ContextWithServices(ctx, edgeAPIServices)
+				handler := http.HandlerFunc(GetUpdateBundleDownload)
+
+				handler.ServeHTTP(rr, req.WithContext(ctx))
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
 	Context("POST PostValidateUpdate", func() {
 		var imageSameGroup1 models.Image
 		var imageSameGroup2 models.Image
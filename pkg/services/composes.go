@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/clients/imagebuilder"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// composeSchedulerPollInterval is how often the scheduler looks for the composes due for a check
+const composeSchedulerPollInterval = 10 * time.Second
+
+// ComposeScheduler tracks the composes of the commits and the installers building on image builder
+// It checks the composes due in batches, checking again a compose with a backoff while image builder
+// reports it in the same state, and stores their progress so they are tracked again after a restart.
+// Once a compose is done, the build of its image is enqueued for the next step.
+// The schedulers of all the replicas share the composes: a compose is claimed for a check with a
+// conditional update, so only one scheduler checks it.
+type ComposeScheduler struct {
+	// BatchSize is the number of composes checked at once
+	BatchSize int
+	// CheckInterval is the delay before the next check of a compose, doubled for every check that finds it in the same state
+	CheckInterval time.Duration
+	// MaxCheckInterval is the longest delay between two checks of a compose
+	MaxCheckInterval time.Duration
+	// PollInterval is how often the scheduler looks for composes due
+	PollInterval time.Duration
+	// ImageBuilder returns the client checking the composes, the image builder client of the config when not set
+	ImageBuilder func(ctx context.Context, log *log.Entry) imagebuilder.ClientInterface
+
+	// Log is the logger of the scheduler, the standard logger when not set
+	Log *log.Entry
+}
+
+// NewComposeScheduler gives a scheduler of the composes configured from the config
+func NewComposeScheduler(log *log.Entry) *ComposeScheduler {
+	cfg := config.Get()
+	return &ComposeScheduler{
+		BatchSize:        cfg.ComposeCheckBatchSize,
+		CheckInterval:    time.Duration(cfg.ComposeCheckInterval) * time.Second,
+		MaxCheckInterval: time.Duration(cfg.ComposeCheckMaxInterval) * time.Second,
+		PollInterval:     composeSchedulerPollInterval,
+		ImageBuilder:     imagebuilder.NewClient,
+		Log:              log.WithField("service", "compose-scheduler"),
+	}
+}
+
+// dueCompose is a compose due for a check
+type dueCompose struct {
+	ID            uint
+	ComposeJobID  string
+	ComposeChecks int
+}
+
+// composeArtifacts are the tables of the artifacts composed on image builder with the column of their image
+var composeArtifacts = []struct {
+	table       string
+	imageColumn string
+}{
+	{table: "commits", imageColumn: "images.commit_id"},
+	{table: "installers", imageColumn: "images.installer_id"},
+}
+
+func (s *ComposeScheduler) logger() *log.Entry {
+	if s.Log == nil {
+		return log.NewEntry(log.StandardLogger())
+	}
+	return s.Log
+}
+
+// Start checks the composes until ctx is done
+func (s *ComposeScheduler) Start(ctx context.Context) {
+	s.logger().Info("Starting compose scheduler")
+	for ctx.Err() == nil {
+		checked, err := s.CheckComposes()
+		if err != nil {
+			s.logger().WithField("error", err.Error()).Error("Error checking composes")
+		}
+		if checked > 0 && err == nil {
+			// there may be more composes due
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.PollInterval):
+		}
+	}
+	s.logger().Info("Compose scheduler stopped")
+}
+
+// CheckComposes checks a batch of the composes due, the ones of the commits first, and returns the number it checked
+func (s *ComposeScheduler) CheckComposes() (int, error) {
+	batchSize := s.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	checked := 0
+	for _, artifact := range composeArtifacts {
+		if checked >= batchSize {
+			break
+		}
+		now := time.Now().UTC()
+		var composes []dueCompose
+		if result := db.DB.Table(artifact.table).Select("id, compose_job_id, compose_checks").
+			Where("deleted_at IS NULL AND status = ? AND compose_job_id <> ''", models.ImageStatusBuilding).
+			Where("compose_next_check_at IS NULL OR compose_next_check_at <= ?", now).
+			Order("compose_next_check_at").Limit(batchSize - checked).Scan(&composes); result.Error != nil {
+			return checked, result.Error
+		}
+		for _, compose := range composes {
+			claimed, err := s.claimCompose(artifact.table, compose)
+			if err != nil {
+				return checked, err
+			}
+			if !claimed {
+				continue
+			}
+			checked++
+			var image models.Image
+			if result := db.DB.Joins("Commit").Joins("Installer").Where(fmt.Sprintf("%s = ?", artifact.imageColumn), compose.ID).
+				First(&image); result.Error != nil {
+				s.logger().WithFields(log.Fields{"error": result.Error.Error(), "composeJobID": compose.ComposeJobID}).
+					Error("Error getting image of compose")
+				continue
+			}
+			if err := s.checkCompose(&image, artifact.table); err != nil {
+				s.logger().WithFields(log.Fields{"error": err.Error(), "imageID": image.ID, "composeJobID": compose.ComposeJobID}).
+					Error("Error checking compose")
+			}
+		}
+	}
+	return checked, nil
+}
+
+// claimCompose takes a compose for a check until the max interval, when it is not checked again by then,
+// because its replica stopped, it is checked by another scheduler
+func (s *ComposeScheduler) claimCompose(table string, compose dueCompose) (bool, error) {
+	// the compose is updated only if no other scheduler checked it since it was read
+	result := db.DB.Table(table).
+		Where("id = ? AND status = ? AND compose_job_id = ? AND compose_checks = ?",
+			compose.ID, models.ImageStatusBuilding, compose.ComposeJobID, compose.ComposeChecks).
+		UpdateColumns(map[string]interface{}{
+			"compose_checks":        compose.ComposeChecks + 1,
+			"compose_next_check_at": models.EdgeAPITime{Time: time.Now().UTC().Add(s.maxCheckInterval()), Valid: true},
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// checkCompose gets the state of the compose of the commit or the installer of an image from image builder and stores it
+// A compose done is saved with its final status and the build of its image is enqueued for the next step
+func (s *ComposeScheduler) checkCompose(image *models.Image, table string) error {
+	ctx := s.imageBuildContext(image)
+	logger := s.logger().WithFields(log.Fields{"imageID": image.ID, "commitID": image.Commit.ID})
+	imageBuilder := s.ImageBuilder
+	if imageBuilder == nil {
+		imageBuilder = imagebuilder.NewClient
+	}
+	client := imageBuilder(ctx, logger)
+
+	// image builder changes the image with the state of the compose it gets
+	var id uint
+	var artifact *models.ComposeProgress
+	var status *string
+	getStatus := client.GetInstallerStatus
+	if table == "commits" {
+		id, artifact, status = image.Commit.ID, &image.Commit.ComposeProgress, &image.Commit.Status
+		getStatus = client.GetCommitStatus
+	} else {
+		id, artifact, status = image.Installer.ID, &image.Installer.ComposeProgress, &image.Installer.Status
+	}
+	previousComposeStatus := artifact.ComposeStatus
+	_, err := getStatus(image)
+	s.recordComposeCheck(artifact, previousComposeStatus, err)
+	if err != nil || *status == models.ImageStatusBuilding {
+		result := db.DB.Table(table).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"compose_status":        artifact.ComposeStatus,
+			"compose_checked_at":    artifact.ComposeCheckedAt,
+			"compose_next_check_at": artifact.ComposeNextCheckAt,
+			"compose_checks":        artifact.ComposeChecks,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if err == nil {
+			logger.WithField("composeStatus", artifact.ComposeStatus).Debug("Compose is building")
+		}
+		return err
+	}
+
+	logger = logger.WithField("status", *status)
+	logger.Info("Compose is done")
+	imageService := NewImageService(ctx, logger).(*ImageService)
+	eventsCtx := models.ContextWithEventActor(ctx, models.EventActorImageBuilder)
+	recordKey := "postProcessCommit"
+	if table == "commits" {
+		if result := eventsDB(eventsCtx).Save(image.Commit); result.Error != nil {
+			return result.Error
+		}
+	} else {
+		recordKey = "postProcessInstaller"
+		if result := eventsDB(eventsCtx).Save(image.Installer); result.Error != nil {
+			return result.Error
+		}
+	}
+	if image.Status != models.ImageStatusBuilding {
+		if result := eventsDB(eventsCtx).Omit("Commit", "Installer").Save(image); result.Error != nil {
+			return result.Error
+		}
+	}
+	imageService.sendImageBuildEvent(recordKey, image)
+
+	if _, err := NewJobService(ctx, logger).Enqueue(models.JobTypeImageBuild, image.Account, image.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recordComposeCheck sets the progress of a compose after a check: the delay before the next check
+// is doubled for every check finding the compose in the same state, it is reset when the state changes
+func (s *ComposeScheduler) recordComposeCheck(progress *models.ComposeProgress, previousComposeStatus string, err error) {
+	now := time.Now().UTC()
+	if err == nil {
+		progress.ComposeCheckedAt = models.EdgeAPITime{Time: now, Valid: true}
+		if progress.ComposeStatus != previousComposeStatus {
+			progress.ComposeChecks = 1
+		}
+	} else {
+		// image builder didn't answer, the compose is checked again with the same backoff
+		progress.ComposeStatus = previousComposeStatus
+	}
+	progress.ComposeNextCheckAt = models.EdgeAPITime{Time: now.Add(s.checkInterval(progress.ComposeChecks)), Valid: true}
+}
+
+// checkInterval returns the delay before the next check of a compose found in the same state by the given checks
+func (s *ComposeScheduler) checkInterval(checks int) time.Duration {
+	if checks < 1 {
+		checks = 1
+	}
+	interval := s.CheckInterval
+	for i := 1; i < checks && interval < s.maxCheckInterval(); i++ {
+		interval *= 2
+	}
+	if interval > s.maxCheckInterval() {
+		return s.maxCheckInterval()
+	}
+	return interval
+}
+
+// maxCheckInterval returns the longest delay between two checks of a compose
+func (s *ComposeScheduler) maxCheckInterval() time.Duration {
+	if s.MaxCheckInterval < s.CheckInterval {
+		return s.CheckInterval
+	}
+	return s.MaxCheckInterval
+}
+
+// imageBuildContext returns the context the composes of an image are checked with:
+// the identity of the request that started its build, kept with its last build job
+func (s *ComposeScheduler) imageBuildContext(image *models.Image) context.Context {
+	var job models.Job
+	result := db.DB.Where("type = ? AND resource_id = ?", models.JobTypeImageBuild, image.ID).Order("id DESC").Limit(1).Find(&job)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return context.Background()
+	}
+	return jobContext(&job)
+}
@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/pkg/clients/imagebuilder"
+	"github.com/redhatinsights/edge-api/pkg/clients/imagebuilder/mock_imagebuilder"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Compose scheduler", func() {
+	var scheduler *services.ComposeScheduler
+	var mockImageBuilderClient *mock_imagebuilder.MockClientInterface
+	var image *models.Image
+	// composeStatus is the state image builder reports for the compose of the commit of the image
+	var composeStatus string
+	var composeErr error
+	getCommit := func() models.Commit {
+		var commit models.Commit
+		Expect(db.DB.First(&commit, image.Commit.ID).Error).ToNot(HaveOccurred())
+		return commit
+	}
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		mockImageBuilderClient = mock_imagebuilder.NewMockClientInterface(ctrl)
+		scheduler = &services.ComposeScheduler{
+			BatchSize:        1000,
+			CheckInterval:    time.Minute,
+			MaxCheckInterval: 10 * time.Minute,
+			ImageBuilder: func(ctx context.Context, log *log.Entry) imagebuilder.ClientInterface {
+				return mockImageBuilderClient
+			},
+		}
+		image = &models.Image{
+			Account:     faker.UUIDHyphenated(),
+			Name:        faker.UUIDHyphenated(),
+			Status:      models.ImageStatusBuilding,
+			OutputTypes: []string{models.ImageTypeCommit},
+			Commit:      &models.Commit{Status: models.ImageStatusBuilding, ComposeJobID: faker.UUIDHyphenated()},
+		}
+		Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+		composeStatus = models.ComposeStatusBuilding
+		composeErr = nil
+		// the composes of the other images stay building
+		mockImageBuilderClient.EXPECT().GetCommitStatus(gomock.Any()).DoAndReturn(func(i *models.Image) (*models.Image, error) {
+			if i.ID != image.ID {
+				return i, nil
+			}
+			if composeErr != nil {
+				return nil, composeErr
+			}
+			i.Commit.ComposeStatus = composeStatus
+			switch composeStatus {
+			case models.ComposeStatusSuccess:
+				i.Commit.Status = models.ImageStatusSuccess
+			case models.ComposeStatusFailure:
+				i.Commit.Status = models.ImageStatusError
+				i.Status = models.ImageStatusError
+			}
+			return i, nil
+		}).AnyTimes()
+		mockImageBuilderClient.EXPECT().GetInstallerStatus(gomock.Any()).DoAndReturn(func(i *models.Image) (*models.Image, error) {
+			return i, nil
+		}).AnyTimes()
+	})
+	It("should store the state of a compose and check it again later", func() {
+		composeStatus = models.ComposeStatusUploading
+		checked, err := scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(checked).To(BeNumerically(">=", 1))
+
+		commit := getCommit()
+		Expect(commit.Status).To(Equal(models.ImageStatusBuilding))
+		Expect(commit.ComposeStatus).To(Equal(models.ComposeStatusUploading))
+		Expect(commit.ComposeCheckedAt.Valid).To(BeTrue())
+		Expect(commit.ComposeNextCheckAt.Time).To(BeTemporally("~", time.Now().Add(time.Minute), 5*time.Second))
+
+		// the compose is not due anymore
+		_, err = scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(getCommit().ComposeChecks).To(Equal(commit.ComposeChecks))
+	})
+	It("should check a compose found in the same state with a backoff", func() {
+		_, err := scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.DB.Model(&models.Commit{}).Where("id = ?", image.Commit.ID).
+			UpdateColumn("compose_next_check_at", models.EdgeAPITime{Time: time.Now().Add(-time.Second), Valid: true}).Error).ToNot(HaveOccurred())
+
+		_, err = scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+		commit := getCommit()
+		Expect(commit.ComposeStatus).To(Equal(models.ComposeStatusBuilding))
+		Expect(commit.ComposeChecks).To(Equal(2))
+		Expect(commit.ComposeNextCheckAt.Time).To(BeTemporally("~", time.Now().Add(2*time.Minute), 5*time.Second))
+	})
+	It("should check a compose again later when image builder is unavailable", func() {
+		composeErr = fmt.Errorf("image builder is unavailable")
+		_, err := scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+
+		commit := getCommit()
+		Expect(commit.Status).To(Equal(models.ImageStatusBuilding))
+		Expect(commit.ComposeCheckedAt.Valid).To(BeFalse())
+		Expect(commit.ComposeNextCheckAt.Time).To(BeTemporally(">", time.Now()))
+	})
+	It("should enqueue the next step of the image build once the compose is done", func() {
+		composeStatus = models.ComposeStatusSuccess
+		_, err := scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+
+		commit := getCommit()
+		Expect(commit.Status).To(Equal(models.ImageStatusSuccess))
+		Expect(commit.ComposeStatus).To(Equal(models.ComposeStatusSuccess))
+		var events []models.Event
+		Expect(db.DB.Where("resource_type = ? AND resource_id = ?", models.EventResourceCommit, commit.ID).Find(&events).Error).ToNot(HaveOccurred())
+		Expect(events).ToNot(BeEmpty())
+		Expect(events[len(events)-1].NewStatus).To(Equal(models.ImageStatusSuccess))
+		var jobs []models.Job
+		Expect(db.DB.Where("type = ? AND resource_id = ?", models.JobTypeImageBuild, image.ID).Find(&jobs).Error).ToNot(HaveOccurred())
+		Expect(jobs).To(HaveLen(1))
+	})
+	It("should set the error status on the image whose compose failed", func() {
+		composeStatus = models.ComposeStatusFailure
+		_, err := scheduler.CheckComposes()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getCommit().Status).To(Equal(models.ImageStatusError))
+		var saved models.Image
+		Expect(db.DB.First(&saved, image.ID).Error).ToNot(HaveOccurred())
+		Expect(saved.Status).To(Equal(models.ImageStatusError))
+	})
+})
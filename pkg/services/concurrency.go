@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+// ConcurrencyLimit is the number of holders that can take a slot of a scope at the same time, 0 or less is no limit
+type ConcurrencyLimit struct {
+	Scope string
+	Limit int
+}
+
+const (
+	// concurrencyScopeUpdateBuilds is the scope of the update repos built at the same time
+	concurrencyScopeUpdateBuilds = "update-builds"
+	// concurrencyScopeRollouts is the scope of the updates rolled out at the same time
+	concurrencyScopeRollouts = "rollouts"
+)
+
+// accountScope returns the scope of an account in a global scope
+func accountScope(scope string, account string) string {
+	return fmt.Sprintf("%s/%s", scope, account)
+}
+
+// AcquireConcurrencySlots takes a slot of every limit for the holder, a holder keeps the slots it already has
+// It returns false, without taking any slot, when a limit is reached
+// The slots are unique in the database, the limits hold across all the replicas
+func AcquireConcurrencySlots(holderType string, holderID uint, limits []ConcurrencyLimit) (bool, error) {
+	var acquired []models.ConcurrencySlot
+	for _, limit := range limits {
+		if limit.Limit <= 0 {
+			continue
+		}
+		slot, err := acquireConcurrencySlot(holderType, holderID, limit)
+		if err == nil && slot == nil {
+			// the slots of the holders that are done are released lazily, when a limit is reached
+			if err = releaseStaleConcurrencySlots(limit.Scope); err == nil {
+				slot, err = acquireConcurrencySlot(holderType, holderID, limit)
+			}
+		}
+		if err != nil || slot == nil {
+			for i := range acquired {
+				if result := db.DB.Unscoped().Delete(&acquired[i]); result.Error != nil && err == nil {
+					err = fmt.Errorf("error releasing concurrency slot %d of %s :: %s", acquired[i].Slot, acquired[i].Scope, result.Error.Error())
+				}
+			}
+			return false, err
+		}
+		if slot.ID != 0 {
+			acquired = append(acquired, *slot)
+		}
+	}
+	return true, nil
+}
+
+// acquireConcurrencySlot takes a free slot of the scope for the holder, it returns nil when all the slots are taken
+// The slot returned has no ID when the holder already had it
+func acquireConcurrencySlot(holderType string, holderID uint, limit ConcurrencyLimit) (*models.ConcurrencySlot, error) {
+	var slots []models.ConcurrencySlot
+	if result := db.DB.Where("scope = ?", limit.Scope).Find(&slots); result.Error != nil {
+		return nil, result.Error
+	}
+	taken := make(map[int]bool, len(slots))
+	for _, slot := range slots {
+		if slot.HolderType == holderType && slot.HolderID == holderID {
+			return &models.ConcurrencySlot{Scope: slot.Scope, Slot: slot.Slot, HolderType: holderType, HolderID: holderID}, nil
+		}
+		taken[slot.Slot] = true
+	}
+	for i := 0; i < limit.Limit; i++ {
+		if taken[i] {
+			continue
+		}
+		slot := models.ConcurrencySlot{Scope: limit.Scope, Slot: i, HolderType: holderType, HolderID: holderID}
+		// another replica may take the slot first
+		result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&slot)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &slot, nil
+		}
+	}
+	return nil, nil
+}
+
+// releaseStaleConcurrencySlots releases the slots of the scope held by the jobs that are not running anymore
+// and by the update transactions whose rollout is over, the slots of the updates that ended are released
+// when they end, the ones left are of the updates that ended before
+// An update on error still dispatching the devices held back by its schedule keeps its rollout slot
+func releaseStaleConcurrencySlots(scope string) error {
+	if result := db.DB.Unscoped().Where("scope = ? AND holder_type = ? AND holder_id NOT IN (SELECT id FROM jobs WHERE status = ?)",
+		scope, models.ConcurrencySlotHolderJob, models.JobStatusRunning).Delete(&models.ConcurrencySlot{}); result.Error != nil {
+		return result.Error
+	}
+	return db.DB.Unscoped().Where("scope = ? AND holder_type = ?", scope, models.ConcurrencySlotHolderUpdateTransaction).
+		Where("holder_id NOT IN (SELECT id FROM update_transactions WHERE status IN ?)",
+			[]string{models.UpdateStatusCreated, models.UpdateStatusQueued, models.UpdateStatusBuilding, models.UpdateStatusPaused}).
+		Where(`holder_id NOT IN (SELECT updatetransaction_dispatchrecords.update_transaction_id FROM updatetransaction_dispatchrecords
+			JOIN dispatch_records ON dispatch_records.id = updatetransaction_dispatchrecords.dispatch_record_id
+			WHERE dispatch_records.status NOT IN ?)`, finalDispatchRecordStatuses).
+		Delete(&models.ConcurrencySlot{}).Error
+}
+
+// ReleaseConcurrencySlots releases all the slots of the holder
+func ReleaseConcurrencySlots(holderType string, holderID uint) error {
+	return db.DB.Unscoped().Where("holder_type = ? AND holder_id = ?", holderType, holderID).Delete(&models.ConcurrencySlot{}).Error
+}
+
+// acquireUpdateBuildSlots takes the slots an update build job needs to run: a slot of the update builds, released
+// when the job is done, and a slot of the rollouts for its update, released once the update rollout is over
+// It returns false when the account, or all the accounts, reached the limit of update builds or rollouts
+func acquireUpdateBuildSlots(job *models.Job) (bool, error) {
+	cfg := config.Get()
+	acquired, err := AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID, []ConcurrencyLimit{
+		{Scope: accountScope(concurrencyScopeUpdateBuilds, job.Account), Limit: cfg.UpdateBuildsPerAccount},
+		{Scope: concurrencyScopeUpdateBuilds, Limit: cfg.UpdateBuildsGlobal},
+	})
+	if err != nil || !acquired {
+		return false, err
+	}
+	acquired, err = acquireRolloutSlots(job.Account, job.ResourceID)
+	if err != nil || !acquired {
+		if releaseErr := ReleaseConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// acquireRolloutSlots takes a slot of the rollouts for an update, released once the update rollout is over
+// It returns false when the account, or all the accounts, reached the limit of rollouts
+func acquireRolloutSlots(account string, updateID uint) (bool, error) {
+	cfg := config.Get()
+	return AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, updateID, []ConcurrencyLimit{
+		{Scope: accountScope(concurrencyScopeRollouts, account), Limit: cfg.RolloutsPerAccount},
+		{Scope: concurrencyScopeRollouts, Limit: cfg.RolloutsGlobal},
+	})
+}
@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
+)
+
+var _ = Describe("Concurrency limits", func() {
+	var accountScope, globalScope string
+	var limits []services.ConcurrencyLimit
+	newRunningJob := func() models.Job {
+		job := models.Job{Account: faker.UUIDHyphenated(), Type: faker.UUIDHyphenated(), Status: models.JobStatusRunning}
+		Expect(db.DB.Create(&job).Error).ToNot(HaveOccurred())
+		return job
+	}
+	countSlots := func(scope string) int64 {
+		var count int64
+		Expect(db.DB.Model(&models.ConcurrencySlot{}).Where("scope = ?", scope).Count(&count).Error).ToNot(HaveOccurred())
+		return count
+	}
+	BeforeEach(func() {
+		accountScope = faker.UUIDHyphenated()
+		globalScope = faker.UUIDHyphenated()
+		limits = []services.ConcurrencyLimit{{Scope: accountScope, Limit: 1}, {Scope: globalScope, Limit: 2}}
+	})
+
+	It("should take a slot of every limit", func() {
+		job := newRunningJob()
+		acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(countSlots(accountScope)).To(Equal(int64(1)))
+		Expect(countSlots(globalScope)).To(Equal(int64(1)))
+
+		// the holder keeps its slots
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(countSlots(accountScope)).To(Equal(int64(1)))
+	})
+	It("should not take any slot when a limit is reached", func() {
+		first := newRunningJob()
+		acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, first.ID,
+			[]services.ConcurrencyLimit{{Scope: globalScope, Limit: 2}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		second := newRunningJob()
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, second.ID,
+			[]services.ConcurrencyLimit{{Scope: globalScope, Limit: 2}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+
+		third := newRunningJob()
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, third.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeFalse())
+		Expect(countSlots(accountScope)).To(BeZero())
+		Expect(countSlots(globalScope)).To(Equal(int64(2)))
+	})
+	It("should release the slots of the holders that are done when a limit is reached", func() {
+		done := newRunningJob()
+		acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, done.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(db.DB.Model(&done).Update("status", models.JobStatusSuccess).Error).ToNot(HaveOccurred())
+
+		job := newRunningJob()
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(countSlots(accountScope)).To(Equal(int64(1)))
+	})
+	It("should keep the slots of an update until its rollout is over", func() {
+		update := models.UpdateTransaction{Account: faker.UUIDHyphenated(), Status: models.UpdateStatusBuilding}
+		Expect(db.DB.Create(&update).Error).ToNot(HaveOccurred())
+		acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, update.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+
+		other := models.UpdateTransaction{Account: update.Account, Status: models.UpdateStatusCreated}
+		Expect(db.DB.Create(&other).Error).ToNot(HaveOccurred())
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, other.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeFalse())
+
+		Expect(db.DB.Model(&update).Update("status", models.UpdateStatusSuccess).Error).ToNot(HaveOccurred())
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, other.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+	})
+	It("should keep the slots of an update on error still dispatching its devices", func() {
+		update := models.UpdateTransaction{Account: faker.UUIDHyphenated(), Status: models.UpdateStatusBuilding}
+		Expect(db.DB.Create(&update).Error).ToNot(HaveOccurred())
+		acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, update.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		record := models.DispatchRecord{Status: models.DispatchRecordStatusCreated}
+		Expect(db.DB.Create(&record).Error).ToNot(HaveOccurred())
+		Expect(db.DB.Model(&update).Association("DispatchRecords").Append(&record)).ToNot(HaveOccurred())
+		Expect(db.DB.Model(&update).Update("status", models.UpdateStatusError).Error).ToNot(HaveOccurred())
+
+		other := models.UpdateTransaction{Account: update.Account, Status: models.UpdateStatusCreated}
+		Expect(db.DB.Create(&other).Error).ToNot(HaveOccurred())
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, other.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeFalse())
+
+		Expect(db.DB.Model(&record).Update("status", models.DispatchRecordStatusComplete).Error).ToNot(HaveOccurred())
+		acquired, err = services.AcquireConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, other.ID, limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+	})
+	It("should not limit a scope without limit", func() {
+		for i := 0; i < 3; i++ {
+			job := newRunningJob()
+			acquired, err := services.AcquireConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID,
+				[]services.ConcurrencyLimit{{Scope: globalScope, Limit: 0}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(acquired).To(BeTrue())
+		}
+		Expect(countSlots(globalScope)).To(BeZero())
+	})
+})
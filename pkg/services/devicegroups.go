@@ -37,6 +37,10 @@ type DeviceGroupsServiceInterface interface {
 	DeleteDeviceGroupDevices(account string, deviceGroupID uint, devices []models.Device) (*[]models.Device, error)
 	GetDeviceImageInfo(setOfImages map[int]models.DeviceImageInfo, account string) error
 	DeviceGroupNameExists(account string, name string) (bool, error)
+	AddDeviceGroupMaintenanceWindow(account string, deviceGroupID uint, window *models.MaintenanceWindow) (*models.MaintenanceWindow, error)
+	DeleteDeviceGroupMaintenanceWindow(account string, deviceGroupID uint, windowID uint) error
+	GetDeviceGroupUpdateHooks(account string, deviceGroupID uint) (*models.UpdateHooks, error)
+	SetDeviceGroupUpdateHooks(account string, deviceGroupID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error)
 }
 
 // DeviceGroupsService is the main implementation of a DeviceGroupsServiceInterface
@@ -262,7 +266,7 @@ func (s *DeviceGroupsService) GetDeviceGroupByID(ID string) (*models.DeviceGroup
 	if err != nil {
 		return nil, new(AccountNotSet)
 	}
-	result := db.DB.Where("account = ? and id = ?", account, ID).Preload("Devices").First(&deviceGroup)
+	result := db.DB.Where("account = ? and id = ?", account, ID).Preload("Devices").Preload("MaintenanceWindows").First(&deviceGroup)
 	if result.Error != nil {
 		return nil, new(DeviceGroupNotFound)
 	}
@@ -452,3 +456,76 @@ func (s *DeviceGroupsService) DeleteDeviceGroupDevices(account string, deviceGro
 
 	return &devicesToRemove, nil
 }
+
+// AddDeviceGroupMaintenanceWindow adds a maintenance window to a device group
+func (s *DeviceGroupsService) AddDeviceGroupMaintenanceWindow(account string, deviceGroupID uint, window *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	if account == "" || deviceGroupID == 0 {
+		s.log.Debug("account and deviceGroupID must be defined")
+		return nil, new(DeviceGroupAccountOrIDUndefined)
+	}
+
+	// get the device group
+	var deviceGroup models.DeviceGroup
+	if res := db.DB.Where(models.DeviceGroup{Account: account}).First(&deviceGroup, deviceGroupID); res.Error != nil {
+		return nil, new(DeviceGroupNotFound)
+	}
+
+	maintenanceWindow := &models.MaintenanceWindow{
+		Account:       account,
+		DeviceGroupID: deviceGroup.ID,
+		Schedule:      window.Schedule,
+		Timezone:      window.Timezone,
+		Duration:      window.Duration,
+	}
+	s.log.Debug(fmt.Sprintf("adding maintenance window to device group id: %d", deviceGroup.ID))
+	if res := db.DB.Create(maintenanceWindow); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return maintenanceWindow, nil
+}
+
+// DeleteDeviceGroupMaintenanceWindow deletes a maintenance window from a device group
+func (s *DeviceGroupsService) DeleteDeviceGroupMaintenanceWindow(account string, deviceGroupID uint, windowID uint) error {
+	if account == "" || deviceGroupID == 0 {
+		s.log.Debug("account and deviceGroupID must be defined")
+		return new(DeviceGroupAccountOrIDUndefined)
+	}
+
+	res := db.DB.Where(models.MaintenanceWindow{Account: account, DeviceGroupID: deviceGroupID}).Delete(&models.MaintenanceWindow{}, windowID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return new(MaintenanceWindowNotFound)
+	}
+
+	return nil
+}
+
+// GetDeviceGroupUpdateHooks returns the update hooks of a device group
+func (s *DeviceGroupsService) GetDeviceGroupUpdateHooks(account string, deviceGroupID uint) (*models.UpdateHooks, error) {
+	if account == "" || deviceGroupID == 0 {
+		s.log.Debug("account and deviceGroupID must be defined")
+		return nil, new(DeviceGroupAccountOrIDUndefined)
+	}
+	var deviceGroup models.DeviceGroup
+	if res := db.DB.Where(models.DeviceGroup{Account: account}).First(&deviceGroup, deviceGroupID); res.Error != nil {
+		return nil, new(DeviceGroupNotFound)
+	}
+	return getUpdateHooks("account = ? AND device_group_id = ?", account, deviceGroup.ID)
+}
+
+// SetDeviceGroupUpdateHooks replaces the update hooks of a device group
+func (s *DeviceGroupsService) SetDeviceGroupUpdateHooks(account string, deviceGroupID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error) {
+	if account == "" || deviceGroupID == 0 {
+		s.log.Debug("account and deviceGroupID must be defined")
+		return nil, new(DeviceGroupAccountOrIDUndefined)
+	}
+	var deviceGroup models.DeviceGroup
+	if res := db.DB.Where(models.DeviceGroup{Account: account}).First(&deviceGroup, deviceGroupID); res.Error != nil {
+		return nil, new(DeviceGroupNotFound)
+	}
+	s.log.Debug(fmt.Sprintf("replacing the update hooks of device group id: %d", deviceGroup.ID))
+	return replaceUpdateHooks(models.UpdateHook{Account: account, DeviceGroupID: &deviceGroup.ID}, hooks)
+}
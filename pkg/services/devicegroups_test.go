@@ -92,6 +92,45 @@ var _ = Describe("DeviceGroupsService basic functions", func() {
 			Expect(err.Error()).To(Equal(expectedError.Error()))
 		})
 	})
+	Context("update hooks of DeviceGroup", func() {
+		account := common.DefaultAccount
+		deviceGroup := &models.DeviceGroup{
+			Name:    faker.Name(),
+			Type:    models.DeviceGroupTypeDefault,
+			Account: account,
+		}
+		It("should create a DeviceGroup", func() {
+			Expect(db.DB.Create(&deviceGroup).Error).To(BeNil())
+		})
+		It("should set the update hooks in order", func() {
+			hooks, err := deviceGroupsService.SetDeviceGroupUpdateHooks(account, deviceGroup.ID, &models.UpdateHooks{
+				PreUpdate:  []models.UpdateHook{{Name: "stop bridge", Command: "systemctl stop plc-bridge.service"}, {Name: "drain queue", Command: "/usr/local/bin/drain"}},
+				PostUpdate: []models.UpdateHook{{Name: "smoke test", Command: "curl -sf http://localhost:8080/health"}},
+			})
+			Expect(err).To(BeNil())
+			Expect(len(hooks.PreUpdate)).To(Equal(2))
+			Expect(hooks.PreUpdate[0].Name).To(Equal("stop bridge"))
+			Expect(hooks.PreUpdate[1].Name).To(Equal("drain queue"))
+			Expect(hooks.PreUpdate[1].Position).To(Equal(2))
+			Expect(len(hooks.PostUpdate)).To(Equal(1))
+			Expect(hooks.PostUpdate[0].Stage).To(Equal(models.UpdateHookStagePost))
+		})
+		It("should replace the update hooks", func() {
+			_, err := deviceGroupsService.SetDeviceGroupUpdateHooks(account, deviceGroup.ID, &models.UpdateHooks{
+				PostUpdate: []models.UpdateHook{{Name: "start bridge", Command: "systemctl start plc-bridge.service"}},
+			})
+			Expect(err).To(BeNil())
+			hooks, err := deviceGroupsService.GetDeviceGroupUpdateHooks(account, deviceGroup.ID)
+			Expect(err).To(BeNil())
+			Expect(hooks.PreUpdate).To(BeEmpty())
+			Expect(len(hooks.PostUpdate)).To(Equal(1))
+			Expect(hooks.PostUpdate[0].Name).To(Equal("start bridge"))
+		})
+		It("should not find the update hooks of a DeviceGroup of another account", func() {
+			_, err := deviceGroupsService.GetDeviceGroupUpdateHooks("another-account", deviceGroup.ID)
+			Expect(err).To(MatchError(new(services.DeviceGroupNotFound)))
+		})
+	})
 	Context("adding devices to DeviceGroup", func() {
 		account1 := faker.UUIDHyphenated()
 		account2 := faker.UUIDHyphenated()
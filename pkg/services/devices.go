@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"time"
 
 	version "github.com/knqyf263/go-rpm-version"
 	"github.com/redhatinsights/edge-api/pkg/clients/inventory"
@@ -23,6 +25,9 @@ const (
 	InventoryEventTypeDelete = "delete"
 	// InventoryHostTypeEdge represent the inventory host_ype = "edge"
 	InventoryHostTypeEdge = "edge"
+
+	// inventoryDevicesByTagPageSize is the number of devices requested per page when getting inventory devices by tag
+	inventoryDevicesByTagPageSize = 100
 )
 
 // DeviceServiceInterface defines the interface to handle the business logic of RHEL for Edge Devices
@@ -37,6 +42,7 @@ type DeviceServiceInterface interface {
 	GetUpdateAvailableForDeviceByUUID(deviceUUID string, latest bool) ([]models.ImageUpdateAvailable, error)
 	GetDeviceImageInfoByUUID(deviceUUID string) (*models.ImageInfo, error)
 	GetLatestCommitFromDevices(account string, devicesUUID []string) (uint, error)
+	GetInventoryDevicesByTag(tag string) (inventory.Response, error)
 	// Device Object Methods
 	GetDeviceDetails(device inventory.Device) (*models.DeviceDetails, error)
 	GetUpdateAvailableForDevice(device inventory.Device, latest bool) ([]models.ImageUpdateAvailable, error)
@@ -63,8 +69,11 @@ type PlatformInsightsCreateUpdateEventPayload struct {
 		Account       string `json:"account"`
 		InsightsID    string `json:"insights_id"`
 		SystemProfile struct {
-			HostType             string                `json:"host_type"`
-			RpmOSTreeDeployments []RpmOSTreeDeployment `json:"rpm_ostree_deployments"`
+			HostType                  string                `json:"host_type"`
+			RpmOSTreeDeployments      []RpmOSTreeDeployment `json:"rpm_ostree_deployments"`
+			GreenbootStatus           string                `json:"greenboot_status"`
+			GreenbootFallbackDetected bool                  `json:"greenboot_fallback_detected"`
+			LastBootTime              time.Time             `json:"last_boot_time"`
 		} `json:"system_profile"`
 	} `json:"host"`
 }
@@ -162,8 +171,10 @@ func (s *DeviceService) GetDeviceDetails(device inventory.Device) (*models.Devic
 		s.log.Info("Could not find device on the devices table yet - returning just the data from inventory")
 		// if err != nil then databaseDevice is nil pointer
 		databaseDevice = &models.Device{
-			UUID:        device.ID,
-			RHCClientID: device.Ostree.RHCClientID,
+			UUID:                      device.ID,
+			RHCClientID:               device.Ostree.RHCClientID,
+			GreenbootStatus:           device.Ostree.GreenbootStatus,
+			GreenbootFallbackDetected: device.Ostree.GreenbootFallbackDetected,
 		}
 	}
 	details := &models.DeviceDetails{
@@ -344,7 +355,10 @@ func (s *DeviceService) GetDeviceImageInfo(device inventory.Device) (*models.Ima
 	}
 	if currentImage.Version > 1 {
 		rollback, err = s.ImageService.GetRollbackImage(currentImage)
-		if err != nil {
+		// the image has no rollback image when none of the previous versions was built successfully
+		if _, ok := err.(*ImageNotFoundError); ok {
+			rollback = nil
+		} else if err != nil {
 			s.log.WithField("error", err.Error()).Error("Could not find rollback image info")
 			return nil, new(ImageNotFoundError)
 		}
@@ -424,6 +438,9 @@ func (s *DeviceService) GetDevices(params *inventory.Params) (*models.DeviceDeta
 				RHCClientID:   device.Ostree.RHCClientID,
 				Account:       device.Account,
 				DevicesGroups: storeDevice.DevicesGroups,
+				// the greenboot health is reported by inventory for the devices not stored yet
+				GreenbootStatus:           device.Ostree.GreenbootStatus,
+				GreenbootFallbackDetected: device.Ostree.GreenbootFallbackDetected,
 			},
 			Account:    device.Account,
 			DeviceName: device.DisplayName,
@@ -522,6 +539,25 @@ func (s *DeviceService) processPlatformInventoryEventUpdateDevice(eventData Plat
 	if len(deployments) == 0 {
 		return new(ImageNotFoundError)
 	}
+	for _, deployment := range deployments {
+		if deployment.Booted {
+			device.CurrentHash = deployment.Checksum
+			break
+		}
+	}
+	device.GreenbootStatus = eventData.Host.SystemProfile.GreenbootStatus
+	device.GreenbootFallbackDetected = eventData.Host.SystemProfile.GreenbootFallbackDetected
+	if result := db.DB.Model(device).Select("current_hash", "greenboot_status", "greenboot_fallback_detected").Updates(device); result.Error != nil {
+		return result.Error
+	}
+	if err := s.completeRebootingDispatchRecords(device); err != nil {
+		return err
+	}
+	if device.GreenbootFallbackDetected {
+		if err := s.setFallbackDispatchRecordRolledBack(device, eventData.Host.SystemProfile.LastBootTime); err != nil {
+			return err
+		}
+	}
 	CommitCheck := deployments[0].Checksum
 	// Get the related commit image
 	var deviceImage models.Image
@@ -539,6 +575,72 @@ func (s *DeviceService) processPlatformInventoryEventUpdateDevice(eventData Plat
 	return s.SetDeviceUpdateAvailability(device.Account, device.ID)
 }
 
+// completeRebootingDispatchRecords completes the dispatch records waiting for the device to reboot
+// when the device booted the commit of their update
+func (s *DeviceService) completeRebootingDispatchRecords(device *models.Device) error {
+	if device.CurrentHash == "" {
+		return nil
+	}
+	var dispatchRecords []models.DispatchRecord
+	if result := db.DB.Where("device_id = ? AND status = ?", device.ID, models.DispatchRecordStatusRebooting).Find(&dispatchRecords); result.Error != nil {
+		return result.Error
+	}
+	for _, dispatchRecord := range dispatchRecords {
+		commit, err := getDispatchRecordUpdateCommit(dispatchRecord.ID)
+		if err != nil {
+			return err
+		}
+		if commit.OSTreeCommit != device.CurrentHash {
+			// the device may not have rebooted yet, the record fails once its reboot deadline passes
+			continue
+		}
+		s.log.WithFields(log.Fields{"host_id": device.UUID, "dispatchRecordID": dispatchRecord.ID}).Info("Device booted the update commit")
+		dispatchRecord.Status = models.DispatchRecordStatusComplete
+		ctx := models.ContextWithEventMessage(models.ContextWithEventActor(s.ctx, models.EventActorInventory), "device booted the update commit")
+		if result := eventsDB(ctx).Model(&dispatchRecord).Update("status", dispatchRecord.Status); result.Error != nil {
+			return result.Error
+		}
+		if err := s.UpdateService.SetUpdateStatusBasedOnDispatchRecord(dispatchRecord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFallbackDispatchRecordRolledBack sets as rolled back the last dispatch record of the device when greenboot
+// fell back to a previous deployment instead of the commit of its update
+// The fallback flag of inventory stays set after a fallback, the record is only rolled back when the device booted
+// another deployment than its update commit after it was dispatched, a device that didn't reboot yet is left rebooting
+func (s *DeviceService) setFallbackDispatchRecordRolledBack(device *models.Device, lastBootTime time.Time) error {
+	var dispatchRecord models.DispatchRecord
+	result := db.DB.Where("device_id = ? AND status IN ?", device.ID,
+		[]string{models.DispatchRecordStatusRebooting, models.DispatchRecordStatusComplete}).
+		Order("id desc").Limit(1).Find(&dispatchRecord)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	if !dispatchRecord.DispatchedAt.Valid || !lastBootTime.After(dispatchRecord.DispatchedAt.Time) {
+		return nil
+	}
+	commit, err := getDispatchRecordUpdateCommit(dispatchRecord.ID)
+	if err != nil {
+		return err
+	}
+	if commit.OSTreeCommit == device.CurrentHash {
+		return nil
+	}
+	s.log.WithFields(log.Fields{"host_id": device.UUID, "dispatchRecordID": dispatchRecord.ID}).Info("Greenboot fell back to a previous deployment, update rolled back")
+	dispatchRecord.Status = models.DispatchRecordStatusRolledBack
+	ctx := models.ContextWithEventMessage(models.ContextWithEventActor(s.ctx, models.EventActorInventory), "greenboot fell back to a previous deployment")
+	if result := eventsDB(ctx).Model(&dispatchRecord).Update("status", dispatchRecord.Status); result.Error != nil {
+		return result.Error
+	}
+	return s.UpdateService.SetUpdateStatusBasedOnDispatchRecord(dispatchRecord)
+}
+
 // ProcessPlatformInventoryUpdatedEvent processes messages from platform.inventory.events kafka topic with event_type="updated"
 func (s *DeviceService) ProcessPlatformInventoryUpdatedEvent(message []byte) error {
 	var eventData PlatformInsightsCreateUpdateEventPayload
@@ -691,16 +793,18 @@ func (s *DeviceService) GetDevicesView(limit int, offset int, tx *gorm.DB) (*mod
 			deviceGroups = deviceToGroupMap[device.ID].info
 		}
 		currentDeviceView := models.DeviceView{
-			DeviceID:        device.ID,
-			DeviceName:      device.Name,
-			DeviceUUID:      device.UUID,
-			ImageID:         device.ImageID,
-			ImageName:       imageName,
-			LastSeen:        device.LastSeen.Time.String(),
-			UpdateAvailable: device.UpdateAvailable,
-			Status:          imageStatus,
-			ImageSetID:      imageSetID,
-			DeviceGroups:    deviceGroups,
+			DeviceID:                  device.ID,
+			DeviceName:                device.Name,
+			DeviceUUID:                device.UUID,
+			ImageID:                   device.ImageID,
+			ImageName:                 imageName,
+			LastSeen:                  device.LastSeen.Time.String(),
+			UpdateAvailable:           device.UpdateAvailable,
+			Status:                    imageStatus,
+			ImageSetID:                imageSetID,
+			DeviceGroups:              deviceGroups,
+			GreenbootStatus:           device.GreenbootStatus,
+			GreenbootFallbackDetected: device.GreenbootFallbackDetected,
 		}
 		returnDevices = append(returnDevices, currentDeviceView)
 	}
@@ -760,6 +864,29 @@ func (s *DeviceService) GetLatestCommitFromDevices(account string, devicesUUID [
 	return updateImages[0].CommitID, nil
 }
 
+// GetInventoryDevicesByTag returns all the edge devices of the inventory with the given tag, going through all the result pages
+func (s *DeviceService) GetInventoryDevicesByTag(tag string) (inventory.Response, error) {
+	s.log.WithField("tag", tag).Info("Getting inventory devices by tag...")
+	var devices inventory.Response
+	for page := 1; ; page++ {
+		inventoryDevices, err := s.Inventory.ReturnDevicesByTag(tag, &inventory.Params{
+			PerPage: strconv.Itoa(inventoryDevicesByTagPageSize),
+			Page:    strconv.Itoa(page),
+		})
+		if err != nil {
+			s.log.WithFields(log.Fields{"error": err.Error(), "tag": tag}).Error("Error retrieving devices by tag from inventory")
+			return inventory.Response{}, err
+		}
+		devices.Total = inventoryDevices.Total
+		devices.Result = append(devices.Result, inventoryDevices.Result...)
+		if len(inventoryDevices.Result) == 0 || len(devices.Result) >= inventoryDevices.Total {
+			break
+		}
+	}
+	devices.Count = len(devices.Result)
+	return devices, nil
+}
+
 // ProcessPlatformInventoryCreateEvent is a method to processes messages from platform.inventory.events kafka topic and save them as devices in the DB
 func (s *DeviceService) ProcessPlatformInventoryCreateEvent(message []byte) error {
 	var e *PlatformInsightsCreateUpdateEventPayload
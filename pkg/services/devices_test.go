@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -73,6 +74,30 @@ var _ = Describe("DeviceService", func() {
 			})
 		})
 	})
+	Context("get inventory devices by tag", func() {
+		tag := "insights-client/location=boston"
+		When("devices span more than one page", func() {
+			It("should return the devices of all the pages", func() {
+				firstPage := inventory.Response{Total: 2, Count: 1, Result: []inventory.Device{{ID: faker.UUIDHyphenated()}}}
+				secondPage := inventory.Response{Total: 2, Count: 1, Result: []inventory.Device{{ID: faker.UUIDHyphenated()}}}
+				mockInventoryClient.EXPECT().ReturnDevicesByTag(tag, &inventory.Params{PerPage: "100", Page: "1"}).Return(firstPage, nil)
+				mockInventoryClient.EXPECT().ReturnDevicesByTag(tag, &inventory.Params{PerPage: "100", Page: "2"}).Return(secondPage, nil)
+
+				devices, err := deviceService.GetInventoryDevicesByTag(tag)
+				Expect(err).To(BeNil())
+				Expect(devices.Count).To(Equal(2))
+				Expect(devices.Result).To(Equal(append(firstPage.Result, secondPage.Result...)))
+			})
+		})
+		When("inventory returns an error", func() {
+			It("should return the error", func() {
+				mockInventoryClient.EXPECT().ReturnDevicesByTag(tag, gomock.Any()).Return(inventory.Response{}, errors.New("error"))
+
+				_, err := deviceService.GetInventoryDevicesByTag(tag)
+				Expect(err).ToNot(BeNil())
+			})
+		})
+	})
 	Context("get last booted deployment", func() {
 		var device inventory.Device
 		BeforeEach(func() {
@@ -735,6 +760,129 @@ var _ = Describe("DeviceService", func() {
 				Expect(savedDevice.UpdateAvailable).To(Equal(true))
 			})
 		})
+
+		Context("rebooting dispatch records", func() {
+			var mockUpdateService *mock_services.MockUpdateServiceInterface
+			var rebootingDeviceService services.DeviceService
+			BeforeEach(func() {
+				ctrl := gomock.NewController(GinkgoT())
+				mockUpdateService = mock_services.NewMockUpdateServiceInterface(ctrl)
+				rebootingDeviceService = services.DeviceService{
+					Service:       services.NewService(context.Background(), log.NewEntry(log.StandardLogger())),
+					UpdateService: mockUpdateService,
+				}
+			})
+
+			newRebootingDispatchRecord := func() (*models.Device, *models.DispatchRecord) {
+				device := &models.Device{UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated(), Account: account}
+				Expect(db.DB.Create(device).Error).To(BeNil())
+				dispatchRecord := &models.DispatchRecord{DeviceID: device.ID, Status: models.DispatchRecordStatusRebooting,
+					DispatchedAt: models.EdgeAPITime{Time: time.Now().Add(-time.Hour), Valid: true}}
+				update := &models.UpdateTransaction{
+					Account:         account,
+					CommitID:        commit.ID,
+					Devices:         []models.Device{*device},
+					DispatchRecords: []models.DispatchRecord{*dispatchRecord},
+					Status:          models.UpdateStatusBuilding,
+				}
+				Expect(db.DB.Create(update).Error).To(BeNil())
+				return device, &update.DispatchRecords[0]
+			}
+
+			newEvent := func(device *models.Device, bootedChecksum string) []byte {
+				event := new(services.PlatformInsightsCreateUpdateEventPayload)
+				event.Type = services.InventoryEventTypeUpdated
+				event.Host.ID = device.UUID
+				event.Host.InsightsID = device.RHCClientID
+				event.Host.Account = account
+				event.Host.SystemProfile.HostType = services.InventoryHostTypeEdge
+				event.Host.SystemProfile.RpmOSTreeDeployments = []services.RpmOSTreeDeployment{
+					{Booted: false, Checksum: commit.OSTreeCommit},
+					{Booted: true, Checksum: bootedChecksum},
+				}
+				message, err := json.Marshal(event)
+				Expect(err).To(BeNil())
+				return message
+			}
+
+			expectDispatchRecordEvent := func(dispatchRecord *models.DispatchRecord, status string, message string) {
+				var events []models.Event
+				Expect(db.DB.Where("resource_type = ? AND resource_id = ? AND new_status = ?",
+					models.EventResourceDispatchRecord, dispatchRecord.ID, status).Find(&events).Error).To(BeNil())
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].OldStatus).To(Equal(models.DispatchRecordStatusRebooting))
+				Expect(events[0].Actor).To(Equal(models.EventActorInventory))
+				Expect(events[0].Message).To(Equal(message))
+			}
+
+			It("should complete the dispatch record when the device booted the update commit", func() {
+				device, dispatchRecord := newRebootingDispatchRecord()
+				mockUpdateService.EXPECT().SetUpdateStatusBasedOnDispatchRecord(gomock.Any()).Return(nil)
+
+				err := rebootingDeviceService.ProcessPlatformInventoryUpdatedEvent(newEvent(device, commit.OSTreeCommit))
+				Expect(err).To(BeNil())
+
+				Expect(db.DB.First(dispatchRecord, dispatchRecord.ID).Error).To(BeNil())
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusComplete))
+				Expect(db.DB.First(device, device.ID).Error).To(BeNil())
+				Expect(device.CurrentHash).To(Equal(commit.OSTreeCommit))
+				expectDispatchRecordEvent(dispatchRecord, models.DispatchRecordStatusComplete, "device booted the update commit")
+			})
+
+			It("should keep the dispatch record rebooting when the device booted another commit", func() {
+				device, dispatchRecord := newRebootingDispatchRecord()
+				previousCommit := faker.UUIDHyphenated()
+
+				err := rebootingDeviceService.ProcessPlatformInventoryUpdatedEvent(newEvent(device, previousCommit))
+				Expect(err).To(BeNil())
+
+				Expect(db.DB.First(dispatchRecord, dispatchRecord.ID).Error).To(BeNil())
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusRebooting))
+				Expect(db.DB.First(device, device.ID).Error).To(BeNil())
+				Expect(device.CurrentHash).To(Equal(previousCommit))
+			})
+
+			It("should set the dispatch record as rolled back when greenboot fell back", func() {
+				device, dispatchRecord := newRebootingDispatchRecord()
+				previousCommit := faker.UUIDHyphenated()
+				event := new(services.PlatformInsightsCreateUpdateEventPayload)
+				Expect(json.Unmarshal(newEvent(device, previousCommit), event)).To(BeNil())
+				event.Host.SystemProfile.GreenbootStatus = models.DeviceGreenbootStatusGreen
+				event.Host.SystemProfile.GreenbootFallbackDetected = true
+				event.Host.SystemProfile.LastBootTime = time.Now()
+				message, err := json.Marshal(event)
+				Expect(err).To(BeNil())
+				mockUpdateService.EXPECT().SetUpdateStatusBasedOnDispatchRecord(gomock.Any()).Return(nil)
+
+				err = rebootingDeviceService.ProcessPlatformInventoryUpdatedEvent(message)
+				Expect(err).To(BeNil())
+
+				Expect(db.DB.First(dispatchRecord, dispatchRecord.ID).Error).To(BeNil())
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusRolledBack))
+				expectDispatchRecordEvent(dispatchRecord, models.DispatchRecordStatusRolledBack, "greenboot fell back to a previous deployment")
+				Expect(db.DB.First(device, device.ID).Error).To(BeNil())
+				Expect(device.GreenbootStatus).To(Equal(models.DeviceGreenbootStatusGreen))
+				Expect(device.GreenbootFallbackDetected).To(BeTrue())
+			})
+
+			It("should keep the dispatch record rebooting when the fallback is from before its dispatch", func() {
+				device, dispatchRecord := newRebootingDispatchRecord()
+				previousCommit := faker.UUIDHyphenated()
+				event := new(services.PlatformInsightsCreateUpdateEventPayload)
+				Expect(json.Unmarshal(newEvent(device, previousCommit), event)).To(BeNil())
+				event.Host.SystemProfile.GreenbootStatus = models.DeviceGreenbootStatusGreen
+				event.Host.SystemProfile.GreenbootFallbackDetected = true
+				event.Host.SystemProfile.LastBootTime = time.Now().Add(-2 * time.Hour)
+				message, err := json.Marshal(event)
+				Expect(err).To(BeNil())
+
+				err = rebootingDeviceService.ProcessPlatformInventoryUpdatedEvent(message)
+				Expect(err).To(BeNil())
+
+				Expect(db.DB.First(dispatchRecord, dispatchRecord.ID).Error).To(BeNil())
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusRebooting))
+			})
+		})
 	})
 
 	Context("ProcessPlatformInventoryDeleteEvent", func() {
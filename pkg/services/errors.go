@@ -72,6 +72,13 @@ func (e *DeviceGroupNotFound) Error() string {
 	return "device group was not found"
 }
 
+// ImageSetNotFound indicates the image set was not found
+type ImageSetNotFound struct{}
+
+func (e *ImageSetNotFound) Error() string {
+	return "image set was not found"
+}
+
 // ImageSetAlreadyExists indicates the ImageSet attempting to be created already exists
 type ImageSetAlreadyExists struct{}
 
@@ -129,6 +136,69 @@ func (e *DeviceGroupAccountOrNameUndefined) Error() string {
 	return "device group account or name are undefined"
 }
 
+// MaintenanceWindowNotFound indicates that the maintenance window was not found in the device group
+type MaintenanceWindowNotFound struct{}
+
+func (e *MaintenanceWindowNotFound) Error() string {
+	return "maintenance window not found in device group"
+}
+
+// DeviceGroupUpdateNotFound indicates that the update was not found in the device group
+type DeviceGroupUpdateNotFound struct{}
+
+func (e *DeviceGroupUpdateNotFound) Error() string {
+	return "update not found in device group"
+}
+
+// UpdateCannotBeCancelled indicates that the update is already finished
+type UpdateCannotBeCancelled struct{}
+
+func (e *UpdateCannotBeCancelled) Error() string {
+	return "only updates that are created, building or paused can be cancelled"
+}
+
+// UpdateCancelled indicates that the update was cancelled while being processed
+type UpdateCancelled struct{}
+
+func (e *UpdateCancelled) Error() string {
+	return "update was cancelled"
+}
+
+// UpdateCannotBeRetried indicates that the update repo is not built or that the update was cancelled
+type UpdateCannotBeRetried struct{}
+
+func (e *UpdateCannotBeRetried) Error() string {
+	return "only updates with a built repo that were not cancelled can be retried"
+}
+
+// UpdateCannotBeResumed indicates that the update is not a paused staged rollout
+type UpdateCannotBeResumed struct{}
+
+func (e *UpdateCannotBeResumed) Error() string {
+	return "only paused updates can be resumed"
+}
+
+// UpdateRolloutLimitReached indicates that the account, or all the accounts, reached the limit of rollouts in progress
+type UpdateRolloutLimitReached struct{}
+
+func (e *UpdateRolloutLimitReached) Error() string {
+	return "too many updates are rolling out, try again once one of them is over"
+}
+
+// UpdateHasNoFailedDevices indicates that no device of the update failed
+type UpdateHasNoFailedDevices struct{}
+
+func (e *UpdateHasNoFailedDevices) Error() string {
+	return "update has no failed devices"
+}
+
+// RollbackImageNotFound indicates that the device image has no previous successful image to roll back to
+type RollbackImageNotFound struct{}
+
+func (e *RollbackImageNotFound) Error() string {
+	return "device has no previous image to roll back to"
+}
+
 // DeviceHasImageUndefined indicates that device record has image not defined
 type DeviceHasImageUndefined struct{}
 
@@ -166,3 +236,73 @@ type CommitNotFound struct{}
 func (e *CommitNotFound) Error() string {
 	return "commit not found"
 }
+
+// UpdateBundleNotFound indicates the update bundle was not found
+type UpdateBundleNotFound struct{}
+
+func (e *UpdateBundleNotFound) Error() string {
+	return "update bundle was not found"
+}
+
+// UpdateBundleCommitNotBuilt indicates a commit of an update bundle has no built repo to be bundled
+type UpdateBundleCommitNotBuilt struct{}
+
+func (e *UpdateBundleCommitNotBuilt) Error() string {
+	return "the commits of an update bundle must be built successfully"
+}
+
+// UpdateBundleNotReady indicates the update bundle is not built yet
+type UpdateBundleNotReady struct{}
+
+func (e *UpdateBundleNotReady) Error() string {
+	return "update bundle is not ready to be downloaded"
+}
+
+// JobNotFound indicates the job was not found
+type JobNotFound struct{}
+
+func (e *JobNotFound) Error() string {
+	return "job was not found"
+}
+
+// ImageBuildFailed indicates the build of an image ended with the error status
+type ImageBuildFailed struct{}
+
+func (e *ImageBuildFailed) Error() string {
+	return "image build failed"
+}
+
+// JobQueued indicates the job waits for a concurrency slot, it runs again later without using an attempt
+type JobQueued struct{}
+
+func (e *JobQueued) Error() string {
+	return "job is queued until a concurrency slot is available"
+}
+
+// ImageInUse indicates that devices are running the image, or a version of the image set, being deleted
+type ImageInUse struct{}
+
+func (e *ImageInUse) Error() string {
+	return "image is in use by devices and cannot be deleted"
+}
+
+// ImageHasUpdateInProgress indicates that an update in progress is to or from the image being deleted
+type ImageHasUpdateInProgress struct{}
+
+func (e *ImageHasUpdateInProgress) Error() string {
+	return "image has an update in progress and cannot be deleted"
+}
+
+// ImageIsBuilding indicates that the image being deleted is still building
+type ImageIsBuilding struct{}
+
+func (e *ImageIsBuilding) Error() string {
+	return "image is still building and cannot be deleted"
+}
+
+// RetentionPolicyNotFound indicates the retention policy was not found
+type RetentionPolicyNotFound struct{}
+
+func (e *RetentionPolicyNotFound) Error() string {
+	return "retention policy was not found"
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -43,6 +44,7 @@ func (s *BasicFileService) GetDownloader() files.Downloader {
 // FilesService is the interface for Files-related service information
 type FilesService interface {
 	GetFile(path string) (io.ReadCloser, error)
+	GetSignedURL(path string, expiration time.Duration) (string, error)
 	GetExtractor() files.Extractor
 	GetUploader() files.Uploader
 	GetDownloader() files.Downloader
@@ -132,3 +134,17 @@ func (s *S3FilesService) GetFile(path string) (io.ReadCloser, error) {
 	}
 	return o.Body, nil
 }
+
+// GetSignedURL returns the path of the file given a path, the local files don't expire
+func (s *LocalFilesService) GetSignedURL(path string, expiration time.Duration) (string, error) {
+	return filepath.Clean("/tmp/" + path), nil
+}
+
+// GetSignedURL returns a pre-signed URL downloading the file given a path until it expires
+func (s *S3FilesService) GetSignedURL(path string, expiration time.Duration) (string, error) {
+	req, _ := s.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(path),
+	})
+	return req.Presign(expiration)
+}
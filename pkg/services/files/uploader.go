@@ -21,6 +21,8 @@ import (
 type Uploader interface {
 	UploadRepo(src string, account string) (string, error)
 	UploadFile(fname string, uploadPath string) (string, error)
+	DeleteRepo(src string, account string) error
+	DeleteFile(url string) error
 }
 
 // NewUploader returns the uploader used by EdgeAPI based on configurations
@@ -77,6 +79,28 @@ func (u *LocalUploader) UploadFile(fname string, uploadPath string) (string, err
 	return destfile, nil
 }
 
+// DeleteRepo removes the src repo folder, as it is what UploadRepo returned
+// It returns error if the repo is not using u.BaseDir as its base folder
+func (u *LocalUploader) DeleteRepo(src string, account string) error {
+	if strings.HasPrefix(src, u.BaseDir) {
+		return os.RemoveAll(src)
+	}
+	return fmt.Errorf("invalid folder to delete on local uploader")
+}
+
+// DeleteFile removes a file copied by UploadFile, the url being the path UploadFile returned
+// It returns error if the file is not using u.BaseDir as its base folder, a file already removed is not an error
+func (u *LocalUploader) DeleteFile(url string) error {
+	path := filepath.Clean(url)
+	if !strings.HasPrefix(path, u.BaseDir+"/") {
+		return fmt.Errorf("invalid file to delete on local uploader")
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func newS3Uploader(log *log.Entry) *S3Uploader {
 	cfg := config.Get()
 	var sess *session.Session
@@ -214,3 +238,39 @@ func (u *S3Uploader) UploadFile(fname string, uploadPath string) (string, error)
 	s3URL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.Bucket, region, uploadPath)
 	return s3URL, nil
 }
+
+// DeleteRepo deletes the files of a repo uploaded, even partially, by UploadRepo with the same src and account
+func (u *S3Uploader) DeleteRepo(src string, account string) error {
+	cfg := config.Get()
+	prefix := fmt.Sprintf("%s/%s", account, strings.TrimPrefix(src, cfg.RepoTempPath))
+	u.log.WithField("prefix", prefix).Info("Deleting repo")
+	iter := s3manager.NewDeleteListIterator(u.Client, &s3.ListObjectsInput{
+		Bucket: aws.String(u.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	if err := s3manager.NewBatchDeleteWithClient(u.Client).Delete(aws.BackgroundContext(), iter); err != nil {
+		u.log.WithField("error", err.Error()).Error("Error deleting repo from AWS S3")
+		return err
+	}
+	return nil
+}
+
+// DeleteFile deletes a file uploaded by UploadFile, the url being the one UploadFile returned
+// It returns error if the url is not a file of the bucket, deleting a file already deleted is not an error
+func (u *S3Uploader) DeleteFile(url string) error {
+	region := *u.Client.Config.Region
+	prefix := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", u.Bucket, region)
+	if !strings.HasPrefix(url, prefix) || url == prefix {
+		return fmt.Errorf("invalid file to delete from bucket %s", u.Bucket)
+	}
+	key := strings.TrimPrefix(url, prefix)
+	u.log.WithField("key", key).Info("Deleting file")
+	if _, err := u.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(u.Bucket),
+		Key:    aws.String(key),
+	}); err != nil {
+		u.log.WithField("error", err.Error()).Error("Error deleting file from AWS S3")
+		return err
+	}
+	return nil
+}
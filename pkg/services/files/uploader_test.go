@@ -77,5 +77,31 @@ var _ = Describe("Uploader Test", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
+		When("delete file is called", func() {
+			var path string
+			BeforeEach(func() {
+				path = "/tmp/random-file-to-delete.txt"
+				f, err := os.Create(path)
+				Expect(err).ToNot(HaveOccurred())
+				f.Close()
+			})
+			AfterEach(func() {
+				os.Remove(path)
+			})
+			It("removes the file", func() {
+				err := uploader.DeleteFile(path)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = os.Stat(path)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+			It("doesnt return error when the file was already removed", func() {
+				Expect(uploader.DeleteFile(path)).To(Succeed())
+				Expect(uploader.DeleteFile(path)).To(Succeed())
+			})
+			It("returns error when the file is not in the base folder", func() {
+				err := uploader.DeleteFile("/invalid-base-folder/random-file.txt")
+				Expect(err).To(HaveOccurred())
+			})
+		})
 	})
 })
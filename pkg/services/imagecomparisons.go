@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	version "github.com/knqyf263/go-rpm-version"
+	"github.com/redhatinsights/edge-api/pkg/models"
+)
+
+// compareImages returns the difference from an image to another image, both loaded with their commit,
+// their installer, their packages and their third party repositories
+func compareImages(image *models.Image, other *models.Image) *models.ImageComparison {
+	comparison := &models.ImageComparison{
+		ImageID:                image.ID,
+		OtherImageID:           other.ID,
+		InstalledPackages:      compareInstalledPackages(commitInstalledPackages(image), commitInstalledPackages(other)),
+		Packages:               compareNames(packageNames(image.Packages), packageNames(other.Packages)),
+		CustomPackages:         compareNames(packageNames(image.CustomPackages), packageNames(other.CustomPackages)),
+		ThirdPartyRepositories: compareThirdPartyRepos(image.ThirdPartyRepositories, other.ThirdPartyRepositories),
+		OutputTypes:            compareNames(image.OutputTypes, other.OutputTypes),
+		Distribution:           compareValues(image.Distribution, other.Distribution),
+	}
+	var arch, otherArch string
+	if image.Commit != nil {
+		arch = image.Commit.Arch
+	}
+	if other.Commit != nil {
+		otherArch = other.Commit.Arch
+	}
+	comparison.Arch = compareValues(arch, otherArch)
+	var installer, otherInstaller models.Installer
+	if image.Installer != nil {
+		installer = *image.Installer
+	}
+	if other.Installer != nil {
+		otherInstaller = *other.Installer
+	}
+	comparison.InstallerUsername = compareValues(installer.Username, otherInstaller.Username)
+	comparison.InstallerSSHKey = compareValues(installer.SSHKey, otherInstaller.SSHKey)
+	return comparison
+}
+
+// compareValues returns the change of a setting, nil when its value is the same
+func compareValues(old string, new string) *models.ValueChange {
+	if old == new {
+		return nil
+	}
+	return &models.ValueChange{Old: old, New: new}
+}
+
+// commitInstalledPackages returns the packages installed by the commit of an image
+func commitInstalledPackages(image *models.Image) []models.InstalledPackage {
+	if image.Commit == nil {
+		return nil
+	}
+	return image.Commit.InstalledPackages
+}
+
+// installedPackageKey identifies an installed package, a package can be installed for several architectures
+func installedPackageKey(pkg models.InstalledPackage) string {
+	return fmt.Sprintf("%s.%s", pkg.Name, pkg.Arch)
+}
+
+// installedPackageVersion returns the epoch, version and release of an installed package as rpm compares them
+func installedPackageVersion(pkg models.InstalledPackage) version.Version {
+	evr := pkg.Version
+	if pkg.Release != "" {
+		evr = fmt.Sprintf("%s-%s", evr, pkg.Release)
+	}
+	if pkg.Epoch != "" && pkg.Epoch != "0" {
+		evr = fmt.Sprintf("%s:%s", pkg.Epoch, evr)
+	}
+	return version.NewVersion(evr)
+}
+
+// compareInstalledPackages returns the difference between the packages installed by two commits sorted by name
+func compareInstalledPackages(old []models.InstalledPackage, new []models.InstalledPackage) models.InstalledPackagesDiff {
+	diff := models.InstalledPackagesDiff{
+		Added:      []models.InstalledPackage{},
+		Removed:    []models.InstalledPackage{},
+		Upgraded:   []models.InstalledPackage{},
+		Downgraded: []models.InstalledPackage{},
+	}
+	oldPkgs := make(map[string]models.InstalledPackage, len(old))
+	for _, pkg := range old {
+		oldPkgs[installedPackageKey(pkg)] = pkg
+	}
+	newPkgs := make(map[string]bool, len(new))
+	for _, pkg := range new {
+		newPkgs[installedPackageKey(pkg)] = true
+		oldPkg, ok := oldPkgs[installedPackageKey(pkg)]
+		if !ok {
+			diff.Added = append(diff.Added, pkg)
+			continue
+		}
+		oldVersion := installedPackageVersion(oldPkg)
+		newVersion := installedPackageVersion(pkg)
+		if newVersion.GreaterThan(oldVersion) {
+			diff.Upgraded = append(diff.Upgraded, pkg)
+		} else if newVersion.LessThan(oldVersion) {
+			diff.Downgraded = append(diff.Downgraded, pkg)
+		}
+	}
+	for _, pkg := range old {
+		if !newPkgs[installedPackageKey(pkg)] {
+			diff.Removed = append(diff.Removed, pkg)
+		}
+	}
+	for _, pkgs := range [][]models.InstalledPackage{diff.Added, diff.Removed, diff.Upgraded, diff.Downgraded} {
+		sort.Slice(pkgs, func(i, j int) bool { return installedPackageKey(pkgs[i]) < installedPackageKey(pkgs[j]) })
+	}
+	return diff
+}
+
+// packageNames returns the names of the packages
+func packageNames(pkgs []models.Package) []string {
+	names := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+// compareNames returns the names added to and removed from a list, sorted
+func compareNames(old []string, new []string) models.NamesDiff {
+	return models.NamesDiff{Added: namesNotIn(new, old), Removed: namesNotIn(old, new)}
+}
+
+// namesNotIn returns the names of a not in b, sorted and without duplicates
+func namesNotIn(a []string, b []string) []string {
+	diff := []string{}
+	seen := make(map[string]bool, len(a)+len(b))
+	for _, name := range b {
+		seen[name] = true
+	}
+	for _, name := range a {
+		if !seen[name] {
+			diff = append(diff, name)
+			seen[name] = true
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// compareThirdPartyRepos returns the third party repositories added and removed, a repository is identified by its URL
+func compareThirdPartyRepos(old []models.ThirdPartyRepo, new []models.ThirdPartyRepo) models.ThirdPartyReposDiff {
+	diff := models.ThirdPartyReposDiff{Added: []models.ThirdPartyRepo{}, Removed: []models.ThirdPartyRepo{}}
+	oldURLs := make(map[string]bool, len(old))
+	for _, repo := range old {
+		oldURLs[repo.URL] = true
+	}
+	newURLs := make(map[string]bool, len(new))
+	for _, repo := range new {
+		newURLs[repo.URL] = true
+		if !oldURLs[repo.URL] {
+			diff.Added = append(diff.Added, repo)
+		}
+	}
+	for _, repo := range old {
+		if !newURLs[repo.URL] {
+			diff.Removed = append(diff.Removed, repo)
+		}
+	}
+	return diff
+}
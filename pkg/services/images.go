@@ -10,11 +10,9 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"strconv"
-	"sync"
-	"syscall"
+	"strings"
 	"text/template"
 	"time"
 
@@ -24,18 +22,16 @@ import (
 	"github.com/redhatinsights/edge-api/pkg/errors"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services/files"
 	log "github.com/sirupsen/logrus"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	clowder "github.com/redhatinsights/app-common-go/pkg/api/v1"
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
 
-// WaitGroup is the waitg roup for pending image builds
-// FIXME: this no longer applies to images. move to devices
-var WaitGroup sync.WaitGroup
-
 // ImageServiceInterface defines the interface that helps handle
 // the business logic of creating RHEL For Edge Images
 type ImageServiceInterface interface {
@@ -45,7 +41,7 @@ type ImageServiceInterface interface {
 	UpdateImageStatus(image *models.Image) (*models.Image, error)
 	SetErrorStatusOnImage(err error, i *models.Image)
 	CreateRepoForImage(i *models.Image) (*models.Repo, error)
-	CreateInstallerForImage(i *models.Image) (*models.Image, chan error, error)
+	CreateInstallerForImage(i *models.Image) (*models.Image, error)
 	GetImageByID(id string) (*models.Image, error)
 	GetUpdateInfo(image models.Image) ([]models.ImageUpdateAvailable, error)
 	AddPackageInfo(image *models.Image) (ImageDetail, error)
@@ -60,15 +56,20 @@ type ImageServiceInterface interface {
 	GetRollbackImage(image *models.Image) (*models.Image, error)
 	SendImageNotification(image *models.Image) (ImageNotification, error)
 	SetDevicesUpdateAvailabilityFromImageSet(account string, ImageSetID uint) error
+	GetImageEvents(image *models.Image) ([]models.Event, error)
+	DeleteImage(image *models.Image) error
+	DeleteImageArtifacts(id uint) error
+	CompareImages(image *models.Image, otherImageID uint) (*models.ImageComparison, error)
 }
 
 // NewImageService gives a instance of the main implementation of a ImageServiceInterface
 func NewImageService(ctx context.Context, log *log.Entry) ImageServiceInterface {
 	return &ImageService{
 		Service:      Service{ctx: ctx, log: log.WithField("service", "image")},
-		ImageBuilder: imagebuilder.InitClient(ctx, log),
+		ImageBuilder: imagebuilder.NewClient(ctx, log),
 		RepoBuilder:  NewRepoBuilder(ctx, log),
 		RepoService:  NewRepoService(ctx, log),
+		JobService:   NewJobService(ctx, log),
 	}
 }
 
@@ -79,6 +80,7 @@ type ImageService struct {
 	ImageBuilder imagebuilder.ClientInterface
 	RepoBuilder  RepoBuilderInterface
 	RepoService  RepoServiceInterface
+	JobService   JobServiceInterface
 }
 
 // ValidateAllImageReposAreFromAccount validates the account for Third Party Repositories
@@ -180,7 +182,7 @@ func (s *ImageService) CreateImage(image *models.Image, account string) error {
 	if image.HasOutputType(models.ImageTypeInstaller) {
 		image.Installer.Status = models.ImageStatusCreated
 		image.Installer.Account = image.Account
-		tx := db.DB.Create(&image.Installer)
+		tx := eventsDB(s.ctx).Create(&image.Installer)
 		if tx.Error != nil {
 			return tx.Error
 		}
@@ -189,16 +191,19 @@ func (s *ImageService) CreateImage(image *models.Image, account string) error {
 	if err := ValidateAllImageReposAreFromAccount(account, image.ThirdPartyRepositories); err != nil {
 		return err
 	}
-	tx := db.DB.Create(&image.Commit)
+	tx := eventsDB(s.ctx).Create(&image.Commit)
 	if tx.Error != nil {
 		return tx.Error
 	}
-	tx = db.DB.Create(&image)
+	tx = eventsDB(s.ctx).Create(&image)
 	if tx.Error != nil {
 		return tx.Error
 	}
 
-	go s.postProcessImage(image.ID)
+	if _, err := s.JobService.Enqueue(models.JobTypeImageBuild, image.Account, image.ID); err != nil {
+		s.log.WithField("error", err.Error()).Error("Error enqueuing image build")
+		return err
+	}
 
 	return nil
 }
@@ -270,7 +275,7 @@ func (s *ImageService) UpdateImage(image *models.Image, previousImage *models.Im
 	if image.HasOutputType(models.ImageTypeInstaller) {
 		image.Installer.Status = models.ImageStatusCreated
 		image.Installer.Account = image.Account
-		tx := db.DB.Create(&image.Installer)
+		tx := eventsDB(s.ctx).Create(&image.Installer)
 		if tx.Error != nil {
 			s.log.WithField("error", tx.Error.Error()).Error("Error creating installer")
 			return tx.Error
@@ -279,12 +284,12 @@ func (s *ImageService) UpdateImage(image *models.Image, previousImage *models.Im
 	if err := ValidateAllImageReposAreFromAccount(image.Account, image.ThirdPartyRepositories); err != nil {
 		return err
 	}
-	tx := db.DB.Create(&image.Commit)
+	tx := eventsDB(s.ctx).Create(&image.Commit)
 	if tx.Error != nil {
 		s.log.WithField("error", tx.Error.Error()).Error("Error creating commit")
 		return tx.Error
 	}
-	tx = db.DB.Create(&image)
+	tx = eventsDB(s.ctx).Create(&image)
 	if tx.Error != nil {
 		s.log.WithField("error", tx.Error.Error()).Error("Error creating image")
 		return tx.Error
@@ -294,68 +299,58 @@ func (s *ImageService) UpdateImage(image *models.Image, previousImage *models.Im
 
 	s.log.Info("Image Updated successfully - starting bulding processs")
 
-	go s.postProcessImage(image.ID)
+	if _, err := s.JobService.Enqueue(models.JobTypeImageBuild, image.Account, image.ID); err != nil {
+		s.log.WithField("error", err.Error()).Error("Error enqueuing image build")
+		return err
+	}
 
 	return nil
 }
 
-func (s *ImageService) postProcessInstaller(image *models.Image) error {
-	s.log.Debug("Post processing the installer for the image")
-	for {
-		i, err := s.UpdateImageStatus(image)
-		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Update image status error")
-			return err
-		}
-
-		// the Image Builder status has changed from BUILDING
-		if i.Installer.Status != models.ImageStatusBuilding {
-
-			// if clowder is enabled, send an event on Image Build completion
-			// TODO: break this out into its own function
-			if clowder.IsClowderEnabled() {
-				// get the list of brokers from the config
-				brokers := make([]string, len(clowder.LoadedConfig.Kafka.Brokers))
-				for i, b := range clowder.LoadedConfig.Kafka.Brokers {
-					brokers[i] = fmt.Sprintf("%s:%d", b.Hostname, *b.Port)
-				}
-
-				topic := "platform.edge.fleetmgmt.image-build"
+// sendImageBuildEvent sends an event on the image build topic when a compose of the image is done
+func (s *ImageService) sendImageBuildEvent(recordKey string, image *models.Image) {
+	if !clowder.IsClowderEnabled() {
+		return
+	}
+	// get the list of brokers from the config
+	brokers := make([]string, len(clowder.LoadedConfig.Kafka.Brokers))
+	for i, b := range clowder.LoadedConfig.Kafka.Brokers {
+		brokers[i] = fmt.Sprintf("%s:%d", b.Hostname, *b.Port)
+	}
 
-				// Create Producer instance
-				p, err := kafka.NewProducer(&kafka.ConfigMap{
-					"bootstrap.servers": brokers[0]})
-				if err != nil {
-					s.log.WithField("error", err).Error("Failed to create producer")
-					os.Exit(1)
-				}
+	topic := "platform.edge.fleetmgmt.image-build"
 
-				// assemble the message to be sent
-				// TODO: formalize message formats
-				recordKey := "postProcessInstaller"
-				recordValue, _ := json.Marshal(&image)
-				s.log.WithField("message", recordValue).Debug("Preparing record for producer")
-				perr := p.Produce(&kafka.Message{
-					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-					Key:            []byte(recordKey),
-					Value:          []byte(recordValue),
-				}, nil)
-				if perr != nil {
-					s.log.Error("Error sending message")
-				}
+	// Create Producer instance
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": brokers[0]})
+	if err != nil {
+		s.log.WithField("error", err).Error("Failed to create producer")
+		return
+	}
 
-				// Wait for all messages to be delivered
-				p.Flush(15 * 1000)
-				p.Close()
+	// assemble the message to be sent
+	// TODO: formalize message formats
+	recordValue, _ := json.Marshal(&image)
+	s.log.WithField("message", recordValue).Debug("Preparing record for producer")
+	perr := p.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(recordKey),
+		Value:          []byte(recordValue),
+	}, nil)
+	if perr != nil {
+		s.log.Error("Error sending message")
+	}
 
-				s.log.WithField("topic", topic).Debug("postProcessInstaller message was produced to topic")
-			}
+	// Wait for all messages to be delivered
+	p.Flush(15 * 1000)
+	p.Close()
 
-			break
-		}
-		time.Sleep(1 * time.Minute)
-	}
+	s.log.WithFields(log.Fields{"topic": topic, "recordKey": recordKey}).Debug("Image build message was produced to topic")
+}
 
+// postProcessInstaller injects the kickstart into the ISO of the installer composed and sets the final image status
+func (s *ImageService) postProcessInstaller(image *models.Image) error {
+	s.log.Debug("Post processing the installer for the image")
 	if image.Installer.Status == models.ImageStatusSuccess {
 		// Post process the installer ISO
 		//	User, kickstart, checksum, etc.
@@ -373,87 +368,82 @@ func (s *ImageService) postProcessInstaller(image *models.Image) error {
 	return nil
 }
 
+// postProcessCommit creates the repo of the commit composed and then composes the installer of the image,
+// or sets the final image status when the image has no installer
 func (s *ImageService) postProcessCommit(image *models.Image) error {
 	s.log.Debug("Processing image build commit")
-	for {
-		i, err := s.UpdateImageStatus(image)
-		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Update image status error")
-			return err
-		}
-		if i.Commit.Status != models.ImageStatusBuilding {
-
-			// if clowder is enabled, send an event on Image Build completion
-			// TODO: break this out into its own function
-			if clowder.IsClowderEnabled() {
-				// get the list of brokers from the config
-				brokers := make([]string, len(clowder.LoadedConfig.Kafka.Brokers))
-				for i, b := range clowder.LoadedConfig.Kafka.Brokers {
-					brokers[i] = fmt.Sprintf("%s:%d", b.Hostname, *b.Port)
-				}
-
-				topic := "platform.edge.fleetmgmt.image-build"
-
-				// Create Producer instance
-				p, err := kafka.NewProducer(&kafka.ConfigMap{
-					"bootstrap.servers": brokers[0]})
-				if err != nil {
-					s.log.WithField("error", err).Error("Failed to create producer")
-					os.Exit(1)
-				}
-
-				// assemble the message to be sent
-				// TODO: formalize message formats
-				recordKey := "postProcessCommit"
-				recordValue, _ := json.Marshal(&image)
-				s.log.WithField("message", recordValue).Debug("Preparing record for producer")
-				// send the message
-				perr := p.Produce(&kafka.Message{
-					TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-					Key:            []byte(recordKey),
-					Value:          []byte(recordValue),
-				}, nil)
-				if perr != nil {
-					s.log.Error("Error sending message")
-				}
-
-				// Wait for all messages to be delivered
-				p.Flush(15 * 1000)
-				p.Close()
-
-				s.log.WithField("topic", topic).Debug("postProcessCommit message was produced to topic")
-			}
-
-			break
-		}
-		time.Sleep(1 * time.Minute)
+	i, err := s.ImageBuilder.GetMetadata(image)
+	if err != nil {
+		s.log.WithField("error", err.Error()).Error("Failed getting metadata from image builder")
+		s.SetErrorStatusOnImage(err, i)
+		return err
 	}
 
-	if image.Commit.Status == models.ImageStatusSuccess {
-		i, err := s.ImageBuilder.GetMetadata(image)
-		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Failed getting metadata from image builder")
-			s.SetErrorStatusOnImage(err, i)
-			return err
-		}
-
-		// Create the repo for the image
-		_, err = s.CreateRepoForImage(image)
-		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Failed creating repo for image")
-			return err
-		}
+	// Create the repo for the image
+	_, err = s.CreateRepoForImage(image)
+	if err != nil {
+		s.log.WithField("error", err.Error()).Error("Failed creating repo for image")
+		return err
 	}
 	if !image.HasOutputType(models.ImageTypeInstaller) {
 		image.Installer = nil
 		s.log.Debug("Setting final image status - no installer to create")
 		s.SetFinalImageStatus(image)
 		s.log.Debug("Processing image is done - no installer to create")
+		return nil
+	}
+
+	// Request an installer ISO from Image Builder for the image
+	s.log.WithField("imageID", image.ID).Debug("Creating an installer for this image")
+	if _, err := s.CreateInstallerForImage(image); err != nil {
+		s.SetErrorStatusOnImage(err, image)
+		s.log.WithField("error", err.Error()).Error("Failed creating installer for image")
+		return err
 	}
 	s.log.Debug("Processing commit is done")
 	return nil
 }
 
+// postProcessImage runs the step of the image build that follows the last compose done
+// The composes are tracked by the compose scheduler, which enqueues the build of the image again once a compose is done
+func (s *ImageService) postProcessImage(image *models.Image) error {
+	// NOTE: Every log message in this method already has commit id and image id injected
+	if image.Commit.RepoID != nil {
+		var repo models.Repo
+		if result := db.DB.First(&repo, *image.Commit.RepoID); result.Error == nil {
+			image.Commit.Repo = &repo
+		}
+	}
+	switch {
+	case image.Commit.Status == models.ImageStatusBuilding:
+		s.log.WithField("composeStatus", image.Commit.ComposeStatus).Debug("Commit is being composed")
+	case image.Commit.Status != models.ImageStatusSuccess:
+		s.SetFinalImageStatus(image)
+	case image.Commit.Repo == nil || image.Commit.Repo.Status != models.RepoStatusSuccess:
+		s.log.Debug("Commit is successful")
+		if err := s.postProcessCommit(image); err != nil {
+			return err
+		}
+	case !image.HasOutputType(models.ImageTypeInstaller) || image.Installer == nil:
+		image.Installer = nil
+		s.SetFinalImageStatus(image)
+	case image.Installer.Status == models.ImageStatusCreated:
+		// the build stopped after the repo of the commit was created
+		if _, err := s.CreateInstallerForImage(image); err != nil {
+			s.SetErrorStatusOnImage(err, image)
+			return err
+		}
+	case image.Installer.Status == models.ImageStatusBuilding:
+		s.log.WithField("composeStatus", image.Installer.ComposeStatus).Debug("Installer is being composed")
+	default:
+		if err := s.postProcessInstaller(image); err != nil {
+			return err
+		}
+	}
+	s.log.WithField("status", image.Status).Debug("Processing image build step is done")
+	return nil
+}
+
 // SetFinalImageStatus sets the final image status
 func (s *ImageService) SetFinalImageStatus(i *models.Image) {
 	// image status can be success if all output types are successful
@@ -465,7 +455,7 @@ func (s *ImageService) SetFinalImageStatus(i *models.Image) {
 			if i.Commit == nil || i.Commit.Status != models.ImageStatusSuccess {
 				success = false
 			}
-			if i.Commit.Status == models.ImageStatusBuilding {
+			if i.Commit != nil && i.Commit.Status == models.ImageStatusBuilding {
 				success = false
 				i.Commit.Status = models.ImageStatusError
 				db.DB.Save(i.Commit)
@@ -475,7 +465,7 @@ func (s *ImageService) SetFinalImageStatus(i *models.Image) {
 			if i.Installer == nil || i.Installer.Status != models.ImageStatusSuccess {
 				success = false
 			}
-			if i.Installer.Status == models.ImageStatusBuilding {
+			if i.Installer != nil && i.Installer.Status == models.ImageStatusBuilding {
 				success = false
 				i.Installer.Status = models.ImageStatusError
 				db.DB.Save(i.Installer)
@@ -499,85 +489,13 @@ func (s *ImageService) SetFinalImageStatus(i *models.Image) {
 		if err := s.SetDevicesUpdateAvailabilityFromImageSet(i.Account, *i.ImageSetID); err != nil {
 			s.log.WithField("error", err.Error()).Error("Error while setting devices update availability flag")
 		}
-	}
-}
-
-func (s *ImageService) postProcessImage(id uint) {
-	// NOTE: Every log message in this method already has commit id and image id injected
-
-	s.log.Debug("Processing image build")
-	var i *models.Image
-
-	// setup a context and signal for SIGTERM
-	ctx := context.Background()
-	intctx, intcancel := context.WithCancel(ctx)
-	sigint := make(chan os.Signal, 1)
-	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
-
-	// this will run at the end of postProcessImage to tidy up signal and context
-	defer func() {
-		s.log.WithField("imageID", id).Debug("Stopping the interrupt context and sigint signal")
-		signal.Stop(sigint)
-		intcancel()
-	}()
-	// This runs alongside and blocks on either a signal or normal completion from defer above
-	// 	if an interrupt, set image to INTERRUPTED in database
-	go func() {
-		s.log.WithField("imageID", id).Debug("Running the select go routine to handle completion and interrupts")
-
-		select {
-		case <-sigint:
-			// we caught an interrupt. Mark the image as interrupted.
-			s.log.WithField("imageID", id).Debug("Select case SIGINT interrupt has been triggered")
-
-			tx := db.DB.Debug().Model(&models.Image{}).Where("ID = ?", id).Update("Status", models.ImageStatusInterrupted)
-			s.log.WithField("imageID", id).Debug("Image updated with interrupted status")
-			if tx.Error != nil {
-				s.log.WithField("error", tx.Error.Error()).Error("Error updating image")
-			}
-
-			// cancel the context
-			intcancel()
-			return
-		case <-intctx.Done():
-			// Things finished normally and reached the defer defined above.
-			s.log.WithField("imageID", id).Info("Select case context intctx done has been triggered")
-		}
-	}()
-
-	// business as usual from here to end of block
-	db.DB.Debug().Joins("Commit").Joins("Installer").First(&i, id)
-
-	// Request a commit from Image Builder for the image
-	s.log.WithField("imageID", i.ID).Debug("Creating a commit for this image")
-	err := s.postProcessCommit(i)
-	if err != nil {
-		s.SetErrorStatusOnImage(err, i)
-		s.log.WithField("error", err.Error()).Error("Failed creating commit for image")
-	}
-
-	if i.Commit.Status == models.ImageStatusSuccess {
-		s.log.Debug("Commit is successful")
-
-		// Request an installer ISO from Image Builder for the image
-		if i.HasOutputType(models.ImageTypeInstaller) {
-			s.log.WithField("imageID", i.ID).Debug("Creating an installer for this image")
-			i, c, err := s.CreateInstallerForImage(i)
-			/* CreateInstallerForImage is also called directly from an endpoint.
-			If called from the endpoint it will not block
-				the caller returns the channel output to _
-			Here, we catch the channel with c and use it in the next if--so it blocks.
-			*/
-			if c != nil {
-				err = <-c
-			}
-			if err != nil {
-				s.SetErrorStatusOnImage(err, i)
-				s.log.WithField("error", err.Error()).Error("Failed creating installer for image")
+		// the update repo to the new version is built before any update to it is created
+		if i.Version > 1 && config.Get().UpdateRepoDeltaVersions > 0 {
+			if _, err := s.JobService.Enqueue(models.JobTypeUpdateRepoPrebuild, i.Account, i.ID); err != nil {
+				s.log.WithField("error", err.Error()).Error("Error enqueuing image update repo prebuild")
 			}
 		}
 	}
-	s.log.WithField("status", i.Status).Debug("Processing image build is done")
 }
 
 // CreateRepoForImage creates the OSTree repo to host that image
@@ -614,22 +532,26 @@ func (s *ImageService) CreateRepoForImage(i *models.Image) (*models.Repo, error)
 // SetErrorStatusOnImage is a helper functions that sets the error status on images
 func (s *ImageService) SetErrorStatusOnImage(err error, i *models.Image) {
 	if i.Status != models.ImageStatusError {
+		ctx := s.ctx
+		if err != nil {
+			ctx = models.ContextWithEventMessage(ctx, err.Error())
+		}
 		i.Status = models.ImageStatusError
-		tx := db.DB.Debug().Save(i)
+		tx := eventsDB(ctx).Debug().Save(i)
 		s.log.Debug("Image saved with error status")
 		if tx.Error != nil {
 			s.log.WithField("error", tx.Error.Error()).Error("Error saving image")
 		}
 		if i.Commit != nil {
 			i.Commit.Status = models.ImageStatusError
-			tx := db.DB.Debug().Save(i.Commit)
+			tx := eventsDB(ctx).Debug().Save(i.Commit)
 			if tx.Error != nil {
 				s.log.WithField("error", tx.Error.Error()).Error("Error saving commit")
 			}
 		}
 		if i.Installer != nil {
 			i.Installer.Status = models.ImageStatusError
-			tx := db.DB.Debug().Save(i.Installer)
+			tx := eventsDB(ctx).Debug().Save(i.Installer)
 			if tx.Error != nil {
 				s.log.WithField("error", tx.Error.Error()).Error("Error saving installer")
 			}
@@ -659,8 +581,17 @@ func (s *ImageService) AddUserInfo(image *models.Image) error {
 		return fmt.Errorf("error downloading ISO file :: %s", err.Error())
 	}
 
+	signingKey, err := GetAccountSigningKey(image.Account)
+	if err != nil {
+		return fmt.Errorf("error getting signing key :: %s", err.Error())
+	}
+	var gpgKey string
+	if signingKey != nil {
+		gpgKey = strings.TrimSpace(string(signingKey.PublicKey))
+	}
+
 	s.log.Debug("Adding SSH Key to kickstart file...")
-	err = s.addSSHKeyToKickstart(sshKey, username, kickstart)
+	err = s.addSSHKeyToKickstart(sshKey, username, gpgKey, kickstart)
 	if err != nil {
 		return fmt.Errorf("error adding ssh key to kickstart file :: %s", err.Error())
 	}
@@ -693,16 +624,18 @@ func (s *ImageService) AddUserInfo(image *models.Image) error {
 }
 
 // UnameSSH is the template struct for username and ssh key
+// GpgKey is the public key verifying the ostree commits, empty when the commits are not signed
 type UnameSSH struct {
 	Sshkey   string
 	Username string
+	GpgKey   string
 }
 
-// Adds user provided ssh key to the kickstart file.
-func (s *ImageService) addSSHKeyToKickstart(sshKey string, username string, kickstart string) error {
+// Adds user provided ssh key and the public key of the account to the kickstart file.
+func (s *ImageService) addSSHKeyToKickstart(sshKey string, username string, gpgKey string, kickstart string) error {
 	cfg := config.Get()
 
-	td := UnameSSH{sshKey, username}
+	td := UnameSSH{sshKey, username, gpgKey}
 
 	s.log.WithField("templatesPath", cfg.TemplatesPath).Debug("Opening file")
 	t, err := template.ParseFiles(cfg.TemplatesPath + "templateKickstart.ks")
@@ -812,13 +745,14 @@ func (s *ImageService) cleanFiles(kickstart string, isoName string, imageID uint
 
 // UpdateImageStatus updates the status of an commit and/or installer based on Image Builder's status
 func (s *ImageService) UpdateImageStatus(image *models.Image) (*models.Image, error) {
+	ctx := models.ContextWithEventActor(s.ctx, models.EventActorImageBuilder)
 	if image.Commit.Status == models.ImageStatusBuilding {
 		image, err := s.ImageBuilder.GetCommitStatus(image)
 		if err != nil {
 			return image, err
 		}
 		if image.Commit.Status != models.ImageStatusBuilding {
-			tx := db.DB.Save(&image.Commit)
+			tx := eventsDB(ctx).Save(&image.Commit)
 			if tx.Error != nil {
 				return image, tx.Error
 			}
@@ -830,14 +764,14 @@ func (s *ImageService) UpdateImageStatus(image *models.Image) (*models.Image, er
 			return image, err
 		}
 		if image.Installer.Status != models.ImageStatusBuilding {
-			tx := db.DB.Save(&image.Installer)
+			tx := eventsDB(ctx).Save(&image.Installer)
 			if tx.Error != nil {
 				return image, tx.Error
 			}
 		}
 	}
 	if image.Status != models.ImageStatusBuilding {
-		tx := db.DB.Save(&image)
+		tx := eventsDB(ctx).Save(&image)
 		if tx.Error != nil {
 			return image, tx.Error
 		}
@@ -1021,7 +955,10 @@ func (s *ImageService) RetryCreateImage(image *models.Image) error {
 		s.log.WithField("error", err.Error()).Error("Failed setting image status")
 		return nil
 	}
-	go s.postProcessImage(image.ID)
+	if _, err := s.JobService.Enqueue(models.JobTypeImageBuild, image.Account, image.ID); err != nil {
+		s.log.WithField("error", err.Error()).Error("Error enqueuing image build")
+		return err
+	}
 	return nil
 }
 
@@ -1045,7 +982,53 @@ func (s *ImageService) ResumeCreateImage(id uint) error {
 		s.log.WithField("error", err.Error()).Error("Failed setting image status")
 		return err
 	}
-	go s.postProcessImage(image.ID)
+	if _, err := s.JobService.Enqueue(models.JobTypeImageBuild, image.Account, image.ID); err != nil {
+		s.log.WithField("error", err.Error()).Error("Error enqueuing image build")
+		return err
+	}
+	return nil
+}
+
+// BuildImage runs the step of the build of an image enqueued as a job, the image is enqueued again
+// by the compose scheduler for the next step once the compose of its commit or installer is done
+// Resuming a build interrupted by a restart tracks the compose of the commit again
+// while retrying a failed build recomposes the commit
+func (s *ImageService) BuildImage(id uint, resume bool) error {
+	var image *models.Image
+	result := db.DB.Preload("Packages").Preload("CustomPackages").Preload("ThirdPartyRepositories").
+		Joins("Commit").Joins("Installer").First(&image, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	s.log = s.log.WithFields(log.Fields{"imageID": image.ID, "commitID": image.Commit.ID})
+	if image.Status == models.ImageStatusSuccess {
+		s.log.Info("Image is already built")
+		return nil
+	}
+	if resume {
+		if image.Status == models.ImageStatusError {
+			s.log.Info("Recomposing the commit of the failed image build")
+			if _, err := s.ImageBuilder.ComposeCommit(image); err != nil {
+				return err
+			}
+		} else {
+			s.log.Info("Resuming the image build")
+		}
+		if err := s.SetBuildingStatusOnImageToRetryBuild(image); err != nil {
+			return err
+		}
+	}
+	if err := s.postProcessImage(image); err != nil {
+		s.SetErrorStatusOnImage(err, image)
+		s.log.WithField("error", err.Error()).Error("Failed processing image build")
+	}
+
+	if result := db.DB.Select("status").First(&image, id); result.Error != nil {
+		return result.Error
+	}
+	if image.Status == models.ImageStatusError {
+		return new(ImageBuildFailed)
+	}
 	return nil
 }
 
@@ -1057,10 +1040,13 @@ func (s *ImageService) SetBuildingStatusOnImageToRetryBuild(image *models.Image)
 		s.log.Debug("Setting commit status")
 		image.Commit.Status = models.ImageStatusBuilding
 		// Repo will be recreated from scratch, its safer and simpler as this stage
-		if image.Commit.Repo != nil {
+		if image.Commit.Repo != nil || image.Commit.RepoID != nil {
 			s.log.Debug("Reset repo")
 			image.Commit.Repo = nil
+			image.Commit.RepoID = nil
 		}
+		// the compose is tracked again from its first check
+		image.Commit.ComposeProgress = models.ComposeProgress{}
 		s.log.Debug("Saving commit status")
 		tx := db.DB.Save(image.Commit)
 		if tx.Error != nil {
@@ -1070,6 +1056,7 @@ func (s *ImageService) SetBuildingStatusOnImageToRetryBuild(image *models.Image)
 	if image.Installer != nil {
 		s.log.Debug("Setting installer status")
 		image.Installer.Status = models.ImageStatusCreated
+		image.Installer.ComposeProgress = models.ComposeProgress{}
 		s.log.Debug("Saving installer status")
 		tx := db.DB.Save(image.Installer)
 		if tx.Error != nil {
@@ -1171,45 +1158,44 @@ func (s *ImageService) GetMetadata(image *models.Image) (*models.Image, error) {
 }
 
 // CreateInstallerForImage creates a installer given an existing image
-func (s *ImageService) CreateInstallerForImage(image *models.Image) (*models.Image, chan error, error) {
+// The compose of the installer is tracked by the compose scheduler, which post processes the installer once it is done
+func (s *ImageService) CreateInstallerForImage(image *models.Image) (*models.Image, error) {
 	s.log.Debug("Creating installer for image")
-	c := make(chan error)
 
 	image.ImageType = models.ImageTypeInstaller
 	image.Installer.Status = models.ImageStatusBuilding
+	image.Installer.ComposeProgress = models.ComposeProgress{}
 	tx := db.DB.Save(&image)
 	if tx.Error != nil {
 		s.log.WithField("error", tx.Error.Error()).Error("Error saving image")
-		return nil, c, tx.Error
+		return nil, tx.Error
 	}
 	tx = db.DB.Save(&image.Installer)
 	if tx.Error != nil {
 		s.log.WithField("error", tx.Error.Error()).Error("Error saving installer")
-		return nil, c, tx.Error
+		return nil, tx.Error
 	}
 	image, err := s.ImageBuilder.ComposeInstaller(image)
 	if err != nil {
-		return nil, c, err
+		return nil, err
 	}
-	go func(chan error) {
-		err := s.postProcessInstaller(image)
-		c <- err
-	}(c)
-	return image, c, nil
+	return image, nil
 }
 
-// GetRollbackImage returns the previous image from the image set in case of a rollback
+// GetRollbackImage returns the image a device running the given image is rolled back to:
+// the latest successful version of its image set older than it
 func (s *ImageService) GetRollbackImage(image *models.Image) (*models.Image, error) {
 	s.log.Info("Getting rollback image")
 	var rollback models.Image
-	account, err := common.GetAccountFromContext(s.ctx)
-	if err != nil {
-		s.log.Error("Error retreving account")
-		return nil, new(AccountNotSet)
-	}
-	result := db.DB.Joins("Commit").Joins("Installer").Preload("Packages").Preload("CustomPackages").Preload("ThirdPartyRepositories").Preload("Commit.InstalledPackages").Preload("Commit.Repo").Where(&models.Image{ImageSetID: image.ImageSetID, Account: account}).Last(&rollback, "images.id < ?", image.ID)
+	result := db.DB.Joins("Commit").Joins("Installer").Preload("Packages").Preload("CustomPackages").Preload("ThirdPartyRepositories").Preload("Commit.InstalledPackages").Preload("Commit.Repo").
+		Where("images.account = ? AND images.image_set_id = ? AND images.status = ? AND images.version < ?",
+			image.Account, image.ImageSetID, models.ImageStatusSuccess, image.Version).
+		Order("images.version DESC").Limit(1).Find(&rollback)
 	if result.Error != nil {
 		s.log.WithField("error", result.Error).Error("Error retrieving rollback image")
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
 		return nil, new(ImageNotFoundError)
 	}
 	s.log = s.log.WithField("imageID", image.ID)
@@ -1331,3 +1317,289 @@ func (s *ImageService) SetDevicesUpdateAvailabilityFromImageSet(account string,
 
 	return nil
 }
+
+// GetImageEvents returns the timeline of an image build,
+// the transitions of the image, of its commit and of its installer in the order they happened
+func (s *ImageService) GetImageEvents(image *models.Image) ([]models.Event, error) {
+	events := []models.Event{}
+	query := db.DB.Where("resource_type = ? AND resource_id = ?", models.EventResourceImage, image.ID)
+	if image.CommitID != 0 {
+		query = query.Or("resource_type = ? AND resource_id = ?", models.EventResourceCommit, image.CommitID)
+	}
+	if image.InstallerID != nil {
+		query = query.Or("resource_type = ? AND resource_id = ?", models.EventResourceInstaller, *image.InstallerID)
+	}
+	if result := query.Order("created_at, id").Find(&events); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error getting image events")
+		return nil, result.Error
+	}
+	return events, nil
+}
+
+// DeleteImage deletes an image with its commit, its installer, its repo and their packages,
+// the artifacts of the image are deleted from the storage by a job
+// An image is not deleted while it is building, while devices run it or while an update to or from it is in progress
+func (s *ImageService) DeleteImage(image *models.Image) error {
+	images := []models.Image{*image}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkImagesCanBeDeleted(tx, images); err != nil {
+			return err
+		}
+		return deleteImages(tx, images)
+	})
+	switch err.(type) {
+	case nil:
+	case *ImageInUse, *ImageHasUpdateInProgress, *ImageIsBuilding:
+		s.log.WithField("error", err.Error()).Info("Image cannot be deleted")
+		return err
+	default:
+		s.log.WithField("error", err.Error()).Error("Error deleting image")
+		return err
+	}
+	s.log.Info("Image deleted")
+	enqueueImageArtifactsDelete(s.JobService, s.log, images)
+	return nil
+}
+
+// checkImagesCanBeDeleted returns an error when an image is building, is run by a device
+// or is the commit, or one of the old commits, of an update in progress
+// It runs in the transaction deleting the images, the images and the cached update repos of their commits
+// are locked until the transaction ends and the status of the images is read again under the lock
+func checkImagesCanBeDeleted(tx *gorm.DB, images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	imageIDs := make([]uint, 0, len(images))
+	for _, image := range images {
+		imageIDs = append(imageIDs, image.ID)
+	}
+	var lockedImages []models.Image
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status", "commit_id").
+		Find(&lockedImages, imageIDs); result.Error != nil {
+		return result.Error
+	}
+	commitIDs := make([]uint, 0, len(images))
+	for _, image := range lockedImages {
+		if image.Status == models.ImageStatusCreated || image.Status == models.ImageStatusBuilding {
+			return new(ImageIsBuilding)
+		}
+		if image.CommitID != 0 {
+			commitIDs = append(commitIDs, image.CommitID)
+		}
+	}
+	var devicesCount int64
+	if result := tx.Model(&models.Device{}).Where("image_id IN ?", imageIDs).Count(&devicesCount); result.Error != nil {
+		return result.Error
+	}
+	if devicesCount > 0 {
+		return new(ImageInUse)
+	}
+	if len(commitIDs) == 0 {
+		return nil
+	}
+	var updatesCount int64
+	if result := tx.Model(&models.UpdateTransaction{}).
+		Where("status IN ?", []string{models.UpdateStatusCreated, models.UpdateStatusQueued, models.UpdateStatusBuilding, models.UpdateStatusPaused}).
+		Where("commit_id IN ? OR id IN (SELECT update_transaction_id FROM updatetransaction_commits WHERE commit_id IN ?)", commitIDs, commitIDs).
+		Count(&updatesCount); result.Error != nil {
+		return result.Error
+	}
+	if updatesCount > 0 {
+		return new(ImageHasUpdateInProgress)
+	}
+	// an update on error may still be dispatching devices with the cached update repo of the commit,
+	// the caches are locked so that no update takes a reference on them before the images are deleted
+	var caches []models.UpdateRepoCache
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "reference_count").
+		Where("commit_id IN ?", commitIDs).Find(&caches); result.Error != nil {
+		return result.Error
+	}
+	for _, cache := range caches {
+		if cache.ReferenceCount > 0 {
+			return new(ImageHasUpdateInProgress)
+		}
+	}
+	return nil
+}
+
+// deleteImages deletes the images with their packages, their commits with their installed packages,
+// the repos of their commits and their installers
+func deleteImages(tx *gorm.DB, images []models.Image) error {
+	for i := range images {
+		image := &images[i]
+		for _, association := range []string{"Packages", "CustomPackages", "ThirdPartyRepositories"} {
+			if err := tx.Model(image).Association(association).Clear(); err != nil {
+				return err
+			}
+		}
+		if image.CommitID != 0 {
+			var commit models.Commit
+			if result := tx.Limit(1).Find(&commit, image.CommitID); result.Error != nil {
+				return result.Error
+			}
+			if commit.ID != 0 {
+				if err := tx.Model(&commit).Association("InstalledPackages").Clear(); err != nil {
+					return err
+				}
+				if commit.RepoID != nil {
+					if result := tx.Delete(&models.Repo{}, *commit.RepoID); result.Error != nil {
+						return result.Error
+					}
+				}
+				if result := tx.Delete(&commit); result.Error != nil {
+					return result.Error
+				}
+			}
+		}
+		if image.InstallerID != nil {
+			if result := tx.Delete(&models.Installer{}, *image.InstallerID); result.Error != nil {
+				return result.Error
+			}
+		}
+		if result := tx.Delete(image); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// enqueueImageArtifactsDelete enqueues the jobs deleting the artifacts of the deleted images from the storage,
+// the images are deleted even when the jobs cannot be enqueued
+func enqueueImageArtifactsDelete(jobService JobServiceInterface, log *log.Entry, images []models.Image) {
+	for _, image := range images {
+		if _, err := jobService.Enqueue(models.JobTypeImageArtifactsDelete, image.Account, image.ID); err != nil {
+			log.WithFields(map[string]interface{}{"error": err.Error(), "imageID": image.ID}).
+				Error("Error enqueueing the deletion of the image artifacts")
+		}
+	}
+}
+
+// DeleteImageArtifacts deletes from the storage the commit tarball, the repo and the ISO of a deleted image,
+// a tarball or an ISO still used by another commit or installer is kept
+func (s *ImageService) DeleteImageArtifacts(id uint) error {
+	var image models.Image
+	if result := db.DB.Unscoped().Limit(1).Find(&image, id); result.Error != nil {
+		return result.Error
+	}
+	if image.ID == 0 {
+		return new(ImageNotFoundError)
+	}
+	if !image.DeletedAt.Valid {
+		return fmt.Errorf("image %d is not deleted", image.ID)
+	}
+	logger := s.log.WithField("imageID", image.ID)
+	uploader := NewFilesService(logger).GetUploader()
+	if image.CommitID != 0 {
+		var commit models.Commit
+		if result := db.DB.Unscoped().Limit(1).Find(&commit, image.CommitID); result.Error != nil {
+			return result.Error
+		}
+		if commit.ImageBuildTarURL != "" {
+			if err := deleteUnusedArtifactFile(uploader, logger, &models.Commit{}, "image_build_tar_url", commit.ImageBuildTarURL); err != nil {
+				return err
+			}
+		}
+		if commit.RepoID != nil {
+			var repo models.Repo
+			if result := db.DB.Unscoped().Limit(1).Find(&repo, *commit.RepoID); result.Error != nil {
+				return result.Error
+			}
+			if repo.URL != "" {
+				cfg := config.Get()
+				repoID := strconv.FormatUint(uint64(repo.ID), 10)
+				logger.WithField("repoID", repo.ID).Info("Deleting image repo")
+				// the repo was uploaded from cfg.RepoTempPath/models.Repo.ID/repo by ImportRepo
+				if err := uploader.DeleteRepo(filepath.Clean(filepath.Join(cfg.RepoTempPath, repoID, "repo")), repoID); err != nil {
+					return err
+				}
+			}
+		}
+		if err := deleteUnusedUpdateRepoCaches(uploader, logger, image.Account, image.CommitID); err != nil {
+			return err
+		}
+	}
+	if image.InstallerID != nil {
+		var installer models.Installer
+		if result := db.DB.Unscoped().Limit(1).Find(&installer, *image.InstallerID); result.Error != nil {
+			return result.Error
+		}
+		if installer.ImageBuildISOURL != "" {
+			if err := deleteUnusedArtifactFile(uploader, logger, &models.Installer{}, "image_build_iso_url", installer.ImageBuildISOURL); err != nil {
+				return err
+			}
+		}
+	}
+	logger.Info("Image artifacts deleted")
+	return nil
+}
+
+// deleteUnusedUpdateRepoCaches deletes the cached update repos to a commit no update uses anymore,
+// with their repo in the storage and their workspace
+func deleteUnusedUpdateRepoCaches(uploader files.Uploader, log *log.Entry, account string, commitID uint) error {
+	var caches []models.UpdateRepoCache
+	if result := db.DB.Where("account = ? AND commit_id = ? AND reference_count <= 0", account, commitID).
+		Find(&caches); result.Error != nil {
+		return result.Error
+	}
+	for i := range caches {
+		cache := &caches[i]
+		var repo models.Repo
+		if result := db.DB.Limit(1).Find(&repo, cache.RepoID); result.Error != nil {
+			return result.Error
+		}
+		path := updateRepoPath(cache.RepoID)
+		if repo.URL != "" {
+			log.WithField("repoID", cache.RepoID).Info("Deleting cached update repo")
+			// the repo was uploaded from its workspace by the repo builder
+			if err := uploader.DeleteRepo(filepath.Clean(filepath.Join(path, "repo")), account); err != nil {
+				return err
+			}
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if result := db.DB.Delete(cache); result.Error != nil {
+			return result.Error
+		}
+		if repo.ID != 0 {
+			if result := db.DB.Delete(&repo); result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+	return nil
+}
+
+// deleteUnusedArtifactFile deletes the file of an artifact from the storage unless a record
+// of the model not deleted still has the url in the column, as the versions of an image share their ISO
+func deleteUnusedArtifactFile(uploader files.Uploader, log *log.Entry, model interface{}, column string, url string) error {
+	var count int64
+	if result := db.DB.Model(model).Where(fmt.Sprintf("%s = ?", column), url).Count(&count); result.Error != nil {
+		return result.Error
+	}
+	if count > 0 {
+		log.WithField("url", url).Info("Artifact file is still used, keeping it")
+		return nil
+	}
+	log.WithField("url", url).Info("Deleting artifact file")
+	return uploader.DeleteFile(url)
+}
+
+// CompareImages returns the difference from an image to another image of its account, of any image set
+func (s *ImageService) CompareImages(image *models.Image, otherImageID uint) (*models.ImageComparison, error) {
+	images := make([]models.Image, 2)
+	for i, id := range []uint{image.ID, otherImageID} {
+		result := db.DB.Joins("Commit").Joins("Installer").Preload("Packages").Preload("CustomPackages").
+			Preload("ThirdPartyRepositories").Preload("Commit.InstalledPackages").
+			Where("images.account = ?", image.Account).Limit(1).Find(&images[i], id)
+		if result.Error != nil {
+			s.log.WithField("error", result.Error.Error()).Error("Error getting image to compare")
+			return nil, result.Error
+		}
+		if images[i].ID == 0 {
+			s.log.WithField("imageID", id).Info("Image to compare not found")
+			return nil, new(ImageNotFoundError)
+		}
+	}
+	return compareImages(&images[0], &images[1]), nil
+}
@@ -3,11 +3,14 @@ package services_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/bxcodec/faker/v3"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/config"
 	"github.com/redhatinsights/edge-api/pkg/clients/imagebuilder/mock_imagebuilder"
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/models"
@@ -15,6 +18,7 @@ import (
 	"github.com/redhatinsights/edge-api/pkg/services"
 	"github.com/redhatinsights/edge-api/pkg/services/mock_services"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 var _ = Describe("Image Service Test", func() {
@@ -142,6 +146,18 @@ var _ = Describe("Image Service Test", func() {
 					Expect(image.ID).To(Equal(imageV1.ID))
 				})
 			})
+			Context("when a failed image is between the image and its rollback image", func() {
+				It("should skip the failed image", func() {
+					failedImage := &models.Image{Status: models.ImageStatusError, ImageSetID: &imageSet.ID, Version: 3, Account: common.DefaultAccount}
+					Expect(db.DB.Create(failedImage).Error).ToNot(HaveOccurred())
+					imageV4 := &models.Image{Status: models.ImageStatusSuccess, ImageSetID: &imageSet.ID, Version: 4, Account: common.DefaultAccount}
+					Expect(db.DB.Create(imageV4).Error).ToNot(HaveOccurred())
+
+					image, err := service.GetRollbackImage(imageV4)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(image.ID).To(Equal(imageV2.ID))
+				})
+			})
 			Context("when rollback image doesnt exists", func() {
 				var image *models.Image
 				var err error
@@ -361,6 +377,31 @@ var _ = Describe("Image Service Test", func() {
 			})
 		})
 
+		Context("when image is a new version of an image set", func() {
+			It("should prebuild the update repo to the new version", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				defer ctrl.Finish()
+				mockJobService := mock_services.NewMockJobServiceInterface(ctrl)
+				service.JobService = mockJobService
+				account := faker.UUIDHyphenated()
+				imageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+				Expect(db.DB.Create(&imageSet).Error).ToNot(HaveOccurred())
+				image := &models.Image{
+					Account:     account,
+					ImageSetID:  &imageSet.ID,
+					Version:     2,
+					Commit:      &models.Commit{Status: models.ImageStatusSuccess},
+					OutputTypes: []string{models.ImageTypeCommit},
+				}
+				Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+				mockJobService.EXPECT().Enqueue(models.JobTypeUpdateRepoPrebuild, account, image.ID).Return(&models.Job{}, nil)
+
+				service.SetFinalImageStatus(image)
+
+				Expect(image.Status).To(Equal(models.ImageStatusSuccess))
+			})
+		})
+
 		Context("when setting the status to retry an image build", func() {
 			It("should set status to building", func() {
 				image := &models.Image{
@@ -590,4 +631,310 @@ var _ = Describe("Image Service Test", func() {
 			})
 		})
 	})
+	Describe("build image job", func() {
+		It("should not build an image already built", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.Name(), Status: models.ImageStatusSuccess, Commit: &models.Commit{}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.BuildImage(image.ID, true)).To(Succeed())
+		})
+		It("should recompose the commit when retrying a failed build", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.Name(), Status: models.ImageStatusError, Commit: &models.Commit{}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			expectedErr := fmt.Errorf("image builder is unavailable")
+			mockImageBuilderClient.EXPECT().ComposeCommit(gomock.Any()).Return(nil, expectedErr)
+
+			Expect(service.BuildImage(image.ID, true)).To(MatchError(expectedErr))
+		})
+		It("should leave the compose of the commit to the compose scheduler", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.Name(), Status: models.ImageStatusBuilding,
+				Commit: &models.Commit{Status: models.ImageStatusBuilding, ComposeJobID: faker.UUIDHyphenated()}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.BuildImage(image.ID, false)).To(Succeed())
+			var saved models.Image
+			Expect(db.DB.First(&saved, image.ID).Error).ToNot(HaveOccurred())
+			Expect(saved.Status).To(Equal(models.ImageStatusBuilding))
+		})
+		It("should compose the installer once the repo of the commit is built", func() {
+			repo := &models.Repo{Status: models.RepoStatusSuccess}
+			Expect(db.DB.Create(repo).Error).ToNot(HaveOccurred())
+			image := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.Name(), Status: models.ImageStatusBuilding,
+				OutputTypes: []string{models.ImageTypeCommit, models.ImageTypeInstaller},
+				Commit:      &models.Commit{Status: models.ImageStatusSuccess, RepoID: &repo.ID},
+				Installer:   &models.Installer{Status: models.ImageStatusCreated}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			mockImageBuilderClient.EXPECT().ComposeInstaller(gomock.Any()).DoAndReturn(func(i *models.Image) (*models.Image, error) {
+				i.Installer.ComposeJobID = faker.UUIDHyphenated()
+				return i, nil
+			})
+
+			Expect(service.BuildImage(image.ID, false)).To(Succeed())
+			var installer models.Installer
+			Expect(db.DB.First(&installer, image.Installer.ID).Error).ToNot(HaveOccurred())
+			Expect(installer.Status).To(Equal(models.ImageStatusBuilding))
+		})
+		It("should fail the build of an image whose commit compose failed", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.Name(), Status: models.ImageStatusBuilding,
+				OutputTypes: []string{models.ImageTypeCommit}, Commit: &models.Commit{Status: models.ImageStatusError}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.BuildImage(image.ID, false)).To(MatchError(new(services.ImageBuildFailed)))
+		})
+	})
+	Describe("delete image", func() {
+		var mockJobService *mock_services.MockJobServiceInterface
+		var account string
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockJobService = mock_services.NewMockJobServiceInterface(ctrl)
+			service.JobService = mockJobService
+			account = faker.UUIDHyphenated()
+		})
+		newImage := func(status string) *models.Image {
+			repo := &models.Repo{Status: models.RepoStatusSuccess, URL: faker.URL()}
+			Expect(db.DB.Create(repo).Error).ToNot(HaveOccurred())
+			image := &models.Image{Account: account, Name: faker.Name(), Status: status,
+				Commit:         &models.Commit{Account: account, Status: status, RepoID: &repo.ID, InstalledPackages: []models.InstalledPackage{{Name: faker.Name()}}},
+				Installer:      &models.Installer{Account: account, Status: status},
+				Packages:       []models.Package{{Name: faker.Name()}},
+				CustomPackages: []models.Package{{Name: faker.Name()}},
+			}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			return image
+		}
+		It("should delete the image with its commit, installer, repo and packages and enqueue the deletion of its artifacts", func() {
+			image := newImage(models.ImageStatusSuccess)
+			mockJobService.EXPECT().Enqueue(models.JobTypeImageArtifactsDelete, account, image.ID).Return(&models.Job{}, nil)
+
+			Expect(service.DeleteImage(image)).To(Succeed())
+			Expect(db.DB.First(&models.Image{}, image.ID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			Expect(db.DB.First(&models.Commit{}, image.CommitID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			Expect(db.DB.First(&models.Installer{}, *image.InstallerID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			Expect(db.DB.First(&models.Repo{}, *image.Commit.RepoID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			var count int64
+			Expect(db.DB.Table("images_packages").Where("image_id = ?", image.ID).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(BeZero())
+			Expect(db.DB.Table("images_custom_packages").Where("image_id = ?", image.ID).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(BeZero())
+			Expect(db.DB.Table("commit_installed_packages").Where("commit_id = ?", image.CommitID).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(BeZero())
+		})
+		It("should not delete an image run by a device", func() {
+			image := newImage(models.ImageStatusSuccess)
+			Expect(db.DB.Create(&models.Device{Account: account, ImageID: image.ID}).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageInUse)))
+			Expect(db.DB.First(&models.Image{}, image.ID).Error).ToNot(HaveOccurred())
+		})
+		It("should not delete an image with an update to it in progress", func() {
+			image := newImage(models.ImageStatusSuccess)
+			Expect(db.DB.Create(&models.UpdateTransaction{Account: account, CommitID: image.CommitID, Status: models.UpdateStatusBuilding}).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageHasUpdateInProgress)))
+			Expect(db.DB.First(&models.Image{}, image.ID).Error).ToNot(HaveOccurred())
+		})
+		It("should not delete an image with an update from it in progress", func() {
+			image := newImage(models.ImageStatusSuccess)
+			newImage := newImage(models.ImageStatusSuccess)
+			update := &models.UpdateTransaction{Account: account, CommitID: newImage.CommitID, Status: models.UpdateStatusPaused,
+				OldCommits: []models.Commit{*image.Commit}}
+			Expect(db.DB.Omit("OldCommits.*").Create(update).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageHasUpdateInProgress)))
+		})
+		It("should delete an image whose updates are done", func() {
+			image := newImage(models.ImageStatusSuccess)
+			Expect(db.DB.Create(&models.UpdateTransaction{Account: account, CommitID: image.CommitID, Status: models.UpdateStatusSuccess}).Error).ToNot(HaveOccurred())
+			mockJobService.EXPECT().Enqueue(models.JobTypeImageArtifactsDelete, account, image.ID).Return(&models.Job{}, nil)
+
+			Expect(service.DeleteImage(image)).To(Succeed())
+		})
+		It("should not delete an image whose cached update repo is still used", func() {
+			image := newImage(models.ImageStatusSuccess)
+			cache := &models.UpdateRepoCache{Account: account, CommitID: image.CommitID, ReferenceCount: 1,
+				Repo: &models.Repo{Status: models.RepoStatusSuccess, URL: faker.URL()}}
+			Expect(db.DB.Create(cache).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageHasUpdateInProgress)))
+		})
+		It("should not delete an image building", func() {
+			image := newImage(models.ImageStatusBuilding)
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageIsBuilding)))
+		})
+		It("should check the status of the image stored when deleting it", func() {
+			image := newImage(models.ImageStatusSuccess)
+			// the image was rebuilt after it was loaded
+			Expect(db.DB.Model(&models.Image{}).Where("id = ?", image.ID).Update("status", models.ImageStatusBuilding).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImage(image)).To(MatchError(new(services.ImageIsBuilding)))
+			Expect(db.DB.First(&models.Image{}, image.ID).Error).ToNot(HaveOccurred())
+		})
+	})
+	Describe("delete image artifacts", func() {
+		var cfgLocal bool
+		var tarFile, isoFile string
+		BeforeEach(func() {
+			cfg := config.Get()
+			cfgLocal = cfg.Local
+			cfg.Local = true
+			tarFile = filepath.Join("/tmp", faker.UUIDHyphenated()+".tar")
+			isoFile = filepath.Join("/tmp", faker.UUIDHyphenated()+".iso")
+			for _, file := range []string{tarFile, isoFile} {
+				Expect(os.WriteFile(file, []byte("artifact"), 0600)).To(Succeed())
+			}
+		})
+		AfterEach(func() {
+			config.Get().Local = cfgLocal
+			os.Remove(tarFile)
+			os.Remove(isoFile)
+		})
+		It("should delete the tarball and the ISO of a deleted image", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Status: models.ImageStatusSuccess,
+				Commit:    &models.Commit{ImageBuildTarURL: tarFile},
+				Installer: &models.Installer{ImageBuildISOURL: isoFile}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image.Commit).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image.Installer).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImageArtifacts(image.ID)).To(Succeed())
+			for _, file := range []string{tarFile, isoFile} {
+				_, err := os.Stat(file)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			}
+		})
+		It("should keep the ISO shared with another version of the image", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Status: models.ImageStatusSuccess,
+				Installer: &models.Installer{ImageBuildISOURL: isoFile}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Create(&models.Installer{ImageBuildISOURL: isoFile}).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image.Installer).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImageArtifacts(image.ID)).To(Succeed())
+			_, err := os.Stat(isoFile)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should delete the cached update repos to the image no update uses", func() {
+			account := faker.UUIDHyphenated()
+			image := &models.Image{Account: account, Status: models.ImageStatusSuccess, Commit: &models.Commit{Account: account}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			newCache := func(referenceCount int) (*models.UpdateRepoCache, string) {
+				cache := &models.UpdateRepoCache{Account: account, CommitID: image.CommitID, ReferenceCount: referenceCount,
+					Repo: &models.Repo{Status: models.RepoStatusSuccess}}
+				Expect(db.DB.Create(cache).Error).ToNot(HaveOccurred())
+				path := filepath.Join(config.Get().RepoTempPath, "upd/repos", fmt.Sprint(cache.RepoID))
+				Expect(os.MkdirAll(filepath.Join(path, "repo"), 0755)).To(Succeed())
+				Expect(db.DB.Model(cache.Repo).Update("url", filepath.Join(path, "repo")).Error).ToNot(HaveOccurred())
+				return cache, path
+			}
+			unused, unusedPath := newCache(0)
+			used, usedPath := newCache(1)
+			defer os.RemoveAll(usedPath)
+			Expect(db.DB.Delete(image.Commit).Error).ToNot(HaveOccurred())
+			Expect(db.DB.Delete(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImageArtifacts(image.ID)).To(Succeed())
+			Expect(db.DB.First(&models.UpdateRepoCache{}, unused.ID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			Expect(db.DB.First(&models.Repo{}, unused.RepoID).Error).To(MatchError(gorm.ErrRecordNotFound))
+			_, err := os.Stat(unusedPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			Expect(db.DB.First(&models.UpdateRepoCache{}, used.ID).Error).ToNot(HaveOccurred())
+			_, err = os.Stat(usedPath)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should not delete the artifacts of an image not deleted", func() {
+			image := &models.Image{Account: faker.UUIDHyphenated(), Status: models.ImageStatusSuccess,
+				Installer: &models.Installer{ImageBuildISOURL: isoFile}}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+
+			Expect(service.DeleteImageArtifacts(image.ID)).ToNot(Succeed())
+			_, err := os.Stat(isoFile)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+	Describe("compare images", func() {
+		var account string
+		var image, otherImage *models.Image
+		BeforeEach(func() {
+			account = faker.UUIDHyphenated()
+			repo := models.ThirdPartyRepo{Account: account, Name: faker.UUIDHyphenated(), URL: faker.URL()}
+			otherRepo := models.ThirdPartyRepo{Account: account, Name: faker.UUIDHyphenated(), URL: faker.URL()}
+			image = &models.Image{Account: account, Name: faker.UUIDHyphenated(), Distribution: "rhel-85",
+				OutputTypes: []string{models.ImageTypeCommit},
+				Commit: &models.Commit{Account: account, Arch: "x86_64", InstalledPackages: []models.InstalledPackage{
+					{Name: "bash", Arch: "x86_64", Version: "5.1.8", Release: "2.el8"},
+					{Name: "vim", Arch: "x86_64", Version: "8.2", Release: "1.el8"},
+					{Name: "curl", Arch: "x86_64", Version: "7.61.1", Release: "22.el8"},
+					{Name: "nano", Arch: "x86_64", Version: "2.9.8", Release: "1.el8"},
+				}},
+				Installer:              &models.Installer{Account: account, Username: "admin", SSHKey: "ssh-rsa key"},
+				Packages:               []models.Package{{Name: "vim"}, {Name: "nano"}},
+				ThirdPartyRepositories: []models.ThirdPartyRepo{repo},
+			}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			otherImageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			Expect(db.DB.Create(&otherImageSet).Error).ToNot(HaveOccurred())
+			otherImage = &models.Image{Account: account, Name: otherImageSet.Name, ImageSetID: &otherImageSet.ID, Distribution: "rhel-86",
+				OutputTypes: []string{models.ImageTypeCommit, models.ImageTypeInstaller},
+				Commit: &models.Commit{Account: account, Arch: "x86_64", InstalledPackages: []models.InstalledPackage{
+					{Name: "bash", Arch: "x86_64", Version: "5.1.8", Release: "3.el8"},
+					{Name: "vim", Arch: "x86_64", Version: "8.2", Release: "1.el8"},
+					{Name: "curl", Arch: "x86_64", Version: "7.61.1", Release: "14.el8"},
+					{Name: "git", Arch: "x86_64", Version: "2.31.1", Release: "2.el8"},
+				}},
+				Installer:              &models.Installer{Account: account, Username: "admin", SSHKey: "ssh-rsa other-key"},
+				Packages:               []models.Package{{Name: "vim"}, {Name: "git"}},
+				CustomPackages:         []models.Package{{Name: "custom"}},
+				ThirdPartyRepositories: []models.ThirdPartyRepo{otherRepo},
+			}
+			Expect(db.DB.Create(otherImage).Error).ToNot(HaveOccurred())
+		})
+		It("should return the difference to an image of another image set", func() {
+			comparison, err := service.CompareImages(image, otherImage.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comparison.ImageID).To(Equal(image.ID))
+			Expect(comparison.OtherImageID).To(Equal(otherImage.ID))
+
+			names := func(pkgs []models.InstalledPackage) []string {
+				pkgNames := []string{}
+				for _, pkg := range pkgs {
+					pkgNames = append(pkgNames, pkg.Name)
+				}
+				return pkgNames
+			}
+			Expect(names(comparison.InstalledPackages.Added)).To(Equal([]string{"git"}))
+			Expect(names(comparison.InstalledPackages.Removed)).To(Equal([]string{"nano"}))
+			Expect(names(comparison.InstalledPackages.Upgraded)).To(Equal([]string{"bash"}))
+			Expect(names(comparison.InstalledPackages.Downgraded)).To(Equal([]string{"curl"}))
+			Expect(comparison.Packages).To(Equal(models.NamesDiff{Added: []string{"git"}, Removed: []string{"nano"}}))
+			Expect(comparison.CustomPackages).To(Equal(models.NamesDiff{Added: []string{"custom"}, Removed: []string{}}))
+			Expect(comparison.OutputTypes).To(Equal(models.NamesDiff{Added: []string{models.ImageTypeInstaller}, Removed: []string{}}))
+			Expect(comparison.ThirdPartyRepositories.Added).To(HaveLen(1))
+			Expect(comparison.ThirdPartyRepositories.Added[0].URL).To(Equal(otherImage.ThirdPartyRepositories[0].URL))
+			Expect(comparison.ThirdPartyRepositories.Removed).To(HaveLen(1))
+			Expect(comparison.Distribution).To(Equal(&models.ValueChange{Old: "rhel-85", New: "rhel-86"}))
+			Expect(comparison.Arch).To(BeNil())
+			Expect(comparison.InstallerUsername).To(BeNil())
+			Expect(comparison.InstallerSSHKey).To(Equal(&models.ValueChange{Old: "ssh-rsa key", New: "ssh-rsa other-key"}))
+		})
+		It("should compare the epochs of the installed packages", func() {
+			otherImage.Commit.InstalledPackages[2].Epoch = "1"
+			Expect(db.DB.Save(&otherImage.Commit.InstalledPackages[2]).Error).ToNot(HaveOccurred())
+
+			comparison, err := service.CompareImages(image, otherImage.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comparison.InstalledPackages.Upgraded).To(HaveLen(2))
+			Expect(comparison.InstalledPackages.Downgraded).To(BeEmpty())
+		})
+		It("should not compare with an image of another account", func() {
+			foreignImage := &models.Image{Account: faker.UUIDHyphenated(), Name: faker.UUIDHyphenated()}
+			Expect(db.DB.Create(foreignImage).Error).ToNot(HaveOccurred())
+
+			_, err := service.CompareImages(image, foreignImage.ID)
+			Expect(err).To(MatchError(new(services.ImageNotFoundError)))
+		})
+	})
 })
@@ -2,17 +2,29 @@ package services
 
 import (
 	"context"
+	"time"
 
+	"github.com/redhatinsights/edge-api/config"
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/errors"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // ImageSetsServiceInterface defines the interface that helps handle
 // the business logic of ImageSets
 type ImageSetsServiceInterface interface {
 	GetImageSetsByID(imageSetID int) (*models.ImageSet, error)
+	GetImageSetUpdateHooks(account string, imageSetID uint) (*models.UpdateHooks, error)
+	SetImageSetUpdateHooks(account string, imageSetID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error)
+	DeleteImageSet(account string, imageSetID uint) error
+	GetRetentionPolicy(account string, imageSetID *uint) (*models.RetentionPolicy, error)
+	SetRetentionPolicy(account string, imageSetID *uint, keepVersions int) (*models.RetentionPolicy, error)
+	DeleteRetentionPolicy(account string, imageSetID *uint) error
+	GetRetentionReports(account string, limit int, offset int) ([]models.RetentionReport, error)
+	ApplyRetentionPolicy(policyID uint) ([]models.RetentionReport, error)
+	EnqueueDueRetentionPolicies() (int, error)
 }
 
 // NewImageSetsService gives a instance of the main implementation of a ImageSetsServiceInterface
@@ -44,3 +56,192 @@ func (s *ImageSetsService) GetImageSetsByID(imageSetID int) (*models.ImageSet, e
 	}
 	return &imageSet, nil
 }
+
+// GetImageSetUpdateHooks returns the update hooks of an image set
+func (s *ImageSetsService) GetImageSetUpdateHooks(account string, imageSetID uint) (*models.UpdateHooks, error) {
+	var imageSet models.ImageSet
+	if result := db.DB.Where("account = ?", account).First(&imageSet, imageSetID); result.Error != nil {
+		return nil, new(ImageSetNotFound)
+	}
+	return getUpdateHooks("account = ? AND image_set_id = ?", account, imageSet.ID)
+}
+
+// SetImageSetUpdateHooks replaces the update hooks of an image set
+func (s *ImageSetsService) SetImageSetUpdateHooks(account string, imageSetID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error) {
+	var imageSet models.ImageSet
+	if result := db.DB.Where("account = ?", account).First(&imageSet, imageSetID); result.Error != nil {
+		return nil, new(ImageSetNotFound)
+	}
+	s.log.WithField("imageSetID", imageSet.ID).Debug("Replacing the update hooks of the image set")
+	return replaceUpdateHooks(models.UpdateHook{Account: account, ImageSetID: &imageSet.ID}, hooks)
+}
+
+// DeleteImageSet deletes an image set with its update hooks, its retention policy and all its images, the artifacts
+// of the images are deleted from the storage by jobs
+// An image set is not deleted while one of its images can't be deleted
+func (s *ImageSetsService) DeleteImageSet(account string, imageSetID uint) error {
+	var imageSet models.ImageSet
+	if result := db.DB.Where("account = ?", account).First(&imageSet, imageSetID); result.Error != nil {
+		return new(ImageSetNotFound)
+	}
+	sLog := s.log.WithField("imageSetID", imageSet.ID)
+	var images []models.Image
+	if result := db.DB.Where("account = ? AND image_set_id = ?", account, imageSet.ID).Find(&images); result.Error != nil {
+		sLog.WithField("error", result.Error.Error()).Error("Error getting image set's images")
+		return result.Error
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkImagesCanBeDeleted(tx, images); err != nil {
+			return err
+		}
+		if err := deleteImages(tx, images); err != nil {
+			return err
+		}
+		if result := tx.Where("image_set_id = ?", imageSet.ID).Delete(&models.UpdateHook{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("image_set_id = ?", imageSet.ID).Delete(&models.RetentionPolicy{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&imageSet).Error
+	})
+	switch err.(type) {
+	case nil:
+	case *ImageInUse, *ImageHasUpdateInProgress, *ImageIsBuilding:
+		sLog.WithField("error", err.Error()).Info("Image set cannot be deleted")
+		return err
+	default:
+		sLog.WithField("error", err.Error()).Error("Error deleting image set")
+		return err
+	}
+	sLog.WithField("imagesCount", len(images)).Info("Image set deleted")
+	enqueueImageArtifactsDelete(NewJobService(s.ctx, s.log), sLog, images)
+	return nil
+}
+
+// checkRetentionPolicyImageSet returns an error when the image set of a retention policy is not an image set of the account
+func checkRetentionPolicyImageSet(account string, imageSetID *uint) error {
+	if imageSetID == nil {
+		return nil
+	}
+	var imageSet models.ImageSet
+	if result := db.DB.Where("account = ?", account).First(&imageSet, *imageSetID); result.Error != nil {
+		return new(ImageSetNotFound)
+	}
+	return nil
+}
+
+// GetRetentionPolicy returns the retention policy of the account, or of the image set when imageSetID is set
+func (s *ImageSetsService) GetRetentionPolicy(account string, imageSetID *uint) (*models.RetentionPolicy, error) {
+	if err := checkRetentionPolicyImageSet(account, imageSetID); err != nil {
+		return nil, err
+	}
+	return getRetentionPolicy(account, imageSetID)
+}
+
+// SetRetentionPolicy sets the number of successful versions the retention policy of the account,
+// or of the image set when imageSetID is set, keeps; the policy is applied again at the next check
+func (s *ImageSetsService) SetRetentionPolicy(account string, imageSetID *uint, keepVersions int) (*models.RetentionPolicy, error) {
+	if err := checkRetentionPolicyImageSet(account, imageSetID); err != nil {
+		return nil, err
+	}
+	policy, err := getRetentionPolicy(account, imageSetID)
+	if err != nil {
+		if _, ok := err.(*RetentionPolicyNotFound); !ok {
+			return nil, err
+		}
+		policy = &models.RetentionPolicy{Account: account, ImageSetID: imageSetID}
+	}
+	policy.KeepVersions = keepVersions
+	policy.AppliedAt = models.EdgeAPITime{}
+	if result := db.DB.Save(policy); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error saving retention policy")
+		return nil, result.Error
+	}
+	s.log.WithFields(log.Fields{"retentionPolicyID": policy.ID, "keepVersions": keepVersions}).Info("Retention policy set")
+	return policy, nil
+}
+
+// DeleteRetentionPolicy deletes the retention policy of the account, or of the image set when imageSetID is set
+func (s *ImageSetsService) DeleteRetentionPolicy(account string, imageSetID *uint) error {
+	policy, err := s.GetRetentionPolicy(account, imageSetID)
+	if err != nil {
+		return err
+	}
+	if result := db.DB.Delete(policy); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error deleting retention policy")
+		return result.Error
+	}
+	return nil
+}
+
+// GetRetentionReports returns the reports of the versions removed by the retention policies of the account, the most recent first
+func (s *ImageSetsService) GetRetentionReports(account string, limit int, offset int) ([]models.RetentionReport, error) {
+	reports := []models.RetentionReport{}
+	if result := db.DB.Preload("RemovedImages").Where("account = ?", account).Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).Find(&reports); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error getting retention reports")
+		return nil, result.Error
+	}
+	return reports, nil
+}
+
+// ApplyRetentionPolicy removes the old versions of the image sets of a retention policy with their artifacts
+// and returns the reports of the versions removed, a retention policy deleted since is not applied
+func (s *ImageSetsService) ApplyRetentionPolicy(policyID uint) ([]models.RetentionReport, error) {
+	reports := []models.RetentionReport{}
+	var policy models.RetentionPolicy
+	if result := db.DB.Limit(1).Find(&policy, policyID); result.Error != nil {
+		return nil, result.Error
+	}
+	if policy.ID == 0 || policy.KeepVersions < 1 {
+		s.log.WithField("retentionPolicyID", policyID).Info("Retention policy was deleted, it is not applied")
+		return reports, nil
+	}
+	imageSetIDs, err := retentionPolicyImageSets(&policy)
+	if err != nil {
+		return nil, err
+	}
+	jobService := NewJobService(s.ctx, s.log)
+	for _, imageSetID := range imageSetIDs {
+		report, err := applyRetentionPolicyToImageSet(&policy, imageSetID, jobService, s.log)
+		if err != nil {
+			s.log.WithFields(log.Fields{"error": err.Error(), "imageSetID": imageSetID}).Error("Error applying retention policy")
+			return reports, err
+		}
+		if report != nil {
+			reports = append(reports, *report)
+		}
+	}
+	return reports, nil
+}
+
+// EnqueueDueRetentionPolicies enqueues the application of the retention policies not applied since the retention
+// policy interval and returns the number enqueued
+// A policy is marked as applied when enqueued, so the policies due are enqueued once even with several replicas
+func (s *ImageSetsService) EnqueueDueRetentionPolicies() (int, error) {
+	now := time.Now().UTC()
+	dueBefore := models.EdgeAPITime{Time: now.Add(-time.Duration(config.Get().RetentionPolicyInterval) * time.Second), Valid: true}
+	var policies []models.RetentionPolicy
+	if result := db.DB.Where("applied_at IS NULL OR applied_at <= ?", dueBefore).Order("id").Find(&policies); result.Error != nil {
+		return 0, result.Error
+	}
+	jobService := NewJobService(s.ctx, s.log)
+	enqueued := 0
+	for _, policy := range policies {
+		result := db.DB.Model(&models.RetentionPolicy{}).
+			Where("id = ? AND (applied_at IS NULL OR applied_at <= ?)", policy.ID, dueBefore).
+			UpdateColumn("applied_at", models.EdgeAPITime{Time: now, Valid: true})
+		if result.Error != nil {
+			return enqueued, result.Error
+		}
+		if result.RowsAffected != 1 {
+			continue
+		}
+		if _, err := jobService.Enqueue(models.JobTypeRetentionPolicyApply, policy.Account, policy.ID); err != nil {
+			return enqueued, err
+		}
+		enqueued++
+	}
+	return enqueued, nil
+}
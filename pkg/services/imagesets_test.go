@@ -4,6 +4,9 @@ import (
 	"context"
 	"testing"
 
+	"github.com/bxcodec/faker/v3"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -17,3 +20,40 @@ func TestGetImageSetByID(t *testing.T) {
 	}
 
 }
+
+func TestDeleteImageSet(t *testing.T) {
+	imageSetService := ImageSetsService{
+		Service{ctx: context.Background(), log: log.NewEntry(log.StandardLogger())},
+	}
+	account := faker.UUIDHyphenated()
+	imageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+	db.DB.Create(&imageSet)
+	images := []models.Image{
+		{Account: account, ImageSetID: &imageSet.ID, Status: models.ImageStatusSuccess, Version: 1, Commit: &models.Commit{Account: account}},
+		{Account: account, ImageSetID: &imageSet.ID, Status: models.ImageStatusError, Version: 2, Commit: &models.Commit{Account: account}},
+	}
+	db.DB.Create(&images)
+	device := models.Device{Account: account, ImageID: images[0].ID}
+	db.DB.Create(&device)
+
+	if err := imageSetService.DeleteImageSet(account, imageSet.ID); err == nil {
+		t.Errorf("Expected image set run by a device not to be deleted")
+	}
+	db.DB.Delete(&device)
+	if err := imageSetService.DeleteImageSet(faker.UUIDHyphenated(), imageSet.ID); err == nil {
+		t.Errorf("Expected image set of another account not to be deleted")
+	}
+	if err := imageSetService.DeleteImageSet(account, imageSet.ID); err != nil {
+		t.Errorf("Expected image set to be deleted, got %#v", err)
+	}
+	var count int64
+	db.DB.Model(&models.Image{}).Where("image_set_id = ?", imageSet.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected images of the image set to be deleted, got %d", count)
+	}
+	db.DB.Model(&models.Job{}).Where("type = ? AND resource_id IN ?", models.JobTypeImageArtifactsDelete,
+		[]uint{images[0].ID, images[1].ID}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected the deletion of the artifacts of 2 images to be enqueued, got %d", count)
+	}
+}
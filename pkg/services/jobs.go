@@ -0,0 +1,522 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/platform-go-middlewares/identity"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// jobPollInterval is how long an idle worker waits before looking for jobs to claim again
+const jobPollInterval = 5 * time.Second
+
+// jobQueueDelay is how long a job queued by a concurrency limit waits before being run again
+const jobQueueDelay = 30 * time.Second
+
+// JobServiceInterface defines the interface to enqueue the long-running jobs and follow their state
+type JobServiceInterface interface {
+	Enqueue(jobType string, account string, resourceID uint) (*models.Job, error)
+	GetJobs(account string, limit int, offset int, tx *gorm.DB) (*[]models.Job, error)
+	GetJobsCount(account string, tx *gorm.DB) (int64, error)
+	GetJobByID(account string, id uint) (*models.Job, error)
+}
+
+// NewJobService gives a instance of the main implementation of a JobServiceInterface
+func NewJobService(ctx context.Context, log *log.Entry) JobServiceInterface {
+	return &JobService{Service: Service{ctx: ctx, log: log.WithField("service", "job")}}
+}
+
+// JobService is the main implementation of a JobServiceInterface
+type JobService struct {
+	Service
+}
+
+// Enqueue creates a pending job run by the first worker claiming it
+// The identity of the request is kept with the job for the calls to the other services
+func (s *JobService) Enqueue(jobType string, account string, resourceID uint) (*models.Job, error) {
+	job := &models.Job{
+		Account:     account,
+		Type:        jobType,
+		ResourceID:  resourceID,
+		Status:      models.JobStatusPending,
+		MaxAttempts: config.Get().JobMaxAttempts,
+		RunAt:       models.EdgeAPITime{Time: time.Now().UTC(), Valid: true},
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = 1
+	}
+	if xrhid, err := common.GetOriginalIdentity(s.ctx); err == nil {
+		job.Identity = xrhid
+	}
+	if result := db.DB.Create(job); result.Error != nil {
+		return nil, result.Error
+	}
+	s.log.WithFields(log.Fields{"jobID": job.ID, "jobType": jobType, "resourceID": resourceID}).Info("Job enqueued")
+	return job, nil
+}
+
+// GetJobs returns the jobs of the account, the most recent first
+func (s *JobService) GetJobs(account string, limit int, offset int, tx *gorm.DB) (*[]models.Job, error) {
+	if tx == nil {
+		tx = db.DB
+	}
+	var jobs []models.Job
+	if result := tx.Where("account = ?", account).Limit(limit).Offset(offset).Find(&jobs); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error getting jobs")
+		return nil, result.Error
+	}
+	return &jobs, nil
+}
+
+// GetJobsCount returns the number of jobs of the account
+func (s *JobService) GetJobsCount(account string, tx *gorm.DB) (int64, error) {
+	if tx == nil {
+		tx = db.DB
+	}
+	var count int64
+	if result := tx.Model(&models.Job{}).Where("account = ?", account).Count(&count); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error getting jobs count")
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// GetJobByID returns a job of the account
+func (s *JobService) GetJobByID(account string, id uint) (*models.Job, error) {
+	var job models.Job
+	if result := db.DB.Where("account = ?", account).First(&job, id); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, new(JobNotFound)
+		}
+		return nil, result.Error
+	}
+	return &job, nil
+}
+
+// JobHandler runs the jobs of a type
+type JobHandler struct {
+	// Run runs a job, the job is retried with a backoff when it returns an error
+	// The attempts after the first one resume the work of a worker that failed or stopped
+	// The job is run again later, without using an attempt, when it returns a JobQueued error
+	Run func(ctx context.Context, log *log.Entry, job *models.Job) error
+	// Fail, when set, is called once the job failed its last attempt to set the error status of its resource
+	Fail func(ctx context.Context, log *log.Entry, job *models.Job, err error)
+}
+
+// JobWorker claims and runs the jobs stored in the database
+// The workers of all the replicas share the jobs: a job is claimed by taking its lease
+// with a conditional update, so only one worker runs it, and the lease is extended
+// by heartbeats while the job runs. A job whose lease expired, because its replica
+// stopped, is claimed again by another worker.
+type JobWorker struct {
+	// ID identifies the worker holding the lease of a job
+	ID string
+	// Concurrency is the number of jobs the worker runs at the same time
+	Concurrency int
+	// LeaseTimeout is how long a job is leased without a heartbeat
+	LeaseTimeout time.Duration
+	// RetryBackoff is the delay before the first retry of a job, doubled for every following retry
+	RetryBackoff time.Duration
+	// PollInterval is how long an idle worker waits before looking for jobs again
+	PollInterval time.Duration
+	// QueueDelay is how long a job queued by a concurrency limit waits before being run again, PollInterval when not set
+	QueueDelay time.Duration
+
+	// Log is the logger of the worker, the standard logger when not set
+	Log *log.Entry
+
+	handlers map[string]JobHandler
+}
+
+// NewJobWorker gives a worker of the Edge API jobs configured from the config
+func NewJobWorker(log *log.Entry) *JobWorker {
+	cfg := config.Get()
+	hostname, _ := os.Hostname()
+	w := &JobWorker{
+		ID:           fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		Concurrency:  cfg.JobWorkers,
+		LeaseTimeout: time.Duration(cfg.JobLeaseTimeout) * time.Second,
+		RetryBackoff: time.Duration(cfg.JobRetryBackoff) * time.Second,
+		PollInterval: jobPollInterval,
+		QueueDelay:   jobQueueDelay,
+		handlers:     map[string]JobHandler{},
+	}
+	w.Log = log.WithFields(map[string]interface{}{"service": "job-worker", "workerID": w.ID})
+	w.Handle(models.JobTypeImageBuild, JobHandler{Run: runImageBuildJob, Fail: failImageBuildJob})
+	w.Handle(models.JobTypeUpdateBuild, JobHandler{Run: runUpdateBuildJob, Fail: failUpdateBuildJob})
+	w.Handle(models.JobTypeUpdateBundleBuild, JobHandler{Run: runUpdateBundleBuildJob, Fail: failUpdateBundleBuildJob})
+	w.Handle(models.JobTypeUpdateRepoPrebuild, JobHandler{Run: runUpdateRepoPrebuildJob})
+	w.Handle(models.JobTypeImageArtifactsDelete, JobHandler{Run: runImageArtifactsDeleteJob})
+	w.Handle(models.JobTypeRetentionPolicyApply, JobHandler{Run: runRetentionPolicyApplyJob})
+	return w
+}
+
+// Handle sets the handler of the jobs of a type, the worker only claims the jobs it has a handler for
+func (w *JobWorker) Handle(jobType string, handler JobHandler) {
+	if w.handlers == nil {
+		w.handlers = map[string]JobHandler{}
+	}
+	w.handlers[jobType] = handler
+}
+
+func (w *JobWorker) logger() *log.Entry {
+	if w.Log == nil {
+		return log.NewEntry(log.StandardLogger()).WithField("workerID", w.ID)
+	}
+	return w.Log
+}
+
+// Start runs the jobs until ctx is done, then waits for the running jobs to return
+func (w *JobWorker) Start(ctx context.Context) {
+	concurrency := w.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	w.logger().WithField("concurrency", concurrency).Info("Starting job worker")
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := w.ClaimJob()
+				if err != nil {
+					w.logger().WithField("error", err.Error()).Error("Error claiming job")
+				}
+				if job != nil {
+					w.RunJob(job)
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(w.PollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	w.logger().Info("Job worker stopped")
+}
+
+// ClaimJob takes the lease of the next job due, pending or left by a worker whose lease expired
+// It returns nil when there is no job to run
+func (w *JobWorker) ClaimJob() (*models.Job, error) {
+	if len(w.handlers) == 0 {
+		return nil, nil
+	}
+	jobTypes := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+	now := time.Now().UTC()
+	var jobs []models.Job
+	if result := db.DB.Where("type IN ?", jobTypes).
+		Where("(status = ? AND run_at <= ?) OR (status = ? AND lease_expires_at < ?)",
+			models.JobStatusPending, now, models.JobStatusRunning, now).
+		Order("run_at").Limit(10).Find(&jobs); result.Error != nil {
+		return nil, result.Error
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		// the job is updated only if no other worker updated it since it was read
+		tx := db.DB.Model(&models.Job{}).Where("id = ? AND status = ? AND attempts = ? AND lease_owner = ?",
+			job.ID, job.Status, job.Attempts, job.LeaseOwner)
+		if job.Status == models.JobStatusRunning {
+			tx = tx.Where("lease_expires_at < ?", now)
+			if job.Attempts >= job.MaxAttempts {
+				lastError := fmt.Sprintf("the lease of the worker %s expired on the last attempt", job.LeaseOwner)
+				result := tx.Updates(map[string]interface{}{"status": models.JobStatusFailed, "last_error": lastError})
+				if result.Error != nil {
+					return nil, result.Error
+				}
+				if result.RowsAffected == 1 {
+					job.Status = models.JobStatusFailed
+					job.LastError = lastError
+					w.logger().WithField("jobID", job.ID).Error("Job failed, its lease expired on the last attempt")
+					w.fail(job, errors.New(lastError))
+				}
+				continue
+			}
+		}
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LeaseOwner = w.ID
+		job.LeaseExpiresAt = models.EdgeAPITime{Time: now.Add(w.LeaseTimeout), Valid: true}
+		job.HeartbeatAt = models.EdgeAPITime{Time: now, Valid: true}
+		result := tx.Updates(map[string]interface{}{
+			"status":           job.Status,
+			"attempts":         job.Attempts,
+			"lease_owner":      job.LeaseOwner,
+			"lease_expires_at": job.LeaseExpiresAt,
+			"heartbeat_at":     job.HeartbeatAt,
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			w.logger().WithFields(log.Fields{"jobID": job.ID, "jobType": job.Type, "attempt": job.Attempts}).Info("Job claimed")
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// RunJob runs a claimed job while extending its lease and then saves how it ended:
+// done, failed or pending for a retry with a backoff
+func (w *JobWorker) RunJob(job *models.Job) {
+	logger := w.logger().WithFields(log.Fields{"jobID": job.ID, "jobType": job.Type, "resourceID": job.ResourceID, "attempt": job.Attempts})
+	done := make(chan struct{})
+	go w.heartbeat(job, done)
+	err := w.run(logger, job)
+	close(done)
+
+	owned := db.DB.Model(&models.Job{}).Where("id = ? AND status = ? AND attempts = ? AND lease_owner = ?",
+		job.ID, models.JobStatusRunning, job.Attempts, w.ID)
+	values := map[string]interface{}{"lease_expires_at": nil}
+	switch {
+	case err == nil:
+		job.Status = models.JobStatusSuccess
+		job.LastError = ""
+		logger.Info("Job done")
+	case isJobQueued(err):
+		job.Status = models.JobStatusPending
+		job.Attempts--
+		values["attempts"] = job.Attempts
+		job.RunAt = models.EdgeAPITime{Time: time.Now().UTC().Add(w.queueDelay()), Valid: true}
+		values["run_at"] = job.RunAt
+		logger.WithField("runAt", job.RunAt.Time).Info("Job is queued until a concurrency slot is available")
+	case job.Attempts >= job.MaxAttempts:
+		job.Status = models.JobStatusFailed
+		job.LastError = err.Error()
+		logger.WithField("error", err.Error()).Error("Job failed its last attempt")
+	default:
+		job.Status = models.JobStatusPending
+		job.LastError = err.Error()
+		job.RunAt = models.EdgeAPITime{Time: time.Now().UTC().Add(w.backoff(job.Attempts)), Valid: true}
+		values["run_at"] = job.RunAt
+		logger.WithFields(log.Fields{"error": err.Error(), "runAt": job.RunAt.Time}).Warning("Job failed, it will be retried")
+	}
+	values["status"] = job.Status
+	values["last_error"] = job.LastError
+	job.LeaseExpiresAt = models.EdgeAPITime{}
+	result := owned.Updates(values)
+	if result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error saving job status")
+		return
+	}
+	if result.RowsAffected == 0 {
+		logger.Warning("Job lease was lost while it was running")
+		return
+	}
+	if job.Status == models.JobStatusFailed {
+		w.fail(job, err)
+	}
+}
+
+// run runs the handler of the job, a panic of the handler fails the attempt
+func (w *JobWorker) run(logger *log.Entry, job *models.Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler for the jobs of type %s", job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithField("error", r).Error("Job panicked")
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler.Run(jobContext(job), logger, job)
+}
+
+// fail calls the failure handler of a job that failed its last attempt
+func (w *JobWorker) fail(job *models.Job, err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok || handler.Fail == nil {
+		return
+	}
+	logger := w.logger().WithFields(log.Fields{"jobID": job.ID, "jobType": job.Type, "resourceID": job.ResourceID})
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithField("error", r).Error("Job failure handler panicked")
+		}
+	}()
+	handler.Fail(jobContext(job), logger, job, err)
+}
+
+// heartbeat extends the lease of a running job until done is closed
+func (w *JobWorker) heartbeat(job *models.Job, done chan struct{}) {
+	interval := w.LeaseTimeout / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			result := db.DB.Model(&models.Job{}).Where("id = ? AND status = ? AND lease_owner = ?", job.ID, models.JobStatusRunning, w.ID).
+				Updates(map[string]interface{}{
+					"heartbeat_at":     models.EdgeAPITime{Time: now, Valid: true},
+					"lease_expires_at": models.EdgeAPITime{Time: now.Add(w.LeaseTimeout), Valid: true},
+				})
+			if result.Error != nil {
+				w.logger().WithFields(log.Fields{"jobID": job.ID, "error": result.Error.Error()}).Error("Error extending job lease")
+			} else if result.RowsAffected == 0 {
+				w.logger().WithField("jobID", job.ID).Warning("Job lease was lost")
+				return
+			}
+		}
+	}
+}
+
+// queueDelay returns the delay before a queued job is run again
+func (w *JobWorker) queueDelay() time.Duration {
+	if w.QueueDelay <= 0 {
+		return w.PollInterval
+	}
+	return w.QueueDelay
+}
+
+// isJobQueued tells if the job returned it is queued by a concurrency limit
+func isJobQueued(err error) bool {
+	_, ok := err.(*JobQueued)
+	return ok
+}
+
+// backoff returns the delay before the next attempt of a job that failed the given attempt
+func (w *JobWorker) backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 10 {
+		attempt = 10
+	}
+	return w.RetryBackoff * time.Duration(1<<uint(attempt-1))
+}
+
+// jobContext returns the context a job runs with: the identity of the request that enqueued it
+func jobContext(job *models.Job) context.Context {
+	ctx := context.Background()
+	if job.Identity == "" {
+		return ctx
+	}
+	ctx = common.SetOriginalIdentity(ctx, job.Identity)
+	idRaw, err := base64.StdEncoding.DecodeString(job.Identity)
+	if err != nil {
+		return ctx
+	}
+	var xrhid identity.XRHID
+	if err := json.Unmarshal(idRaw, &xrhid); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, identity.Key, xrhid)
+}
+
+// runImageBuildJob runs the next step of the build of an image: the commit, its repo, the installer
+func runImageBuildJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	s := NewImageService(ctx, log).(*ImageService)
+	return s.BuildImage(job.ResourceID, job.Attempts > 1)
+}
+
+// failImageBuildJob sets the error status on an image whose build job failed
+func failImageBuildJob(ctx context.Context, log *log.Entry, job *models.Job, err error) {
+	var image models.Image
+	if result := db.DB.Joins("Commit").Joins("Installer").First(&image, job.ResourceID); result.Error != nil {
+		log.WithField("error", result.Error.Error()).Error("Error getting image of failed job")
+		return
+	}
+	s := NewImageService(ctx, log)
+	s.SetErrorStatusOnImage(err, &image)
+}
+
+// runUpdateBuildJob builds the update repo of an update transaction and dispatches it to the devices
+// The update is queued while the account or all the accounts have too many update builds or rollouts running
+func runUpdateBuildJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	acquired, err := acquireUpdateBuildSlots(job)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		result := eventsDB(ctx).Model(&models.UpdateTransaction{}).Where("id = ? AND status = ?", job.ResourceID, models.UpdateStatusCreated).
+			Update("status", models.UpdateStatusQueued)
+		if result.Error != nil {
+			return result.Error
+		}
+		log.WithField("updateID", job.ResourceID).Info("Update is queued, its account reached the limit of update builds or rollouts")
+		return new(JobQueued)
+	}
+	defer func() {
+		if err := ReleaseConcurrencySlots(models.ConcurrencySlotHolderJob, job.ID); err != nil {
+			log.WithField("error", err.Error()).Error("Error releasing update build slots")
+		}
+	}()
+	_, err = NewUpdateService(ctx, log).CreateUpdate(job.ResourceID)
+	if _, ok := err.(*UpdateCancelled); ok {
+		return nil
+	}
+	return err
+}
+
+// failUpdateBuildJob sets the error status on an update transaction whose build job failed
+func failUpdateBuildJob(ctx context.Context, log *log.Entry, job *models.Job, err error) {
+	var update models.UpdateTransaction
+	if result := db.DB.First(&update, job.ResourceID); result.Error != nil {
+		log.WithField("error", result.Error.Error()).Error("Error getting update of failed job")
+		return
+	}
+	if update.Status != models.UpdateStatusBuilding && update.Status != models.UpdateStatusQueued {
+		return
+	}
+	result := eventsDB(models.ContextWithEventMessage(ctx, err.Error())).Model(&update).Update("status", models.UpdateStatusError)
+	if result.Error != nil {
+		log.WithField("error", result.Error.Error()).Error("Error setting error status on update of failed job")
+	}
+}
+
+// runUpdateBundleBuildJob builds an offline update bundle
+func runUpdateBundleBuildJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	_, err := NewUpdateService(ctx, log).BuildUpdateBundle(job.ResourceID)
+	return err
+}
+
+// failUpdateBundleBuildJob sets the error status on an update bundle whose build job failed
+func failUpdateBundleBuildJob(ctx context.Context, log *log.Entry, job *models.Job, err error) {
+	result := db.DB.Model(&models.UpdateBundle{}).Where("id = ? AND status = ?", job.ResourceID, models.UpdateBundleStatusBuilding).
+		Update("status", models.UpdateBundleStatusError)
+	if result.Error != nil {
+		log.WithField("error", result.Error.Error()).Error("Error setting error status on update bundle of failed job")
+	}
+}
+
+// runUpdateRepoPrebuildJob builds the update repo of an image with the static deltas from the previous versions
+// of its image set, a failure only costs the updates to the image the time to build their repo
+func runUpdateRepoPrebuildJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	_, err := NewRepoBuilder(ctx, log).PrebuildImageUpdateRepo(job.ResourceID)
+	return err
+}
+
+// runImageArtifactsDeleteJob deletes the artifacts of a deleted image from the storage
+func runImageArtifactsDeleteJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	return NewImageService(ctx, log).DeleteImageArtifacts(job.ResourceID)
+}
+
+// runRetentionPolicyApplyJob removes the old image versions of the image sets of a retention policy
+func runRetentionPolicyApplyJob(ctx context.Context, log *log.Entry, job *models.Job) error {
+	_, err := NewImageSetsService(ctx, log).ApplyRetentionPolicy(job.ResourceID)
+	return err
+}
@@ -0,0 +1,210 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Job queue", func() {
+	var account, jobType string
+	var jobService services.JobServiceInterface
+	var newWorker func(id string, handler services.JobHandler) *services.JobWorker
+	BeforeEach(func() {
+		account = faker.UUIDHyphenated()
+		// a job type per test so the workers only claim the jobs of their test
+		jobType = faker.UUIDHyphenated()
+		jobService = services.NewJobService(context.Background(), log.NewEntry(log.StandardLogger()))
+		newWorker = func(id string, handler services.JobHandler) *services.JobWorker {
+			worker := &services.JobWorker{ID: id, LeaseTimeout: time.Minute, RetryBackoff: time.Minute, PollInterval: time.Millisecond}
+			worker.Handle(jobType, handler)
+			return worker
+		}
+	})
+	getJob := func(id uint) models.Job {
+		var job models.Job
+		Expect(db.DB.First(&job, id).Error).ToNot(HaveOccurred())
+		return job
+	}
+	expireLease := func(id uint) {
+		Expect(db.DB.Model(&models.Job{}).Where("id = ?", id).
+			Update("lease_expires_at", models.EdgeAPITime{Time: time.Now().UTC().Add(-time.Minute), Valid: true}).Error).ToNot(HaveOccurred())
+	}
+
+	Context("enqueue", func() {
+		It("should create a pending job due now", func() {
+			job, err := jobService.Enqueue(jobType, account, 7)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(job.Status).To(Equal(models.JobStatusPending))
+			Expect(job.Attempts).To(Equal(0))
+			Expect(job.MaxAttempts).To(BeNumerically(">=", 1))
+
+			saved, err := jobService.GetJobByID(account, job.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(saved.ResourceID).To(Equal(uint(7)))
+			Expect(saved.Type).To(Equal(jobType))
+		})
+		It("should not return the job of another account", func() {
+			job, err := jobService.Enqueue(jobType, account, 7)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = jobService.GetJobByID(faker.UUIDHyphenated(), job.ID)
+			Expect(err).To(MatchError(new(services.JobNotFound)))
+		})
+	})
+	Context("claim", func() {
+		It("should let a single worker claim a job", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			first := newWorker("first", services.JobHandler{})
+			second := newWorker("second", services.JobHandler{})
+
+			claimed, err := first.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claimed).ToNot(BeNil())
+			Expect(claimed.ID).To(Equal(job.ID))
+			Expect(claimed.Attempts).To(Equal(1))
+			Expect(claimed.LeaseOwner).To(Equal("first"))
+
+			claimed, err = second.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claimed).To(BeNil())
+		})
+		It("should resume the job of a worker whose lease expired", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			first := newWorker("first", services.JobHandler{})
+			_, err = first.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			expireLease(job.ID)
+
+			var resumed bool
+			second := newWorker("second", services.JobHandler{Run: func(ctx context.Context, log *log.Entry, job *models.Job) error {
+				resumed = job.Attempts > 1
+				return nil
+			}})
+			claimed, err := second.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claimed).ToNot(BeNil())
+			Expect(claimed.LeaseOwner).To(Equal("second"))
+			second.RunJob(claimed)
+			Expect(resumed).To(BeTrue())
+			Expect(getJob(job.ID).Status).To(Equal(models.JobStatusSuccess))
+		})
+		It("should fail the job whose lease expired on its last attempt", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(db.DB.Model(job).Update("max_attempts", 1).Error).ToNot(HaveOccurred())
+			var failed bool
+			handler := services.JobHandler{Fail: func(ctx context.Context, log *log.Entry, job *models.Job, err error) {
+				failed = true
+			}}
+			_, err = newWorker("first", handler).ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			expireLease(job.ID)
+
+			claimed, err := newWorker("second", handler).ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claimed).To(BeNil())
+			Expect(failed).To(BeTrue())
+			saved := getJob(job.ID)
+			Expect(saved.Status).To(Equal(models.JobStatusFailed))
+			Expect(saved.LastError).ToNot(BeEmpty())
+		})
+	})
+	Context("run", func() {
+		It("should retry a failed job with a backoff", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			worker := newWorker("worker", services.JobHandler{Run: func(ctx context.Context, log *log.Entry, job *models.Job) error {
+				return errors.New("repo download failed")
+			}})
+			claimed, err := worker.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			worker.RunJob(claimed)
+
+			saved := getJob(job.ID)
+			Expect(saved.Status).To(Equal(models.JobStatusPending))
+			Expect(saved.LastError).To(Equal("repo download failed"))
+			Expect(saved.RunAt.Time).To(BeTemporally(">", time.Now().Add(30*time.Second)))
+			// the retry is not due yet
+			claimed, err = worker.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claimed).To(BeNil())
+		})
+		It("should run a queued job again without using an attempt", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			worker := newWorker("worker", services.JobHandler{Run: func(ctx context.Context, log *log.Entry, job *models.Job) error {
+				return new(services.JobQueued)
+			}})
+			worker.QueueDelay = time.Minute
+			claimed, err := worker.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			worker.RunJob(claimed)
+
+			saved := getJob(job.ID)
+			Expect(saved.Status).To(Equal(models.JobStatusPending))
+			Expect(saved.Attempts).To(Equal(0))
+			Expect(saved.LastError).To(BeEmpty())
+			Expect(saved.RunAt.Time).To(BeTemporally(">", time.Now().Add(30*time.Second)))
+		})
+		It("should fail a job on its last attempt", func() {
+			job, err := jobService.Enqueue(jobType, account, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(db.DB.Model(job).Update("max_attempts", 1).Error).ToNot(HaveOccurred())
+			var failure error
+			worker := newWorker("worker", services.JobHandler{
+				Run: func(ctx context.Context, log *log.Entry, job *models.Job) error {
+					panic("unexpected")
+				},
+				Fail: func(ctx context.Context, log *log.Entry, job *models.Job, err error) {
+					failure = err
+				},
+			})
+			claimed, err := worker.ClaimJob()
+			Expect(err).ToNot(HaveOccurred())
+			worker.RunJob(claimed)
+
+			Expect(failure).To(HaveOccurred())
+			saved := getJob(job.ID)
+			Expect(saved.Status).To(Equal(models.JobStatusFailed))
+			Expect(saved.LastError).To(ContainSubstring("unexpected"))
+		})
+		It("should run the jobs until stopped", func() {
+			var jobIDs []uint
+			for i := 0; i < 3; i++ {
+				job, err := jobService.Enqueue(jobType, account, uint(i))
+				Expect(err).ToNot(HaveOccurred())
+				jobIDs = append(jobIDs, job.ID)
+			}
+			done := make(chan uint, len(jobIDs))
+			worker := newWorker("worker", services.JobHandler{Run: func(ctx context.Context, log *log.Entry, job *models.Job) error {
+				done <- job.ID
+				return nil
+			}})
+			worker.Concurrency = 2
+			ctx, cancel := context.WithCancel(context.Background())
+			stopped := make(chan struct{})
+			go func() {
+				worker.Start(ctx)
+				close(stopped)
+			}()
+			for range jobIDs {
+				Eventually(done, 5*time.Second).Should(Receive())
+			}
+			cancel()
+			Eventually(stopped, 5*time.Second).Should(BeClosed())
+			for _, id := range jobIDs {
+				Expect(getJob(id).Status).To(Equal(models.JobStatusSuccess))
+			}
+		})
+	})
+})
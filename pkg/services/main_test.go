@@ -42,6 +42,20 @@ func setupTestDB() {
 		&models.FDOUser{},
 		&models.SSHKey{},
 		&models.DeviceGroup{},
+		&models.UpdateWave{},
+		&models.MaintenanceWindow{},
+		&models.DeviceGroupUpdate{},
+		&models.DeviceGroupUpdateRejectedDevice{},
+		&models.DispatchRecordAttempt{},
+		&models.UpdateHook{},
+		&models.Event{},
+		&models.UpdateBundle{},
+		&models.Job{},
+		&models.UpdateRepoCache{},
+		&models.ConcurrencySlot{},
+		&models.RetentionPolicy{},
+		&models.RetentionReport{},
+		&models.RetentionReportImage{},
 	)
 	if err != nil {
 		panic(err)
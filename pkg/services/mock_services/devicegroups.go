@@ -5,36 +5,67 @@
 package mock_services
 
 import (
+	reflect "reflect"
+
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/redhatinsights/edge-api/pkg/models"
 	gorm "gorm.io/gorm"
-	reflect "reflect"
 )
 
-// MockDeviceGroupsServiceInterface is a mock of DeviceGroupsServiceInterface interface
+// MockDeviceGroupsServiceInterface is a mock of DeviceGroupsServiceInterface interface.
 type MockDeviceGroupsServiceInterface struct {
 	ctrl     *gomock.Controller
 	recorder *MockDeviceGroupsServiceInterfaceMockRecorder
 }
 
-// MockDeviceGroupsServiceInterfaceMockRecorder is the mock recorder for MockDeviceGroupsServiceInterface
+// MockDeviceGroupsServiceInterfaceMockRecorder is the mock recorder for MockDeviceGroupsServiceInterface.
 type MockDeviceGroupsServiceInterfaceMockRecorder struct {
 	mock *MockDeviceGroupsServiceInterface
 }
 
-// NewMockDeviceGroupsServiceInterface creates a new mock instance
+// NewMockDeviceGroupsServiceInterface creates a new mock instance.
 func NewMockDeviceGroupsServiceInterface(ctrl *gomock.Controller) *MockDeviceGroupsServiceInterface {
 	mock := &MockDeviceGroupsServiceInterface{ctrl: ctrl}
 	mock.recorder = &MockDeviceGroupsServiceInterfaceMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockDeviceGroupsServiceInterface) EXPECT() *MockDeviceGroupsServiceInterfaceMockRecorder {
 	return m.recorder
 }
 
-// CreateDeviceGroup mocks base method
+// AddDeviceGroupDevices mocks base method.
+func (m *MockDeviceGroupsServiceInterface) AddDeviceGroupDevices(account string, deviceGroupID uint, devices []models.Device) (*[]models.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeviceGroupDevices", account, deviceGroupID, devices)
+	ret0, _ := ret[0].(*[]models.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDeviceGroupDevices indicates an expected call of AddDeviceGroupDevices.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) AddDeviceGroupDevices(account, deviceGroupID, devices interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeviceGroupDevices", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).AddDeviceGroupDevices), account, deviceGroupID, devices)
+}
+
+// AddDeviceGroupMaintenanceWindow mocks base method.
+func (m *MockDeviceGroupsServiceInterface) AddDeviceGroupMaintenanceWindow(account string, deviceGroupID uint, window *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeviceGroupMaintenanceWindow", account, deviceGroupID, window)
+	ret0, _ := ret[0].(*models.MaintenanceWindow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDeviceGroupMaintenanceWindow indicates an expected call of AddDeviceGroupMaintenanceWindow.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) AddDeviceGroupMaintenanceWindow(account, deviceGroupID, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeviceGroupMaintenanceWindow", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).AddDeviceGroupMaintenanceWindow), account, deviceGroupID, window)
+}
+
+// CreateDeviceGroup mocks base method.
 func (m *MockDeviceGroupsServiceInterface) CreateDeviceGroup(deviceGroup *models.DeviceGroup) (*models.DeviceGroup, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateDeviceGroup", deviceGroup)
@@ -43,43 +74,71 @@ func (m *MockDeviceGroupsServiceInterface) CreateDeviceGroup(deviceGroup *models
 	return ret0, ret1
 }
 
-// CreateDeviceGroup indicates an expected call of CreateDeviceGroup
+// CreateDeviceGroup indicates an expected call of CreateDeviceGroup.
 func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) CreateDeviceGroup(deviceGroup interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeviceGroup", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).CreateDeviceGroup), deviceGroup)
 }
 
-// GetDeviceGroups mocks base method
-func (m *MockDeviceGroupsServiceInterface) GetDeviceGroups(account string, limit, offset int, tx *gorm.DB) (*[]models.DeviceGroupListDetail, error) {
+// DeleteDeviceGroupByID mocks base method.
+func (m *MockDeviceGroupsServiceInterface) DeleteDeviceGroupByID(ID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDeviceGroups", account, limit, offset, tx)
-	ret0, _ := ret[0].(*[]models.DeviceGroupListDetail)
+	ret := m.ctrl.Call(m, "DeleteDeviceGroupByID", ID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeviceGroupByID indicates an expected call of DeleteDeviceGroupByID.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeleteDeviceGroupByID(ID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeviceGroupByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeleteDeviceGroupByID), ID)
+}
+
+// DeleteDeviceGroupDevices mocks base method.
+func (m *MockDeviceGroupsServiceInterface) DeleteDeviceGroupDevices(account string, deviceGroupID uint, devices []models.Device) (*[]models.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeviceGroupDevices", account, deviceGroupID, devices)
+	ret0, _ := ret[0].(*[]models.Device)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetDeviceGroups indicates an expected call of GetDeviceGroups
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroups(account, limit, offset, tx interface{}) *gomock.Call {
+// DeleteDeviceGroupDevices indicates an expected call of DeleteDeviceGroupDevices.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeleteDeviceGroupDevices(account, deviceGroupID, devices interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroups", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroups), account, limit, offset, tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeviceGroupDevices", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeleteDeviceGroupDevices), account, deviceGroupID, devices)
 }
 
-// GetDeviceGroupsCount mocks base method
-func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupsCount(account string, tx *gorm.DB) (int64, error) {
+// DeleteDeviceGroupMaintenanceWindow mocks base method.
+func (m *MockDeviceGroupsServiceInterface) DeleteDeviceGroupMaintenanceWindow(account string, deviceGroupID, windowID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDeviceGroupsCount", account, tx)
-	ret0, _ := ret[0].(int64)
+	ret := m.ctrl.Call(m, "DeleteDeviceGroupMaintenanceWindow", account, deviceGroupID, windowID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeviceGroupMaintenanceWindow indicates an expected call of DeleteDeviceGroupMaintenanceWindow.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeleteDeviceGroupMaintenanceWindow(account, deviceGroupID, windowID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeviceGroupMaintenanceWindow", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeleteDeviceGroupMaintenanceWindow), account, deviceGroupID, windowID)
+}
+
+// DeviceGroupNameExists mocks base method.
+func (m *MockDeviceGroupsServiceInterface) DeviceGroupNameExists(account, name string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeviceGroupNameExists", account, name)
+	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetDeviceGroupsCount indicates an expected call of GetDeviceGroupsCount
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupsCount(account, tx interface{}) *gomock.Call {
+// DeviceGroupNameExists indicates an expected call of DeviceGroupNameExists.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeviceGroupNameExists(account, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupsCount", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupsCount), account, tx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeviceGroupNameExists", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeviceGroupNameExists), account, name)
 }
 
-// GetDeviceGroupByID mocks base method
+// GetDeviceGroupByID mocks base method.
 func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupByID(ID string) (*models.DeviceGroup, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetDeviceGroupByID", ID)
@@ -88,13 +147,13 @@ func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupByID(ID string) (*model
 	return ret0, ret1
 }
 
-// GetDeviceGroupByID indicates an expected call of GetDeviceGroupByID
+// GetDeviceGroupByID indicates an expected call of GetDeviceGroupByID.
 func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupByID(ID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupByID), ID)
 }
 
-// GetDeviceGroupDetailsByID mocks base method
+// GetDeviceGroupDetailsByID mocks base method.
 func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupDetailsByID(ID string) (*models.DeviceGroupDetails, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetDeviceGroupDetailsByID", ID)
@@ -103,86 +162,73 @@ func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupDetailsByID(ID string)
 	return ret0, ret1
 }
 
-// GetDeviceGroupDetailsByID indicates an expected call of GetDeviceGroupDetailsByID
+// GetDeviceGroupDetailsByID indicates an expected call of GetDeviceGroupDetailsByID.
 func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupDetailsByID(ID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupDetailsByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupDetailsByID), ID)
 }
 
-// DeleteDeviceGroupByID mocks base method
-func (m *MockDeviceGroupsServiceInterface) DeleteDeviceGroupByID(ID string) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteDeviceGroupByID", ID)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// DeleteDeviceGroupByID indicates an expected call of DeleteDeviceGroupByID
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeleteDeviceGroupByID(ID interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeviceGroupByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeleteDeviceGroupByID), ID)
-}
-
-// UpdateDeviceGroup mocks base method
-func (m *MockDeviceGroupsServiceInterface) UpdateDeviceGroup(deviceGroup *models.DeviceGroup, account, ID string) error {
+// GetDeviceGroupDeviceByID mocks base method.
+func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupDeviceByID(account string, deviceGroupID, deviceID uint) (*models.Device, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateDeviceGroup", deviceGroup, account, ID)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetDeviceGroupDeviceByID", account, deviceGroupID, deviceID)
+	ret0, _ := ret[0].(*models.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// UpdateDeviceGroup indicates an expected call of UpdateDeviceGroup
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) UpdateDeviceGroup(deviceGroup, account, ID interface{}) *gomock.Call {
+// GetDeviceGroupDeviceByID indicates an expected call of GetDeviceGroupDeviceByID.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupDeviceByID(account, deviceGroupID, deviceID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeviceGroup", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).UpdateDeviceGroup), deviceGroup, account, ID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupDeviceByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupDeviceByID), account, deviceGroupID, deviceID)
 }
 
-// GetDeviceGroupDeviceByID mocks base method
-func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupDeviceByID(account string, deviceGroupID, deviceID uint) (*models.Device, error) {
+// GetDeviceGroupUpdateHooks mocks base method.
+func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupUpdateHooks(account string, deviceGroupID uint) (*models.UpdateHooks, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDeviceGroupDeviceByID", account, deviceGroupID, deviceID)
-	ret0, _ := ret[0].(*models.Device)
+	ret := m.ctrl.Call(m, "GetDeviceGroupUpdateHooks", account, deviceGroupID)
+	ret0, _ := ret[0].(*models.UpdateHooks)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetDeviceGroupDeviceByID indicates an expected call of GetDeviceGroupDeviceByID
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupDeviceByID(account, deviceGroupID, deviceID interface{}) *gomock.Call {
+// GetDeviceGroupUpdateHooks indicates an expected call of GetDeviceGroupUpdateHooks.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupUpdateHooks(account, deviceGroupID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupDeviceByID", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupDeviceByID), account, deviceGroupID, deviceID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupUpdateHooks", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupUpdateHooks), account, deviceGroupID)
 }
 
-// AddDeviceGroupDevices mocks base method
-func (m *MockDeviceGroupsServiceInterface) AddDeviceGroupDevices(account string, deviceGroupID uint, devices []models.Device) (*[]models.Device, error) {
+// GetDeviceGroups mocks base method.
+func (m *MockDeviceGroupsServiceInterface) GetDeviceGroups(account string, limit, offset int, tx *gorm.DB) (*[]models.DeviceGroupListDetail, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddDeviceGroupDevices", account, deviceGroupID, devices)
-	ret0, _ := ret[0].(*[]models.Device)
+	ret := m.ctrl.Call(m, "GetDeviceGroups", account, limit, offset, tx)
+	ret0, _ := ret[0].(*[]models.DeviceGroupListDetail)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AddDeviceGroupDevices indicates an expected call of AddDeviceGroupDevices
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) AddDeviceGroupDevices(account, deviceGroupID, devices interface{}) *gomock.Call {
+// GetDeviceGroups indicates an expected call of GetDeviceGroups.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroups(account, limit, offset, tx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeviceGroupDevices", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).AddDeviceGroupDevices), account, deviceGroupID, devices)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroups", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroups), account, limit, offset, tx)
 }
 
-// DeleteDeviceGroupDevices mocks base method
-func (m *MockDeviceGroupsServiceInterface) DeleteDeviceGroupDevices(account string, deviceGroupID uint, devices []models.Device) (*[]models.Device, error) {
+// GetDeviceGroupsCount mocks base method.
+func (m *MockDeviceGroupsServiceInterface) GetDeviceGroupsCount(account string, tx *gorm.DB) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteDeviceGroupDevices", account, deviceGroupID, devices)
-	ret0, _ := ret[0].(*[]models.Device)
+	ret := m.ctrl.Call(m, "GetDeviceGroupsCount", account, tx)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// DeleteDeviceGroupDevices indicates an expected call of DeleteDeviceGroupDevices
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeleteDeviceGroupDevices(account, deviceGroupID, devices interface{}) *gomock.Call {
+// GetDeviceGroupsCount indicates an expected call of GetDeviceGroupsCount.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceGroupsCount(account, tx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeviceGroupDevices", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeleteDeviceGroupDevices), account, deviceGroupID, devices)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupsCount", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceGroupsCount), account, tx)
 }
 
-// GetDeviceImageInfo mocks base method
+// GetDeviceImageInfo mocks base method.
 func (m *MockDeviceGroupsServiceInterface) GetDeviceImageInfo(setOfImages map[int]models.DeviceImageInfo, account string) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetDeviceImageInfo", setOfImages, account)
@@ -190,23 +236,37 @@ func (m *MockDeviceGroupsServiceInterface) GetDeviceImageInfo(setOfImages map[in
 	return ret0
 }
 
-// GetDeviceImageInfo indicates an expected call of GetDeviceImageInfo
+// GetDeviceImageInfo indicates an expected call of GetDeviceImageInfo.
 func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) GetDeviceImageInfo(setOfImages, account interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceImageInfo", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).GetDeviceImageInfo), setOfImages, account)
 }
 
-// DeviceGroupNameExists mocks base method
-func (m *MockDeviceGroupsServiceInterface) DeviceGroupNameExists(account, name string) (bool, error) {
+// SetDeviceGroupUpdateHooks mocks base method.
+func (m *MockDeviceGroupsServiceInterface) SetDeviceGroupUpdateHooks(account string, deviceGroupID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeviceGroupNameExists", account, name)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "SetDeviceGroupUpdateHooks", account, deviceGroupID, hooks)
+	ret0, _ := ret[0].(*models.UpdateHooks)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// DeviceGroupNameExists indicates an expected call of DeviceGroupNameExists
-func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) DeviceGroupNameExists(account, name interface{}) *gomock.Call {
+// SetDeviceGroupUpdateHooks indicates an expected call of SetDeviceGroupUpdateHooks.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) SetDeviceGroupUpdateHooks(account, deviceGroupID, hooks interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeviceGroupNameExists", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).DeviceGroupNameExists), account, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDeviceGroupUpdateHooks", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).SetDeviceGroupUpdateHooks), account, deviceGroupID, hooks)
+}
+
+// UpdateDeviceGroup mocks base method.
+func (m *MockDeviceGroupsServiceInterface) UpdateDeviceGroup(deviceGroup *models.DeviceGroup, account, ID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeviceGroup", deviceGroup, account, ID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeviceGroup indicates an expected call of UpdateDeviceGroup.
+func (mr *MockDeviceGroupsServiceInterfaceMockRecorder) UpdateDeviceGroup(deviceGroup, account, ID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeviceGroup", reflect.TypeOf((*MockDeviceGroupsServiceInterface)(nil).UpdateDeviceGroup), deviceGroup, account, ID)
 }
@@ -199,6 +199,36 @@ func (mr *MockDeviceServiceInterfaceMockRecorder) GetDevicesView(limit, offset,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDevicesView", reflect.TypeOf((*MockDeviceServiceInterface)(nil).GetDevicesView), limit, offset, tx)
 }
 
+// GetInventoryDevicesByTag mocks base method.
+func (m *MockDeviceServiceInterface) GetInventoryDevicesByTag(tag string) (inventory.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInventoryDevicesByTag", tag)
+	ret0, _ := ret[0].(inventory.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInventoryDevicesByTag indicates an expected call of GetInventoryDevicesByTag.
+func (mr *MockDeviceServiceInterfaceMockRecorder) GetInventoryDevicesByTag(tag interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInventoryDevicesByTag", reflect.TypeOf((*MockDeviceServiceInterface)(nil).GetInventoryDevicesByTag), tag)
+}
+
+// GetLatestCommitFromDevices mocks base method.
+func (m *MockDeviceServiceInterface) GetLatestCommitFromDevices(account string, devicesUUID []string) (uint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestCommitFromDevices", account, devicesUUID)
+	ret0, _ := ret[0].(uint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestCommitFromDevices indicates an expected call of GetLatestCommitFromDevices.
+func (mr *MockDeviceServiceInterfaceMockRecorder) GetLatestCommitFromDevices(account, devicesUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestCommitFromDevices", reflect.TypeOf((*MockDeviceServiceInterface)(nil).GetLatestCommitFromDevices), account, devicesUUID)
+}
+
 // GetUpdateAvailableForDevice mocks base method.
 func (m *MockDeviceServiceInterface) GetUpdateAvailableForDevice(device inventory.Device, latest bool) ([]models.ImageUpdateAvailable, error) {
 	m.ctrl.T.Helper()
@@ -270,18 +300,3 @@ func (mr *MockDeviceServiceInterfaceMockRecorder) ProcessPlatformInventoryUpdate
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessPlatformInventoryUpdatedEvent", reflect.TypeOf((*MockDeviceServiceInterface)(nil).ProcessPlatformInventoryUpdatedEvent), message)
 }
-
-// GetLatestCommitFromDevices mocks base method
-func (m *MockDeviceServiceInterface) GetLatestCommitFromDevices(account string, deviceUUID []string) (uint, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLatestCommitFromDevices", account, deviceUUID)
-	ret0, _ := ret[0].(uint)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// GetLatestCommitFromDevices indicates an expected call of GetLatestCommitFromDevices
-func (mr *MockDeviceServiceInterfaceMockRecorder) GetLatestCommitFromDevices(account string, deviceUUID interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestCommitFromDevices", reflect.TypeOf((*MockDeviceServiceInterface)(nil).GetLatestCommitFromDevices), account, deviceUUID)
-}
@@ -7,6 +7,7 @@ package mock_services
 import (
 	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	files "github.com/redhatinsights/edge-api/pkg/services/files"
@@ -78,6 +79,21 @@ func (mr *MockFilesServiceMockRecorder) GetFile(path interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockFilesService)(nil).GetFile), path)
 }
 
+// GetSignedURL mocks base method.
+func (m *MockFilesService) GetSignedURL(path string, expiration time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedURL", path, expiration)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedURL indicates an expected call of GetSignedURL.
+func (mr *MockFilesServiceMockRecorder) GetSignedURL(path, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedURL", reflect.TypeOf((*MockFilesService)(nil).GetSignedURL), path, expiration)
+}
+
 // GetUploader mocks base method.
 func (m *MockFilesService) GetUploader() files.Uploader {
 	m.ctrl.T.Helper()
@@ -93,6 +93,21 @@ func (mr *MockImageServiceInterfaceMockRecorder) CheckImageName(name, account in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckImageName", reflect.TypeOf((*MockImageServiceInterface)(nil).CheckImageName), name, account)
 }
 
+// CompareImages mocks base method.
+func (m *MockImageServiceInterface) CompareImages(image *models.Image, otherImageID uint) (*models.ImageComparison, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareImages", image, otherImageID)
+	ret0, _ := ret[0].(*models.ImageComparison)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareImages indicates an expected call of CompareImages.
+func (mr *MockImageServiceInterfaceMockRecorder) CompareImages(image, otherImageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareImages", reflect.TypeOf((*MockImageServiceInterface)(nil).CompareImages), image, otherImageID)
+}
+
 // CreateImage mocks base method.
 func (m *MockImageServiceInterface) CreateImage(image *models.Image, account string) error {
 	m.ctrl.T.Helper()
@@ -108,13 +123,12 @@ func (mr *MockImageServiceInterfaceMockRecorder) CreateImage(image, account inte
 }
 
 // CreateInstallerForImage mocks base method.
-func (m *MockImageServiceInterface) CreateInstallerForImage(i *models.Image) (*models.Image, chan error, error) {
+func (m *MockImageServiceInterface) CreateInstallerForImage(i *models.Image) (*models.Image, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateInstallerForImage", i)
 	ret0, _ := ret[0].(*models.Image)
-	ret1, _ := ret[1].(chan error)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CreateInstallerForImage indicates an expected call of CreateInstallerForImage.
@@ -138,6 +152,34 @@ func (mr *MockImageServiceInterfaceMockRecorder) CreateRepoForImage(i interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRepoForImage", reflect.TypeOf((*MockImageServiceInterface)(nil).CreateRepoForImage), i)
 }
 
+// DeleteImage mocks base method.
+func (m *MockImageServiceInterface) DeleteImage(image *models.Image) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteImage", image)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteImage indicates an expected call of DeleteImage.
+func (mr *MockImageServiceInterfaceMockRecorder) DeleteImage(image interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteImage", reflect.TypeOf((*MockImageServiceInterface)(nil).DeleteImage), image)
+}
+
+// DeleteImageArtifacts mocks base method.
+func (m *MockImageServiceInterface) DeleteImageArtifacts(id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteImageArtifacts", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteImageArtifacts indicates an expected call of DeleteImageArtifacts.
+func (mr *MockImageServiceInterfaceMockRecorder) DeleteImageArtifacts(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteImageArtifacts", reflect.TypeOf((*MockImageServiceInterface)(nil).DeleteImageArtifacts), id)
+}
+
 // GetImageByID mocks base method.
 func (m *MockImageServiceInterface) GetImageByID(id string) (*models.Image, error) {
 	m.ctrl.T.Helper()
@@ -168,6 +210,21 @@ func (mr *MockImageServiceInterfaceMockRecorder) GetImageByOSTreeCommitHash(comm
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageByOSTreeCommitHash", reflect.TypeOf((*MockImageServiceInterface)(nil).GetImageByOSTreeCommitHash), commitHash)
 }
 
+// GetImageEvents mocks base method.
+func (m *MockImageServiceInterface) GetImageEvents(image *models.Image) ([]models.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageEvents", image)
+	ret0, _ := ret[0].([]models.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageEvents indicates an expected call of GetImageEvents.
+func (mr *MockImageServiceInterfaceMockRecorder) GetImageEvents(image interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageEvents", reflect.TypeOf((*MockImageServiceInterface)(nil).GetImageEvents), image)
+}
+
 // GetMetadata mocks base method.
 func (m *MockImageServiceInterface) GetMetadata(image *models.Image) (*models.Image, error) {
 	m.ctrl.T.Helper()
@@ -34,6 +34,79 @@ func (m *MockImageSetsServiceInterface) EXPECT() *MockImageSetsServiceInterfaceM
 	return m.recorder
 }
 
+// ApplyRetentionPolicy mocks base method.
+func (m *MockImageSetsServiceInterface) ApplyRetentionPolicy(policyID uint) ([]models.RetentionReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyRetentionPolicy", policyID)
+	ret0, _ := ret[0].([]models.RetentionReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyRetentionPolicy indicates an expected call of ApplyRetentionPolicy.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) ApplyRetentionPolicy(policyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyRetentionPolicy", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).ApplyRetentionPolicy), policyID)
+}
+
+// DeleteImageSet mocks base method.
+func (m *MockImageSetsServiceInterface) DeleteImageSet(account string, imageSetID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteImageSet", account, imageSetID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteImageSet indicates an expected call of DeleteImageSet.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) DeleteImageSet(account, imageSetID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteImageSet", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).DeleteImageSet), account, imageSetID)
+}
+
+// DeleteRetentionPolicy mocks base method.
+func (m *MockImageSetsServiceInterface) DeleteRetentionPolicy(account string, imageSetID *uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRetentionPolicy", account, imageSetID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRetentionPolicy indicates an expected call of DeleteRetentionPolicy.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) DeleteRetentionPolicy(account, imageSetID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRetentionPolicy", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).DeleteRetentionPolicy), account, imageSetID)
+}
+
+// EnqueueDueRetentionPolicies mocks base method.
+func (m *MockImageSetsServiceInterface) EnqueueDueRetentionPolicies() (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDueRetentionPolicies")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueDueRetentionPolicies indicates an expected call of EnqueueDueRetentionPolicies.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) EnqueueDueRetentionPolicies() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDueRetentionPolicies", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).EnqueueDueRetentionPolicies))
+}
+
+// GetImageSetUpdateHooks mocks base method.
+func (m *MockImageSetsServiceInterface) GetImageSetUpdateHooks(account string, imageSetID uint) (*models.UpdateHooks, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageSetUpdateHooks", account, imageSetID)
+	ret0, _ := ret[0].(*models.UpdateHooks)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageSetUpdateHooks indicates an expected call of GetImageSetUpdateHooks.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) GetImageSetUpdateHooks(account, imageSetID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageSetUpdateHooks", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).GetImageSetUpdateHooks), account, imageSetID)
+}
+
 // GetImageSetsByID mocks base method.
 func (m *MockImageSetsServiceInterface) GetImageSetsByID(imageSetID int) (*models.ImageSet, error) {
 	m.ctrl.T.Helper()
@@ -48,3 +121,63 @@ func (mr *MockImageSetsServiceInterfaceMockRecorder) GetImageSetsByID(imageSetID
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageSetsByID", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).GetImageSetsByID), imageSetID)
 }
+
+// GetRetentionPolicy mocks base method.
+func (m *MockImageSetsServiceInterface) GetRetentionPolicy(account string, imageSetID *uint) (*models.RetentionPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRetentionPolicy", account, imageSetID)
+	ret0, _ := ret[0].(*models.RetentionPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRetentionPolicy indicates an expected call of GetRetentionPolicy.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) GetRetentionPolicy(account, imageSetID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRetentionPolicy", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).GetRetentionPolicy), account, imageSetID)
+}
+
+// GetRetentionReports mocks base method.
+func (m *MockImageSetsServiceInterface) GetRetentionReports(account string, limit, offset int) ([]models.RetentionReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRetentionReports", account, limit, offset)
+	ret0, _ := ret[0].([]models.RetentionReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRetentionReports indicates an expected call of GetRetentionReports.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) GetRetentionReports(account, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRetentionReports", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).GetRetentionReports), account, limit, offset)
+}
+
+// SetImageSetUpdateHooks mocks base method.
+func (m *MockImageSetsServiceInterface) SetImageSetUpdateHooks(account string, imageSetID uint, hooks *models.UpdateHooks) (*models.UpdateHooks, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetImageSetUpdateHooks", account, imageSetID, hooks)
+	ret0, _ := ret[0].(*models.UpdateHooks)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetImageSetUpdateHooks indicates an expected call of SetImageSetUpdateHooks.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) SetImageSetUpdateHooks(account, imageSetID, hooks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetImageSetUpdateHooks", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).SetImageSetUpdateHooks), account, imageSetID, hooks)
+}
+
+// SetRetentionPolicy mocks base method.
+func (m *MockImageSetsServiceInterface) SetRetentionPolicy(account string, imageSetID *uint, keepVersions int) (*models.RetentionPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRetentionPolicy", account, imageSetID, keepVersions)
+	ret0, _ := ret[0].(*models.RetentionPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRetentionPolicy indicates an expected call of SetRetentionPolicy.
+func (mr *MockImageSetsServiceInterfaceMockRecorder) SetRetentionPolicy(account, imageSetID, keepVersions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetentionPolicy", reflect.TypeOf((*MockImageSetsServiceInterface)(nil).SetRetentionPolicy), account, imageSetID, keepVersions)
+}
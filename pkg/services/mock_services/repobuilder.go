@@ -5,65 +5,65 @@
 package mock_services
 
 import (
+	reflect "reflect"
+
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/redhatinsights/edge-api/pkg/models"
-	reflect "reflect"
 )
 
-// MockRepoBuilderInterface is a mock of RepoBuilderInterface interface
+// MockRepoBuilderInterface is a mock of RepoBuilderInterface interface.
 type MockRepoBuilderInterface struct {
 	ctrl     *gomock.Controller
 	recorder *MockRepoBuilderInterfaceMockRecorder
 }
 
-// MockRepoBuilderInterfaceMockRecorder is the mock recorder for MockRepoBuilderInterface
+// MockRepoBuilderInterfaceMockRecorder is the mock recorder for MockRepoBuilderInterface.
 type MockRepoBuilderInterfaceMockRecorder struct {
 	mock *MockRepoBuilderInterface
 }
 
-// NewMockRepoBuilderInterface creates a new mock instance
+// NewMockRepoBuilderInterface creates a new mock instance.
 func NewMockRepoBuilderInterface(ctrl *gomock.Controller) *MockRepoBuilderInterface {
 	mock := &MockRepoBuilderInterface{ctrl: ctrl}
 	mock.recorder = &MockRepoBuilderInterfaceMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockRepoBuilderInterface) EXPECT() *MockRepoBuilderInterfaceMockRecorder {
 	return m.recorder
 }
 
-// BuildUpdateRepo mocks base method
-func (m *MockRepoBuilderInterface) BuildUpdateRepo(id uint) (*models.UpdateTransaction, error) {
+// BuildUpdateBundle mocks base method.
+func (m *MockRepoBuilderInterface) BuildUpdateBundle(bundle *models.UpdateBundle) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuildUpdateRepo", id)
-	ret0, _ := ret[0].(*models.UpdateTransaction)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "BuildUpdateBundle", bundle)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// BuildUpdateRepo indicates an expected call of BuildUpdateRepo
-func (mr *MockRepoBuilderInterfaceMockRecorder) BuildUpdateRepo(id interface{}) *gomock.Call {
+// BuildUpdateBundle indicates an expected call of BuildUpdateBundle.
+func (mr *MockRepoBuilderInterfaceMockRecorder) BuildUpdateBundle(bundle interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildUpdateRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).BuildUpdateRepo), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildUpdateBundle", reflect.TypeOf((*MockRepoBuilderInterface)(nil).BuildUpdateBundle), bundle)
 }
 
-// ImportRepo mocks base method
-func (m *MockRepoBuilderInterface) ImportRepo(r *models.Repo) (*models.Repo, error) {
+// BuildUpdateRepo mocks base method.
+func (m *MockRepoBuilderInterface) BuildUpdateRepo(id uint) (*models.UpdateTransaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ImportRepo", r)
-	ret0, _ := ret[0].(*models.Repo)
+	ret := m.ctrl.Call(m, "BuildUpdateRepo", id)
+	ret0, _ := ret[0].(*models.UpdateTransaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ImportRepo indicates an expected call of ImportRepo
-func (mr *MockRepoBuilderInterfaceMockRecorder) ImportRepo(r interface{}) *gomock.Call {
+// BuildUpdateRepo indicates an expected call of BuildUpdateRepo.
+func (mr *MockRepoBuilderInterfaceMockRecorder) BuildUpdateRepo(id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).ImportRepo), r)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildUpdateRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).BuildUpdateRepo), id)
 }
 
-// DownloadVersionRepo mocks base method
+// DownloadVersionRepo mocks base method.
 func (m *MockRepoBuilderInterface) DownloadVersionRepo(c *models.Commit, dest string) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DownloadVersionRepo", c, dest)
@@ -72,13 +72,13 @@ func (m *MockRepoBuilderInterface) DownloadVersionRepo(c *models.Commit, dest st
 	return ret0, ret1
 }
 
-// DownloadVersionRepo indicates an expected call of DownloadVersionRepo
+// DownloadVersionRepo indicates an expected call of DownloadVersionRepo.
 func (mr *MockRepoBuilderInterfaceMockRecorder) DownloadVersionRepo(c, dest interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadVersionRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).DownloadVersionRepo), c, dest)
 }
 
-// ExtractVersionRepo mocks base method
+// ExtractVersionRepo mocks base method.
 func (m *MockRepoBuilderInterface) ExtractVersionRepo(c *models.Commit, tarFileName, dest string) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ExtractVersionRepo", c, tarFileName, dest)
@@ -86,13 +86,43 @@ func (m *MockRepoBuilderInterface) ExtractVersionRepo(c *models.Commit, tarFileN
 	return ret0
 }
 
-// ExtractVersionRepo indicates an expected call of ExtractVersionRepo
+// ExtractVersionRepo indicates an expected call of ExtractVersionRepo.
 func (mr *MockRepoBuilderInterfaceMockRecorder) ExtractVersionRepo(c, tarFileName, dest interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractVersionRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).ExtractVersionRepo), c, tarFileName, dest)
 }
 
-// UploadVersionRepo mocks base method
+// ImportRepo mocks base method.
+func (m *MockRepoBuilderInterface) ImportRepo(r *models.Repo) (*models.Repo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportRepo", r)
+	ret0, _ := ret[0].(*models.Repo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportRepo indicates an expected call of ImportRepo.
+func (mr *MockRepoBuilderInterfaceMockRecorder) ImportRepo(r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).ImportRepo), r)
+}
+
+// PrebuildImageUpdateRepo mocks base method.
+func (m *MockRepoBuilderInterface) PrebuildImageUpdateRepo(imageID uint) (*models.UpdateRepoCache, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrebuildImageUpdateRepo", imageID)
+	ret0, _ := ret[0].(*models.UpdateRepoCache)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrebuildImageUpdateRepo indicates an expected call of PrebuildImageUpdateRepo.
+func (mr *MockRepoBuilderInterfaceMockRecorder) PrebuildImageUpdateRepo(imageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrebuildImageUpdateRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).PrebuildImageUpdateRepo), imageID)
+}
+
+// UploadVersionRepo mocks base method.
 func (m *MockRepoBuilderInterface) UploadVersionRepo(c *models.Commit, tarFileName string) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UploadVersionRepo", c, tarFileName)
@@ -100,7 +130,7 @@ func (m *MockRepoBuilderInterface) UploadVersionRepo(c *models.Commit, tarFileNa
 	return ret0
 }
 
-// UploadVersionRepo indicates an expected call of UploadVersionRepo
+// UploadVersionRepo indicates an expected call of UploadVersionRepo.
 func (mr *MockRepoBuilderInterfaceMockRecorder) UploadVersionRepo(c, tarFileName interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadVersionRepo", reflect.TypeOf((*MockRepoBuilderInterface)(nil).UploadVersionRepo), c, tarFileName)
@@ -36,6 +36,80 @@ func (m *MockUpdateServiceInterface) EXPECT() *MockUpdateServiceInterfaceMockRec
 	return m.recorder
 }
 
+// BuildUpdateBundle mocks base method.
+func (m *MockUpdateServiceInterface) BuildUpdateBundle(id uint) (*models.UpdateBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BuildUpdateBundle", id)
+	ret0, _ := ret[0].(*models.UpdateBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BuildUpdateBundle indicates an expected call of BuildUpdateBundle.
+func (mr *MockUpdateServiceInterfaceMockRecorder) BuildUpdateBundle(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildUpdateBundle", reflect.TypeOf((*MockUpdateServiceInterface)(nil).BuildUpdateBundle), id)
+}
+
+// CancelUpdate mocks base method.
+func (m *MockUpdateServiceInterface) CancelUpdate(update *models.UpdateTransaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelUpdate", update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelUpdate indicates an expected call of CancelUpdate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) CancelUpdate(update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CancelUpdate), update)
+}
+
+// CreateDeviceGroupRollback mocks base method.
+func (m *MockUpdateServiceInterface) CreateDeviceGroupRollback(deviceGroup *models.DeviceGroup) (*models.DeviceGroupUpdate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeviceGroupRollback", deviceGroup)
+	ret0, _ := ret[0].(*models.DeviceGroupUpdate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeviceGroupRollback indicates an expected call of CreateDeviceGroupRollback.
+func (mr *MockUpdateServiceInterfaceMockRecorder) CreateDeviceGroupRollback(deviceGroup interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeviceGroupRollback", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CreateDeviceGroupRollback), deviceGroup)
+}
+
+// CreateDeviceGroupUpdate mocks base method.
+func (m *MockUpdateServiceInterface) CreateDeviceGroupUpdate(deviceGroup *models.DeviceGroup, commitID uint) (*models.DeviceGroupUpdate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeviceGroupUpdate", deviceGroup, commitID)
+	ret0, _ := ret[0].(*models.DeviceGroupUpdate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeviceGroupUpdate indicates an expected call of CreateDeviceGroupUpdate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) CreateDeviceGroupUpdate(deviceGroup, commitID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeviceGroupUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CreateDeviceGroupUpdate), deviceGroup, commitID)
+}
+
+// CreateDeviceRollback mocks base method.
+func (m *MockUpdateServiceInterface) CreateDeviceRollback(account, deviceUUID string) (*models.UpdateTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeviceRollback", account, deviceUUID)
+	ret0, _ := ret[0].(*models.UpdateTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeviceRollback indicates an expected call of CreateDeviceRollback.
+func (mr *MockUpdateServiceInterfaceMockRecorder) CreateDeviceRollback(account, deviceUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeviceRollback", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CreateDeviceRollback), account, deviceUUID)
+}
+
 // CreateUpdate mocks base method.
 func (m *MockUpdateServiceInterface) CreateUpdate(id uint) (*models.UpdateTransaction, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +125,94 @@ func (mr *MockUpdateServiceInterfaceMockRecorder) CreateUpdate(id interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CreateUpdate), id)
 }
 
+// CreateUpdateBundle mocks base method.
+func (m *MockUpdateServiceInterface) CreateUpdateBundle(account string, commitID uint, sourceCommitIDs []uint) (*models.UpdateBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUpdateBundle", account, commitID, sourceCommitIDs)
+	ret0, _ := ret[0].(*models.UpdateBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUpdateBundle indicates an expected call of CreateUpdateBundle.
+func (mr *MockUpdateServiceInterfaceMockRecorder) CreateUpdateBundle(account, commitID, sourceCommitIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUpdateBundle", reflect.TypeOf((*MockUpdateServiceInterface)(nil).CreateUpdateBundle), account, commitID, sourceCommitIDs)
+}
+
+// DispatchScheduledUpdates mocks base method.
+func (m *MockUpdateServiceInterface) DispatchScheduledUpdates() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DispatchScheduledUpdates")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DispatchScheduledUpdates indicates an expected call of DispatchScheduledUpdates.
+func (mr *MockUpdateServiceInterfaceMockRecorder) DispatchScheduledUpdates() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DispatchScheduledUpdates", reflect.TypeOf((*MockUpdateServiceInterface)(nil).DispatchScheduledUpdates))
+}
+
+// ExpireRebootingDispatchRecords mocks base method.
+func (m *MockUpdateServiceInterface) ExpireRebootingDispatchRecords() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireRebootingDispatchRecords")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireRebootingDispatchRecords indicates an expected call of ExpireRebootingDispatchRecords.
+func (mr *MockUpdateServiceInterfaceMockRecorder) ExpireRebootingDispatchRecords() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireRebootingDispatchRecords", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ExpireRebootingDispatchRecords))
+}
+
+// GetDeviceGroupUpdateByID mocks base method.
+func (m *MockUpdateServiceInterface) GetDeviceGroupUpdateByID(account string, deviceGroupID, ID uint) (*models.DeviceGroupUpdate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceGroupUpdateByID", account, deviceGroupID, ID)
+	ret0, _ := ret[0].(*models.DeviceGroupUpdate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceGroupUpdateByID indicates an expected call of GetDeviceGroupUpdateByID.
+func (mr *MockUpdateServiceInterfaceMockRecorder) GetDeviceGroupUpdateByID(account, deviceGroupID, ID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceGroupUpdateByID", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetDeviceGroupUpdateByID), account, deviceGroupID, ID)
+}
+
+// GetUpdateBundleByID mocks base method.
+func (m *MockUpdateServiceInterface) GetUpdateBundleByID(account string, id uint) (*models.UpdateBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdateBundleByID", account, id)
+	ret0, _ := ret[0].(*models.UpdateBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpdateBundleByID indicates an expected call of GetUpdateBundleByID.
+func (mr *MockUpdateServiceInterfaceMockRecorder) GetUpdateBundleByID(account, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdateBundleByID", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetUpdateBundleByID), account, id)
+}
+
+// GetUpdateBundleDownload mocks base method.
+func (m *MockUpdateServiceInterface) GetUpdateBundleDownload(bundle *models.UpdateBundle) (*models.UpdateBundleDownload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdateBundleDownload", bundle)
+	ret0, _ := ret[0].(*models.UpdateBundleDownload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpdateBundleDownload indicates an expected call of GetUpdateBundleDownload.
+func (mr *MockUpdateServiceInterfaceMockRecorder) GetUpdateBundleDownload(bundle interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdateBundleDownload", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetUpdateBundleDownload), bundle)
+}
+
 // GetUpdatePlaybook mocks base method.
 func (m *MockUpdateServiceInterface) GetUpdatePlaybook(update *models.UpdateTransaction) (io.ReadCloser, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +228,21 @@ func (mr *MockUpdateServiceInterfaceMockRecorder) GetUpdatePlaybook(update inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdatePlaybook", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetUpdatePlaybook), update)
 }
 
+// GetUpdateTransactionEvents mocks base method.
+func (m *MockUpdateServiceInterface) GetUpdateTransactionEvents(update *models.UpdateTransaction) ([]models.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdateTransactionEvents", update)
+	ret0, _ := ret[0].([]models.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpdateTransactionEvents indicates an expected call of GetUpdateTransactionEvents.
+func (mr *MockUpdateServiceInterfaceMockRecorder) GetUpdateTransactionEvents(update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdateTransactionEvents", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetUpdateTransactionEvents), update)
+}
+
 // GetUpdateTransactionsForDevice mocks base method.
 func (m *MockUpdateServiceInterface) GetUpdateTransactionsForDevice(device *models.Device) (*[]models.UpdateTransaction, error) {
 	m.ctrl.T.Helper()
@@ -81,6 +258,21 @@ func (mr *MockUpdateServiceInterfaceMockRecorder) GetUpdateTransactionsForDevice
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdateTransactionsForDevice", reflect.TypeOf((*MockUpdateServiceInterface)(nil).GetUpdateTransactionsForDevice), device)
 }
 
+// PreviewUpdate mocks base method.
+func (m *MockUpdateServiceInterface) PreviewUpdate(account string, commitID uint, devicesUUID []string) (*models.UpdatePreview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewUpdate", account, commitID, devicesUUID)
+	ret0, _ := ret[0].(*models.UpdatePreview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewUpdate indicates an expected call of PreviewUpdate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) PreviewUpdate(account, commitID, devicesUUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).PreviewUpdate), account, commitID, devicesUUID)
+}
+
 // ProcessPlaybookDispatcherRunEvent mocks base method.
 func (m *MockUpdateServiceInterface) ProcessPlaybookDispatcherRunEvent(message []byte) error {
 	m.ctrl.T.Helper()
@@ -95,6 +287,48 @@ func (mr *MockUpdateServiceInterfaceMockRecorder) ProcessPlaybookDispatcherRunEv
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessPlaybookDispatcherRunEvent", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ProcessPlaybookDispatcherRunEvent), message)
 }
 
+// ReconcileDispatchRecords mocks base method.
+func (m *MockUpdateServiceInterface) ReconcileDispatchRecords() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileDispatchRecords")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReconcileDispatchRecords indicates an expected call of ReconcileDispatchRecords.
+func (mr *MockUpdateServiceInterfaceMockRecorder) ReconcileDispatchRecords() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileDispatchRecords", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ReconcileDispatchRecords))
+}
+
+// ResumeUpdate mocks base method.
+func (m *MockUpdateServiceInterface) ResumeUpdate(update *models.UpdateTransaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeUpdate", update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeUpdate indicates an expected call of ResumeUpdate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) ResumeUpdate(update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ResumeUpdate), update)
+}
+
+// RetryUpdate mocks base method.
+func (m *MockUpdateServiceInterface) RetryUpdate(update *models.UpdateTransaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryUpdate", update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryUpdate indicates an expected call of RetryUpdate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) RetryUpdate(update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryUpdate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).RetryUpdate), update)
+}
+
 // SendDeviceNotification mocks base method.
 func (m *MockUpdateServiceInterface) SendDeviceNotification(update *models.UpdateTransaction) (services.ImageNotification, error) {
 	m.ctrl.T.Helper()
@@ -152,32 +386,32 @@ func (mr *MockUpdateServiceInterfaceMockRecorder) UpdateDevicesFromUpdateTransac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDevicesFromUpdateTransaction", reflect.TypeOf((*MockUpdateServiceInterface)(nil).UpdateDevicesFromUpdateTransaction), update)
 }
 
-// WriteTemplate mocks base method.
-func (m *MockUpdateServiceInterface) WriteTemplate(templateInfo services.TemplateRemoteInfo, account string) (string, error) {
+// ValidateUpdateSelection mocks base method.
+func (m *MockUpdateServiceInterface) ValidateUpdateSelection(account string, imageIds []uint) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WriteTemplate", templateInfo, account)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "ValidateUpdateSelection", account, imageIds)
+	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// WriteTemplate indicates an expected call of WriteTemplate.
-func (mr *MockUpdateServiceInterfaceMockRecorder) WriteTemplate(templateInfo, account interface{}) *gomock.Call {
+// ValidateUpdateSelection indicates an expected call of ValidateUpdateSelection.
+func (mr *MockUpdateServiceInterfaceMockRecorder) ValidateUpdateSelection(account, imageIds interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTemplate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).WriteTemplate), templateInfo, account)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateUpdateSelection", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ValidateUpdateSelection), account, imageIds)
 }
 
-// ValidateUpdateSelection mocks base method
-func (m *MockUpdateServiceInterface) ValidateUpdateSelection(account string, imageIds []uint) (bool, error) {
+// WriteTemplate mocks base method.
+func (m *MockUpdateServiceInterface) WriteTemplate(templateInfo services.TemplateRemoteInfo, account string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ValidateUpdateSelection", account, imageIds)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "WriteTemplate", templateInfo, account)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ValidateUpdateSelection mock
-func (mr *MockUpdateServiceInterfaceMockRecorder) ValidateUpdateSelection(account string, imageIds []uint) *gomock.Call {
+// WriteTemplate indicates an expected call of WriteTemplate.
+func (mr *MockUpdateServiceInterfaceMockRecorder) WriteTemplate(templateInfo, account interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendDeviceNotification", reflect.TypeOf((*MockUpdateServiceInterface)(nil).ValidateUpdateSelection), account, imageIds)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTemplate", reflect.TypeOf((*MockUpdateServiceInterface)(nil).WriteTemplate), templateInfo, account)
 }
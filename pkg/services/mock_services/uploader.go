@@ -33,6 +33,34 @@ func (m *MockUploader) EXPECT() *MockUploaderMockRecorder {
 	return m.recorder
 }
 
+// DeleteFile mocks base method.
+func (m *MockUploader) DeleteFile(url string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFile", url)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFile indicates an expected call of DeleteFile.
+func (mr *MockUploaderMockRecorder) DeleteFile(url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFile", reflect.TypeOf((*MockUploader)(nil).DeleteFile), url)
+}
+
+// DeleteRepo mocks base method.
+func (m *MockUploader) DeleteRepo(src, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRepo", src, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRepo indicates an expected call of DeleteRepo.
+func (mr *MockUploaderMockRecorder) DeleteRepo(src, account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRepo", reflect.TypeOf((*MockUploader)(nil).DeleteRepo), src, account)
+}
+
 // UploadFile mocks base method.
 func (m *MockUploader) UploadFile(fname, uploadPath string) (string, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// playbookSignatureExcludeVar is the play variable listing the elements left out of the signature
+	playbookSignatureExcludeVar = "insights_signature_exclude"
+	// playbookSignatureLineLength is the length of the lines of the signature in the playbook
+	playbookSignatureLineLength = 76
+)
+
+// SignPlaybook signs the play of an update playbook the way the insights-client playbook verifier checks it
+// Returns the value of the insights_signature variable, the base64 encoded ASCII armored detached signature
+// of the play hash, base64 encoded again as the variable is a YAML binary.
+func SignPlaybook(playbook []byte, key *SigningKey) (string, error) {
+	hash, err := PlaybookHash(playbook)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--batch", "--homedir", key.Homedir, "--local-user", key.KeyID, "--armor", "--detach-sign")
+	cmd.Stdin = bytes.NewReader(hash)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error signing playbook :: %s", strings.TrimSpace(stderr.String()))
+	}
+	signature := base64.StdEncoding.EncodeToString(stdout.Bytes())
+	return base64.StdEncoding.EncodeToString([]byte(signature)), nil
+}
+
+// PlaybookHash returns the hash of the play of an update playbook covered by its signature
+// The signature covers the play without the insights_signature_exclude elements, serialized the way
+// the insights-client playbook verifier serializes it.
+func PlaybookHash(playbook []byte) ([]byte, error) {
+	var plays []yaml.MapSlice
+	if err := yaml.Unmarshal(playbook, &plays); err != nil {
+		return nil, fmt.Errorf("error parsing playbook :: %s", err.Error())
+	}
+	if len(plays) == 0 {
+		return nil, errors.New("playbook has no play")
+	}
+	play := excludePlaybookElements(plays[0])
+	hash := sha256.Sum256([]byte(serializePlaybookValue(play)))
+	return hash[:], nil
+}
+
+// excludePlaybookElements removes the elements listed by insights_signature_exclude from the play
+// The elements are play keys, /name, or play variables, /vars/name.
+func excludePlaybookElements(play yaml.MapSlice) yaml.MapSlice {
+	vars, _ := playbookMapValue(play, "vars").(yaml.MapSlice)
+	exclude, _ := playbookMapValue(vars, playbookSignatureExcludeVar).(string)
+	excludedKeys := map[string]bool{}
+	excludedVars := map[string]bool{}
+	for _, element := range strings.Split(exclude, ",") {
+		element = strings.TrimSpace(element)
+		if name := strings.TrimPrefix(element, "/vars/"); name != element {
+			excludedVars[name] = true
+		} else if name := strings.TrimPrefix(element, "/"); name != element && name != "" {
+			excludedKeys[name] = true
+		}
+	}
+	result := yaml.MapSlice{}
+	for _, item := range play {
+		name := fmt.Sprint(item.Key)
+		if excludedKeys[name] {
+			continue
+		}
+		if name == "vars" {
+			if itemVars, ok := item.Value.(yaml.MapSlice); ok {
+				keptVars := yaml.MapSlice{}
+				for _, v := range itemVars {
+					if !excludedVars[fmt.Sprint(v.Key)] {
+						keptVars = append(keptVars, v)
+					}
+				}
+				item.Value = keptVars
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// playbookMapValue returns the value of a key of a YAML mapping, nil when the key is missing
+func playbookMapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// serializePlaybookValue serializes a value of the play like the Python representation of the ordered
+// dictionaries the insights-client playbook verifier loads the playbook into
+func serializePlaybookValue(value interface{}) string {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprintf("(%s, %s)", serializePlaybookValue(item.Key), serializePlaybookValue(item.Value)))
+		}
+		return "ordereddict([" + strings.Join(items, ", ") + "])"
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, serializePlaybookValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return serializePlaybookString(v)
+	default:
+		return serializePlaybookString(fmt.Sprint(v))
+	}
+}
+
+// serializePlaybookString serializes a string like the Python representation of a string
+func serializePlaybookString(s string) string {
+	quote := '\''
+	if strings.ContainsRune(s, '\'') && !strings.ContainsRune(s, '"') {
+		quote = '"'
+	}
+	var b strings.Builder
+	b.WriteRune(quote)
+	for _, r := range s {
+		switch {
+		case r == quote || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case !unicode.IsPrint(r) && r <= 0xff:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case !unicode.IsPrint(r) && r <= 0xffff:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(quote)
+	return b.String()
+}
+
+// formatPlaybookSignature splits the signature into the indented lines of the insights_signature binary
+func formatPlaybookSignature(signature string, indent string) string {
+	var lines []string
+	for len(signature) > playbookSignatureLineLength {
+		lines = append(lines, signature[:playbookSignatureLineLength])
+		signature = signature[playbookSignatureLineLength:]
+	}
+	lines = append(lines, signature)
+	return strings.Join(lines, "\n"+indent)
+}
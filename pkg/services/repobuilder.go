@@ -6,11 +6,13 @@ import (
 	"fmt"
 
 	"bytes"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/redhatinsights/edge-api/config"
 	"github.com/redhatinsights/edge-api/pkg/db"
@@ -18,6 +20,7 @@ import (
 
 	"github.com/cavaliercoder/grab"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // RepoBuilderInterface defines the interface of a repository builder
@@ -27,6 +30,8 @@ type RepoBuilderInterface interface {
 	DownloadVersionRepo(c *models.Commit, dest string) (string, error)
 	ExtractVersionRepo(c *models.Commit, tarFileName string, dest string) error
 	UploadVersionRepo(c *models.Commit, tarFileName string) error
+	BuildUpdateBundle(bundle *models.UpdateBundle) error
+	PrebuildImageUpdateRepo(imageID uint) (*models.UpdateRepoCache, error)
 }
 
 // RepoBuilder is the implementation of a RepoBuilderInterface
@@ -49,9 +54,12 @@ func NewRepoBuilder(ctx context.Context, log *log.Entry) RepoBuilderInterface {
 
 // BuildUpdateRepo build an update repo with the set of commits all merged into a single repo
 // with static deltas generated between them all
+// The update repos are cached by commit: the update uses the repo of a previous update to the same commit
+// when it has the static deltas from all its old commits, otherwise the missing static deltas are added
+// to a cached repo of the commit, or a new repo is built and cached when none is available
 func (rb *RepoBuilder) BuildUpdateRepo(id uint) (*models.UpdateTransaction, error) {
 	var update *models.UpdateTransaction
-	db.DB.Preload("DispatchRecords").Preload("Devices").Joins("Commit").Joins("Repo").Find(&update, id)
+	db.DB.Preload("DispatchRecords").Preload("Devices").Preload("OldCommits").Joins("Commit").Joins("Repo").Find(&update, id)
 
 	rb.log.Info("Starts building update repo...")
 	if update == nil {
@@ -69,61 +77,524 @@ func (rb *RepoBuilder) BuildUpdateRepo(id uint) (*models.UpdateTransaction, erro
 		rb.log.Error("Repo is unavailable")
 		return nil, errors.New("repo unavailable")
 	}
+	// a previous attempt of the update build job already built or found the update repo
+	if update.Repo.Status == models.RepoStatusSuccess {
+		rb.log.WithField("repoID", update.RepoID).Info("Update repo was already built")
+		return update, nil
+	}
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return nil, rb.abortUpdateRepo(update, "", false, err)
+	}
+
+	signingKey, err := GetAccountSigningKey(update.Account)
+	if err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error getting signing key")
+		return nil, err
+	}
+	// the devices verify the commit with the current key of the account, the repos signed with another key can't be used
+	var caches []models.UpdateRepoCache
+	if result := db.DB.Preload("FromCommits").Preload("Repo").
+		Joins("JOIN repos ON repos.id = update_repo_caches.repo_id").
+		Where("update_repo_caches.account = ? AND update_repo_caches.commit_id = ? AND update_repo_caches.signing_key_id = ? AND repos.status = ?",
+			update.Account, update.CommitID, signingKeyID(signingKey), models.RepoStatusSuccess).
+		Order("update_repo_caches.created_at").Find(&caches); result.Error != nil {
+		return nil, result.Error
+	}
+	for i := range caches {
+		if len(missingFromCommits(&caches[i], update.OldCommits)) == 0 {
+			rb.log.WithField("repoID", caches[i].RepoID).Info("Using the cached update repo of the commit")
+			return rb.useUpdateRepoCache(update, &caches[i])
+		}
+	}
+	owner := fmt.Sprintf("update-%d", update.ID)
+	for i := range caches {
+		cache := &caches[i]
+		// the repo is extended by a single update at a time
+		leased, err := leaseUpdateRepoCache(cache.ID, owner)
+		if err != nil {
+			return nil, err
+		}
+		if leased {
+			return rb.extendUpdateRepoCache(update, cache, owner, signingKey)
+		}
+	}
+	return rb.buildUpdateRepoCache(update, signingKey)
+}
+
+// signingKeyID returns the ID of the signing key, empty when there is no key
+func signingKeyID(signingKey *SigningKey) string {
+	if signingKey == nil {
+		return ""
+	}
+	return signingKey.KeyID
+}
+
+// updateRepoCacheLeaseTimeout is how long an update leases a cached repo to extend it without renewing the lease
+func updateRepoCacheLeaseTimeout() time.Duration {
+	timeout := time.Duration(config.Get().JobLeaseTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return timeout
+}
+
+// leaseUpdateRepoCache takes the lease of a cached repo for the owner, when it is not leased, its lease expired
+// or the owner already has it, the lease is updated only if no other owner took it since it was read
+func leaseUpdateRepoCache(cacheID uint, owner string) (bool, error) {
+	now := time.Now().UTC()
+	result := db.DB.Model(&models.UpdateRepoCache{}).
+		Where("id = ? AND (extending_until IS NULL OR extending_until < ? OR extending_owner = ?)", cacheID, now, owner).
+		Updates(map[string]interface{}{
+			"extending_owner": owner,
+			"extending_until": models.EdgeAPITime{Time: now.Add(updateRepoCacheLeaseTimeout()), Valid: true},
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// renewUpdateRepoCacheLease extends the lease of the owner on a cached repo until done is closed
+func (rb *RepoBuilder) renewUpdateRepoCacheLease(cacheID uint, owner string, done chan struct{}) {
+	ticker := time.NewTicker(updateRepoCacheLeaseTimeout() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			result := db.DB.Model(&models.UpdateRepoCache{}).Where("id = ? AND extending_owner = ?", cacheID, owner).
+				Update("extending_until", models.EdgeAPITime{Time: time.Now().UTC().Add(updateRepoCacheLeaseTimeout()), Valid: true})
+			if result.Error != nil {
+				rb.log.WithField("error", result.Error.Error()).Error("Error extending the lease of the cached update repo")
+			} else if result.RowsAffected == 0 {
+				rb.log.Warning("Lease of the cached update repo was lost")
+				return
+			}
+		}
+	}
+}
+
+// releaseUpdateRepoCacheLease releases the lease of the owner on a cached repo
+func releaseUpdateRepoCacheLease(cacheID uint, owner string) error {
+	return db.DB.Model(&models.UpdateRepoCache{}).Where("id = ? AND extending_owner = ?", cacheID, owner).
+		Updates(map[string]interface{}{"extending_owner": "", "extending_until": nil}).Error
+}
+
+// updateRepoVersionFile is the file of the workspace of a cached update repo holding the version of the repo
+const updateRepoVersionFile = "version"
+
+// updateRepoPath returns the workspace of the cached update repo
+// NOTE: the repo is uploaded from this path, its URL relies on the path being cfg.RepoTempPath/upd/repos/models.Repo.ID/
+func updateRepoPath(repoID uint) string {
 	cfg := config.Get()
-	path := filepath.Clean(filepath.Join(cfg.RepoTempPath, "upd/", strconv.FormatUint(uint64(update.ID), 10)))
+	return filepath.Clean(filepath.Join(cfg.RepoTempPath, "upd/repos/", strconv.FormatUint(uint64(repoID), 10)))
+}
+
+// readUpdateRepoVersion returns the version of the cached repo in its workspace, 0 when the workspace has no repo
+func readUpdateRepoVersion(path string) int {
+	if _, err := os.Stat(filepath.Clean(filepath.Join(path, "repo"))); err != nil {
+		return 0
+	}
+	content, err := ioutil.ReadFile(filepath.Clean(filepath.Join(path, updateRepoVersionFile)))
+	if err != nil {
+		return 0
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// writeUpdateRepoVersion records the version of the cached repo in its workspace
+func writeUpdateRepoVersion(path string, version int) error {
+	return ioutil.WriteFile(filepath.Clean(filepath.Join(path, updateRepoVersionFile)), []byte(strconv.Itoa(version)), 0644)
+}
+
+// missingFromCommits returns the commits of oldCommits the cached repo has no static deltas from
+func missingFromCommits(cache *models.UpdateRepoCache, oldCommits []models.Commit) []models.Commit {
+	var missing []models.Commit
+	for _, oldCommit := range oldCommits {
+		found := false
+		for _, fromCommit := range cache.FromCommits {
+			if fromCommit.ID == oldCommit.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, oldCommit)
+		}
+	}
+	return missing
+}
+
+// buildUpdateRepoCache builds the repo of the update with the static deltas from its old commits, signed with
+// the signing key when there is one, and caches it for the next updates to the same commit
+func (rb *RepoBuilder) buildUpdateRepoCache(update *models.UpdateTransaction, signingKey *SigningKey) (*models.UpdateTransaction, error) {
+	path := updateRepoPath(update.RepoID)
 	rb.log.WithField("path", path).Debug("Update path will be created")
+	// the workspace of an interrupted build can't be trusted
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
 	err := os.MkdirAll(path, os.FileMode(int(0755)))
 	if err != nil {
 		return nil, err
 	}
-	err = os.Chdir(path)
+	cancelled, err := rb.buildStaticDeltasRepo(path, signingKey, update.Commit, update.OldCommits, func() (bool, error) {
+		return isUpdateCancelled(update)
+	})
+	if cancelled {
+		return nil, rb.abortUpdateRepo(update, path, false, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return nil, rb.abortUpdateRepo(update, path, false, err)
+	}
+	rb.log.Info("Upload repo")
+	repoURL, err := rb.filesService.GetUploader().UploadRepo(filepath.Clean(filepath.Join(path, "repo")), update.Account)
+	rb.log.Info("Finished uploading repo")
+	if err != nil {
+		return nil, err
+	}
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return nil, rb.abortUpdateRepo(update, path, true, err)
+	}
+
+	update.Repo.URL = repoURL
+	update.Repo.Status = models.RepoStatusSuccess
+	if err := db.DB.Save(&update).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Save(&update.Repo).Error; err != nil {
+		return nil, err
+	}
+	cache := models.UpdateRepoCache{
+		Account:        update.Account,
+		CommitID:       update.CommitID,
+		FromCommits:    update.OldCommits,
+		RepoID:         update.RepoID,
+		ReferenceCount: 1,
+		Version:        1,
+		SigningKeyID:   signingKeyID(signingKey),
+	}
+	if err := db.DB.Omit("FromCommits.*").Create(&cache).Error; err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error caching update repo")
+		return nil, err
+	}
+	update.HoldsRepoCache = true
+	if err := db.DB.Model(update).UpdateColumn("holds_repo_cache", true).Error; err != nil {
+		return nil, err
+	}
+	if err := writeUpdateRepoVersion(path, cache.Version); err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error writing the version of the cached update repo")
+	}
+
+	return update, nil
+}
+
+// extendUpdateRepoCache adds the static deltas from the old commits of the update missing to the cached repo
+// leased by the owner and uses it for the update, the cached repo is rebuilt with all its static deltas when
+// its workspace doesn't have its latest version, as another replica extended it for instance
+// The repo is signed with the signing key it was signed with before
+func (rb *RepoBuilder) extendUpdateRepoCache(update *models.UpdateTransaction, cache *models.UpdateRepoCache, owner string, signingKey *SigningKey) (*models.UpdateTransaction, error) {
+	done := make(chan struct{})
+	go rb.renewUpdateRepoCacheLease(cache.ID, owner, done)
+	defer func() {
+		close(done)
+		if err := releaseUpdateRepoCacheLease(cache.ID, owner); err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error releasing the cached update repo")
+		}
+	}()
+	// the cached repo may have been extended since it was read
+	if result := db.DB.Preload("FromCommits").Preload("Repo").First(cache, cache.ID); result.Error != nil {
+		return nil, result.Error
+	}
+	missing := missingFromCommits(cache, update.OldCommits)
+	if len(missing) == 0 {
+		return rb.useUpdateRepoCache(update, cache)
+	}
+	path := updateRepoPath(cache.RepoID)
+	rb.log.WithFields(log.Fields{"repoID": cache.RepoID, "path": path, "missingCommits": len(missing)}).Info("Adding the missing static deltas to the cached update repo")
+	isCancelled := func() (bool, error) {
+		return isUpdateCancelled(update)
+	}
+	var cancelled bool
+	var err error
+	if readUpdateRepoVersion(path) == cache.Version {
+		cancelled, err = rb.pullStaticDeltas(path, update.Commit, missing, isCancelled)
+		if err == nil && !cancelled {
+			err = rb.signUpdateRepo(path, signingKey, update.Commit)
+		}
+	} else {
+		rb.log.WithField("version", cache.Version).Info("Workspace doesn't have the latest version of the cached update repo, rebuilding it")
+		if err := os.RemoveAll(path); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(path, os.FileMode(int(0755))); err != nil {
+			return nil, err
+		}
+		fromCommits := append(append([]models.Commit{}, cache.FromCommits...), missing...)
+		cancelled, err = rb.buildStaticDeltasRepo(path, signingKey, update.Commit, fromCommits, isCancelled)
+	}
+	if cancelled {
+		return nil, rb.abortUpdateRepo(update, "", false, nil)
+	}
+	if err != nil {
+		// the workspace is rebuilt by the next update extending the repo
+		if removeErr := os.RemoveAll(path); removeErr != nil {
+			rb.log.WithField("error", removeErr.Error()).Error("Error removing the workspace of the cached update repo")
+		}
+		return nil, err
+	}
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return nil, rb.abortUpdateRepo(update, "", false, err)
+	}
+	// the repo is uploaded again where it was, the updates using it keep its URL
+	rb.log.Info("Upload repo")
+	if _, err := rb.filesService.GetUploader().UploadRepo(filepath.Clean(filepath.Join(path, "repo")), update.Account); err != nil {
+		return nil, err
+	}
+	rb.log.Info("Finished uploading repo")
+	if err := db.DB.Model(cache).Omit("FromCommits.*").Association("FromCommits").Append(missing); err != nil {
+		return nil, err
+	}
+	cache.Version++
+	if err := db.DB.Model(&models.UpdateRepoCache{}).Where("id = ?", cache.ID).Update("version", cache.Version).Error; err != nil {
+		return nil, err
+	}
+	if err := writeUpdateRepoVersion(path, cache.Version); err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error writing the version of the cached update repo")
+	}
+	return rb.useUpdateRepoCache(update, cache)
+}
+
+// useUpdateRepoCache makes the update use the repo of the cache instead of its own repo
+func (rb *RepoBuilder) useUpdateRepoCache(update *models.UpdateTransaction, cache *models.UpdateRepoCache) (*models.UpdateTransaction, error) {
+	ownRepo := update.Repo
+	if result := db.DB.Model(&models.UpdateRepoCache{}).Where("id = ?", cache.ID).
+		Update("reference_count", gorm.Expr("reference_count + ?", 1)); result.Error != nil {
+		return nil, result.Error
+	}
+	cache.ReferenceCount++
+	update.RepoID = cache.RepoID
+	update.Repo = cache.Repo
+	update.HoldsRepoCache = true
+	if err := db.DB.Omit("Repo").Save(&update).Error; err != nil {
+		return nil, err
+	}
+	if ownRepo.ID != cache.RepoID {
+		if err := db.DB.Delete(ownRepo).Error; err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error deleting the unused update repo")
+		}
+	}
+	return update, nil
+}
+
+// acquireUpdateRepoCache counts the update again in the references of the cached repo it uses,
+// when the update used the repo before and released it
+func acquireUpdateRepoCache(update *models.UpdateTransaction) error {
+	result := db.DB.Model(&models.UpdateTransaction{}).Where("id = ? AND holds_repo_cache = ?", update.ID, false).
+		UpdateColumn("holds_repo_cache", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	update.HoldsRepoCache = true
+	return db.DB.Model(&models.UpdateRepoCache{}).Where("repo_id = ?", update.RepoID).
+		Update("reference_count", gorm.Expr("reference_count + ?", 1)).Error
+}
+
+// releaseUpdateRepoCache removes the update from the references of the cached repo it uses, once it is
+// over, the cached repo is removed with the artifacts of the image of its commit once no update uses it
+func releaseUpdateRepoCache(update *models.UpdateTransaction) error {
+	result := db.DB.Model(&models.UpdateTransaction{}).Where("id = ? AND holds_repo_cache = ?", update.ID, true).
+		UpdateColumn("holds_repo_cache", false)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	update.HoldsRepoCache = false
+	return db.DB.Model(&models.UpdateRepoCache{}).Where("repo_id = ? AND reference_count > 0", update.RepoID).
+		Update("reference_count", gorm.Expr("reference_count - ?", 1)).Error
+}
+
+// PrebuildImageUpdateRepo builds and caches the update repo of the commit of a successful image with the static deltas
+// from the previous successful versions of its image set, the last cfg.UpdateRepoDeltaVersions of them,
+// so that the updates to the image are dispatched without waiting for their repo to be built
+// It returns nil when the image has no previous version or a cached repo already has the static deltas
+func (rb *RepoBuilder) PrebuildImageUpdateRepo(imageID uint) (*models.UpdateRepoCache, error) {
+	cfg := config.Get()
+	var image models.Image
+	if result := db.DB.Joins("Commit").First(&image, imageID); result.Error != nil {
+		return nil, result.Error
+	}
+	rb.log = rb.log.WithFields(log.Fields{"imageID": image.ID, "commitID": image.CommitID})
+	if cfg.UpdateRepoDeltaVersions <= 0 || image.Status != models.ImageStatusSuccess || image.ImageSetID == nil || image.Commit == nil {
+		return nil, nil
+	}
+	var previousImages []models.Image
+	if result := db.DB.Joins("Commit").
+		Where("images.image_set_id = ? AND images.status = ? AND images.version < ? AND images.commit_id <> ?",
+			*image.ImageSetID, models.ImageStatusSuccess, image.Version, image.CommitID).
+		Order("images.version DESC").Limit(cfg.UpdateRepoDeltaVersions).Find(&previousImages); result.Error != nil {
+		return nil, result.Error
+	}
+	var fromCommits []models.Commit
+	for _, previousImage := range previousImages {
+		if previousImage.Commit != nil {
+			fromCommits = append(fromCommits, *previousImage.Commit)
+		}
+	}
+	if len(fromCommits) == 0 {
+		rb.log.Debug("Image has no previous version to prebuild an update repo from")
+		return nil, nil
+	}
+
+	signingKey, err := GetAccountSigningKey(image.Account)
+	if err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error getting signing key")
+		return nil, err
+	}
+	var caches []models.UpdateRepoCache
+	if result := db.DB.Preload("FromCommits").
+		Joins("JOIN repos ON repos.id = update_repo_caches.repo_id").
+		Where("update_repo_caches.account = ? AND update_repo_caches.commit_id = ? AND update_repo_caches.signing_key_id = ? AND repos.status = ?",
+			image.Account, image.CommitID, signingKeyID(signingKey), models.RepoStatusSuccess).Find(&caches); result.Error != nil {
+		return nil, result.Error
+	}
+	for i := range caches {
+		if len(missingFromCommits(&caches[i], fromCommits)) == 0 {
+			rb.log.WithField("repoID", caches[i].RepoID).Info("Image update repo was already built")
+			return nil, nil
+		}
+	}
+
+	repo := &models.Repo{Status: models.RepoStatusBuilding}
+	if result := db.DB.Create(repo); result.Error != nil {
+		return nil, result.Error
+	}
+	path := updateRepoPath(repo.ID)
+	rb.log.WithFields(log.Fields{"path": path, "fromCommits": len(fromCommits)}).Info("Prebuilding image update repo")
+	repoURL, err := rb.prebuildUpdateRepo(path, &image, fromCommits, signingKey)
 	if err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error prebuilding image update repo")
+		repo.Status = models.RepoStatusError
+		if result := db.DB.Save(repo); result.Error != nil {
+			rb.log.WithField("error", result.Error.Error()).Error("Error saving update repo status")
+		}
+		if err := os.RemoveAll(path); err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error removing update repo workspace")
+		}
+		return nil, err
+	}
+	repo.URL = repoURL
+	repo.Status = models.RepoStatusSuccess
+	if result := db.DB.Save(repo); result.Error != nil {
+		return nil, result.Error
+	}
+	cache := &models.UpdateRepoCache{
+		Account:     image.Account,
+		CommitID:    image.CommitID,
+		FromCommits: fromCommits,
+		RepoID:       repo.ID,
+		Repo:         repo,
+		Version:      1,
+		SigningKeyID: signingKeyID(signingKey),
+	}
+	if err := db.DB.Omit("FromCommits.*", "Repo").Create(cache).Error; err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error caching image update repo")
 		return nil, err
 	}
-	tarFileName, err := rb.DownloadVersionRepo(update.Commit, path)
+	if err := writeUpdateRepoVersion(path, cache.Version); err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error writing the version of the cached update repo")
+	}
+	rb.log.WithField("repoID", repo.ID).Info("Image update repo was prebuilt")
+	return cache, nil
+}
+
+// prebuildUpdateRepo builds in path the update repo of the commit of the image with the static deltas
+// from the commits, signed with the signing key when there is one, and uploads it, it returns the URL of the uploaded repo
+func (rb *RepoBuilder) prebuildUpdateRepo(path string, image *models.Image, fromCommits []models.Commit, signingKey *SigningKey) (string, error) {
+	if err := os.MkdirAll(path, os.FileMode(int(0755))); err != nil {
+		return "", err
+	}
+	if _, err := rb.buildStaticDeltasRepo(path, signingKey, image.Commit, fromCommits, func() (bool, error) {
+		return false, nil
+	}); err != nil {
+		return "", err
+	}
+	rb.log.Info("Upload repo")
+	repoURL, err := rb.filesService.GetUploader().UploadRepo(filepath.Clean(filepath.Join(path, "repo")), image.Account)
+	if err != nil {
+		return "", err
+	}
+	rb.log.Info("Finished uploading repo")
+	return repoURL, nil
+}
+
+// buildStaticDeltasRepo builds in path the repo of the commit with the static deltas from the old commits,
+// signed with the signing key when there is one
+// isCancelled is called before each download, the build stops when it returns true or an error
+func (rb *RepoBuilder) buildStaticDeltasRepo(path string, signingKey *SigningKey, commit *models.Commit, oldCommits []models.Commit, isCancelled func() (bool, error)) (bool, error) {
+	if cancelled, err := isCancelled(); err != nil || cancelled {
+		return cancelled, err
+	}
+	tarFileName, err := rb.DownloadVersionRepo(commit, path)
 	if err != nil {
 		rb.log.WithField("error", err.Error()).Error("Error downloading tar")
-		return nil, fmt.Errorf("error Upload repo repo :: %s", err.Error())
+		return false, fmt.Errorf("error Upload repo repo :: %s", err.Error())
 	}
-	err = rb.ExtractVersionRepo(update.Commit, tarFileName, path)
+	err = rb.ExtractVersionRepo(commit, tarFileName, path)
 	if err != nil {
 		rb.log.WithField("error", err.Error()).Error("Error extracting tar")
-		return nil, fmt.Errorf("error extracting repo :: %s", err.Error())
+		return false, fmt.Errorf("error extracting repo :: %s", err.Error())
 	}
 
-	if len(update.OldCommits) > 0 {
+	if cancelled, err := rb.pullStaticDeltas(path, commit, oldCommits, isCancelled); err != nil || cancelled {
+		return cancelled, err
+	}
+	return false, rb.signUpdateRepo(path, signingKey, commit)
+}
+
+// pullStaticDeltas pulls the old commits into the repo of the commit in path and generates
+// the static deltas from them
+func (rb *RepoBuilder) pullStaticDeltas(path string, commit *models.Commit, oldCommits []models.Commit, isCancelled func() (bool, error)) (bool, error) {
+	if len(oldCommits) > 0 {
 		stagePath := filepath.Clean(filepath.Join(path, "staging"))
-		err = os.MkdirAll(stagePath, os.FileMode(int(0755)))
+		err := os.MkdirAll(stagePath, os.FileMode(int(0755)))
 		if err != nil {
 			rb.log.WithField("error", err.Error()).Error("Error making dir")
-			return nil, fmt.Errorf("error mkdir :: %s", err.Error())
-		}
-		err = os.Chdir(stagePath)
-		if err != nil {
-			rb.log.WithField("error", err.Error()).Error("Error changing dir")
-			return nil, fmt.Errorf("error chdir :: %s", err.Error())
+			return false, fmt.Errorf("error mkdir :: %s", err.Error())
 		}
 
 		// If there are any old commits, we need to download them all to be merged
 		// into the update commit repo
-		for _, commit := range update.OldCommits {
-			commit := commit // this will prevent implicit memory aliasing in the loop
-			tarFileName, err := rb.DownloadVersionRepo(&commit, filepath.Clean(filepath.Join(stagePath, commit.OSTreeCommit)))
+		for _, oldCommit := range oldCommits {
+			oldCommit := oldCommit // this will prevent implicit memory aliasing in the loop
+			if cancelled, err := isCancelled(); err != nil || cancelled {
+				return cancelled, err
+			}
+			oldCommitPath := filepath.Clean(filepath.Join(stagePath, oldCommit.OSTreeCommit))
+			tarFileName, err := rb.DownloadVersionRepo(&oldCommit, oldCommitPath)
 			if err != nil {
 				rb.log.WithField("error", err.Error()).Error("Error downloading tar")
-				return nil, fmt.Errorf("error Upload repo repo :: %s", err.Error())
+				return false, fmt.Errorf("error Upload repo repo :: %s", err.Error())
 			}
-			err = rb.ExtractVersionRepo(update.Commit, tarFileName, path)
+			err = rb.ExtractVersionRepo(&oldCommit, tarFileName, oldCommitPath)
 			if err != nil {
 				rb.log.WithField("error", err.Error()).Error("Error extracing repo")
-				return nil, err
+				return false, err
 			}
 			// FIXME: hardcoding "repo" in here because that's how it comes from osbuild
-			err = rb.repoPullLocalStaticDeltas(update.Commit, &commit, filepath.Clean(filepath.Join(path, "repo")),
-				filepath.Clean(filepath.Join(stagePath, commit.OSTreeCommit, "repo")))
+			err = rb.repoPullLocalStaticDeltas(commit, &oldCommit, filepath.Clean(filepath.Join(path, "repo")),
+				filepath.Clean(filepath.Join(oldCommitPath, "repo")))
 			if err != nil {
 				rb.log.WithField("error", err.Error()).Error("Error pulling static deltas")
-				return nil, err
+				return false, err
 			}
 		}
 
@@ -132,29 +603,113 @@ func (rb *RepoBuilder) BuildUpdateRepo(id uint) (*models.UpdateTransaction, erro
 		// anymore.
 		err = os.RemoveAll(stagePath)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
 
 	}
-	// NOTE: This relies on the file path being cfg.RepoTempPath/models.Repo.ID/
+	return false, nil
+}
 
-	rb.log.Info("Upload repo")
-	repoURL, err := rb.filesService.GetUploader().UploadRepo(filepath.Clean(filepath.Join(path, "repo")), strconv.FormatUint(uint64(update.ID), 10))
-	rb.log.Info("Finished uploading repo")
+// signUpdateRepo signs the commit of the repo in path with the signing key when there is one
+func (rb *RepoBuilder) signUpdateRepo(path string, signingKey *SigningKey, commit *models.Commit) error {
+	if signingKey != nil {
+		rb.log.WithField("keyID", signingKey.KeyID).Info("Signing commit")
+		err := RepoSign(filepath.Clean(filepath.Join(path, "repo")), commit.OSTreeRef, signingKey)
+		if err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error signing commit")
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildUpdateBundle builds the repo of the commit of an update bundle with the static deltas from its
+// source commits, as the update repos are, and uploads it with its manifest, checksums and apply script
+// as a tarball to the storage
+func (rb *RepoBuilder) BuildUpdateBundle(bundle *models.UpdateBundle) error {
+	if bundle.Commit == nil {
+		rb.log.Error("nil pointer to models.UpdateBundle.Commit provided")
+		return errors.New("invalid models.UpdateBundle.Commit Provided: nil pointer")
+	}
+	rb.log = rb.log.WithFields(log.Fields{"commitID": bundle.Commit.ID, "updateBundleID": bundle.ID})
+	rb.log.Info("Starts building update bundle...")
+	cfg := config.Get()
+	bundleName := fmt.Sprintf("update-bundle-%d", bundle.ID)
+	workPath := filepath.Clean(filepath.Join(cfg.RepoTempPath, "bundles/", strconv.FormatUint(uint64(bundle.ID), 10)))
+	path := filepath.Clean(filepath.Join(workPath, bundleName))
+	if err := os.MkdirAll(path, os.FileMode(int(0755))); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(workPath); err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error removing update bundle workspace")
+		}
+	}()
+	signingKey, err := GetAccountSigningKey(bundle.Account)
 	if err != nil {
-		return nil, err
+		rb.log.WithField("error", err.Error()).Error("Error getting signing key")
+		return err
+	}
+	_, err = rb.buildStaticDeltasRepo(path, signingKey, bundle.Commit, bundle.SourceCommits, func() (bool, error) {
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeUpdateBundleFiles(path, bundle); err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error writing update bundle files")
+		return err
 	}
 
-	update.Repo.URL = repoURL
-	update.Repo.Status = models.RepoStatusSuccess
-	if err := db.DB.Save(&update).Error; err != nil {
-		return nil, err
+	tarFileName := filepath.Clean(filepath.Join(workPath, bundleName+".tar.gz"))
+	cmd := exec.Command("tar", "-C", workPath, "-czf", tarFileName, bundleName) //#nosec G204 - the paths are built from the bundle ID
+	if output, err := cmd.CombinedOutput(); err != nil {
+		rb.log.WithFields(log.Fields{"error": err.Error(), "output": string(output)}).Error("Error archiving update bundle")
+		return err
 	}
-	if err := db.DB.Save(&update.Repo).Error; err != nil {
-		return nil, err
+	checksum, size, err := fileChecksum(tarFileName)
+	if err != nil {
+		return err
 	}
+	rb.log.Info("Upload update bundle")
+	uploadPath := fmt.Sprintf("%s/bundles/%s.tar.gz", bundle.Account, bundleName)
+	if _, err := rb.filesService.GetUploader().UploadFile(tarFileName, uploadPath); err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error uploading update bundle")
+		return err
+	}
+	rb.log.Info("Finished uploading update bundle")
 
-	return update, nil
+	bundle.FilePath = uploadPath
+	bundle.Checksum = checksum
+	bundle.Size = size
+	bundle.Status = models.UpdateBundleStatusSuccess
+	return db.DB.Omit("Commit", "SourceCommits").Save(bundle).Error
+}
+
+// abortUpdateRepo cleans up the workspace and the uploaded files of the repo of a cancelled update
+// err is the error that happened while finding out whether the update was cancelled, if any
+// path is empty when the update was adding its static deltas to a cached repo, which is left as it is
+func (rb *RepoBuilder) abortUpdateRepo(update *models.UpdateTransaction, path string, uploaded bool, err error) error {
+	if err != nil {
+		rb.log.WithField("error", err.Error()).Error("Error reloading update status")
+		return err
+	}
+	rb.log.Info("Update was cancelled, aborting the update repo build")
+	if uploaded {
+		if err := rb.filesService.GetUploader().DeleteRepo(filepath.Clean(filepath.Join(path, "repo")), update.Account); err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error deleting uploaded repo")
+		}
+	}
+	if path != "" {
+		if err := os.RemoveAll(path); err != nil {
+			rb.log.WithField("error", err.Error()).Error("Error removing update repo workspace")
+		}
+	}
+	update.Repo.Status = models.RepoStatusError
+	if result := db.DB.Save(update.Repo); result.Error != nil {
+		rb.log.WithField("error", result.Error.Error()).Error("Error saving update repo status")
+	}
+	return new(UpdateCancelled)
 }
 
 // ImportRepo (unpack and upload) a single repo
@@ -173,10 +728,6 @@ func (rb *RepoBuilder) ImportRepo(r *models.Repo) (*models.Repo, error) {
 		log.Error(err)
 		return nil, err
 	}
-	err = os.Chdir(path)
-	if err != nil {
-		return nil, err
-	}
 
 	tarFileName, err := rb.DownloadVersionRepo(&cmt, path)
 	if err != nil {
@@ -240,10 +791,6 @@ func (rb *RepoBuilder) DownloadVersionRepo(c *models.Commit, dest string) (strin
 	if err != nil {
 		return "", err
 	}
-	err = os.Chdir(dest)
-	if err != nil {
-		return "", err
-	}
 
 	// Save the tarball to the OSBuild Hash ID and then extract it
 	tarFileName := "repo.tar"
@@ -297,6 +844,9 @@ func (rb *RepoBuilder) UploadVersionRepo(c *models.Commit, tarFileName string) e
 		return err
 	}
 	c.ImageBuildTarURL = repoTarURL
+	if info, err := os.Stat(tarFileName); err == nil {
+		c.TarballSize = info.Size()
+	}
 	result := db.DB.Save(c)
 	if result.Error != nil {
 		rb.log.WithField("error", result.Error.Error()).Error("Error saving tar file")
@@ -336,6 +886,7 @@ func (rb *RepoBuilder) ExtractVersionRepo(c *models.Commit, tarFileName string,
 		return err
 	}
 
+	// the jobs build repos concurrently, the command runs in dest instead of the working directory of the process
 	var cmd *exec.Cmd
 	if c.OSTreeRef == "" {
 		cfg := config.Get()
@@ -344,6 +895,7 @@ func (rb *RepoBuilder) ExtractVersionRepo(c *models.Commit, tarFileName string,
 			Args: []string{
 				"--repo", "./repo", "commit", cfg.DefaultOSTreeRef, "--add-metadata-string", fmt.Sprintf("version=%s.%d", c.BuildDate, c.BuildNumber),
 			},
+			Dir: dest,
 		}
 	} else {
 		cmd = &exec.Cmd{
@@ -351,6 +903,7 @@ func (rb *RepoBuilder) ExtractVersionRepo(c *models.Commit, tarFileName string,
 			Args: []string{
 				"--repo", "./repo", "commit", c.OSTreeRef, "--add-metadata-string", fmt.Sprintf("version=%s.%d", c.BuildDate, c.BuildNumber),
 			},
+			Dir: dest,
 		}
 	}
 	err = cmd.Run()
@@ -367,11 +920,6 @@ func (rb *RepoBuilder) ExtractVersionRepo(c *models.Commit, tarFileName string,
 //  uprepo should be where the update commit lives, u is the update commit
 //  oldrepo should be where the old commit lives, o is the commit to be merged
 func (rb *RepoBuilder) repoPullLocalStaticDeltas(u *models.Commit, o *models.Commit, uprepo string, oldrepo string) error {
-	err := os.Chdir(uprepo)
-	if err != nil {
-		return err
-	}
-
 	updateRevParse, err := RepoRevParse(uprepo, u.OSTreeRef)
 	if err != nil {
 		return err
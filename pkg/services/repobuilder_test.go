@@ -6,13 +6,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/bxcodec/faker/v3"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	"github.com/redhatinsights/edge-api/pkg/services"
 	log "github.com/sirupsen/logrus"
@@ -55,6 +59,229 @@ var _ = Describe("RepoBuilder Service Test", func() {
 			})
 		})
 	})
+	Describe("#BuildUpdateRepo", func() {
+		When("the update was cancelled", func() {
+			It("should abort and clean up the workspace", func() {
+				update := models.UpdateTransaction{
+					Account: faker.UUIDHyphenated(),
+					Status:  models.UpdateStatusCancelled,
+					Commit:  &models.Commit{OSTreeCommit: faker.UUIDHyphenated()},
+					Repo:    &models.Repo{Status: models.RepoStatusBuilding},
+				}
+				Expect(db.DB.Create(&update).Error).ToNot(HaveOccurred())
+
+				_, err := service.BuildUpdateRepo(update.ID)
+				Expect(err).To(MatchError(new(services.UpdateCancelled)))
+
+				path := filepath.Join(config.Get().RepoTempPath, "upd", fmt.Sprint(update.ID))
+				_, err = os.Stat(path)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+				var repo models.Repo
+				db.DB.First(&repo, update.RepoID)
+				Expect(repo.Status).To(Equal(models.RepoStatusError))
+			})
+		})
+		When("the commit has a cached repo", func() {
+			var account string
+			var commit, oldCommit, otherOldCommit models.Commit
+			var cache models.UpdateRepoCache
+			BeforeEach(func() {
+				account = faker.UUIDHyphenated()
+				commit = models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+				oldCommit = models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+				otherOldCommit = models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+				Expect(db.DB.Create(&[]*models.Commit{&commit, &oldCommit, &otherOldCommit}).Error).ToNot(HaveOccurred())
+				cache = models.UpdateRepoCache{
+					Account:        account,
+					CommitID:       commit.ID,
+					FromCommits:    []models.Commit{oldCommit},
+					Repo:           &models.Repo{URL: faker.URL(), Status: models.RepoStatusSuccess},
+					ReferenceCount: 1,
+				}
+				Expect(db.DB.Omit("FromCommits.*").Create(&cache).Error).ToNot(HaveOccurred())
+			})
+			newUpdate := func(oldCommits ...models.Commit) models.UpdateTransaction {
+				update := models.UpdateTransaction{
+					Account:    account,
+					Status:     models.UpdateStatusBuilding,
+					CommitID:   commit.ID,
+					OldCommits: oldCommits,
+					Repo:       &models.Repo{Status: models.RepoStatusBuilding},
+				}
+				Expect(db.DB.Omit("OldCommits.*").Create(&update).Error).ToNot(HaveOccurred())
+				return update
+			}
+			It("should use the cached repo having the static deltas from the old commits", func() {
+				update := newUpdate(oldCommit)
+
+				result, err := service.BuildUpdateRepo(update.ID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.RepoID).To(Equal(cache.RepoID))
+				Expect(result.Repo.URL).To(Equal(cache.Repo.URL))
+
+				var saved models.UpdateTransaction
+				Expect(db.DB.First(&saved, update.ID).Error).ToNot(HaveOccurred())
+				Expect(saved.RepoID).To(Equal(cache.RepoID))
+				Expect(saved.HoldsRepoCache).To(BeTrue())
+				Expect(db.DB.First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ReferenceCount).To(Equal(2))
+				// the repo created with the update isn't used anymore
+				Expect(db.DB.First(&models.Repo{}, update.RepoID).Error).To(HaveOccurred())
+
+				// building the update again doesn't count it twice
+				_, err = service.BuildUpdateRepo(update.ID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(db.DB.First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ReferenceCount).To(Equal(2))
+			})
+			Context("when the account got a signing key after the repo was cached", func() {
+				var keysPath, previousKeysPath string
+				BeforeEach(func() {
+					var err error
+					keysPath, err = ioutil.TempDir("", "gpg-keys")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(os.MkdirAll(filepath.Join(keysPath, account), 0700)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(keysPath, account, "key_id"), []byte("ACCOUNTKEY\n"), 0600)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(keysPath, account, "public.asc"), []byte("public key"), 0600)).To(Succeed())
+					previousKeysPath = config.Get().GpgKeysPath
+					config.Get().GpgKeysPath = keysPath
+				})
+				AfterEach(func() {
+					config.Get().GpgKeysPath = previousKeysPath
+					os.RemoveAll(keysPath)
+				})
+				It("should not use the unsigned cached repo", func() {
+					update := newUpdate(oldCommit)
+
+					// the repo builder builds a new repo and fails to download the commit
+					_, err := service.BuildUpdateRepo(update.ID)
+					Expect(err).To(HaveOccurred())
+
+					Expect(db.DB.First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+					Expect(cache.ReferenceCount).To(Equal(1))
+					var saved models.UpdateTransaction
+					Expect(db.DB.First(&saved, update.ID).Error).ToNot(HaveOccurred())
+					Expect(saved.RepoID).To(Equal(update.RepoID))
+					Expect(saved.HoldsRepoCache).To(BeFalse())
+				})
+				It("should use the cached repo signed with the key", func() {
+					Expect(db.DB.Model(&cache).Update("signing_key_id", "ACCOUNTKEY").Error).ToNot(HaveOccurred())
+					update := newUpdate(oldCommit)
+
+					result, err := service.BuildUpdateRepo(update.ID)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RepoID).To(Equal(cache.RepoID))
+				})
+			})
+			It("should release the cached repo when adding the missing static deltas fails", func() {
+				update := newUpdate(oldCommit, otherOldCommit)
+
+				_, err := service.BuildUpdateRepo(update.ID)
+				Expect(err).To(HaveOccurred())
+
+				Expect(db.DB.Preload("FromCommits").First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ExtendingOwner).To(BeEmpty())
+				Expect(cache.ExtendingUntil.Valid).To(BeFalse())
+				Expect(cache.ReferenceCount).To(Equal(1))
+				Expect(cache.FromCommits).To(HaveLen(1))
+				Expect(cache.Version).To(BeZero())
+				var saved models.UpdateTransaction
+				Expect(db.DB.First(&saved, update.ID).Error).ToNot(HaveOccurred())
+				Expect(saved.RepoID).To(Equal(update.RepoID))
+			})
+			It("should not change the working directory of the process", func() {
+				// the jobs build the repos concurrently in the same process
+				update := newUpdate(oldCommit, otherOldCommit)
+				wd, err := os.Getwd()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = service.BuildUpdateRepo(update.ID)
+				Expect(err).To(HaveOccurred())
+				Expect(os.Getwd()).To(Equal(wd))
+			})
+			It("should not extend the cached repo leased by another update", func() {
+				until := models.EdgeAPITime{Time: time.Now().UTC().Add(time.Hour), Valid: true}
+				Expect(db.DB.Model(&cache).Updates(map[string]interface{}{"extending_owner": "update-0", "extending_until": until}).Error).ToNot(HaveOccurred())
+				update := newUpdate(oldCommit, otherOldCommit)
+
+				_, err := service.BuildUpdateRepo(update.ID)
+				Expect(err).To(HaveOccurred())
+
+				Expect(db.DB.First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ExtendingOwner).To(Equal("update-0"))
+			})
+			It("should extend the cached repo whose lease expired", func() {
+				until := models.EdgeAPITime{Time: time.Now().UTC().Add(-time.Minute), Valid: true}
+				Expect(db.DB.Model(&cache).Updates(map[string]interface{}{"extending_owner": "update-0", "extending_until": until}).Error).ToNot(HaveOccurred())
+				update := newUpdate(oldCommit, otherOldCommit)
+
+				// the repo builder fails to download the commit but released the lease it took over
+				_, err := service.BuildUpdateRepo(update.ID)
+				Expect(err).To(HaveOccurred())
+
+				Expect(db.DB.First(&cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ExtendingOwner).To(BeEmpty())
+				Expect(cache.ExtendingUntil.Valid).To(BeFalse())
+			})
+		})
+	})
+	Describe("#PrebuildImageUpdateRepo", func() {
+		var account string
+		var imageSet models.ImageSet
+		newImage := func(version int, status string) *models.Image {
+			image := &models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    version,
+				Status:     status,
+				Commit:     &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()},
+			}
+			Expect(db.DB.Create(image).Error).ToNot(HaveOccurred())
+			return image
+		}
+		BeforeEach(func() {
+			account = faker.UUIDHyphenated()
+			imageSet = models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			Expect(db.DB.Create(&imageSet).Error).ToNot(HaveOccurred())
+		})
+		It("should not build a repo for the first version", func() {
+			image := newImage(1, models.ImageStatusSuccess)
+
+			cache, err := service.PrebuildImageUpdateRepo(image.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache).To(BeNil())
+		})
+		It("should not build a repo already cached with the static deltas from the previous versions", func() {
+			previous := newImage(1, models.ImageStatusSuccess)
+			newImage(2, models.ImageStatusError)
+			image := newImage(3, models.ImageStatusSuccess)
+			cached := models.UpdateRepoCache{
+				Account:     account,
+				CommitID:    image.CommitID,
+				FromCommits: []models.Commit{*previous.Commit},
+				Repo:        &models.Repo{URL: faker.URL(), Status: models.RepoStatusSuccess},
+			}
+			Expect(db.DB.Omit("FromCommits.*").Create(&cached).Error).ToNot(HaveOccurred())
+
+			cache, err := service.PrebuildImageUpdateRepo(image.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cache).To(BeNil())
+		})
+		It("should set the error status on the repo it failed to build", func() {
+			newImage(1, models.ImageStatusSuccess)
+			image := newImage(2, models.ImageStatusSuccess)
+
+			_, err := service.PrebuildImageUpdateRepo(image.ID)
+			Expect(err).To(HaveOccurred())
+
+			var count int64
+			Expect(db.DB.Model(&models.UpdateRepoCache{}).Where("commit_id = ?", image.CommitID).Count(&count).Error).ToNot(HaveOccurred())
+			Expect(count).To(BeZero())
+			var repo models.Repo
+			Expect(db.DB.Last(&repo).Error).ToNot(HaveOccurred())
+			Expect(repo.Status).To(Equal(models.RepoStatusError))
+		})
+	})
 })
 
 func createTarball(tarballFilePath string, filePath string) error {
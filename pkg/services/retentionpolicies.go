@@ -0,0 +1,150 @@
+package services
+
+import (
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// getRetentionPolicy returns the retention policy of the account, or of the image set when imageSetID is set
+func getRetentionPolicy(account string, imageSetID *uint) (*models.RetentionPolicy, error) {
+	var policy models.RetentionPolicy
+	query := db.DB.Where("account = ?", account)
+	if imageSetID == nil {
+		query = query.Where("image_set_id IS NULL")
+	} else {
+		query = query.Where("image_set_id = ?", *imageSetID)
+	}
+	if result := query.Limit(1).Find(&policy); result.Error != nil {
+		return nil, result.Error
+	}
+	if policy.ID == 0 {
+		return nil, new(RetentionPolicyNotFound)
+	}
+	return &policy, nil
+}
+
+// retentionPolicyImageSets returns the IDs of the image sets a retention policy applies to: its image set,
+// or the image sets of its account without a retention policy of their own
+func retentionPolicyImageSets(policy *models.RetentionPolicy) ([]uint, error) {
+	var imageSetIDs []uint
+	query := db.DB.Model(&models.ImageSet{}).Where("account = ?", policy.Account)
+	if policy.ImageSetID != nil {
+		query = query.Where("id = ?", *policy.ImageSetID)
+	} else {
+		query = query.Where("id NOT IN (?)", db.DB.Model(&models.RetentionPolicy{}).Select("image_set_id").
+			Where("account = ? AND image_set_id IS NOT NULL", policy.Account))
+	}
+	if result := query.Order("id").Pluck("id", &imageSetIDs); result.Error != nil {
+		return nil, result.Error
+	}
+	return imageSetIDs, nil
+}
+
+// retentionProtectedImages returns the IDs of the versions of an image set, ordered from the newest, the retention
+// policies never remove: the versions deployed on a device and their rollback targets, the latest successful
+// version older than them as GetRollbackImage returns to roll their devices back
+func retentionProtectedImages(images []models.Image) (map[uint]bool, error) {
+	protected := map[uint]bool{}
+	if len(images) == 0 {
+		return protected, nil
+	}
+	imageIDs := make([]uint, 0, len(images))
+	for _, image := range images {
+		imageIDs = append(imageIDs, image.ID)
+	}
+	var deployedImageIDs []uint
+	if result := db.DB.Model(&models.Device{}).Distinct("image_id").Where("image_id IN ?", imageIDs).
+		Pluck("image_id", &deployedImageIDs); result.Error != nil {
+		return nil, result.Error
+	}
+	deployed := map[uint]bool{}
+	for _, id := range deployedImageIDs {
+		deployed[id] = true
+	}
+	for i, image := range images {
+		if !deployed[image.ID] {
+			continue
+		}
+		protected[image.ID] = true
+		for _, older := range images[i+1:] {
+			if older.Status == models.ImageStatusSuccess && older.Version < image.Version {
+				protected[older.ID] = true
+				break
+			}
+		}
+	}
+	return protected, nil
+}
+
+// applyRetentionPolicyToImageSet removes the versions of an image set older than the last successful versions
+// the retention policy keeps, the versions protected, still building or in an update in progress are kept
+// It returns the report of the versions removed, nil when no version was removed
+func applyRetentionPolicyToImageSet(policy *models.RetentionPolicy, imageSetID uint, jobService JobServiceInterface, log *log.Entry) (*models.RetentionReport, error) {
+	logger := log.WithFields(map[string]interface{}{"retentionPolicyID": policy.ID, "imageSetID": imageSetID})
+	// the versions are ordered as GetRollbackImage looks for the latest successful version before the one of a device
+	var images []models.Image
+	if result := db.DB.Where("account = ? AND image_set_id = ?", policy.Account, imageSetID).Order("version DESC, id DESC").
+		Find(&images); result.Error != nil {
+		return nil, result.Error
+	}
+	protected, err := retentionProtectedImages(images)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.RetentionReport{
+		Account:           policy.Account,
+		ImageSetID:        imageSetID,
+		RetentionPolicyID: policy.ID,
+		KeepVersions:      policy.KeepVersions,
+	}
+	var removed []models.Image
+	keptSuccessful := 0
+	for _, image := range images {
+		if keptSuccessful < policy.KeepVersions {
+			if image.Status == models.ImageStatusSuccess {
+				keptSuccessful++
+			}
+			continue
+		}
+		if protected[image.ID] || (image.Status != models.ImageStatusSuccess && image.Status != models.ImageStatusError) {
+			report.ProtectedVersions++
+			continue
+		}
+		images := []models.Image{image}
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := checkImagesCanBeDeleted(tx, images); err != nil {
+				return err
+			}
+			return deleteImages(tx, images)
+		}); err != nil {
+			switch err.(type) {
+			case *ImageInUse, *ImageHasUpdateInProgress, *ImageIsBuilding:
+				report.ProtectedVersions++
+				continue
+			}
+			return nil, err
+		}
+		removed = append(removed, image)
+		report.RemovedImages = append(report.RemovedImages, models.RetentionReportImage{
+			ImageID: image.ID,
+			Name:    image.Name,
+			Version: image.Version,
+			Status:  image.Status,
+		})
+	}
+	logger = logger.WithFields(map[string]interface{}{"removedVersions": len(removed), "protectedVersions": report.ProtectedVersions})
+	if len(removed) == 0 {
+		logger.Debug("Retention policy removed no version of the image set")
+		return nil, nil
+	}
+	enqueueImageArtifactsDelete(jobService, logger, removed)
+	if result := db.DB.Create(report); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error saving retention report")
+		return nil, result.Error
+	}
+	logger.WithField("retentionReportID", report.ID).Info("Retention policy removed old versions of the image set")
+	return report, nil
+}
@@ -0,0 +1,195 @@
+package services_test
+
+import (
+	"context"
+
+	"github.com/bxcodec/faker/v3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"github.com/redhatinsights/edge-api/pkg/routes/common"
+	"github.com/redhatinsights/edge-api/pkg/services"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var _ = Describe("Retention policies", func() {
+	var service services.ImageSetsServiceInterface
+	BeforeEach(func() {
+		service = services.NewImageSetsService(context.Background(), log.NewEntry(log.StandardLogger()))
+	})
+	// newImageSet creates an image set with a version for every status given, from the oldest
+	newImageSet := func(account string, statuses ...string) (*models.ImageSet, []models.Image) {
+		imageSet := &models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+		Expect(db.DB.Create(imageSet).Error).ToNot(HaveOccurred())
+		images := make([]models.Image, 0, len(statuses))
+		for i, status := range statuses {
+			image := models.Image{Account: account, Name: imageSet.Name, ImageSetID: &imageSet.ID, Version: i + 1, Status: status,
+				Commit: &models.Commit{Account: account, Status: status}}
+			Expect(db.DB.Create(&image).Error).ToNot(HaveOccurred())
+			images = append(images, image)
+		}
+		return imageSet, images
+	}
+	isDeleted := func(image models.Image) bool {
+		err := db.DB.First(&models.Image{}, image.ID).Error
+		if err != nil {
+			Expect(err).To(MatchError(gorm.ErrRecordNotFound))
+		}
+		return err != nil
+	}
+
+	It("should keep the last successful versions and the versions after them", func() {
+		account := faker.UUIDHyphenated()
+		_, images := newImageSet(account, models.ImageStatusError, models.ImageStatusSuccess, models.ImageStatusSuccess,
+			models.ImageStatusSuccess, models.ImageStatusError)
+		policy, err := service.SetRetentionPolicy(account, nil, 2)
+		Expect(err).ToNot(HaveOccurred())
+
+		reports, err := service.ApplyRetentionPolicy(policy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].RemovedImages).To(HaveLen(2))
+		Expect(reports[0].RemovedImages[0].ImageID).To(Equal(images[1].ID))
+		Expect(reports[0].RemovedImages[1].ImageID).To(Equal(images[0].ID))
+		for i, deleted := range []bool{true, true, false, false, false} {
+			Expect(isDeleted(images[i])).To(Equal(deleted))
+		}
+		var count int64
+		Expect(db.DB.Model(&models.Job{}).Where("type = ? AND resource_id IN ?", models.JobTypeImageArtifactsDelete,
+			[]uint{images[0].ID, images[1].ID}).Count(&count).Error).ToNot(HaveOccurred())
+		Expect(count).To(Equal(int64(2)))
+
+		storedReports, err := service.GetRetentionReports(account, 10, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedReports).To(HaveLen(1))
+		Expect(storedReports[0].RemovedImages).To(HaveLen(2))
+
+		// applying the policy again removes nothing
+		reports, err = service.ApplyRetentionPolicy(policy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(BeEmpty())
+	})
+	It("should keep the versions deployed and their rollback targets", func() {
+		imageSet, images := newImageSet(common.DefaultAccount, models.ImageStatusSuccess, models.ImageStatusSuccess,
+			models.ImageStatusError, models.ImageStatusSuccess, models.ImageStatusSuccess, models.ImageStatusSuccess)
+		Expect(db.DB.Create(&models.Device{Account: common.DefaultAccount, ImageID: images[3].ID}).Error).ToNot(HaveOccurred())
+		policy, err := service.SetRetentionPolicy(common.DefaultAccount, &imageSet.ID, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		reports, err := service.ApplyRetentionPolicy(policy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].ProtectedVersions).To(Equal(2))
+		for i, deleted := range []bool{true, false, true, false, true, false} {
+			Expect(isDeleted(images[i])).To(Equal(deleted))
+		}
+		imageService := services.NewImageService(context.Background(), log.NewEntry(log.StandardLogger()))
+		rollbackImage, err := imageService.GetRollbackImage(&images[3])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rollbackImage.ID).To(Equal(images[1].ID))
+	})
+	It("should keep the latest successful version before a deployed version after a failed version", func() {
+		account := faker.UUIDHyphenated()
+		imageSet, images := newImageSet(account, models.ImageStatusSuccess, models.ImageStatusError, models.ImageStatusSuccess)
+		device := models.Device{Account: account, UUID: faker.UUIDHyphenated(), ImageID: images[2].ID}
+		Expect(db.DB.Create(&device).Error).ToNot(HaveOccurred())
+		policy, err := service.SetRetentionPolicy(account, &imageSet.ID, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		reports, err := service.ApplyRetentionPolicy(policy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].RemovedImages).To(HaveLen(1))
+		Expect(reports[0].RemovedImages[0].ImageID).To(Equal(images[1].ID))
+		for i, deleted := range []bool{false, true, false} {
+			Expect(isDeleted(images[i])).To(Equal(deleted))
+		}
+		rollback, err := services.NewUpdateService(context.Background(), log.NewEntry(log.StandardLogger())).
+			CreateDeviceRollback(account, device.UUID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rollback.CommitID).To(Equal(images[0].CommitID))
+	})
+	It("should keep the versions of an update in progress", func() {
+		account := faker.UUIDHyphenated()
+		_, images := newImageSet(account, models.ImageStatusSuccess, models.ImageStatusSuccess)
+		Expect(db.DB.Create(&models.UpdateTransaction{Account: account, CommitID: images[0].CommitID,
+			Status: models.UpdateStatusBuilding}).Error).ToNot(HaveOccurred())
+		policy, err := service.SetRetentionPolicy(account, nil, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		reports, err := service.ApplyRetentionPolicy(policy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(BeEmpty())
+		Expect(isDeleted(images[0])).To(BeFalse())
+	})
+	It("should apply the policy of an image set instead of the policy of its account", func() {
+		account := faker.UUIDHyphenated()
+		imageSet, images := newImageSet(account, models.ImageStatusSuccess, models.ImageStatusSuccess, models.ImageStatusSuccess)
+		_, otherImages := newImageSet(account, models.ImageStatusSuccess, models.ImageStatusSuccess, models.ImageStatusSuccess)
+		accountPolicy, err := service.SetRetentionPolicy(account, nil, 1)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = service.SetRetentionPolicy(account, &imageSet.ID, 3)
+		Expect(err).ToNot(HaveOccurred())
+
+		reports, err := service.ApplyRetentionPolicy(accountPolicy.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		for i := range images {
+			Expect(isDeleted(images[i])).To(BeFalse())
+		}
+		for i, deleted := range []bool{true, true, false} {
+			Expect(isDeleted(otherImages[i])).To(Equal(deleted))
+		}
+	})
+	It("should set, get and delete the retention policy of an image set", func() {
+		account := faker.UUIDHyphenated()
+		imageSet, _ := newImageSet(account)
+		_, err := service.GetRetentionPolicy(account, &imageSet.ID)
+		Expect(err).To(MatchError(new(services.RetentionPolicyNotFound)))
+		_, err = service.SetRetentionPolicy(faker.UUIDHyphenated(), &imageSet.ID, 2)
+		Expect(err).To(MatchError(new(services.ImageSetNotFound)))
+
+		_, err = service.SetRetentionPolicy(account, &imageSet.ID, 2)
+		Expect(err).ToNot(HaveOccurred())
+		policy, err := service.SetRetentionPolicy(account, &imageSet.ID, 5)
+		Expect(err).ToNot(HaveOccurred())
+		stored, err := service.GetRetentionPolicy(account, &imageSet.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ID).To(Equal(policy.ID))
+		Expect(stored.KeepVersions).To(Equal(5))
+		_, err = service.GetRetentionPolicy(account, nil)
+		Expect(err).To(MatchError(new(services.RetentionPolicyNotFound)))
+
+		Expect(service.DeleteRetentionPolicy(account, &imageSet.ID)).To(Succeed())
+		_, err = service.GetRetentionPolicy(account, &imageSet.ID)
+		Expect(err).To(MatchError(new(services.RetentionPolicyNotFound)))
+	})
+	It("should enqueue a retention policy once per interval", func() {
+		account := faker.UUIDHyphenated()
+		policy, err := service.SetRetentionPolicy(account, nil, 1)
+		Expect(err).ToNot(HaveOccurred())
+		countJobs := func() int64 {
+			var count int64
+			Expect(db.DB.Model(&models.Job{}).Where("type = ? AND resource_id = ?", models.JobTypeRetentionPolicyApply, policy.ID).
+				Count(&count).Error).ToNot(HaveOccurred())
+			return count
+		}
+
+		_, err = service.EnqueueDueRetentionPolicies()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(countJobs()).To(Equal(int64(1)))
+		_, err = service.EnqueueDueRetentionPolicies()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(countJobs()).To(Equal(int64(1)))
+
+		interval := config.Get().RetentionPolicyInterval
+		config.Get().RetentionPolicyInterval = 0
+		defer func() { config.Get().RetentionPolicyInterval = interval }()
+		_, err = service.EnqueueDueRetentionPolicies()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(countJobs()).To(Equal(int64(2)))
+	})
+})
@@ -3,7 +3,10 @@ package services
 import (
 	"context"
 
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // Service is a blueprint for a service
@@ -19,3 +22,8 @@ type ServiceInterface interface{}
 func NewService(ctx context.Context, log *log.Entry) Service {
 	return Service{ctx: ctx, log: log}
 }
+
+// eventsDB returns a database session recording the status transitions it saves with the actor and message of ctx
+func eventsDB(ctx context.Context) *gorm.DB {
+	return db.DB.WithContext(models.EventContext(ctx))
+}
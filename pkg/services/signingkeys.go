@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhatinsights/edge-api/config"
+)
+
+const (
+	// defaultSigningKeyDir is the signing key directory used by the accounts without their own signing key
+	defaultSigningKeyDir = "default"
+	// signingKeyIDFile is the file of a signing key directory holding the ID of the GPG key
+	signingKeyIDFile = "key_id"
+	// signingPublicKeyFile is the file of a signing key directory holding the ASCII armored public key
+	signingPublicKeyFile = "public.asc"
+)
+
+// SigningKey is the GPG key used to sign the ostree commits of an account
+// Homedir is the GPG home directory holding the private key
+type SigningKey struct {
+	KeyID     string
+	Homedir   string
+	PublicKey []byte
+}
+
+// GetAccountSigningKey returns the signing key of an account
+// The signing keys live in a directory per account under GpgKeysPath, the default directory is used
+// by the accounts without their own signing key. Returns nil when commit signing is not configured.
+func GetAccountSigningKey(account string) (*SigningKey, error) {
+	cfg := config.Get()
+	if cfg.GpgKeysPath == "" {
+		return nil, nil
+	}
+	for _, dir := range []string{account, defaultSigningKeyDir} {
+		if dir == "" {
+			continue
+		}
+		homedir := filepath.Clean(filepath.Join(cfg.GpgKeysPath, dir))
+		if _, err := os.Stat(homedir); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return readSigningKey(homedir)
+	}
+	return nil, nil
+}
+
+// GetPlaybookSigningKey returns the key signing the update playbooks
+// The key directory has the same layout as the account signing keys. Returns nil when playbook signing
+// is not configured.
+func GetPlaybookSigningKey() (*SigningKey, error) {
+	cfg := config.Get()
+	if cfg.PlaybookSigningKeyPath == "" {
+		return nil, nil
+	}
+	return readSigningKey(filepath.Clean(cfg.PlaybookSigningKeyPath))
+}
+
+// readSigningKey reads the signing key of a key directory
+func readSigningKey(homedir string) (*SigningKey, error) {
+	keyID, err := ioutil.ReadFile(filepath.Join(homedir, signingKeyIDFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key id :: %s", err.Error())
+	}
+	publicKey, err := ioutil.ReadFile(filepath.Join(homedir, signingPublicKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing public key :: %s", err.Error())
+	}
+	return &SigningKey{
+		KeyID:     strings.TrimSpace(string(keyID)),
+		Homedir:   homedir,
+		PublicKey: publicKey,
+	}, nil
+}
+
+// RepoSign signs the commit of the ref and updates the signed summary of the repo
+func RepoSign(path string, ref string, key *SigningKey) error {
+	rev, err := RepoRevParse(path, ref)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("ostree", "gpg-sign", "--repo", path, "--gpg-homedir", key.Homedir, rev, key.KeyID)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error signing commit %s :: %s", rev, strings.TrimSpace(stderr.String()))
+	}
+	stderr.Reset()
+	cmd = exec.Command("ostree", "summary", "--repo", path, "--update", "--gpg-sign", key.KeyID, "--gpg-homedir", key.Homedir)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error signing repo summary :: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
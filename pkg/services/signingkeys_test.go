@@ -0,0 +1,96 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/services"
+)
+
+var _ = Describe("Signing keys", func() {
+	var keysPath string
+	var previousKeysPath string
+
+	writeSigningKey := func(dir string, keyID string) {
+		Expect(os.MkdirAll(filepath.Join(keysPath, dir), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(keysPath, dir, "key_id"), []byte(keyID+"\n"), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(keysPath, dir, "public.asc"), []byte("public key "+keyID), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		keysPath, err = ioutil.TempDir("", "gpg-keys")
+		Expect(err).ToNot(HaveOccurred())
+		previousKeysPath = config.Get().GpgKeysPath
+		config.Get().GpgKeysPath = keysPath
+	})
+	AfterEach(func() {
+		config.Get().GpgKeysPath = previousKeysPath
+		os.RemoveAll(keysPath)
+	})
+
+	Context("when commit signing is not configured", func() {
+		It("should not return a signing key", func() {
+			config.Get().GpgKeysPath = ""
+			key, err := services.GetAccountSigningKey("0000000")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(BeNil())
+		})
+	})
+	Context("when the account has its own signing key", func() {
+		It("should return the signing key of the account", func() {
+			writeSigningKey("0000000", "ACCOUNTKEY")
+			writeSigningKey("default", "DEFAULTKEY")
+			key, err := services.GetAccountSigningKey("0000000")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).ToNot(BeNil())
+			Expect(key.KeyID).To(Equal("ACCOUNTKEY"))
+			Expect(key.Homedir).To(Equal(filepath.Join(keysPath, "0000000")))
+			Expect(string(key.PublicKey)).To(Equal("public key ACCOUNTKEY"))
+		})
+	})
+	Context("when the account has no signing key", func() {
+		It("should return the default signing key", func() {
+			writeSigningKey("default", "DEFAULTKEY")
+			key, err := services.GetAccountSigningKey("0000000")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).ToNot(BeNil())
+			Expect(key.KeyID).To(Equal("DEFAULTKEY"))
+		})
+		It("should not return a signing key without default signing key", func() {
+			key, err := services.GetAccountSigningKey("0000000")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(BeNil())
+		})
+	})
+	Context("when the signing key is incomplete", func() {
+		It("should return an error", func() {
+			Expect(os.MkdirAll(filepath.Join(keysPath, "0000000"), 0700)).To(Succeed())
+			_, err := services.GetAccountSigningKey("0000000")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("when playbook signing is configured", func() {
+		AfterEach(func() {
+			config.Get().PlaybookSigningKeyPath = ""
+		})
+		It("should return the playbook signing key", func() {
+			writeSigningKey("playbook", "PLAYBOOKKEY")
+			config.Get().PlaybookSigningKeyPath = filepath.Join(keysPath, "playbook")
+			key, err := services.GetPlaybookSigningKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).ToNot(BeNil())
+			Expect(key.KeyID).To(Equal("PLAYBOOKKEY"))
+			Expect(key.Homedir).To(Equal(filepath.Join(keysPath, "playbook")))
+		})
+		It("should not return a playbook signing key when not configured", func() {
+			key, err := services.GetPlaybookSigningKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/models"
+)
+
+const (
+	// updateBundleManifestFile is the manifest of an update bundle
+	updateBundleManifestFile = "manifest.json"
+	// updateBundleChecksumsFile holds the sha256 checksums of the files of an update bundle
+	updateBundleChecksumsFile = "SHA256SUMS"
+	// updateBundleScriptFile is the script applying an update bundle on a device
+	updateBundleScriptFile = "apply-update.sh"
+	// updateBundleScriptTemplate is the template of the script applying an update bundle
+	updateBundleScriptTemplate = "template_offline_update_bundle.sh"
+	// updateBundleRemoteName is the ostree remote the devices are updated from
+	updateBundleRemoteName = "rhel-edge"
+)
+
+// updateBundleScript is the data of the template of the script applying an update bundle
+type updateBundleScript struct {
+	BundleID   uint
+	RemoteName string
+	Ref        string
+	Commit     string
+}
+
+// writeUpdateBundleFiles writes next to the repo built in path the manifest, the apply script
+// and then the checksums of all the files of the update bundle
+func writeUpdateBundleFiles(path string, bundle *models.UpdateBundle) error {
+	repoPath := filepath.Clean(filepath.Join(path, "repo"))
+	rev, err := RepoRevParse(repoPath, bundle.Commit.OSTreeRef)
+	if err != nil {
+		return fmt.Errorf("error getting update bundle commit :: %s", err.Error())
+	}
+	signingKey, err := GetAccountSigningKey(bundle.Account)
+	if err != nil {
+		return err
+	}
+	manifest := models.UpdateBundleManifest{
+		BundleID:      bundle.ID,
+		Account:       bundle.Account,
+		Ref:           bundle.Commit.OSTreeRef,
+		Commit:        rev,
+		SourceCommits: []string{},
+		Signed:        signingKey != nil,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, commit := range bundle.SourceCommits {
+		manifest.SourceCommits = append(manifest.SourceCommits, commit.OSTreeCommit)
+	}
+	manifestContents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, updateBundleManifestFile), manifestContents, 0600); err != nil {
+		return err
+	}
+
+	if err := writeUpdateBundleScript(filepath.Join(path, updateBundleScriptFile), updateBundleScript{
+		BundleID:   bundle.ID,
+		RemoteName: updateBundleRemoteName,
+		Ref:        manifest.Ref,
+		Commit:     manifest.Commit,
+	}); err != nil {
+		return err
+	}
+	return writeUpdateBundleChecksums(path)
+}
+
+// writeUpdateBundleScript renders the script applying an update bundle to scriptPath
+func writeUpdateBundleScript(scriptPath string, data updateBundleScript) error {
+	cfg := config.Get()
+	t, err := template.ParseFiles(cfg.TemplatesPath + updateBundleScriptTemplate)
+	if err != nil {
+		return fmt.Errorf("error parsing update bundle script template :: %s", err.Error())
+	}
+	f, err := os.OpenFile(filepath.Clean(scriptPath), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0700) //#nosec G302 - the script is run on the devices
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing update bundle script :: %s", err.Error())
+	}
+	return f.Close()
+}
+
+// writeUpdateBundleChecksums writes the sha256 checksums of the files of the update bundle in path,
+// in the format checked by sha256sum --check
+func writeUpdateBundleChecksums(path string) error {
+	var checksums strings.Builder
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		if relPath == updateBundleChecksumsFile {
+			return nil
+		}
+		checksum, _, err := fileChecksum(filePath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&checksums, "%s  %s\n", checksum, relPath)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(path, updateBundleChecksumsFile), []byte(checksums.String()), 0600)
+}
+
+// fileChecksum returns the sha256 checksum and the size of a file
+func fileChecksum(filePath string) (string, int64, error) {
+	f, err := os.Open(filepath.Clean(filePath))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	sumCalculator := sha256.New()
+	size, err := io.Copy(sumCalculator, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(sumCalculator.Sum(nil)), size, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redhatinsights/edge-api/config"
+)
+
+func TestWriteUpdateBundleScript(t *testing.T) {
+	cfg := config.Get()
+	templatesPath := cfg.TemplatesPath
+	cfg.TemplatesPath = "./../../templates/"
+	defer func() { cfg.TemplatesPath = templatesPath }()
+	dir, err := ioutil.TempDir("", "update-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	scriptPath := filepath.Join(dir, updateBundleScriptFile)
+	if err := writeUpdateBundleScript(scriptPath, updateBundleScript{
+		BundleID:   3,
+		RemoteName: updateBundleRemoteName,
+		Ref:        "rhel/8/x86_64/edge",
+		Commit:     "abc123",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	script, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`OSTREE_REMOTE="rhel-edge"`,
+		`OSTREE_REF="rhel/8/x86_64/edge"`,
+		`OSTREE_COMMIT="abc123"`,
+		"sha256sum --quiet --check SHA256SUMS",
+		"rpm-ostree rebase --cache-only",
+	} {
+		if !strings.Contains(string(script), expected) {
+			t.Errorf("expected the script to contain %q", expected)
+		}
+	}
+	if info, err := os.Stat(scriptPath); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected the script to be executable")
+	}
+}
+
+func TestWriteUpdateBundleChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "update-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "repo", "objects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, updateBundleManifestFile), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "repo", "objects", "object"), []byte("hello\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeUpdateBundleChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	// writing them again doesn't checksum the checksums file
+	if err := writeUpdateBundleChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	checksums, err := ioutil.ReadFile(filepath.Join(dir, updateBundleChecksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a  manifest.json\n" +
+		"5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  repo/objects/object\n"
+	if string(checksums) != expected {
+		t.Errorf("expected checksums %q but got %q", expected, string(checksums))
+	}
+}
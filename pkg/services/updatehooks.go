@@ -0,0 +1,100 @@
+package services
+
+import (
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+	"gorm.io/gorm"
+)
+
+// getUpdateHooks returns by stage the update hooks matching the query
+func getUpdateHooks(query string, args ...interface{}) (*models.UpdateHooks, error) {
+	var hooks []models.UpdateHook
+	if result := db.DB.Where(query, args...).Order("position").Find(&hooks); result.Error != nil {
+		return nil, result.Error
+	}
+	updateHooks := &models.UpdateHooks{PreUpdate: []models.UpdateHook{}, PostUpdate: []models.UpdateHook{}}
+	for _, hook := range hooks {
+		switch hook.Stage {
+		case models.UpdateHookStagePre:
+			updateHooks.PreUpdate = append(updateHooks.PreUpdate, hook)
+		case models.UpdateHookStagePost:
+			updateHooks.PostUpdate = append(updateHooks.PostUpdate, hook)
+		}
+	}
+	return updateHooks, nil
+}
+
+// replaceUpdateHooks replaces the update hooks of the owner, an image set or a device group
+func replaceUpdateHooks(owner models.UpdateHook, hooks *models.UpdateHooks) (*models.UpdateHooks, error) {
+	var newHooks []models.UpdateHook
+	for stage, stageHooks := range map[string][]models.UpdateHook{
+		models.UpdateHookStagePre:  hooks.PreUpdate,
+		models.UpdateHookStagePost: hooks.PostUpdate,
+	} {
+		for i, hook := range stageHooks {
+			newHooks = append(newHooks, models.UpdateHook{
+				Account:       owner.Account,
+				ImageSetID:    owner.ImageSetID,
+				DeviceGroupID: owner.DeviceGroupID,
+				Stage:         stage,
+				Position:      i + 1,
+				Name:          hook.Name,
+				Command:       hook.Command,
+			})
+		}
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("account = ?", owner.Account)
+		if owner.ImageSetID != nil {
+			query = query.Where("image_set_id = ?", *owner.ImageSetID)
+		} else {
+			query = query.Where("device_group_id = ?", *owner.DeviceGroupID)
+		}
+		if err := query.Delete(&models.UpdateHook{}).Error; err != nil {
+			return err
+		}
+		if len(newHooks) == 0 {
+			return nil
+		}
+		return tx.Create(&newHooks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if owner.ImageSetID != nil {
+		return getUpdateHooks("account = ? AND image_set_id = ?", owner.Account, *owner.ImageSetID)
+	}
+	return getUpdateHooks("account = ? AND device_group_id = ?", owner.Account, *owner.DeviceGroupID)
+}
+
+// getUpdateTransactionHooks returns the update hooks run by the devices of an update transaction,
+// the hooks of the image set of the update commit followed by the hooks of the device group of the update
+func getUpdateTransactionHooks(update *models.UpdateTransaction) (*models.UpdateHooks, error) {
+	updateHooks := &models.UpdateHooks{PreUpdate: []models.UpdateHook{}, PostUpdate: []models.UpdateHook{}}
+	var image models.Image
+	result := db.DB.Where("account = ? AND commit_id = ?", update.Account, update.CommitID).Limit(1).Find(&image)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 && image.ImageSetID != nil {
+		imageSetHooks, err := getUpdateHooks("account = ? AND image_set_id = ?", update.Account, *image.ImageSetID)
+		if err != nil {
+			return nil, err
+		}
+		updateHooks.PreUpdate = append(updateHooks.PreUpdate, imageSetHooks.PreUpdate...)
+		updateHooks.PostUpdate = append(updateHooks.PostUpdate, imageSetHooks.PostUpdate...)
+	}
+	if update.DeviceGroupUpdateID != nil {
+		var deviceGroupUpdate models.DeviceGroupUpdate
+		if result := db.DB.First(&deviceGroupUpdate, *update.DeviceGroupUpdateID); result.Error != nil {
+			return nil, result.Error
+		}
+		deviceGroupHooks, err := getUpdateHooks("account = ? AND device_group_id = ?", update.Account, deviceGroupUpdate.DeviceGroupID)
+		if err != nil {
+			return nil, err
+		}
+		updateHooks.PreUpdate = append(updateHooks.PreUpdate, deviceGroupHooks.PreUpdate...)
+		updateHooks.PostUpdate = append(updateHooks.PostUpdate, deviceGroupHooks.PostUpdate...)
+	}
+	return updateHooks, nil
+}
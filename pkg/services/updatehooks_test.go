@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/redhatinsights/edge-api/pkg/db"
+	"github.com/redhatinsights/edge-api/pkg/models"
+)
+
+func TestGetUpdateTransactionHooks(t *testing.T) {
+	account := faker.UUIDHyphenated()
+	imageSet := models.ImageSet{Name: faker.UUIDHyphenated(), Account: account}
+	db.DB.Create(&imageSet)
+	commit := models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+	db.DB.Create(&commit)
+	db.DB.Create(&models.Image{Name: imageSet.Name, Account: account, ImageSetID: &imageSet.ID, CommitID: commit.ID})
+	deviceGroup := models.DeviceGroup{Name: faker.UUIDHyphenated(), Account: account, Type: models.DeviceGroupTypeDefault}
+	db.DB.Create(&deviceGroup)
+	deviceGroupUpdate := models.DeviceGroupUpdate{Account: account, DeviceGroupID: deviceGroup.ID}
+	db.DB.Create(&deviceGroupUpdate)
+
+	if _, err := replaceUpdateHooks(models.UpdateHook{Account: account, ImageSetID: &imageSet.ID}, &models.UpdateHooks{
+		PreUpdate: []models.UpdateHook{{Name: "image set pre", Command: "true"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replaceUpdateHooks(models.UpdateHook{Account: account, DeviceGroupID: &deviceGroup.ID}, &models.UpdateHooks{
+		PreUpdate:  []models.UpdateHook{{Name: "group pre 1", Command: "true"}, {Name: "group pre 2", Command: "true"}},
+		PostUpdate: []models.UpdateHook{{Name: "group post", Command: "true"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	update := &models.UpdateTransaction{Account: account, CommitID: commit.ID}
+	hooks, err := getUpdateTransactionHooks(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks.PreUpdate) != 1 || len(hooks.PostUpdate) != 0 {
+		t.Errorf("Expected only the image set hooks without device group update, got %+v", hooks)
+	}
+
+	update.DeviceGroupUpdateID = &deviceGroupUpdate.ID
+	hooks, err = getUpdateTransactionHooks(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var preUpdate []string
+	for _, hook := range hooks.PreUpdate {
+		preUpdate = append(preUpdate, hook.Name)
+	}
+	if len(preUpdate) != 3 || preUpdate[0] != "image set pre" || preUpdate[1] != "group pre 1" || preUpdate[2] != "group pre 2" {
+		t.Errorf("Expected the image set hooks followed by the device group hooks, got %v", preUpdate)
+	}
+	if len(hooks.PostUpdate) != 1 || hooks.PostUpdate[0].Name != "group post" {
+		t.Errorf("Expected the device group post-update hook, got %+v", hooks.PostUpdate)
+	}
+}
@@ -1,16 +1,17 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
 	"text/template"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/redhatinsights/edge-api/pkg/models"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"gorm.io/gorm"
 )
 
 // UpdateServiceInterface defines the interface that helps
@@ -31,40 +33,63 @@ type UpdateServiceInterface interface {
 	GetUpdateTransactionsForDevice(device *models.Device) (*[]models.UpdateTransaction, error)
 	ProcessPlaybookDispatcherRunEvent(message []byte) error
 	WriteTemplate(templateInfo TemplateRemoteInfo, account string) (string, error)
+	PreviewUpdate(account string, commitID uint, devicesUUID []string) (*models.UpdatePreview, error)
 	SetUpdateStatusBasedOnDispatchRecord(dispatchRecord models.DispatchRecord) error
 	SetUpdateStatus(update *models.UpdateTransaction) error
 	SendDeviceNotification(update *models.UpdateTransaction) (ImageNotification, error)
 	UpdateDevicesFromUpdateTransaction(update models.UpdateTransaction) error
 	ValidateUpdateSelection(account string, imageIds []uint) (bool, error)
+	DispatchScheduledUpdates() error
+	CreateDeviceGroupUpdate(deviceGroup *models.DeviceGroup, commitID uint) (*models.DeviceGroupUpdate, error)
+	GetDeviceGroupUpdateByID(account string, deviceGroupID uint, ID uint) (*models.DeviceGroupUpdate, error)
+	CancelUpdate(update *models.UpdateTransaction) error
+	RetryUpdate(update *models.UpdateTransaction) error
+	ResumeUpdate(update *models.UpdateTransaction) error
+	ExpireRebootingDispatchRecords() error
+	ReconcileDispatchRecords() error
+	CreateDeviceRollback(account string, deviceUUID string) (*models.UpdateTransaction, error)
+	CreateDeviceGroupRollback(deviceGroup *models.DeviceGroup) (*models.DeviceGroupUpdate, error)
+	GetUpdateTransactionEvents(update *models.UpdateTransaction) ([]models.Event, error)
+	CreateUpdateBundle(account string, commitID uint, sourceCommitIDs []uint) (*models.UpdateBundle, error)
+	BuildUpdateBundle(id uint) (*models.UpdateBundle, error)
+	GetUpdateBundleByID(account string, id uint) (*models.UpdateBundle, error)
+	GetUpdateBundleDownload(bundle *models.UpdateBundle) (*models.UpdateBundleDownload, error)
 }
 
 // NewUpdateService gives a instance of the main implementation of a UpdateServiceInterface
 func NewUpdateService(ctx context.Context, log *log.Entry) UpdateServiceInterface {
 	return &UpdateService{
-		Service:       Service{ctx: ctx, log: log.WithField("service", "update")},
-		FilesService:  NewFilesService(log),
-		RepoBuilder:   NewRepoBuilder(ctx, log),
-		WaitForReboot: time.Minute * 5,
+		Service:        Service{ctx: ctx, log: log.WithField("service", "update")},
+		FilesService:   NewFilesService(log),
+		RepoBuilder:    NewRepoBuilder(ctx, log),
+		PlaybookClient: playbookdispatcher.InitClient(ctx, log),
+		ImageService:   NewImageService(ctx, log),
+		RebootTimeout:  time.Duration(config.Get().UpdateRebootTimeout) * time.Minute,
 	}
 }
 
 // UpdateService is the main implementation of a UpdateServiceInterface
 type UpdateService struct {
 	Service
-	RepoBuilder   RepoBuilderInterface
-	FilesService  FilesService
-	WaitForReboot time.Duration
+	RepoBuilder    RepoBuilderInterface
+	FilesService   FilesService
+	PlaybookClient playbookdispatcher.ClientInterface
+	ImageService   ImageServiceInterface
+	// RebootTimeout is how long a device has to boot the update commit once the update playbook succeeded
+	RebootTimeout time.Duration
 }
 
 type playbooks struct {
 	GoTemplateRemoteName string
-	GoTemplateGpgVerify  string
 	OstreeRemoteName     string
 	OstreeGpgVerify      string
-	OstreeGpgKeypath     string
+	OstreeGpgKey         string
+	PreUpdateHooks       string
+	PostUpdateHooks      string
 	FleetInfraEnv        string
 	UpdateNumber         string
 	RepoURL              string
+	InsightsSignature    string
 }
 
 // TemplateRemoteInfo the values to playbook
@@ -73,9 +98,29 @@ type TemplateRemoteInfo struct {
 	RemoteURL           string
 	ContentURL          string
 	GpgVerify           string
+	GpgKey              []byte
+	UpdateHooks         *models.UpdateHooks
 	UpdateTransactionID uint
 }
 
+// encodePlaybookHooks renders the update hooks as a YAML flow sequence
+// The names and commands are tagged !unsafe so Ansible runs them as given instead of templating them.
+func encodePlaybookHooks(hooks []models.UpdateHook) (string, error) {
+	playbookHooks := make([]string, 0, len(hooks))
+	for _, hook := range hooks {
+		name, err := json.Marshal(hook.Name)
+		if err != nil {
+			return "", err
+		}
+		command, err := json.Marshal(hook.Command)
+		if err != nil {
+			return "", err
+		}
+		playbookHooks = append(playbookHooks, fmt.Sprintf(`{"name": !unsafe %s, "command": !unsafe %s}`, name, command))
+	}
+	return "[" + strings.Join(playbookHooks, ", ") + "]", nil
+}
+
 // PlaybookDispatcherEventPayload belongs to PlaybookDispatcherEvent
 type PlaybookDispatcherEventPayload struct {
 	ID            string `json:"id"`
@@ -104,42 +149,38 @@ type PlaybookDispatcherEvent struct {
 func (s *UpdateService) CreateUpdate(id uint) (*models.UpdateTransaction, error) {
 	var update *models.UpdateTransaction
 	db.DB.Preload("DispatchRecords").Preload("Devices").Joins("Commit").Joins("Repo").Find(&update, id)
-	update.Status = models.UpdateStatusBuilding
-	db.DB.Save(&update)
-
-	WaitGroup.Add(1) // Processing one update
-	defer func() {
-		WaitGroup.Done() // Done with one update (successfully or not)
-		s.log.Debug("Done with one update - successfully or not")
-		if err := recover(); err != nil {
-			s.log.WithField("error", err).Error("Error on update")
-		}
-	}()
-	go func(update *models.UpdateTransaction) {
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
-		sig := <-sigint
-		// Reload update to get updated status
-		db.DB.First(&update, update.ID)
-		if update.Status == models.UpdateStatusBuilding {
-			s.log.WithFields(log.Fields{
-				"signal":   sig,
-				"updateID": update.ID,
-			}).Info("Captured signal marking update as error")
-			update.Status = models.UpdateStatusError
-			tx := db.DB.Save(update)
-			if tx.Error != nil {
-				s.log.WithField("error", tx.Error.Error()).Error("Error saving update")
+	if update.Status == models.UpdateStatusCancelled {
+		s.log.WithField("updateID", update.ID).Info("Update was cancelled before being built")
+		return update, nil
+	}
+	// a previous attempt of the update build job was interrupted after dispatching the update
+	if len(update.DispatchRecords) > 0 {
+		s.log.WithField("updateID", update.ID).Info("Update was already built, resuming its dispatch")
+		if result := db.DB.Where("update_transaction_id = ?", update.ID).Order("position").Find(&update.Waves); result.Error != nil {
+			return nil, result.Error
+		}
+		if len(update.Waves) == 0 {
+			if err := s.dispatchPendingRecords(update, nil); err != nil {
+				return nil, err
 			}
-			WaitGroup.Done()
 		}
-	}(update)
+		if err := s.SetUpdateStatus(update); err != nil {
+			return nil, err
+		}
+		return update, nil
+	}
+	update.Status = models.UpdateStatusBuilding
+	eventsDB(s.ctx).Save(&update)
 
 	update, err := s.RepoBuilder.BuildUpdateRepo(id)
+	if _, ok := err.(*UpdateCancelled); ok {
+		s.log.WithField("updateID", id).Info("Update was cancelled while building the update repo")
+		return nil, err
+	}
 	if err != nil {
 		db.DB.First(&update, id)
 		update.Status = models.UpdateStatusError
-		db.DB.Save(update)
+		eventsDB(models.ContextWithEventMessage(s.ctx, err.Error())).Save(update)
 		s.log.WithField("error", err.Error()).Error("Error building update repo")
 		return nil, err
 	}
@@ -150,64 +191,247 @@ func (s *UpdateService) CreateUpdate(id uint) (*models.UpdateTransaction, error)
 	remoteInfo.ContentURL = update.Repo.URL
 	remoteInfo.UpdateTransactionID = update.ID
 	remoteInfo.GpgVerify = "false"
+	signingKey, err := GetAccountSigningKey(update.Account)
+	if err != nil {
+		update.Status = models.UpdateStatusError
+		eventsDB(models.ContextWithEventMessage(s.ctx, err.Error())).Save(update)
+		s.log.WithField("error", err.Error()).Error("Error getting signing key")
+		return nil, err
+	}
+	// the update commit was signed by the repo builder, the playbook installs the public key verifying it
+	// on the devices installed before the account had a signing key
+	if signingKey != nil {
+		remoteInfo.GpgVerify = "true"
+		remoteInfo.GpgKey = signingKey.PublicKey
+	}
+	remoteInfo.UpdateHooks, err = getUpdateTransactionHooks(update)
+	if err != nil {
+		update.Status = models.UpdateStatusError
+		eventsDB(models.ContextWithEventMessage(s.ctx, err.Error())).Save(update)
+		s.log.WithField("error", err.Error()).Error("Error getting update hooks")
+		return nil, err
+	}
 	playbookURL, err := s.WriteTemplate(remoteInfo, update.Account)
 	if err != nil {
 		update.Status = models.UpdateStatusError
-		db.DB.Save(update)
+		eventsDB(models.ContextWithEventMessage(s.ctx, err.Error())).Save(update)
 		s.log.WithField("error", err.Error()).Error("Error writing playbook template")
 		return nil, err
 	}
-	// 3. Loop through all devices in UpdateTransaction
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return update, err
+	}
+	if err := s.createDispatchRecords(update, playbookURL); err != nil {
+		update.Status = models.UpdateStatusError
+		eventsDB(models.ContextWithEventMessage(s.ctx, err.Error())).Save(update)
+		s.log.WithField("error", err.Error()).Error("Error creating dispatch records")
+		return nil, err
+	}
+	// Staged rollouts are dispatched wave by wave by SetUpdateStatus
+	if len(update.Waves) == 0 {
+		if err := s.dispatchPendingRecords(update, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.SetUpdateStatus(update); err != nil {
+		s.log.WithField("error", err.Error()).Error("Error saving update")
+		return nil, err
+	}
+
+	s.log.WithField("updateID", update.ID).Info("Update was finished")
+	return update, nil
+}
+
+// createDispatchRecords creates a pending dispatch record for every device of the update
+// assigning the devices to the waves of a staged rollout in order
+func (s *UpdateService) createDispatchRecords(update *models.UpdateTransaction, playbookURL string) error {
+	if result := db.DB.Where("update_transaction_id = ?", update.ID).Order("position").Find(&update.Waves); result.Error != nil {
+		return result.Error
+	}
 	dispatchRecords := update.DispatchRecords
+	waveIndex, waveDevices := 0, 0
 	for _, device := range update.Devices {
 		device := device // this will prevent implicit memory aliasing in the loop
-		// Create new &DispatcherPayload{}
-		payloadDispatcher := playbookdispatcher.DispatcherPayload{
-			Recipient:   device.RHCClientID,
+		dispatchRecord := models.DispatchRecord{
+			Device:      &device,
 			PlaybookURL: playbookURL,
-			Account:     update.Account,
+			Status:      models.DispatchRecordStatusPending,
+		}
+		if waveIndex < len(update.Waves) {
+			dispatchRecord.UpdateWaveID = &update.Waves[waveIndex].ID
+			waveDevices++
+			if waveDevices == update.Waves[waveIndex].DevicesCount {
+				waveIndex++
+				waveDevices = 0
+			}
 		}
-		s.log.Debug("Calling playbook dispatcher")
-		client := playbookdispatcher.InitClient(s.ctx, s.log)
-		exc, err := client.ExecuteDispatcher(payloadDispatcher)
+		dispatchRecords = append(dispatchRecords, dispatchRecord)
+	}
+	update.DispatchRecords = dispatchRecords
+	return db.DB.Save(update).Error
+}
 
+// dispatchPendingRecords sends the update playbook to the devices of the pending dispatch records
+// of the given wave, or of all the pending dispatch records when no wave is given
+// The playbook is sent to batches of devices, up to the playbook dispatcher batch size of the config
+func (s *UpdateService) dispatchPendingRecords(update *models.UpdateTransaction, waveID *uint) error {
+	batchSize := config.Get().PlaybookDispatcherConfig.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	now := time.Now()
+	var batch []*models.DispatchRecord
+	for i := range update.DispatchRecords {
+		dispatchRecord := &update.DispatchRecords[i]
+		if dispatchRecord.Status != models.DispatchRecordStatusPending {
+			continue
+		}
+		if waveID != nil && (dispatchRecord.UpdateWaveID == nil || *dispatchRecord.UpdateWaveID != *waveID) {
+			continue
+		}
+		if dispatchRecord.Device == nil {
+			var device models.Device
+			if result := db.DB.First(&device, dispatchRecord.DeviceID); result.Error != nil {
+				return result.Error
+			}
+			dispatchRecord.Device = &device
+		}
+		dispatchable, err := s.isDispatchable(update, dispatchRecord.Device, now)
 		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Error on playbook-dispatcher execution")
-			update.Status = models.UpdateStatusError
-			db.DB.Save(update)
-			return nil, err
+			return err
 		}
-		for _, excPlaybook := range exc {
-			if excPlaybook.StatusCode == http.StatusCreated {
-				device.Connected = true
-				dispatchRecord := &models.DispatchRecord{
-					Device:               &device,
-					PlaybookURL:          playbookURL,
-					Status:               models.DispatchRecordStatusCreated,
-					PlaybookDispatcherID: excPlaybook.PlaybookDispatcherID,
-				}
-				dispatchRecords = append(dispatchRecords, *dispatchRecord)
-			} else {
-				device.Connected = false
-				dispatchRecord := &models.DispatchRecord{
-					Device:      &device,
-					PlaybookURL: playbookURL,
-					Status:      models.DispatchRecordStatusError,
-				}
-				dispatchRecords = append(dispatchRecords, *dispatchRecord)
+		if !dispatchable {
+			s.log.WithFields(log.Fields{"updateID": update.ID, "deviceUUID": dispatchRecord.Device.UUID}).Debug("Device is held back by the update schedule")
+			continue
+		}
+		batch = append(batch, dispatchRecord)
+		if len(batch) == batchSize {
+			if err := s.dispatchRecordsBatch(update, batch); err != nil {
+				return err
 			}
-			db.DB.Save(&device)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		return s.dispatchRecordsBatch(update, batch)
+	}
+	return nil
+}
+
+// dispatchRecordsBatch sends the update playbook to the devices of the dispatch records in a single request
+// and sets the status of every dispatch record from its response, the dispatch records of a request
+// the playbook dispatcher failed to run are set on error
+func (s *UpdateService) dispatchRecordsBatch(update *models.UpdateTransaction, dispatchRecords []*models.DispatchRecord) error {
+	// the update may have been cancelled while dispatching the previous devices
+	if cancelled, err := isUpdateCancelled(update); err != nil || cancelled {
+		return err
+	}
+	payloads := make([]playbookdispatcher.DispatcherPayload, len(dispatchRecords))
+	for i, dispatchRecord := range dispatchRecords {
+		payloads[i] = playbookdispatcher.DispatcherPayload{
+			Recipient:   dispatchRecord.Device.RHCClientID,
+			PlaybookURL: dispatchRecord.PlaybookURL,
+			Account:     update.Account,
 		}
-		update.DispatchRecords = dispatchRecords
-		err = s.SetUpdateStatus(update)
+		dispatchRecord.Attempts++
+	}
+	s.log.WithFields(log.Fields{"updateID": update.ID, "devicesCount": len(payloads)}).Debug("Calling playbook dispatcher")
+	exc, err := s.PlaybookClient.ExecuteDispatcher(payloads)
+	if err != nil {
+		s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error on playbook-dispatcher execution")
+	}
+	for i, dispatchRecord := range dispatchRecords {
 		if err != nil {
-			s.log.WithField("error", err.Error()).Error("Error saving update")
-			return nil, err
+			dispatchRecord.Status = models.DispatchRecordStatusError
+		} else if exc[i].StatusCode == http.StatusCreated {
+			dispatchRecord.Device.Connected = true
+			dispatchRecord.Status = models.DispatchRecordStatusCreated
+			dispatchRecord.PlaybookDispatcherID = exc[i].PlaybookDispatcherID
+			dispatchRecord.DispatchedAt = models.EdgeAPITime{Time: time.Now(), Valid: true}
+			db.DB.Save(dispatchRecord.Device)
+		} else {
+			s.log.WithFields(log.Fields{"deviceUUID": dispatchRecord.Device.UUID, "statusCode": exc[i].StatusCode}).Error("Playbook dispatcher didn't run the update playbook on the device")
+			dispatchRecord.Device.Connected = false
+			dispatchRecord.Status = models.DispatchRecordStatusError
+			db.DB.Save(dispatchRecord.Device)
+		}
+		if result := db.DB.Save(dispatchRecord); result.Error != nil {
+			return result.Error
 		}
 	}
+	return nil
+}
 
-	s.log.WithField("updateID", update.ID).Info("Update was finished")
-	return update, nil
+// isDispatchable returns whether the schedule of the update allows to dispatch the device at the given time
+// Devices that don't belong to a device group with maintenance windows are not held back by the NEXT_WINDOW policy
+func (s *UpdateService) isDispatchable(update *models.UpdateTransaction, device *models.Device, now time.Time) (bool, error) {
+	if update.NotBefore.Valid && now.Before(update.NotBefore.Time) {
+		return false, nil
+	}
+	if update.SchedulePolicy != models.UpdateSchedulePolicyNextWindow {
+		return true, nil
+	}
+	var windows []models.MaintenanceWindow
+	if result := db.DB.Joins("JOIN device_groups_devices ON device_groups_devices.device_group_id = maintenance_windows.device_group_id").
+		Where("device_groups_devices.device_id = ?", device.ID).Find(&windows); result.Error != nil {
+		return false, result.Error
+	}
+	if len(windows) == 0 {
+		return true, nil
+	}
+	for _, window := range windows {
+		open, err := window.IsOpen(now)
+		if err != nil {
+			return false, err
+		}
+		if open {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DispatchScheduledUpdates dispatches the devices that were held back by the schedule of the updates
+// It is meant to be called periodically so the devices left over when a maintenance window closes
+// are dispatched on the next one
+// The updates are selected from their pending dispatch records whatever their status, an update on error because
+// of a device keeps dispatching the others, only the cancelled and paused updates don't dispatch
+func (s *UpdateService) DispatchScheduledUpdates() error {
+	var updates []models.UpdateTransaction
+	result := db.DB.Preload("DispatchRecords").Preload("Waves", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("status NOT IN ?", []string{models.UpdateStatusCancelled, models.UpdateStatusPaused}).
+		Where(`id IN (SELECT updatetransaction_dispatchrecords.update_transaction_id FROM updatetransaction_dispatchrecords
+			JOIN dispatch_records ON dispatch_records.id = updatetransaction_dispatchrecords.dispatch_record_id
+			WHERE dispatch_records.status = ?)`, models.DispatchRecordStatusPending).
+		Find(&updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	for i := range updates {
+		update := &updates[i]
+		s.log.WithField("updateID", update.ID).Debug("Dispatching scheduled update")
+		if len(update.Waves) == 0 {
+			if err := s.dispatchPendingRecords(update, nil); err != nil {
+				s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error dispatching scheduled update")
+				continue
+			}
+		}
+		for _, wave := range update.Waves {
+			if wave.Status != models.UpdateWaveStatusRunning {
+				continue
+			}
+			wave := wave // this will prevent implicit memory aliasing in the loop
+			if err := s.dispatchPendingRecords(update, &wave.ID); err != nil {
+				s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error dispatching scheduled update")
+				break
+			}
+		}
+		if err := s.SetUpdateStatus(update); err != nil {
+			s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error setting scheduled update status")
+		}
+	}
+	return nil
 }
 
 // GetUpdatePlaybook is the function that returns the path to an update playbook
@@ -241,23 +465,60 @@ func (s *UpdateService) WriteTemplate(templateInfo TemplateRemoteInfo, account s
 	} else {
 		envName = "dev"
 	}
+	hooks := templateInfo.UpdateHooks
+	if hooks == nil {
+		hooks = &models.UpdateHooks{}
+	}
+	preUpdateHooks, err := encodePlaybookHooks(hooks.PreUpdate)
+	if err != nil {
+		return "", err
+	}
+	postUpdateHooks, err := encodePlaybookHooks(hooks.PostUpdate)
+	if err != nil {
+		return "", err
+	}
 	templateData := playbooks{
 		GoTemplateRemoteName: templateInfo.RemoteName,
+		OstreeGpgVerify:      templateInfo.GpgVerify,
+		OstreeGpgKey:         base64.StdEncoding.EncodeToString(templateInfo.GpgKey),
+		PreUpdateHooks:       preUpdateHooks,
+		PostUpdateHooks:      postUpdateHooks,
 		FleetInfraEnv:        envName,
 		UpdateNumber:         strconv.FormatUint(uint64(templateInfo.UpdateTransactionID), 10),
 		RepoURL:              "https://{{ s3_buckets[fleet_infra_env] | default('rh-edge-tarballs-prod') }}.s3.us-east-1.amazonaws.com/{{ update_number }}/upd/{{ update_number }}/repo",
 	}
 
-	fname := fmt.Sprintf("playbook_dispatcher_update_%s_%d.yml", account, templateInfo.UpdateTransactionID)
-	tmpfilepath := fmt.Sprintf("/tmp/%s", fname)
-	f, err := os.Create(tmpfilepath)
-	if err != nil {
-		s.log.WithField("error", err.Error()).Errorf("Error creating file")
+	var playbook bytes.Buffer
+	if err := templateContents.Execute(&playbook, templateData); err != nil {
+		s.log.WithField("error", err.Error()).Errorf("Error executing template")
 		return "", err
 	}
-	err = templateContents.Execute(f, templateData)
+	// the signature covers the rendered playbook as its variables, like the public key, change per update
+	signingKey, err := GetPlaybookSigningKey()
 	if err != nil {
-		s.log.WithField("error", err.Error()).Errorf("Error executing template")
+		s.log.WithField("error", err.Error()).Errorf("Error getting playbook signing key")
+		return "", err
+	}
+	if signingKey != nil {
+		signature, err := SignPlaybook(playbook.Bytes(), signingKey)
+		if err != nil {
+			s.log.WithField("error", err.Error()).Errorf("Error signing playbook")
+			return "", err
+		}
+		templateData.InsightsSignature = formatPlaybookSignature(signature, "      ")
+		playbook.Reset()
+		if err := templateContents.Execute(&playbook, templateData); err != nil {
+			s.log.WithField("error", err.Error()).Errorf("Error executing template")
+			return "", err
+		}
+	} else {
+		s.log.Warn("Playbook signing is not configured, the update playbook is not signed")
+	}
+
+	fname := fmt.Sprintf("playbook_dispatcher_update_%s_%d.yml", account, templateInfo.UpdateTransactionID)
+	tmpfilepath := fmt.Sprintf("/tmp/%s", fname)
+	if err := ioutil.WriteFile(tmpfilepath, playbook.Bytes(), 0600); err != nil {
+		s.log.WithField("error", err.Error()).Errorf("Error creating file")
 		return "", err
 	}
 
@@ -322,9 +583,6 @@ func (s *UpdateService) ProcessPlaybookDispatcherRunEvent(message []byte) error
 	if e.Payload.Status == PlaybookStatusRunning {
 		s.log.Debug("Playbook is running - waiting for next messages")
 		return nil
-	} else if e.Payload.Status == PlaybookStatusSuccess {
-		s.log.Debug("The playbook was applied successfully. Waiting two minutes for reboot before setting status to success.")
-		time.Sleep(s.WaitForReboot)
 	}
 
 	var dispatchRecord models.DispatchRecord
@@ -333,32 +591,145 @@ func (s *UpdateService) ProcessPlaybookDispatcherRunEvent(message []byte) error
 		return result.Error
 	}
 
-	if e.Payload.Status == PlaybookStatusFailure || e.Payload.Status == PlaybookStatusTimeout {
+	if err := s.setDispatchRecordRunStatus(&dispatchRecord, e.Payload.Status); err != nil {
+		return err
+	}
+	result = eventsDB(models.ContextWithEventActor(s.ctx, models.EventActorPlaybookDispatcher)).Save(&dispatchRecord)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return s.SetUpdateStatusBasedOnDispatchRecord(dispatchRecord)
+}
+
+// setDispatchRecordRunStatus sets the status of a dispatch record from the status of its playbook run
+func (s *UpdateService) setDispatchRecordRunStatus(dispatchRecord *models.DispatchRecord, runStatus string) error {
+	if runStatus == PlaybookStatusFailure || runStatus == PlaybookStatusTimeout {
 		dispatchRecord.Status = models.DispatchRecordStatusError
-	} else if e.Payload.Status == PlaybookStatusSuccess {
-		fmt.Printf("$$$$$$$$$ dispatchRecord.Device %v\n", dispatchRecord.Device)
-		// TODO: We might wanna check if it's really success by checking the running hash on the device here
-		dispatchRecord.Status = models.DispatchRecordStatusComplete
-		dispatchRecord.Device.AvailableHash = os.DevNull
-		dispatchRecord.Device.CurrentHash = dispatchRecord.Device.AvailableHash
-	} else if e.Payload.Status == PlaybookStatusRunning {
+	} else if runStatus == PlaybookStatusSuccess {
+		// The device reboots at the end of the playbook, the update is complete once
+		// an inventory event reports the update commit as the booted deployment
+		commit, err := getDispatchRecordUpdateCommit(dispatchRecord.ID)
+		if err != nil {
+			return err
+		}
+		if dispatchRecord.Device != nil && dispatchRecord.Device.CurrentHash == commit.OSTreeCommit {
+			dispatchRecord.Status = models.DispatchRecordStatusComplete
+		} else {
+			s.log.Debug("The playbook was applied successfully. Waiting for the device to boot the update commit.")
+			dispatchRecord.Status = models.DispatchRecordStatusRebooting
+			dispatchRecord.RebootDeadline = models.EdgeAPITime{Time: time.Now().Add(s.RebootTimeout), Valid: true}
+		}
+	} else if runStatus == PlaybookStatusRunning {
 		dispatchRecord.Status = models.DispatchRecordStatusRunning
 	} else {
 		dispatchRecord.Status = models.DispatchRecordStatusError
 		s.log.Error("Playbook status is not on the json schema for this event")
 	}
-	result = db.DB.Save(&dispatchRecord)
+	return nil
+}
+
+// getDispatchRecordUpdateCommit returns the commit of the update transaction of a dispatch record
+func getDispatchRecordUpdateCommit(dispatchRecordID uint) (*models.Commit, error) {
+	var commit models.Commit
+	result := db.DB.
+		Joins("JOIN update_transactions ON update_transactions.commit_id = commits.id").
+		Joins("JOIN updatetransaction_dispatchrecords ON updatetransaction_dispatchrecords.update_transaction_id = update_transactions.id").
+		Where("updatetransaction_dispatchrecords.dispatch_record_id = ?", dispatchRecordID).
+		First(&commit)
 	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &commit, nil
+}
+
+// ExpireRebootingDispatchRecords sets to error the dispatch records whose device didn't boot the update commit before their reboot deadline
+func (s *UpdateService) ExpireRebootingDispatchRecords() error {
+	var dispatchRecords []models.DispatchRecord
+	if result := db.DB.Where("status = ?", models.DispatchRecordStatusRebooting).Find(&dispatchRecords); result.Error != nil {
 		return result.Error
 	}
+	now := time.Now()
+	for _, dispatchRecord := range dispatchRecords {
+		if !dispatchRecord.RebootDeadline.Valid || now.Before(dispatchRecord.RebootDeadline.Time) {
+			continue
+		}
+		s.log.WithField("dispatchRecordID", dispatchRecord.ID).Info("Device did not boot the update commit before the reboot deadline")
+		dispatchRecord.Status = models.DispatchRecordStatusError
+		ctx := models.ContextWithEventMessage(s.ctx, "device did not boot the update commit before the reboot deadline")
+		if result := eventsDB(ctx).Model(&dispatchRecord).Update("status", dispatchRecord.Status); result.Error != nil {
+			return result.Error
+		}
+		if err := s.SetUpdateStatusBasedOnDispatchRecord(dispatchRecord); err != nil {
+			s.log.WithFields(log.Fields{"dispatchRecordID": dispatchRecord.ID, "error": err.Error()}).Error("Error setting update status")
+		}
+	}
+	return nil
+}
 
-	return s.SetUpdateStatusBasedOnDispatchRecord(dispatchRecord)
+// ReconcileDispatchRecords checks the playbook runs of the dispatch records playbook dispatcher didn't report about
+// for the dispatch record timeout of the config, sets their status from their run, or on error when their run
+// can't be found, and sets the status of their update transaction
+func (s *UpdateService) ReconcileDispatchRecords() error {
+	timeout := time.Duration(config.Get().DispatchRecordTimeout) * time.Minute
+	var dispatchRecords []models.DispatchRecord
+	if result := db.DB.Preload("Device").Where("status IN ? AND updated_at < ?",
+		[]string{models.DispatchRecordStatusCreated, models.DispatchRecordStatusRunning}, time.Now().Add(-timeout)).
+		Find(&dispatchRecords); result.Error != nil {
+		return result.Error
+	}
+	for i := range dispatchRecords {
+		dispatchRecord := &dispatchRecords[i]
+		logger := s.log.WithFields(log.Fields{"dispatchRecordID": dispatchRecord.ID, "PlaybookDispatcherID": dispatchRecord.PlaybookDispatcherID})
+		var reason string
+		if dispatchRecord.PlaybookDispatcherID == "" || dispatchRecord.Device == nil {
+			reason = "the playbook run of the device is unknown"
+		} else {
+			run, err := s.PlaybookClient.GetRun(dispatchRecord.Device.Account, dispatchRecord.PlaybookDispatcherID)
+			if err != nil {
+				// the run is checked again on the next reconciliation
+				logger.WithField("error", err.Error()).Error("Error getting playbook run")
+				continue
+			}
+			if run == nil {
+				reason = "the playbook run was not found by playbook dispatcher"
+			} else {
+				logger.WithField("runStatus", run.Status).Info("Playbook dispatcher didn't report the status of the playbook run")
+				if err := s.setDispatchRecordRunStatus(dispatchRecord, run.Status); err != nil {
+					logger.WithField("error", err.Error()).Error("Error setting dispatch record status from playbook run")
+					continue
+				}
+				ctx := models.ContextWithEventActor(s.ctx, models.EventActorPlaybookDispatcher)
+				if dispatchRecord.Status == models.DispatchRecordStatusError {
+					ctx = models.ContextWithEventMessage(ctx, fmt.Sprintf("the playbook run status is %s", run.Status))
+				}
+				// the running dispatch records are checked again after another timeout
+				if result := eventsDB(ctx).Omit("Device").Save(dispatchRecord); result.Error != nil {
+					return result.Error
+				}
+			}
+		}
+		if reason != "" {
+			logger.WithField("reason", reason).Info("Setting error status on stuck dispatch record")
+			dispatchRecord.Status = models.DispatchRecordStatusError
+			ctx := models.ContextWithEventMessage(s.ctx, reason)
+			if result := eventsDB(ctx).Model(dispatchRecord).Update("status", dispatchRecord.Status); result.Error != nil {
+				return result.Error
+			}
+		}
+		if err := s.SetUpdateStatusBasedOnDispatchRecord(*dispatchRecord); err != nil {
+			logger.WithField("error", err.Error()).Error("Error setting update status")
+		}
+	}
+	return nil
 }
 
 // SetUpdateStatusBasedOnDispatchRecord is the function that, given a dispatch record, finds the update transaction related to and update its status if necessary
 func (s *UpdateService) SetUpdateStatusBasedOnDispatchRecord(dispatchRecord models.DispatchRecord) error {
 	var update models.UpdateTransaction
-	result := db.DB.Preload("DispatchRecords").
+	result := db.DB.Preload("DispatchRecords").Preload("Waves", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).
 		Table("update_transactions").
 		Joins(
 			`JOIN updatetransaction_dispatchrecords ON update_transactions.id = updatetransaction_dispatchrecords.update_transaction_id`).
@@ -378,25 +749,368 @@ func (s *UpdateService) SetUpdateStatusBasedOnDispatchRecord(dispatchRecord mode
 
 // SetUpdateStatus is the function to set the update status from an UpdateTransaction
 func (s *UpdateService) SetUpdateStatus(update *models.UpdateTransaction) error {
+	if err := s.setUpdateStatus(update); err != nil {
+		return err
+	}
+	return s.releaseEndedUpdate(update)
+}
+
+// setUpdateStatus sets the update status from its dispatch records
+func (s *UpdateService) setUpdateStatus(update *models.UpdateTransaction) error {
+	// A cancelled update keeps its status while the devices already dispatched finish
+	if update.Status == models.UpdateStatusCancelled {
+		return nil
+	}
+	if update.Waves == nil {
+		if result := db.DB.Where("update_transaction_id = ?", update.ID).Order("position").Find(&update.Waves); result.Error != nil {
+			return result.Error
+		}
+	}
+	if len(update.Waves) > 0 {
+		return s.setRolloutStatus(update)
+	}
+
 	allSuccess := true
+	allFinal := true
+	rolledBack := false
 
 	for _, d := range update.DispatchRecords {
 		if d.Status != models.DispatchRecordStatusComplete {
 			allSuccess = false
 		}
+		if !models.IsFinalDispatchRecordStatus(d.Status) {
+			allFinal = false
+		}
 		if d.Status == models.DispatchRecordStatusError {
 			update.Status = models.UpdateStatusError
 			break
 		}
+		// A device rolled back by greenboot doesn't run the update even if it was successful before
+		if d.Status == models.DispatchRecordStatusRolledBack {
+			rolledBack = true
+		}
 	}
 	if allSuccess {
 		update.Status = models.UpdateStatusSuccess
+	} else if rolledBack && allFinal && update.Status != models.UpdateStatusError {
+		// the update is rolled back once the other devices are done with it
+		update.Status = models.UpdateStatusRolledBack
 	}
 	// If there isn't an error and it's not all success, some updates are still happening
 	result := db.DB.Save(update)
 	return result.Error
 }
 
+// setRolloutStatus sets the status of a staged rollout, dispatching the next wave
+// once the current one reaches the success threshold and pausing the rollout
+// when the current one exceeds the failure threshold or ends below the success threshold
+func (s *UpdateService) setRolloutStatus(update *models.UpdateTransaction) error {
+	successThreshold := update.SuccessThreshold
+	if successThreshold == 0 {
+		successThreshold = 100
+	}
+	failureThreshold := update.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = 100
+	}
+	for i := range update.Waves {
+		wave := &update.Waves[i]
+		if wave.Status == models.UpdateWaveStatusSuccess || wave.Status == models.UpdateWaveStatusResumed {
+			continue
+		}
+		if wave.Status == models.UpdateWaveStatusFailed {
+			update.Status = models.UpdateStatusPaused
+			return eventsDB(s.ctx).Save(update).Error
+		}
+		if wave.Status == models.UpdateWaveStatusPending {
+			s.log.WithFields(log.Fields{"updateID": update.ID, "wave": wave.Position}).Info("Dispatching rollout wave")
+			wave.Status = models.UpdateWaveStatusRunning
+			if result := db.DB.Save(wave); result.Error != nil {
+				return result.Error
+			}
+			if err := s.dispatchPendingRecords(update, &wave.ID); err != nil {
+				return err
+			}
+		}
+		var total, complete, failed, finished int
+		for _, d := range update.DispatchRecords {
+			if d.UpdateWaveID == nil || *d.UpdateWaveID != wave.ID {
+				continue
+			}
+			total++
+			if d.Status == models.DispatchRecordStatusComplete {
+				complete++
+			} else if d.Status == models.DispatchRecordStatusError || d.Status == models.DispatchRecordStatusRolledBack {
+				failed++
+			}
+			if models.IsFinalDispatchRecordStatus(d.Status) {
+				finished++
+			}
+		}
+		var pauseReason string
+		if failed*100 > failureThreshold*total {
+			pauseReason = fmt.Sprintf("rollout wave %d exceeded the failure threshold", wave.Position)
+		} else if complete*100 < successThreshold*total && finished == total {
+			pauseReason = fmt.Sprintf("rollout wave %d ended below the success threshold", wave.Position)
+		}
+		if pauseReason != "" {
+			s.log.WithFields(log.Fields{"updateID": update.ID, "wave": wave.Position}).Info("Rollout wave failed, pausing the update")
+			wave.Status = models.UpdateWaveStatusFailed
+			if result := db.DB.Save(wave); result.Error != nil {
+				return result.Error
+			}
+			update.Status = models.UpdateStatusPaused
+			return eventsDB(models.ContextWithEventMessage(s.ctx, pauseReason)).Save(update).Error
+		}
+		if complete*100 < successThreshold*total {
+			// The current wave is still running
+			return eventsDB(s.ctx).Save(update).Error
+		}
+		wave.Status = models.UpdateWaveStatusSuccess
+		if result := db.DB.Save(wave); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	// All the waves have succeeded, the update is done once every device has finished
+	allSuccess, rolledBack := true, false
+	for _, d := range update.DispatchRecords {
+		if d.Status == models.DispatchRecordStatusError {
+			allSuccess = false
+		} else if d.Status == models.DispatchRecordStatusRolledBack {
+			rolledBack = true
+		} else if d.Status != models.DispatchRecordStatusComplete {
+			return eventsDB(s.ctx).Save(update).Error
+		}
+	}
+	switch {
+	case !allSuccess:
+		update.Status = models.UpdateStatusError
+	case rolledBack:
+		update.Status = models.UpdateStatusRolledBack
+	default:
+		update.Status = models.UpdateStatusSuccess
+	}
+	return eventsDB(s.ctx).Save(update).Error
+}
+
+// finalDispatchRecordStatuses are the statuses of the dispatch records whose device is done with the update
+var finalDispatchRecordStatuses = []string{models.DispatchRecordStatusComplete, models.DispatchRecordStatusError,
+	models.DispatchRecordStatusCancelled, models.DispatchRecordStatusRolledBack}
+
+// isUpdateOver returns whether an update is done with its devices: it reached a final status
+// and none of its devices is still pending or running the update playbook
+// An update on error keeps dispatching the devices held back by its schedule, it is not over until they are done
+func isUpdateOver(update *models.UpdateTransaction) (bool, error) {
+	switch update.Status {
+	case models.UpdateStatusCreated, models.UpdateStatusQueued, models.UpdateStatusBuilding, models.UpdateStatusPaused:
+		return false, nil
+	}
+	var count int64
+	if result := db.DB.Model(&models.DispatchRecord{}).
+		Where("status NOT IN ? AND id IN (SELECT dispatch_record_id FROM updatetransaction_dispatchrecords WHERE update_transaction_id = ?)",
+			finalDispatchRecordStatuses, update.ID).Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+	return count == 0, nil
+}
+
+// releaseEndedUpdate releases what an update holds while it runs once it is over:
+// its rollout slots and its reference on the cached update repo
+func (s *UpdateService) releaseEndedUpdate(update *models.UpdateTransaction) error {
+	over, err := isUpdateOver(update)
+	if err != nil || !over {
+		return err
+	}
+	if err := ReleaseConcurrencySlots(models.ConcurrencySlotHolderUpdateTransaction, update.ID); err != nil {
+		s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error releasing the rollout slots of the update")
+		return err
+	}
+	if err := releaseUpdateRepoCache(update); err != nil {
+		s.log.WithFields(log.Fields{"updateID": update.ID, "error": err.Error()}).Error("Error releasing the cached update repo")
+		return err
+	}
+	return nil
+}
+
+// isUpdateCancelled reloads the status of the update to find out whether it was cancelled since it was loaded
+func isUpdateCancelled(update *models.UpdateTransaction) (bool, error) {
+	var current models.UpdateTransaction
+	if result := db.DB.Select("status").First(&current, update.ID); result.Error != nil {
+		return false, result.Error
+	}
+	if current.Status != models.UpdateStatusCancelled {
+		return false, nil
+	}
+	update.Status = current.Status
+	return true, nil
+}
+
+// CancelUpdate cancels an update transaction that is not finished, the devices that were not dispatched yet
+// won't be and the build of the update repo is aborted
+func (s *UpdateService) CancelUpdate(update *models.UpdateTransaction) error {
+	logger := s.log.WithField("updateID", update.ID)
+	if update.Status != models.UpdateStatusCreated && update.Status != models.UpdateStatusQueued &&
+		update.Status != models.UpdateStatusBuilding && update.Status != models.UpdateStatusPaused {
+		return new(UpdateCannotBeCancelled)
+	}
+	update.Status = models.UpdateStatusCancelled
+	if result := eventsDB(s.ctx).Model(update).Update("status", update.Status); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error cancelling update")
+		return result.Error
+	}
+	var pendingRecords []models.DispatchRecord
+	if result := db.DB.
+		Where("status = ? AND id IN (SELECT dispatch_record_id FROM updatetransaction_dispatchrecords WHERE update_transaction_id = ?)",
+			models.DispatchRecordStatusPending, update.ID).
+		Find(&pendingRecords); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error getting update pending dispatch records")
+		return result.Error
+	}
+	for i := range pendingRecords {
+		if result := eventsDB(s.ctx).Model(&pendingRecords[i]).Update("status", models.DispatchRecordStatusCancelled); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error cancelling update pending dispatch records")
+			return result.Error
+		}
+	}
+	for i := range update.DispatchRecords {
+		if update.DispatchRecords[i].Status == models.DispatchRecordStatusPending {
+			update.DispatchRecords[i].Status = models.DispatchRecordStatusCancelled
+		}
+	}
+	logger.Info("Update was cancelled")
+	return s.releaseEndedUpdate(update)
+}
+
+// RetryUpdate dispatches again the update playbook to the devices whose dispatch failed, reusing the update repo
+// The failed attempts are kept in the attempt history of the dispatch records
+func (s *UpdateService) RetryUpdate(update *models.UpdateTransaction) error {
+	logger := s.log.WithField("updateID", update.ID)
+	if update.Status == models.UpdateStatusCreated || update.Status == models.UpdateStatusQueued || update.Status == models.UpdateStatusCancelled ||
+		update.Repo == nil || update.Repo.Status != models.RepoStatusSuccess {
+		return new(UpdateCannotBeRetried)
+	}
+	if update.Waves == nil {
+		if result := db.DB.Where("update_transaction_id = ?", update.ID).Order("position").Find(&update.Waves); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	var failedRecords []*models.DispatchRecord
+	for i := range update.DispatchRecords {
+		if update.DispatchRecords[i].Status == models.DispatchRecordStatusError {
+			failedRecords = append(failedRecords, &update.DispatchRecords[i])
+		}
+	}
+	if len(failedRecords) == 0 {
+		return new(UpdateHasNoFailedDevices)
+	}
+	// the update released its rollout slots when it ended
+	if acquired, err := acquireRolloutSlots(update.Account, update.ID); err != nil {
+		logger.WithField("error", err.Error()).Error("Error acquiring the rollout slots of the update")
+		return err
+	} else if !acquired {
+		return new(UpdateRolloutLimitReached)
+	}
+
+	var retriedWaveIDs []uint
+	retriedWaves := make(map[uint]bool)
+	for _, dispatchRecord := range failedRecords {
+		attempt := models.DispatchRecordAttempt{
+			DispatchRecordID:     dispatchRecord.ID,
+			Attempt:              dispatchRecord.Attempts,
+			Status:               dispatchRecord.Status,
+			PlaybookDispatcherID: dispatchRecord.PlaybookDispatcherID,
+		}
+		if result := db.DB.Create(&attempt); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error saving dispatch record attempt")
+			return result.Error
+		}
+		dispatchRecord.AttemptHistory = append(dispatchRecord.AttemptHistory, attempt)
+		dispatchRecord.Status = models.DispatchRecordStatusPending
+		dispatchRecord.PlaybookDispatcherID = ""
+		if result := eventsDB(s.ctx).Omit("AttemptHistory").Save(dispatchRecord); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error saving dispatch record")
+			return result.Error
+		}
+		if dispatchRecord.UpdateWaveID != nil && !retriedWaves[*dispatchRecord.UpdateWaveID] {
+			retriedWaves[*dispatchRecord.UpdateWaveID] = true
+			retriedWaveIDs = append(retriedWaveIDs, *dispatchRecord.UpdateWaveID)
+		}
+	}
+	// a rollout paused by a failed wave runs again
+	for i := range update.Waves {
+		wave := &update.Waves[i]
+		if retriedWaves[wave.ID] && wave.Status == models.UpdateWaveStatusFailed {
+			wave.Status = models.UpdateWaveStatusRunning
+			if result := db.DB.Save(wave); result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+	update.Status = models.UpdateStatusBuilding
+	if result := eventsDB(s.ctx).Model(update).Update("status", update.Status); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error saving update status")
+		return result.Error
+	}
+	// the update released its cached repo when it ended
+	if err := acquireUpdateRepoCache(update); err != nil {
+		logger.WithField("error", err.Error()).Error("Error using the cached update repo again")
+		return err
+	}
+
+	logger.WithField("devicesCount", len(failedRecords)).Info("Retrying update on the failed devices")
+	if len(update.Waves) == 0 {
+		if err := s.dispatchPendingRecords(update, nil); err != nil {
+			return err
+		}
+	}
+	for i := range retriedWaveIDs {
+		if err := s.dispatchPendingRecords(update, &retriedWaveIDs[i]); err != nil {
+			return err
+		}
+	}
+	return s.SetUpdateStatus(update)
+}
+
+// ResumeUpdate resumes a staged rollout paused by a failed wave, the next waves are dispatched
+// without waiting for the devices of the failed wave
+func (s *UpdateService) ResumeUpdate(update *models.UpdateTransaction) error {
+	logger := s.log.WithField("updateID", update.ID)
+	if update.Status != models.UpdateStatusPaused {
+		return new(UpdateCannotBeResumed)
+	}
+	if acquired, err := acquireRolloutSlots(update.Account, update.ID); err != nil {
+		logger.WithField("error", err.Error()).Error("Error acquiring the rollout slots of the update")
+		return err
+	} else if !acquired {
+		return new(UpdateRolloutLimitReached)
+	}
+	if update.Waves == nil {
+		if result := db.DB.Where("update_transaction_id = ?", update.ID).Order("position").Find(&update.Waves); result.Error != nil {
+			return result.Error
+		}
+	}
+	for i := range update.Waves {
+		wave := &update.Waves[i]
+		if wave.Status == models.UpdateWaveStatusFailed {
+			wave.Status = models.UpdateWaveStatusResumed
+			if result := db.DB.Save(wave); result.Error != nil {
+				logger.WithField("error", result.Error.Error()).Error("Error resuming rollout wave")
+				return result.Error
+			}
+		}
+	}
+	update.Status = models.UpdateStatusBuilding
+	if result := eventsDB(models.ContextWithEventMessage(s.ctx, "rollout was resumed")).Model(update).
+		Update("status", update.Status); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error saving update status")
+		return result.Error
+	}
+	logger.Info("Resuming update rollout")
+	return s.SetUpdateStatus(update)
+}
+
 // SendDeviceNotification connects to platform.notifications.ingress on image topic
 func (s *UpdateService) SendDeviceNotification(i *models.UpdateTransaction) (ImageNotification, error) {
 	s.log.WithField("message", i).Info("SendImageNotification::Starts")
@@ -550,3 +1264,592 @@ func (s *UpdateService) ValidateUpdateSelection(account string, imageIds []uint)
 
 	return count == 1, nil
 }
+
+// CreateDeviceGroupUpdate creates the update transactions of the devices of a device group, one per image set
+// the devices run. Each image set is updated to the requested commit, or to its latest successful image when
+// commitID is 0, the devices that can't be updated are reported as rejected devices.
+func (s *UpdateService) CreateDeviceGroupUpdate(deviceGroup *models.DeviceGroup, commitID uint) (*models.DeviceGroupUpdate, error) {
+	if deviceGroup.Account == "" || deviceGroup.ID == 0 {
+		return nil, new(DeviceGroupAccountOrIDUndefined)
+	}
+	account := deviceGroup.Account
+	logger := s.log.WithFields(log.Fields{"account": account, "deviceGroupID": deviceGroup.ID, "commitID": commitID})
+
+	devices, err := getDeviceGroupDevices(deviceGroup)
+	if err != nil {
+		logger.WithField("error", err.Error()).Error("Error retrieving device group devices")
+		return nil, err
+	}
+
+	var commitImage *models.Image
+	if commitID != 0 {
+		var image models.Image
+		if result := db.DB.Where("account = ? AND commit_id = ?", account, commitID).Preload("Commit").First(&image); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error retrieving the image of the commit")
+			return nil, new(CommitNotFound)
+		}
+		commitImage = &image
+	}
+
+	groupUpdate := &models.DeviceGroupUpdate{Account: account, DeviceGroupID: deviceGroup.ID, CommitID: commitID, Kind: models.UpdateKindUpdate}
+	reject := func(device models.Device, reason string) {
+		groupUpdate.RejectedDevices = append(groupUpdate.RejectedDevices, models.DeviceGroupUpdateRejectedDevice{
+			DeviceID:   device.ID,
+			DeviceUUID: device.UUID,
+			Reason:     reason,
+		})
+	}
+
+	imageIDs := make([]uint, 0, len(devices))
+	for _, device := range devices {
+		if device.ImageID != 0 {
+			imageIDs = append(imageIDs, device.ImageID)
+		}
+	}
+	var images []models.Image
+	if result := db.DB.Where("account = ? AND id IN ?", account, imageIDs).Find(&images); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error retrieving device group devices images")
+		return nil, result.Error
+	}
+	imagesByID := make(map[uint]models.Image, len(images))
+	for _, image := range images {
+		imagesByID[image.ID] = image
+	}
+
+	// split the devices by the image set of the image they run
+	var imageSetIDs []uint
+	devicesByImageSet := make(map[uint][]models.Device)
+	for _, device := range devices {
+		image, ok := imagesByID[device.ImageID]
+		if !ok {
+			reject(device, models.DeviceGroupUpdateRejectedImageUndefined)
+			continue
+		}
+		if image.ImageSetID == nil {
+			reject(device, models.DeviceGroupUpdateRejectedImageSetUndefined)
+			continue
+		}
+		if _, ok := devicesByImageSet[*image.ImageSetID]; !ok {
+			imageSetIDs = append(imageSetIDs, *image.ImageSetID)
+		}
+		devicesByImageSet[*image.ImageSetID] = append(devicesByImageSet[*image.ImageSetID], device)
+	}
+
+	for _, imageSetID := range imageSetIDs {
+		var targetImage models.Image
+		if commitImage != nil {
+			if commitImage.ImageSetID == nil || *commitImage.ImageSetID != imageSetID {
+				for _, device := range devicesByImageSet[imageSetID] {
+					reject(device, models.DeviceGroupUpdateRejectedOtherImageSet)
+				}
+				continue
+			}
+			targetImage = *commitImage
+		} else {
+			result := db.DB.Where("account = ? AND image_set_id = ? AND status = ?", account, imageSetID, models.ImageStatusSuccess).
+				Order("version desc").Preload("Commit").Limit(1).Find(&targetImage)
+			if result.Error != nil {
+				logger.WithField("error", result.Error.Error()).Error("Error retrieving the latest image of the image set")
+				return nil, result.Error
+			}
+			if result.RowsAffected == 0 {
+				for _, device := range devicesByImageSet[imageSetID] {
+					reject(device, models.DeviceGroupUpdateRejectedNoUpdate)
+				}
+				continue
+			}
+		}
+
+		var updateDevices []models.Device
+		var oldCommitIDs []uint
+		for _, device := range devicesByImageSet[imageSetID] {
+			if device.ImageID == targetImage.ID {
+				reject(device, models.DeviceGroupUpdateRejectedUpToDate)
+				continue
+			}
+			updateDevices = append(updateDevices, device)
+			oldCommitIDs = append(oldCommitIDs, imagesByID[device.ImageID].CommitID)
+		}
+		if len(updateDevices) == 0 {
+			continue
+		}
+		var oldCommits []models.Commit
+		if result := db.DB.Where("account = ? AND id IN ?", account, oldCommitIDs).Find(&oldCommits); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error retrieving the old commits")
+			return nil, result.Error
+		}
+		groupUpdate.UpdateTransactions = append(groupUpdate.UpdateTransactions, models.UpdateTransaction{
+			Account:         account,
+			CommitID:        targetImage.CommitID,
+			Status:          models.UpdateStatusCreated,
+			Kind:            models.UpdateKindUpdate,
+			Repo:            &models.Repo{Status: models.RepoStatusBuilding},
+			Devices:         updateDevices,
+			OldCommits:      oldCommits,
+			DispatchRecords: []models.DispatchRecord{},
+		})
+		if targetImage.Commit != nil {
+			devicesIDs := make([]uint, 0, len(updateDevices))
+			for _, device := range updateDevices {
+				devicesIDs = append(devicesIDs, device.ID)
+			}
+			if result := db.DB.Model(&models.Device{}).Where("account = ? AND id IN ?", account, devicesIDs).
+				Update("available_hash", targetImage.Commit.OSTreeCommit); result.Error != nil {
+				logger.WithField("error", result.Error.Error()).Error("Error saving the devices available hash")
+				return nil, result.Error
+			}
+		}
+	}
+
+	if result := db.DB.Create(groupUpdate); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error creating device group update")
+		return nil, result.Error
+	}
+	logger.WithFields(log.Fields{
+		"deviceGroupUpdateID": groupUpdate.ID,
+		"updatesCount":        len(groupUpdate.UpdateTransactions),
+		"rejectedCount":       len(groupUpdate.RejectedDevices),
+	}).Info("Device group update created")
+
+	return groupUpdate, nil
+}
+
+// getDeviceGroupDevices returns the devices of a device group
+func getDeviceGroupDevices(deviceGroup *models.DeviceGroup) ([]models.Device, error) {
+	var devices []models.Device
+	if result := db.DB.Joins("JOIN device_groups_devices ON device_groups_devices.device_id = devices.id").
+		Where("devices.account = ? AND device_groups_devices.device_group_id = ?", deviceGroup.Account, deviceGroup.ID).
+		Order("devices.id").Find(&devices); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(devices) == 0 {
+		return nil, new(DeviceGroupDevicesNotFound)
+	}
+	return devices, nil
+}
+
+// GetDeviceGroupUpdateByID returns the device group update with its aggregated progress
+func (s *UpdateService) GetDeviceGroupUpdateByID(account string, deviceGroupID uint, ID uint) (*models.DeviceGroupUpdate, error) {
+	var groupUpdate models.DeviceGroupUpdate
+	result := db.DB.Where("account = ? AND device_group_id = ?", account, deviceGroupID).
+		Preload("UpdateTransactions.Devices").Preload("UpdateTransactions.DispatchRecords").
+		Preload("UpdateTransactions.Waves").Preload("RejectedDevices").
+		First(&groupUpdate, ID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, new(DeviceGroupUpdateNotFound)
+		}
+		s.log.WithField("error", result.Error.Error()).Error("Error retrieving device group update")
+		return nil, result.Error
+	}
+	groupUpdate.ComputeProgress()
+	return &groupUpdate, nil
+}
+
+// buildRollbackUpdates builds the rollback update transactions of the given devices, one per image they are
+// rolled back to, the devices that can't be rolled back are returned as rejected devices.
+// Rolling back deploys the commit of the previous image through an update repo, the same way as updates do.
+func (s *UpdateService) buildRollbackUpdates(account string, devices []models.Device) ([]models.UpdateTransaction, []models.DeviceGroupUpdateRejectedDevice, error) {
+	var updates []models.UpdateTransaction
+	var rejectedDevices []models.DeviceGroupUpdateRejectedDevice
+	reject := func(device models.Device, reason string) {
+		rejectedDevices = append(rejectedDevices, models.DeviceGroupUpdateRejectedDevice{
+			DeviceID:   device.ID,
+			DeviceUUID: device.UUID,
+			Reason:     reason,
+		})
+	}
+
+	imageIDs := make([]uint, 0, len(devices))
+	for _, device := range devices {
+		if device.ImageID != 0 {
+			imageIDs = append(imageIDs, device.ImageID)
+		}
+	}
+	var images []models.Image
+	if result := db.DB.Where("account = ? AND id IN ?", account, imageIDs).Preload("Commit").Find(&images); result.Error != nil {
+		return nil, nil, result.Error
+	}
+	imagesByID := make(map[uint]models.Image, len(images))
+	for _, image := range images {
+		imagesByID[image.ID] = image
+	}
+
+	// split the devices by the image they are rolled back to
+	var rollbackImageIDs []uint
+	rollbackImages := make(map[uint]*models.Image)
+	rollbackImagesByImageID := make(map[uint]*models.Image)
+	devicesByRollbackImage := make(map[uint][]models.Device)
+	for _, device := range devices {
+		image, ok := imagesByID[device.ImageID]
+		if !ok {
+			reject(device, models.DeviceGroupUpdateRejectedImageUndefined)
+			continue
+		}
+		if image.ImageSetID == nil {
+			reject(device, models.DeviceGroupUpdateRejectedImageSetUndefined)
+			continue
+		}
+		rollback, ok := rollbackImagesByImageID[image.ID]
+		if !ok {
+			var err error
+			if rollback, err = s.ImageService.GetRollbackImage(&image); err != nil {
+				if _, ok := err.(*ImageNotFoundError); !ok {
+					return nil, nil, err
+				}
+			}
+			rollbackImagesByImageID[image.ID] = rollback
+		}
+		if rollback == nil {
+			reject(device, models.DeviceGroupUpdateRejectedNoRollback)
+			continue
+		}
+		if _, ok := rollbackImages[rollback.ID]; !ok {
+			rollbackImageIDs = append(rollbackImageIDs, rollback.ID)
+			rollbackImages[rollback.ID] = rollback
+		}
+		devicesByRollbackImage[rollback.ID] = append(devicesByRollbackImage[rollback.ID], device)
+	}
+
+	for _, rollbackImageID := range rollbackImageIDs {
+		rollback := rollbackImages[rollbackImageID]
+		rollbackDevices := devicesByRollbackImage[rollbackImageID]
+		var oldCommits []models.Commit
+		oldCommitIDs := make(map[uint]bool)
+		devicesIDs := make([]uint, 0, len(rollbackDevices))
+		for _, device := range rollbackDevices {
+			devicesIDs = append(devicesIDs, device.ID)
+			image := imagesByID[device.ImageID]
+			if image.Commit != nil && !oldCommitIDs[image.CommitID] {
+				oldCommitIDs[image.CommitID] = true
+				oldCommits = append(oldCommits, *image.Commit)
+			}
+		}
+		updates = append(updates, models.UpdateTransaction{
+			Account:         account,
+			CommitID:        rollback.CommitID,
+			Status:          models.UpdateStatusCreated,
+			Kind:            models.UpdateKindRollback,
+			Repo:            &models.Repo{Status: models.RepoStatusBuilding},
+			Devices:         rollbackDevices,
+			OldCommits:      oldCommits,
+			DispatchRecords: []models.DispatchRecord{},
+		})
+		if rollback.Commit != nil {
+			if result := db.DB.Model(&models.Device{}).Where("account = ? AND id IN ?", account, devicesIDs).
+				Update("available_hash", rollback.Commit.OSTreeCommit); result.Error != nil {
+				return nil, nil, result.Error
+			}
+		}
+	}
+	return updates, rejectedDevices, nil
+}
+
+// CreateDeviceRollback creates the update transaction rolling back a device to the previous image of its image set
+func (s *UpdateService) CreateDeviceRollback(account string, deviceUUID string) (*models.UpdateTransaction, error) {
+	logger := s.log.WithFields(log.Fields{"account": account, "deviceUUID": deviceUUID})
+	var device models.Device
+	if result := db.DB.Where("account = ? AND uuid = ?", account, deviceUUID).First(&device); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, new(DeviceNotFoundError)
+		}
+		logger.WithField("error", result.Error.Error()).Error("Error retrieving device")
+		return nil, result.Error
+	}
+
+	updates, rejectedDevices, err := s.buildRollbackUpdates(account, []models.Device{device})
+	if err != nil {
+		logger.WithField("error", err.Error()).Error("Error building device rollback")
+		return nil, err
+	}
+	if len(rejectedDevices) > 0 {
+		switch rejectedDevices[0].Reason {
+		case models.DeviceGroupUpdateRejectedImageSetUndefined:
+			return nil, new(ImageHasNoImageSet)
+		case models.DeviceGroupUpdateRejectedNoRollback:
+			return nil, new(RollbackImageNotFound)
+		default:
+			return nil, new(DeviceHasImageUndefined)
+		}
+	}
+
+	update := &updates[0]
+	if result := db.DB.Create(update); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error creating device rollback")
+		return nil, result.Error
+	}
+	logger.WithFields(log.Fields{"updateID": update.ID, "commitID": update.CommitID}).Info("Device rollback created")
+
+	return update, nil
+}
+
+// CreateDeviceGroupRollback creates the update transactions rolling back the devices of a device group
+// to the previous image of their image set, the devices that can't be rolled back are reported as rejected devices
+func (s *UpdateService) CreateDeviceGroupRollback(deviceGroup *models.DeviceGroup) (*models.DeviceGroupUpdate, error) {
+	if deviceGroup.Account == "" || deviceGroup.ID == 0 {
+		return nil, new(DeviceGroupAccountOrIDUndefined)
+	}
+	account := deviceGroup.Account
+	logger := s.log.WithFields(log.Fields{"account": account, "deviceGroupID": deviceGroup.ID})
+
+	devices, err := getDeviceGroupDevices(deviceGroup)
+	if err != nil {
+		logger.WithField("error", err.Error()).Error("Error retrieving device group devices")
+		return nil, err
+	}
+	updates, rejectedDevices, err := s.buildRollbackUpdates(account, devices)
+	if err != nil {
+		logger.WithField("error", err.Error()).Error("Error building device group rollback")
+		return nil, err
+	}
+
+	groupUpdate := &models.DeviceGroupUpdate{
+		Account:            account,
+		DeviceGroupID:      deviceGroup.ID,
+		Kind:               models.UpdateKindRollback,
+		UpdateTransactions: updates,
+		RejectedDevices:    rejectedDevices,
+	}
+	if result := db.DB.Create(groupUpdate); result.Error != nil {
+		logger.WithField("error", result.Error.Error()).Error("Error creating device group rollback")
+		return nil, result.Error
+	}
+	logger.WithFields(log.Fields{
+		"deviceGroupUpdateID": groupUpdate.ID,
+		"updatesCount":        len(groupUpdate.UpdateTransactions),
+		"rejectedCount":       len(groupUpdate.RejectedDevices),
+	}).Info("Device group rollback created")
+
+	return groupUpdate, nil
+}
+
+// PreviewUpdate returns what an update of the devices to the commit would do, without creating it
+func (s *UpdateService) PreviewUpdate(account string, commitID uint, devicesUUID []string) (*models.UpdatePreview, error) {
+	var commit models.Commit
+	if result := db.DB.Where("account = ?", account).Preload("InstalledPackages").First(&commit, commitID); result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, new(CommitNotFound)
+		}
+		return nil, result.Error
+	}
+	var commitImage models.Image
+	if result := db.DB.Where("account = ? AND commit_id = ?", account, commit.ID).Limit(1).Find(&commitImage); result.Error != nil {
+		return nil, result.Error
+	}
+	commitImage.Commit = &commit
+
+	preview := &models.UpdatePreview{CommitID: commit.ID, Devices: []models.UpdatePreviewDevice{}}
+	for _, deviceUUID := range devicesUUID {
+		devicePreview, err := previewDeviceUpdate(account, deviceUUID, &commitImage)
+		if err != nil {
+			s.log.WithFields(log.Fields{"error": err.Error(), "deviceUUID": deviceUUID}).Error("Error previewing device update")
+			return nil, err
+		}
+		if devicePreview.Skipped {
+			preview.SkippedCount++
+		} else {
+			preview.UpdatedCount++
+			preview.EstimatedDownloadSize += devicePreview.EstimatedDownloadSize
+		}
+		preview.Devices = append(preview.Devices, *devicePreview)
+	}
+	preview.DevicesCount = len(preview.Devices)
+	return preview, nil
+}
+
+// previewDeviceUpdate returns what the update of a device to the commit of the image would do
+// The devices are skipped for the same reasons a device group update rejects them, or when they are disconnected
+func previewDeviceUpdate(account string, deviceUUID string, commitImage *models.Image) (*models.UpdatePreviewDevice, error) {
+	devicePreview := &models.UpdatePreviewDevice{DeviceUUID: deviceUUID}
+	var device models.Device
+	result := db.DB.Where("account = ? AND uuid = ?", account, deviceUUID).Limit(1).Find(&device)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		devicePreview.Skipped, devicePreview.SkipReason = true, models.UpdatePreviewSkippedNotFound
+		return devicePreview, nil
+	}
+	devicePreview.DeviceName = device.Name
+	devicePreview.CurrentCommit = device.CurrentHash
+	if device.CurrentHash == commitImage.Commit.OSTreeCommit {
+		devicePreview.Skipped, devicePreview.SkipReason = true, models.DeviceGroupUpdateRejectedUpToDate
+		return devicePreview, nil
+	}
+
+	// the update repo has a static delta from the booted commit when it is a known commit
+	var currentCommit models.Commit
+	result = db.DB.Where("account = ? AND os_tree_commit = ?", account, device.CurrentHash).Preload("InstalledPackages").Limit(1).Find(&currentCommit)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	devicePreview.StaticDelta = device.CurrentHash != "" && result.RowsAffected > 0
+	if devicePreview.StaticDelta {
+		var currentImage models.Image
+		result := db.DB.Where("account = ? AND commit_id = ?", account, currentCommit.ID).Limit(1).Find(&currentImage)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			if currentImage.ImageSetID != nil && commitImage.ImageSetID != nil && *currentImage.ImageSetID != *commitImage.ImageSetID {
+				devicePreview.Skipped, devicePreview.SkipReason = true, models.DeviceGroupUpdateRejectedOtherImageSet
+				return devicePreview, nil
+			}
+			devicePreview.CurrentImageID = currentImage.ID
+			currentImage.Commit = &currentCommit
+			diff := GetDiffOnUpdate(currentImage, *commitImage)
+			devicePreview.PackageDiff = &diff
+		}
+	}
+	if !device.Connected || device.RHCClientID == "" {
+		devicePreview.Skipped, devicePreview.SkipReason = true, models.UpdatePreviewSkippedDisconnected
+		return devicePreview, nil
+	}
+	devicePreview.EstimatedDownloadSize = estimateDownloadSize(commitImage.Commit, devicePreview.PackageDiff)
+	return devicePreview, nil
+}
+
+// estimateDownloadSize estimates the download size of the update to the commit
+// Without static delta the whole commit is downloaded, with a static delta only the share of
+// the packages of the commit that were added or upgraded
+func estimateDownloadSize(commit *models.Commit, diff *models.PackageDiff) int64 {
+	packagesCount := int64(len(commit.InstalledPackages))
+	if diff == nil || packagesCount == 0 {
+		return commit.TarballSize
+	}
+	changedCount := int64(len(diff.Added) + len(diff.Upgraded))
+	if changedCount > packagesCount {
+		changedCount = packagesCount
+	}
+	return commit.TarballSize * changedCount / packagesCount
+}
+
+// GetUpdateTransactionEvents returns the timeline of an update transaction,
+// the transitions of the update transaction and of its dispatch records in the order they happened
+func (s *UpdateService) GetUpdateTransactionEvents(update *models.UpdateTransaction) ([]models.Event, error) {
+	events := []models.Event{}
+	result := db.DB.Where("(resource_type = ? AND resource_id = ?) OR "+
+		"(resource_type = ? AND resource_id IN (SELECT dispatch_record_id FROM updatetransaction_dispatchrecords WHERE update_transaction_id = ?))",
+		models.EventResourceUpdateTransaction, update.ID, models.EventResourceDispatchRecord, update.ID).
+		Order("created_at, id").Find(&events)
+	if result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error getting update transaction events")
+		return nil, result.Error
+	}
+	return events, nil
+}
+
+// getUpdateBundleCommits returns the commits of the account with a built repo to be bundled
+func getUpdateBundleCommits(account string, commitIDs []uint) ([]models.Commit, error) {
+	var commits []models.Commit
+	if result := db.DB.Where("account = ? AND id IN (?)", account, commitIDs).Find(&commits); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(commits) != len(commitIDs) {
+		return nil, new(CommitNotFound)
+	}
+	for _, commit := range commits {
+		if commit.Status != models.ImageStatusSuccess || commit.ImageBuildTarURL == "" {
+			return nil, new(UpdateBundleCommitNotBuilt)
+		}
+	}
+	return commits, nil
+}
+
+// CreateUpdateBundle creates the offline update bundle of a commit with the static deltas from the source commits
+// The bundle is built by BuildUpdateBundle
+func (s *UpdateService) CreateUpdateBundle(account string, commitID uint, sourceCommitIDs []uint) (*models.UpdateBundle, error) {
+	commits, err := getUpdateBundleCommits(account, []uint{commitID})
+	if err != nil {
+		return nil, err
+	}
+	bundle := &models.UpdateBundle{
+		Account:       account,
+		CommitID:      commitID,
+		Commit:        &commits[0],
+		SourceCommits: []models.Commit{},
+		Status:        models.UpdateBundleStatusBuilding,
+	}
+	var sourceIDs []uint
+	knownIDs := map[uint]bool{commitID: true}
+	for _, id := range sourceCommitIDs {
+		if !knownIDs[id] {
+			knownIDs[id] = true
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+	if len(sourceIDs) > 0 {
+		if bundle.SourceCommits, err = getUpdateBundleCommits(account, sourceIDs); err != nil {
+			return nil, err
+		}
+	}
+	if result := db.DB.Omit("Commit", "SourceCommits.*").Create(bundle); result.Error != nil {
+		s.log.WithField("error", result.Error.Error()).Error("Error creating update bundle")
+		return nil, result.Error
+	}
+	s.log.WithFields(log.Fields{"updateBundleID": bundle.ID, "commitID": commitID}).Info("Update bundle created")
+	return bundle, nil
+}
+
+// BuildUpdateBundle builds and uploads an update bundle, the bundle is in ERROR when the build fails
+func (s *UpdateService) BuildUpdateBundle(id uint) (*models.UpdateBundle, error) {
+	var bundle models.UpdateBundle
+	if result := db.DB.Preload("Commit").Preload("SourceCommits").First(&bundle, id); result.Error != nil {
+		return nil, result.Error
+	}
+	logger := s.log.WithField("updateBundleID", bundle.ID)
+	if bundle.Status == models.UpdateBundleStatusSuccess {
+		logger.Info("Update bundle is already built")
+		return &bundle, nil
+	}
+	// a retry of the update bundle build job
+	if bundle.Status != models.UpdateBundleStatusBuilding {
+		bundle.Status = models.UpdateBundleStatusBuilding
+		if result := db.DB.Omit("Commit", "SourceCommits").Save(&bundle); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	if err := s.RepoBuilder.BuildUpdateBundle(&bundle); err != nil {
+		logger.WithField("error", err.Error()).Error("Error building update bundle")
+		bundle.Status = models.UpdateBundleStatusError
+		if result := db.DB.Omit("Commit", "SourceCommits").Save(&bundle); result.Error != nil {
+			logger.WithField("error", result.Error.Error()).Error("Error saving update bundle status")
+		}
+		return nil, err
+	}
+	logger.Info("Update bundle built")
+	return &bundle, nil
+}
+
+// GetUpdateBundleByID returns an update bundle of the account
+func (s *UpdateService) GetUpdateBundleByID(account string, id uint) (*models.UpdateBundle, error) {
+	var bundle models.UpdateBundle
+	result := db.DB.Where("account = ?", account).Preload("Commit").Preload("SourceCommits").First(&bundle, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, new(UpdateBundleNotFound)
+		}
+		return nil, result.Error
+	}
+	return &bundle, nil
+}
+
+// GetUpdateBundleDownload returns where a built update bundle is downloaded from,
+// a pre-signed URL of the storage or its local path
+func (s *UpdateService) GetUpdateBundleDownload(bundle *models.UpdateBundle) (*models.UpdateBundleDownload, error) {
+	if bundle.Status != models.UpdateBundleStatusSuccess {
+		return nil, new(UpdateBundleNotReady)
+	}
+	expiration := time.Duration(config.Get().UpdateBundleURLTimeout) * time.Minute
+	url, err := s.FilesService.GetSignedURL(bundle.FilePath, expiration)
+	if err != nil {
+		s.log.WithFields(log.Fields{"error": err.Error(), "updateBundleID": bundle.ID}).Error("Error signing update bundle URL")
+		return nil, err
+	}
+	return &models.UpdateBundleDownload{
+		URL:       url,
+		ExpiresAt: models.EdgeAPITime{Time: time.Now().Add(expiration), Valid: true},
+		Checksum:  bundle.Checksum,
+		Size:      bundle.Size,
+	}, nil
+}
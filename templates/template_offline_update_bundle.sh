@@ -0,0 +1,39 @@
+#!/usr/bin/bash
+# Applies the edge management offline update bundle {{ .BundleID }} from local media
+# The commit is pulled from the bundle with the static deltas into the ostree remote of the device,
+# the remote keeps pointing to its update repo for the online updates
+# Usage: apply-update.sh [--reboot]
+set -euo pipefail
+
+BUNDLE_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+OSTREE_REMOTE="{{ .RemoteName }}"
+OSTREE_REF="{{ .Ref }}"
+OSTREE_COMMIT="{{ .Commit }}"
+
+cd "${BUNDLE_DIR}"
+echo "Verifying the checksums of the update bundle"
+sha256sum --quiet --check SHA256SUMS
+
+if ostree admin status | grep -q "${OSTREE_COMMIT}"; then
+    echo "The commit ${OSTREE_COMMIT} is already deployed"
+    exit 0
+fi
+if ! ostree remote list | grep -qx "${OSTREE_REMOTE}"; then
+    echo "The ostree remote ${OSTREE_REMOTE} is not configured on this device" >&2
+    exit 1
+fi
+
+echo "Pulling the commit ${OSTREE_COMMIT} from the update bundle"
+ostree pull --url="file://${BUNDLE_DIR}/repo" "${OSTREE_REMOTE}" "${OSTREE_REF}"
+if [ "$(ostree rev-parse "${OSTREE_REMOTE}:${OSTREE_REF}")" != "${OSTREE_COMMIT}" ]; then
+    echo "The update bundle does not contain the commit ${OSTREE_COMMIT}" >&2
+    exit 1
+fi
+
+echo "Staging the update"
+rpm-ostree rebase --cache-only "${OSTREE_REMOTE}:${OSTREE_REF}"
+if [ "${1:-}" = "--reboot" ]; then
+    systemctl reboot
+else
+    echo "The update is applied on the next boot"
+fi